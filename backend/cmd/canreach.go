@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deggja/netfetch/backend/pkg/k8s"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	canReachPort   string
+	canReachOutput string
+)
+
+var canReachCmd = &cobra.Command{
+	Use:   "can-reach SOURCE DESTINATION",
+	Short: "Check whether one pod can connect to another",
+	Long: `Check whether the network policies in the cluster allow a connection between two pods.
+	SOURCE and DESTINATION are given as namespace/pod. Native, admin, Cilium, Calico and Antrea network policies are evaluated,
+	and the policies and rules responsible for the verdict are listed.
+	The command exits non-zero when the connection is denied.`,
+	Example: `  netfetch can-reach shop/web-0 shop/db-0 --port 5432/TCP`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if canReachOutput != "" && canReachOutput != k8s.OutputFormatJSON {
+			fmt.Printf("unsupported output format %q, must be %s\n", canReachOutput, k8s.OutputFormatJSON)
+			os.Exit(1)
+		}
+
+		port, protocol, err := k8s.ParsePortProtocol(canReachPort)
+		if err != nil {
+			fmt.Println("Error parsing port:", err)
+			os.Exit(1)
+		}
+
+		// Keep stdout clean for the JSON verdict
+		stdout := os.Stdout
+		if canReachOutput != "" {
+			os.Stdout = os.Stderr
+		}
+		state, err := loadClusterState()
+		os.Stdout = stdout
+		if err != nil {
+			fmt.Println("Error loading cluster state:", err)
+			os.Exit(1)
+		}
+
+		source, err := findPod(state, args[0])
+		if err != nil {
+			fmt.Println("Error finding source pod:", err)
+			os.Exit(1)
+		}
+		destination, err := findPod(state, args[1])
+		if err != nil {
+			fmt.Println("Error finding destination pod:", err)
+			os.Exit(1)
+		}
+
+		verdict := state.CanReach(*source, *destination, port, protocol)
+		if canReachOutput == k8s.OutputFormatJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(verdict); err != nil {
+				fmt.Println("Error encoding verdict:", err)
+				os.Exit(1)
+			}
+		} else {
+			printReachabilityVerdict(verdict)
+		}
+
+		if !verdict.Allowed {
+			os.Exit(1)
+		}
+	},
+}
+
+// loadClusterState reads the objects evaluated by the reachability engine from the cluster or from --from-files
+func loadClusterState() (*k8s.ClusterState, error) {
+	if fromFiles != "" {
+		if err := loadOfflineManifests(fromFiles); err != nil {
+			return nil, err
+		}
+	}
+
+	clientset, err := k8s.GetClientset(kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := k8s.GetDynamicClient(kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := k8s.LoadClusterState(clientset, dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range state.Warnings {
+		fmt.Fprintln(os.Stderr, "Warning: skipping policy:", warning)
+	}
+	return state, nil
+}
+
+// findPod resolves a namespace/pod argument against the cluster state
+func findPod(state *k8s.ClusterState, reference string) (*corev1.Pod, error) {
+	namespace, name, err := k8s.ParsePodReference(reference)
+	if err != nil {
+		return nil, err
+	}
+	return state.FindPod(namespace, name)
+}
+
+// printReachabilityVerdict prints the verdict with the policies and rules responsible for each direction
+func printReachabilityVerdict(verdict k8s.ReachabilityVerdict) {
+	fmt.Printf("Source:      %s\n", verdict.Source)
+	fmt.Printf("Destination: %s\n", verdict.Destination)
+	fmt.Printf("Port:        %d/%s\n\n", verdict.Port, verdict.Protocol)
+
+	for _, direction := range []k8s.DirectionVerdict{verdict.Egress, verdict.Ingress} {
+		status := "allowed"
+		if !direction.Allowed {
+			status = "denied"
+		}
+		title := strings.ToUpper(direction.Direction[:1]) + direction.Direction[1:]
+		fmt.Printf("%s of %s: %s (%s)\n", title, direction.Pod, status, direction.Reason)
+
+		if len(direction.Policies) > 0 {
+			policies := []string{}
+			for _, policy := range direction.Policies {
+				policies = append(policies, fmt.Sprintf("%s %s", policy.Kind, qualifiedPolicyName(policy)))
+			}
+			fmt.Printf("  Isolated by: %s\n", strings.Join(policies, ", "))
+		}
+		for _, rule := range direction.DeniedBy {
+			fmt.Printf("  Denied by %s %s rule #%d: %s\n", rule.Policy, rule.Direction, rule.Index, rule)
+		}
+		for _, rule := range direction.AllowedBy {
+			fmt.Printf("  Allowed by %s %s rule #%d: %s\n", rule.Policy, rule.Direction, rule.Index, rule)
+		}
+	}
+
+	if verdict.Allowed {
+		fmt.Println("\n" + HeaderStyle.Render("Verdict: ALLOW"))
+	} else {
+		fmt.Println("\n" + HeaderStyle.Render("Verdict: DENY"))
+	}
+}
+
+// qualifiedPolicyName returns namespace/name for namespaced policies and name for cluster scoped ones
+func qualifiedPolicyName(policy k8s.ObjectReference) string {
+	if policy.Namespace == "" {
+		return policy.Name
+	}
+	return policy.Namespace + "/" + policy.Name
+}
+
+func init() {
+	canReachCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (optional)")
+	canReachCmd.Flags().StringVarP(&canReachPort, "port", "p", "", "Destination port and protocol, for example 5432/TCP")
+	canReachCmd.Flags().StringVarP(&canReachOutput, "output", "o", "", "Output format for the verdict: json")
+	canReachCmd.Flags().StringVar(&fromFiles, "from-files", "", "Evaluate a file or directory of rendered manifests instead of a live cluster")
+	canReachCmd.MarkFlagRequired("port")
+	rootCmd.AddCommand(canReachCmd)
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/charmbracelet/lipgloss"
 	_ "github.com/deggja/netfetch/backend/statik"
@@ -22,7 +24,8 @@ var dashCmd = &cobra.Command{
 	Short: "Launch the Netfetch interactive dashboard",
 	Run: func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetString("port")
-		startDashboardServer(port, kubeconfigPath)
+		metricsInterval, _ := cmd.Flags().GetDuration("metrics-interval")
+		startDashboardServer(port, kubeconfigPath, metricsInterval)
 	},
 }
 
@@ -32,7 +35,7 @@ func setNoCacheHeaders(w http.ResponseWriter) {
 	w.Header().Set("Expires", "0")
 }
 
-func startDashboardServer(port string, kubeconfigPath string) {
+func startDashboardServer(port string, kubeconfigPath string, metricsInterval time.Duration) {
 	// Verify connection to cluster or throw error
 	clientset, err := k8s.GetClientset(kubeconfigPath)
 	if err != nil {
@@ -46,6 +49,34 @@ func startDashboardServer(port string, kubeconfigPath string) {
 		return
 	}
 
+	// The dashboard never prompts on the terminal, so the background scans started below cannot block on a
+	// confirmation
+	k8s.SetInteractive(false)
+
+	// Answer dashboard requests from an informer cache instead of listing the cluster on every request
+	dynamicClient, err := k8s.GetDynamicClient(kubeconfigPath)
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes dynamic client: %v", err)
+		return
+	}
+	fmt.Println("Loading cluster cache...")
+	clusterCache, err := k8s.NewClusterCache(clientset, dynamicClient, wait.NeverStop)
+	if err != nil {
+		log.Fatalf("Failed to load cluster cache: %v", err)
+		return
+	}
+	k8s.UseClusterCache(clusterCache)
+
+	// Push the protection of the pods under every detected policy engine to the dashboard as it changes, and scan
+	// the engines on an interval to serve the results as Prometheus metrics
+	engines, err := k8s.DetectPolicyEngines(clientset)
+	if err != nil {
+		log.Printf("Error detecting policy engines, scanning native network policies only: %v\n", err)
+	}
+	go clusterCache.WatchProtection("", kubeconfigPath, k8s.ScannedEngines(engines), wait.NeverStop)
+	metricsExporter := k8s.NewMetricsExporter(kubeconfigPath, engines)
+	go metricsExporter.Run(metricsInterval, wait.NeverStop)
+
 	c := cors.New(cors.Options{
 		AllowOriginRequestFunc: func(r *http.Request, origin string) bool {
 			// Implement your dynamic origin check here
@@ -63,9 +94,12 @@ func startDashboardServer(port string, kubeconfigPath string) {
 		AllowCredentials: true,
 	})
 
+	// Scans requested from the dashboard are kept in the history
+	history := k8s.ConfiguredHistory()
+
 	// Set up handlers
 	http.HandleFunc("/", dashboardHandler)
-	http.HandleFunc("/scan", k8s.HandleScanRequest(kubeconfigPath))
+	http.HandleFunc("/scan", k8s.HandleScanRequest(kubeconfigPath, history))
 	http.HandleFunc("/namespaces", k8s.HandleNamespaceListRequest(kubeconfigPath))
 	http.HandleFunc("/add-policy", k8s.HandleAddPolicyRequest(kubeconfigPath))
 	http.HandleFunc("/create-policy", k8s.HandleCreatePolicyRequest(kubeconfigPath))
@@ -73,8 +107,12 @@ func startDashboardServer(port string, kubeconfigPath string) {
 	http.HandleFunc("/namespace-policies", k8s.HandleNamespacePoliciesRequest(kubeconfigPath))
 	http.HandleFunc("/visualization", k8s.HandleVisualizationRequest(kubeconfigPath))
 	http.HandleFunc("/visualization/cluster", k8s.HandleClusterVisualizationRequest(kubeconfigPath))
+	http.HandleFunc("/visualization/reachability", k8s.HandleReachabilityMatrixRequest(kubeconfigPath))
 	http.HandleFunc("/policy-yaml", k8s.HandlePolicyYAMLRequest(kubeconfigPath))
 	http.HandleFunc("/pod-info", k8s.HandlePodInfoRequest(kubeconfigPath))
+	http.HandleFunc("/events", k8s.HandleEventsRequest(clusterCache))
+	http.HandleFunc("/metrics", k8s.HandleMetricsRequest(metricsExporter))
+	http.HandleFunc("/history", k8s.HandleHistoryRequest(history, k8s.CurrentCluster(kubeconfigPath)))
 
 	// Wrap the default serve mux with the CORS middleware
 	handler := c.Handler(http.DefaultServeMux)
@@ -132,5 +170,6 @@ var HeaderStyle = lipgloss.NewStyle().
 func init() {
 	dashCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (optional)")
 	dashCmd.Flags().StringP("port", "p", "8080", "Port for the interactive dashboard")
+	dashCmd.Flags().Duration("metrics-interval", time.Minute, "How often the cluster is scanned for the Prometheus metrics served on /metrics")
 	rootCmd.AddCommand(dashCmd)
 }
\ No newline at end of file
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/deggja/netfetch/backend/pkg/k8s"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySince      string
+	historyCluster    string
+	historySource     string
+	historyLimit      int
+	historyOutput     string
+	historyOutputFile string
+)
+
+// sparkBlocks are the bars of the score trend, from the lowest to the highest score
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+var historyCmd = &cobra.Command{
+	Use:   "history [namespace]",
+	Short: "List past scans and the trend of the score",
+	Long: `List the scans recorded by netfetch scan and the dashboard, and show how the score changed over time.
+	Without a namespace the scans of the whole cluster are listed; with a namespace the scans limited to it.
+	Only the scans of the current kubeconfig context are listed unless --cluster selects another one, and scans of
+	manifests are left out unless --source manifests is given.
+	Use --since to start at a date, for example the first day of a month, and --limit to show only the latest scans.
+	Use --output json|csv to export the score series.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var namespace string
+		if len(args) > 0 {
+			namespace = args[0]
+		}
+
+		if historyOutput != "" && historyOutput != k8s.OutputFormatJSON && historyOutput != k8s.OutputFormatCSV {
+			fmt.Printf("unsupported output format %q, must be one of: %s, %s\n", historyOutput, k8s.OutputFormatJSON, k8s.OutputFormatCSV)
+			os.Exit(1)
+		}
+		if err := k8s.ValidateHistorySource(historySource); err != nil {
+			fmt.Println("Error parsing --source:", err)
+			os.Exit(1)
+		}
+		since, err := k8s.ParseHistorySince(historySince)
+		if err != nil {
+			fmt.Println("Error parsing --since:", err)
+			os.Exit(1)
+		}
+
+		history := k8s.ConfiguredHistory()
+		records, err := history.Records()
+		if err != nil {
+			fmt.Println("Error reading the history:", err)
+			os.Exit(1)
+		}
+		filter := k8s.HistoryFilter{Cluster: historyCluster, Namespace: namespace, Source: historySource, Since: since}
+		if !cmd.Flags().Changed("cluster") {
+			filter.Cluster = k8s.CurrentCluster(kubeconfigPath)
+		}
+		points := k8s.ScoreSeries(records, filter)
+		if historyLimit > 0 && len(points) > historyLimit {
+			points = points[len(points)-historyLimit:]
+		}
+
+		if historyOutput != "" {
+			if err := writeScoreSeries(points); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing score series:", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(points) == 0 {
+			fmt.Printf("No scans recorded in %s yet.\n", history.Path())
+			return
+		}
+		fmt.Println(createHistoryTable(points))
+		first, last := points[0], points[len(points)-1]
+		fmt.Printf("Score trend: %s\n", scoreSparkline(points))
+		fmt.Printf("The score went from %d on %s to %d on %s (%+d) over %d scans.\n",
+			first.Score, formatScanTime(first.ScannedAt), last.Score, formatScanTime(last.ScannedAt), last.Score-first.Score, len(points))
+	},
+}
+
+// writeScoreSeries exports the score series to stdout or the requested file
+func writeScoreSeries(points []k8s.ScorePoint) error {
+	if historyOutputFile == "" {
+		return k8s.WriteScoreSeries(os.Stdout, points, historyOutput)
+	}
+
+	file, err := os.Create(historyOutputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := k8s.WriteScoreSeries(file, points, historyOutput); err != nil {
+		return err
+	}
+	fmt.Printf("Score series written to %s\n", historyOutputFile)
+	return nil
+}
+
+// createHistoryTable renders a row per scan with its score, the change since the previous scan and the score of
+// every engine
+func createHistoryTable(points []k8s.ScorePoint) string {
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(tableBorderStyle).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			if row%2 == 0 {
+				return evenRowStyle
+			}
+			return oddRowStyle
+		}).
+		Headers("Scanned At", "Source", "Score", "Change", "Engines", "Unprotected")
+
+	for i, point := range points {
+		change := ""
+		if i > 0 {
+			change = fmt.Sprintf("%+d", point.Score-points[i-1].Score)
+		}
+		engines := []string{}
+		for _, policyType := range sortedEngineNames(point.Engines) {
+			engines = append(engines, fmt.Sprintf("%s %d", policyType, point.Engines[policyType]))
+		}
+		t.Row(formatScanTime(point.ScannedAt), point.Source, fmt.Sprint(point.Score), change, strings.Join(engines, ", "), fmt.Sprint(point.Unprotected))
+	}
+
+	return t.String()
+}
+
+// scoreSparkline draws a bar per scan, scaled from a score of 0 to 100
+func scoreSparkline(points []k8s.ScorePoint) string {
+	var line strings.Builder
+	for _, point := range points {
+		index := point.Score * (len(sparkBlocks) - 1) / 100
+		if index < 0 {
+			index = 0
+		}
+		line.WriteRune(sparkBlocks[index])
+	}
+	return line.String()
+}
+
+// formatScanTime shows the time of a scan in the local time zone
+func formatScanTime(scannedAt string) string {
+	parsed, err := time.Parse(time.RFC3339, scannedAt)
+	if err != nil {
+		return scannedAt
+	}
+	return parsed.Local().Format("2006-01-02 15:04")
+}
+
+// sortedEngineNames returns the policy types of the engine scores in alphabetical order
+func sortedEngineNames(engines map[string]int) []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "", "Only list scans made on or after this date, such as 2026-01-31, or RFC 3339 time")
+	historyCmd.Flags().StringVar(&historyCluster, "cluster", "", "Only list scans of this kubeconfig context (defaults to the current context, empty lists every cluster)")
+	historyCmd.Flags().StringVar(&historySource, "source", "", "Only list scans from this source: cli, dashboard or manifests (defaults to cli and dashboard)")
+	historyCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (optional)")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 0, "Only list the latest scans (0 lists every scan)")
+	historyCmd.Flags().StringVarP(&historyOutput, "output", "o", "", "Export format for the score series: json or csv")
+	historyCmd.Flags().StringVar(&historyOutputFile, "output-file", "", "Write the exported score series to a file instead of stdout (requires --output)")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/deggja/netfetch/backend/pkg/k8s"
+	"github.com/spf13/cobra"
+)
+
+var (
+	matrixPort       string
+	matrixOutput     string
+	matrixOutputFile string
+)
+
+var matrixCmd = &cobra.Command{
+	Use:   "matrix [namespace]",
+	Short: "Build a reachability matrix between namespaces and workloads",
+	Long: `Build an allow/deny matrix between namespaces and between workloads by evaluating native, admin, Cilium, Calico and Antrea network policies.
+	Without a namespace every non-system namespace is included; with a namespace only traffic from and to it is evaluated.
+	Use --port to evaluate a single port, otherwise a pair is allowed when any port is reachable.
+	Use --output json|csv to export the matrix, for example as audit evidence of tenant segmentation.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var namespace string
+		if len(args) > 0 {
+			namespace = args[0]
+		}
+
+		if matrixOutput != "" && matrixOutput != k8s.OutputFormatJSON && matrixOutput != k8s.OutputFormatCSV {
+			fmt.Printf("unsupported output format %q, must be one of: %s, %s\n", matrixOutput, k8s.OutputFormatJSON, k8s.OutputFormatCSV)
+			os.Exit(1)
+		}
+
+		var port int32
+		protocol := ""
+		if matrixPort != "" {
+			var err error
+			port, protocol, err = k8s.ParsePortProtocol(matrixPort)
+			if err != nil {
+				fmt.Println("Error parsing port:", err)
+				os.Exit(1)
+			}
+		}
+
+		// Keep stdout clean for the exported matrix
+		stdout := os.Stdout
+		if matrixOutput != "" && matrixOutputFile == "" {
+			os.Stdout = os.Stderr
+		}
+		state, err := loadClusterState()
+		os.Stdout = stdout
+		if err != nil {
+			fmt.Println("Error loading cluster state:", err)
+			os.Exit(1)
+		}
+
+		matrix := state.BuildReachabilityMatrix(namespace, port, protocol)
+		if matrixOutput == "" {
+			fmt.Println(createNamespaceMatrixTable(matrix))
+			fmt.Printf("Evaluated %d workload pairs across %d namespaces.\n", len(matrix.WorkloadMatrix), len(matrix.Namespaces))
+			return
+		}
+
+		if err := writeReachabilityMatrix(matrix); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing reachability matrix:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// writeReachabilityMatrix exports the matrix to stdout or the requested file
+func writeReachabilityMatrix(matrix *k8s.ReachabilityMatrix) error {
+	if matrixOutputFile == "" {
+		return k8s.WriteReachabilityMatrix(os.Stdout, matrix, matrixOutput)
+	}
+
+	file, err := os.Create(matrixOutputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := k8s.WriteReachabilityMatrix(file, matrix, matrixOutput); err != nil {
+		return err
+	}
+	fmt.Printf("Reachability matrix written to %s\n", matrixOutputFile)
+	return nil
+}
+
+// createNamespaceMatrixTable renders the namespace matrix with sources as rows and destinations as columns
+func createNamespaceMatrixTable(matrix *k8s.ReachabilityMatrix) string {
+	headers := append([]string{"From \\ To"}, matrix.Namespaces...)
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(tableBorderStyle).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 || col == 0 {
+				return headerStyle
+			}
+			if row%2 == 0 {
+				return evenRowStyle
+			}
+			return oddRowStyle
+		}).
+		Headers(headers...)
+
+	for _, source := range matrix.Namespaces {
+		row := []string{source}
+		for _, destination := range matrix.Namespaces {
+			verdict := matrix.NamespaceVerdict(source, destination)
+			if verdict == "" {
+				verdict = "-"
+			}
+			row = append(row, verdict)
+		}
+		t.Row(row...)
+	}
+
+	return t.String()
+}
+
+func init() {
+	matrixCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (optional)")
+	matrixCmd.Flags().StringVarP(&matrixPort, "port", "p", "", "Evaluate a single destination port and protocol, for example 5432/TCP")
+	matrixCmd.Flags().StringVarP(&matrixOutput, "output", "o", "", "Export format for the matrix: json or csv")
+	matrixCmd.Flags().StringVar(&matrixOutputFile, "output-file", "", "Write the exported matrix to a file instead of stdout (requires --output)")
+	matrixCmd.Flags().StringVar(&fromFiles, "from-files", "", "Evaluate a file or directory of rendered manifests instead of a live cluster")
+	rootCmd.AddCommand(matrixCmd)
+}
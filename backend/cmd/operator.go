@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/deggja/netfetch/backend/pkg/k8s"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var resyncInterval time.Duration
+
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Keep NetfetchReport resources up to date in the cluster",
+	Long: `Run netfetch as an operator that writes scan results to the cluster.
+	A NetfetchReport in every scanned namespace lists its score and unprotected pods, and the ClusterNetfetchReport
+	summarizes the whole cluster. The reports are rewritten whenever pods or policies change, and on every resync interval.
+	The NetfetchReport and ClusterNetfetchReport CRDs must be installed, for example by the Helm chart.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		clientset, err := k8s.GetClientset(kubeconfigPath)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		dynamicClient, err := k8s.GetDynamicClient(kubeconfigPath)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes dynamic client: %v", err)
+		}
+
+		engines, err := k8s.DetectPolicyEngines(clientset)
+		if err != nil {
+			log.Printf("Error detecting policy engines, reporting native network policies only: %v\n", err)
+		} else {
+			reportPolicyEngines(engines)
+		}
+
+		fmt.Println("Loading cluster cache...")
+		clusterCache, err := k8s.NewClusterCache(clientset, dynamicClient, wait.NeverStop)
+		if err != nil {
+			log.Fatalf("Failed to load cluster cache: %v", err)
+		}
+		k8s.UseClusterCache(clusterCache)
+
+		// The operator never prompts, so scans cannot block on a confirmation
+		k8s.SetInteractive(false)
+
+		fmt.Println("Writing Netfetch reports...")
+		k8s.NewReportController(clusterCache, kubeconfigPath, engines, resyncInterval).Run(wait.NeverStop)
+	},
+}
+
+func init() {
+	operatorCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (optional)")
+	operatorCmd.Flags().DurationVar(&resyncInterval, "resync-interval", 5*time.Minute, "How often the reports are rewritten when nothing changes")
+	rootCmd.AddCommand(operatorCmd)
+}
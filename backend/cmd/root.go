@@ -3,23 +3,34 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/deggja/netfetch/backend/pkg/k8s"
 	"github.com/spf13/cobra"
 )
 
-var Version string
+var (
+	Version    string
+	configPath string
+	historyDir string
+)
 
 var rootCmd = &cobra.Command{
 	Use:   "netfetch",
 	Short: "Netfetch is a CLI tool for scanning Kubernetes clusters for network policies",
 	Long: `Netfetch is a CLI  tool for scanning clusters for network policies and identifying unprotected workloads.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// A broken configuration file is not a usage error
+		cmd.SilenceUsage = true
+		return loadConfig(cmd.Flags().Changed("config"), cmd.Flags().Changed("history-dir"))
+	},
 }
 
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number of Netfetch",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf(Version + "\n")
+		fmt.Println(Version)
 	},
 }
 
@@ -30,6 +41,25 @@ func Execute() {
 	}
 }
 
+// loadConfig makes every scanner respect the configuration file. The default file is optional, a file given with
+// --config must exist, and --history-dir overrides the history directory of the file.
+func loadConfig(required bool, overrideHistoryDir bool) error {
+	config, err := k8s.LoadConfig(configPath, !required)
+	if err != nil {
+		return err
+	}
+	if overrideHistoryDir {
+		config.History.Directory = historyDir
+	}
+	for _, suppression := range config.ExpiredSuppressions(time.Now()) {
+		fmt.Fprintf(os.Stderr, "Warning: the suppression of %s expired on %s and no longer applies\n", suppression, suppression.Expires)
+	}
+	k8s.UseConfig(config)
+	return nil
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", k8s.ConfigFileName, "Path to the netfetch configuration file")
+	rootCmd.PersistentFlags().StringVar(&historyDir, "history-dir", "", "Directory scans are recorded in (default ~/.netfetch/history)")
 	rootCmd.AddCommand(versionCmd)
 }
@@ -2,6 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
@@ -13,19 +19,40 @@ var (
 	dryRun         bool
 	native         bool
 	cilium         bool
+	calico         bool
+	antrea         bool
+	istio          bool
 	verbose        bool
 	targetPolicy   string
 	kubeconfigPath string
+	fromFiles      string
+	outputFormat   string
+	outputFile     string
+	ciMode         bool
+	failUnderScore int
+	maxUnprotected int
+	watchMode      bool
+	scanFailed     bool
+	scanResults    []*k8s.ScanResult
 )
 
 var scanCmd = &cobra.Command{
 	Use:   "scan [namespace]",
 	Short: "Scan Kubernetes namespaces for network policies",
 	Long: `Scan Kubernetes namespaces for network policies.
-    By default, it scans for native Kubernetes network policies.
+    By default, it detects the policy engines the cluster serves and scans every one of them.
+    Use --native to scan for native Kubernetes network policies.
     Use --cilium to scan for Cilium network policies.
-	You may also target a specific network policy using the --target flag.
-	This can be used in combination with --native and --cilium for select policy types.`,
+    Use --calico to scan for Calico network policies and global network policies.
+    Use --antrea to scan for Antrea cluster network policies and network policies.
+    Use --istio to add Istio mTLS and AuthorizationPolicy coverage to the scanned pods.
+	AdminNetworkPolicies and BaselineAdminNetworkPolicies are evaluated together with native network policies.
+	You may also target a specific network policy using the --target flag, including admin network policies by name.
+	This can be used in combination with --native and --cilium for select policy types, but not with --calico or --antrea.
+	Use --from-files to scan a directory of rendered manifests instead of a live cluster.
+	Use --output json|yaml|csv|sarif to print a machine-readable result, or --output-file to write it to a file.
+	Use --ci to run without prompts, combined with --fail-under-score and --max-unprotected to gate pipelines.
+	Use --watch to keep running and report pods becoming protected or unprotected, and score changes, as they happen.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		var namespace string
@@ -33,26 +60,110 @@ var scanCmd = &cobra.Command{
 			namespace = args[0]
 		}
 
+		// Watch mode reports changes until interrupted instead of running a single scan
+		if watchMode {
+			if err := watchScan(namespace); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		// Targeted scans look up native, admin and Cilium policies only, and would otherwise run a full scan instead
+		if targetPolicy != "" && (calico || antrea) {
+			fmt.Println("--target cannot be combined with --calico or --antrea, it finds native, admin and Cilium network policies only")
+			os.Exit(1)
+		}
+
+		// CI mode never prompts and never applies policies
+		if ciMode {
+			dryRun = true
+			k8s.SetInteractive(false)
+		}
+
+		// Emit a machine-readable document and enforce thresholds once every scan has finished. The document is
+		// usually piped into another program, so the scan never prompts.
+		restoreStdout := func() {}
+		if outputFormat != "" {
+			if err := k8s.ValidateOutputFormat(outputFormat); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			k8s.SetInteractive(false)
+			restoreStdout = redirectHumanOutput()
+		}
+		defer func() {
+			document := k8s.NewScanResultDocument(scanResults...)
+			printWaivedFindings(document)
+			// A failed scan holds the results of some engines at most, and would show up in the trend as a real score
+			if !scanFailed {
+				source, cluster := k8s.HistorySourceCLI, k8s.CurrentCluster(kubeconfigPath)
+				if fromFiles != "" {
+					source, cluster = k8s.HistorySourceManifests, ""
+				}
+				if err := k8s.ConfiguredHistory().Record(source, cluster, namespace, document); err != nil {
+					fmt.Println("Error recording scan in the history:", err)
+				}
+			}
+			restoreStdout()
+			finishScan(document)
+		}()
+
+		// Scan rendered manifests instead of a live cluster
+		if fromFiles != "" {
+			if err := loadOfflineManifests(fromFiles); err != nil {
+				fmt.Println("Error loading manifests:", err)
+				scanFailed = true
+				return
+			}
+			dryRun = true
+		}
+
 		// Initialize the Kubernetes clients
 		clientset, err := k8s.GetClientset(kubeconfigPath)
 		if err != nil {
 			fmt.Println("Error creating Kubernetes client:", err)
+			scanFailed = true
 			return
 		}
-		dynamicClient, err := k8s.GetCiliumDynamicClient(kubeconfigPath)
+		dynamicClient, err := k8s.GetDynamicClient(kubeconfigPath)
 		if err != nil {
 			fmt.Println("Error creating Kubernetes dynamic client:", err)
+			scanFailed = true
 			return
 		}
 
 		// Handle target policy for native Kubernetes network policies
 		if targetPolicy != "" {
-			if !cilium || native {
+			if (!cilium && !calico && !antrea) || native {
 				fmt.Println("Policy type: Kubernetes")
 				fmt.Printf("Searching for Kubernetes native network policy '%s' across all non-system namespaces...\n", targetPolicy)
 				policy, foundNamespace, err := k8s.FindNativeNetworkPolicyByName(dynamicClient, clientset, targetPolicy)
 				if err != nil {
-					fmt.Println("Error during Kubernetes native network policy search:", err)
+					// If not found in namespaces, search for cluster scoped admin network policies
+					fmt.Println("Kubernetes native network policy not found in namespaces, searching for admin network policies...")
+					policy, err = k8s.FindAdminNetworkPolicyByName(dynamicClient, targetPolicy)
+					if err != nil {
+						fmt.Println("Error during Kubernetes native network policy search:", err)
+					} else {
+						policyType := k8s.PolicyTypeAdminNetworkPolicy
+						if policy.GetKind() == k8s.BaselineAdminNetworkPolicyKind {
+							policyType = k8s.PolicyTypeBaselineAdminNetworkPolicy
+						}
+						fmt.Printf("Found %s '%s'.\n", policy.GetKind(), policy.GetName())
+
+						// List the pods selected by the subject of this admin policy
+						pods, err := k8s.ListPodsTargetedByAdminNetworkPolicy(clientset, policy)
+						if err != nil {
+							fmt.Printf("Error listing pods targeted by %s %s: %v\n", policy.GetKind(), policy.GetName(), err)
+						} else if len(pods) == 0 {
+							fmt.Printf("No pods targeted by %s '%s'.\n", policy.GetKind(), policy.GetName())
+							recordEmptyTargetPolicy(policyType, policy.GetName())
+						} else {
+							fmt.Printf("Pods targeted by %s '%s':\n", policy.GetKind(), policy.GetName())
+							fmt.Println(createTargetPodsTable(pods))
+						}
+					}
 				} else {
 					fmt.Printf("Found Kubernetes native network policy '%s' in namespace '%s'.\n", policy.GetName(), foundNamespace)
 
@@ -62,6 +173,7 @@ var scanCmd = &cobra.Command{
 						fmt.Printf("Error listing pods targeted by policy %s: %v\n", policy.GetName(), err)
 					} else if len(pods) == 0 {
 						fmt.Printf("No pods targeted by policy '%s' in namespace '%s'.\n", policy.GetName(), foundNamespace)
+						recordEmptyTargetPolicy(k8s.PolicyTypeKubernetes, foundNamespace+"/"+policy.GetName())
 					} else {
 						fmt.Printf("Pods targeted by policy '%s' in namespace '%s':\n", policy.GetName(), foundNamespace)
 						fmt.Println(createTargetPodsTable(pods))
@@ -91,6 +203,7 @@ var scanCmd = &cobra.Command{
                         fmt.Printf("Error listing pods targeted by cluster wide policy %s: %v\n", policy.GetName(), err)
                     } else if len(pods) == 0 {
                         fmt.Printf("No pods targeted by cluster wide policy '%s'.\n", policy.GetName())
+                        recordEmptyTargetPolicy(k8s.PolicyTypeCiliumClusterwide, policy.GetName())
                     } else {
                         fmt.Printf("Pods targeted by cluster wide policy '%s':\n", policy.GetName())
                         fmt.Println(createTargetPodsTable(pods))
@@ -105,6 +218,7 @@ var scanCmd = &cobra.Command{
                     fmt.Printf("Error listing pods targeted by policy %s: %v\n", policy.GetName(), err)
                 } else if len(pods) == 0 {
                     fmt.Printf("No pods targeted by policy '%s' in namespace '%s'.\n", policy.GetName(), foundNamespace)
+                    recordEmptyTargetPolicy(k8s.PolicyTypeCilium, foundNamespace+"/"+policy.GetName())
                 } else {
                     fmt.Printf("Pods targeted by policy '%s' in namespace '%s':\n", policy.GetName(), foundNamespace)
                     fmt.Println(createTargetPodsTable(pods))
@@ -113,57 +227,339 @@ var scanCmd = &cobra.Command{
             return
         }
 
-		// Default to native scan if no specific type is mentioned or if --native is used
-		if !cilium || native {
+		// Scan the requested policy engines, or every engine the cluster serves when none is requested
+		engines, err := k8s.DetectPolicyEngines(clientset)
+		if err != nil {
+			fmt.Println("Error detecting policy engines:", err)
+		} else {
+			reportPolicyEngines(engines)
+		}
+		selected := selectEngines(engines)
+		scanNative := slices.Contains(selected, k8s.PolicyTypeKubernetes)
+		scanCilium := slices.Contains(selected, k8s.PolicyTypeCilium)
+		scanCalico := slices.Contains(selected, k8s.PolicyTypeCalico)
+		scanAntrea := slices.Contains(selected, k8s.PolicyTypeAntrea)
+
+		// Native network policies are scanned together with admin network policies
+		if scanNative {
 			fmt.Println("Running native network policies scan...")
 			nativeScanResult, err := k8s.ScanNetworkPolicies(namespace, dryRun, false, true, true, true, kubeconfigPath)
 			if err != nil {
 				fmt.Println("Error during Kubernetes native network policies scan:", err)
+				scanFailed = true
 			} else {
 				fmt.Println("Kubernetes native network policies scan completed successfully.")
 				handleScanResult(nativeScanResult)
 			}
 		}
 
-		// Perform Cilium network policy scan if --cilium is used
-		if cilium {
-			// Perform cluster wide Cilium scan first if no namespace is specified
+		// Perform Cilium network policy scan if --cilium is used or Cilium was detected
+		if scanCilium {
+			// Perform cluster wide Cilium scan first if no namespace is specified. When it protects every pod, or no
+			// client can be created, only the namespaced Cilium scan is skipped and the other engines still run.
+			skipNamespacedScan := false
 			if namespace == "" {
 				fmt.Println("Running cluster wide Cilium network policies scan...")
-				dynamicClient, err := k8s.GetCiliumDynamicClient(kubeconfigPath)
+				dynamicClient, err := k8s.GetDynamicClient(kubeconfigPath)
 				if err != nil {
 					fmt.Println("Error obtaining dynamic client:", err)
-					return
+					scanFailed = true
+					skipNamespacedScan = true
+				} else {
+					clusterwideScanResult, err := k8s.ScanCiliumClusterwideNetworkPolicies(dynamicClient, false, dryRun, true, kubeconfigPath)
+					if err != nil {
+						fmt.Println("Error during cluster wide Cilium network policies scan:", err)
+						scanFailed = true
+					} else {
+						if clusterwideScanResult.AllPodsProtected {
+							fmt.Println("All pods are protected by cluster wide cilium policies.\nYour Netfetch security score is: 100/100")
+							clusterwideScanResult.Score = 100
+							skipNamespacedScan = true
+						}
+						handleScanResult(clusterwideScanResult)
+					}
 				}
+			}
 
-				clusterwideScanResult, err := k8s.ScanCiliumClusterwideNetworkPolicies(dynamicClient, false, dryRun, true, kubeconfigPath)
+			// Proceed with normal Cilium network policy scan
+			if !skipNamespacedScan {
+				fmt.Println("Running cilium network policies scan...")
+				ciliumScanResult, err := k8s.ScanCiliumNetworkPolicies(namespace, dryRun, false, true, true, true, kubeconfigPath)
 				if err != nil {
-					fmt.Println("Error during cluster wide Cilium network policies scan:", err)
+					fmt.Println("Error during Cilium network policies scan:", err)
+					scanFailed = true
 				} else {
-					// Handle the cluster wide scan result; skip further scanning if all pods are protected
-					if clusterwideScanResult.AllPodsProtected {
-						fmt.Println("All pods are protected by cluster wide cilium policies.\nYour Netfetch security score is: 100/100")
-						return
-					}
-					handleScanResult(clusterwideScanResult)
+					fmt.Println("Cilium network policies scan completed successfully.")
+					handleScanResult(ciliumScanResult)
 				}
 			}
+		}
 
-			// Proceed with normal Cilium network policy scan
-			fmt.Println("Running cilium network policies scan...")
-			ciliumScanResult, err := k8s.ScanCiliumNetworkPolicies(namespace, dryRun, false, true, true, true, kubeconfigPath)
+		// Perform Calico network policy scan if --calico is used or Calico was detected
+		if scanCalico {
+			fmt.Println("Running Calico network policies scan...")
+			calicoScanResult, err := k8s.ScanCalicoNetworkPolicies(namespace, dryRun, false, true, true, true, kubeconfigPath)
 			if err != nil {
-				fmt.Println("Error during Cilium network policies scan:", err)
+				fmt.Println("Error during Calico network policies scan:", err)
+				scanFailed = true
 			} else {
-				fmt.Println("Cilium network policies scan completed successfully.")
-				handleScanResult(ciliumScanResult)
+				fmt.Println("Calico network policies scan completed successfully.")
+				handleScanResult(calicoScanResult)
+			}
+		}
+
+		// Perform Antrea network policy scan if --antrea is used or Antrea was detected
+		if scanAntrea {
+			fmt.Println("Running Antrea network policies scan...")
+			antreaScanResult, err := k8s.ScanAntreaNetworkPolicies(namespace, dryRun, false, true, true, true, kubeconfigPath)
+			if err != nil {
+				fmt.Println("Error during Antrea network policies scan:", err)
+				scanFailed = true
+			} else {
+				fmt.Println("Antrea network policies scan completed successfully.")
+				handleScanResult(antreaScanResult)
+			}
+		}
+
+		// Add the service mesh dimension once every engine has been scanned
+		if istio {
+			if err := k8s.ScanIstioCoverage(scanResults, true, kubeconfigPath); err != nil {
+				fmt.Println("Error during Istio coverage scan:", err)
+				scanFailed = true
 			}
 		}
 	},
 }
 
+// watchScan keeps the cluster in an informer cache and re-evaluates a namespace whenever its pods, policies or labels
+// change, printing a line for every pod that becomes protected or unprotected and every change of the score. It
+// watches the same policy engines a scan would, and runs until interrupted.
+func watchScan(namespace string) error {
+	if fromFiles != "" || outputFormat != "" || targetPolicy != "" || ciMode || istio {
+		return fmt.Errorf("--watch cannot be combined with --from-files, --output, --target, --ci or --istio")
+	}
+
+	clientset, err := k8s.GetClientset(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("error creating Kubernetes client: %w", err)
+	}
+	dynamicClient, err := k8s.GetDynamicClient(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("error creating Kubernetes dynamic client: %w", err)
+	}
+
+	// Watch mode never prompts, as the scans run in the background
+	k8s.SetInteractive(false)
+	engines, err := k8s.DetectPolicyEngines(clientset)
+	if err != nil {
+		fmt.Println("Error detecting policy engines:", err)
+	} else {
+		reportPolicyEngines(engines)
+	}
+	selected := selectEngines(engines)
+
+	stopCh := make(chan struct{})
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupts
+		close(stopCh)
+	}()
+
+	fmt.Println("Loading cluster cache...")
+	clusterCache, err := k8s.NewClusterCache(clientset, dynamicClient, stopCh)
+	if err != nil {
+		return fmt.Errorf("failed to load cluster cache: %w", err)
+	}
+	k8s.UseClusterCache(clusterCache)
+
+	events, unsubscribe := clusterCache.Events().Subscribe()
+	defer unsubscribe()
+	go clusterCache.WatchProtection(namespace, kubeconfigPath, selected, stopCh)
+
+	scope := "all non-system namespaces"
+	if namespace != "" {
+		scope = fmt.Sprintf("namespace %s", namespace)
+	}
+	fmt.Printf("Watching %s network policy coverage of %s. Press Ctrl+C to stop.\n", strings.Join(selected, ", "), scope)
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case event := <-events:
+			fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), event)
+		}
+	}
+}
+
+// selectEngines returns the policy engines requested with --native, --cilium, --calico and --antrea, or native
+// network policies and every other engine the cluster serves when none is requested
+func selectEngines(engines k8s.PolicyEngines) []string {
+	if !native && !cilium && !calico && !antrea {
+		return k8s.ScannedEngines(engines)
+	}
+
+	selected := []string{}
+	for _, engine := range []struct {
+		policyType string
+		requested  bool
+	}{
+		{k8s.PolicyTypeKubernetes, native},
+		{k8s.PolicyTypeCilium, cilium},
+		{k8s.PolicyTypeCalico, calico},
+		{k8s.PolicyTypeAntrea, antrea},
+	} {
+		if engine.requested {
+			selected = append(selected, engine.policyType)
+		}
+	}
+	return selected
+}
+
+// reportPolicyEngines prints the detected policy engines and warns about the ones the CNI does not enforce
+func reportPolicyEngines(engines k8s.PolicyEngines) {
+	fmt.Println("Detected policy engines:")
+	fmt.Println(engines.Summary())
+	for _, warning := range engines.Warnings() {
+		fmt.Println("Warning:", warning)
+	}
+	fmt.Printf("\n")
+}
+
+// loadOfflineManifests reads the manifests at path and points the scanners at them
+func loadOfflineManifests(path string) error {
+	manifests, err := k8s.LoadManifests(path)
+	if err != nil {
+		return err
+	}
+
+	offlineClientset, offlineDynamicClient, err := manifests.Clients()
+	if err != nil {
+		return err
+	}
+	k8s.UseOfflineClients(offlineClientset, offlineDynamicClient)
+
+	fmt.Println("Mode: Offline")
+	fmt.Printf("Loaded %d pods, %d network policies and %d custom policies from %s\n", len(manifests.Pods), len(manifests.NetworkPolicies), len(manifests.CustomResources), path)
+	if len(manifests.SkippedKinds) > 0 {
+		fmt.Printf("Ignored unsupported kinds: %s\n", strings.Join(manifests.SkippedKinds, ", "))
+	}
+	fmt.Printf("\n")
+	return nil
+}
+
+// handleScanResult collects a finished scan so it can be included in the machine-readable output
 func handleScanResult(scanResult *k8s.ScanResult) {
-	// Implement your logic to handle scan results
+	if scanResult == nil {
+		return
+	}
+	scanResults = append(scanResults, scanResult)
+}
+
+// recordEmptyTargetPolicy reports a targeted policy that selects no pods in the machine-readable output
+func recordEmptyTargetPolicy(policyType string, policy string) {
+	handleScanResult(&k8s.ScanResult{
+		PolicyType:               policyType,
+		PoliciesSelectingNothing: []string{policy},
+	})
+}
+
+// redirectHumanOutput sends the human readable scan output to stderr while a document is written to stdout.
+// It returns a function restoring the original stdout.
+func redirectHumanOutput() func() {
+	stdout := os.Stdout
+	if outputFile == "" {
+		os.Stdout = os.Stderr
+	}
+	return func() {
+		os.Stdout = stdout
+	}
+}
+
+// printWaivedFindings lists the findings covered by a netfetch.io/waiver annotation, once per finding, and warns
+// about expired waivers whose findings are reported again
+func printWaivedFindings(document *k8s.ScanResultDocument) {
+	rows := [][]string{}
+	seen := map[string]bool{}
+	for _, entry := range document.Results {
+		for _, waived := range entry.Waived {
+			key := waived.Rule + "/" + waived.Namespace + "/" + waived.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			status := "waived"
+			if waived.Expired {
+				status = "expired " + waived.Expires
+			}
+			object := waived.Namespace + "/" + waived.Name
+			if waived.Rule == k8s.RuleNamespaceWithoutDenyAll {
+				object = waived.Name
+			}
+			rows = append(rows, []string{waived.Rule, object, waiverObject(waived), waived.Waiver, status})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Println("\n" + HeaderStyle.Render("Waived findings:"))
+	fmt.Println(createWaivedFindingsTable(rows))
+	for _, waived := range k8s.ExpiredWaivers(document) {
+		fmt.Printf("Warning: the %s annotation of %s is no longer valid (expires %s), its findings are reported again\n", k8s.WaiverAnnotation, waiverObject(waived), waived.Expires)
+	}
+}
+
+// waiverObject describes the pod or namespace carrying the waiver of a finding
+func waiverObject(waived k8s.WaivedFinding) string {
+	if waived.WaivedBy.Namespace == "" {
+		return waived.WaivedBy.Kind + " " + waived.WaivedBy.Name
+	}
+	return waived.WaivedBy.Kind + " " + waived.WaivedBy.Namespace + "/" + waived.WaivedBy.Name
+}
+
+// finishScan writes the machine-readable output and exits non-zero when a scan failed in CI mode
+// or the results breach the configured thresholds
+func finishScan(document *k8s.ScanResultDocument) {
+	if outputFormat != "" {
+		if err := writeScanResults(document); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing scan results:", err)
+			os.Exit(1)
+		}
+	}
+
+	if ciMode && scanFailed {
+		fmt.Fprintln(os.Stderr, "Netfetch scan failed.")
+		os.Exit(1)
+	}
+
+	breaches := k8s.CheckThresholds(document, k8s.Thresholds{FailUnderScore: failUnderScore, MaxUnprotected: maxUnprotected})
+	if len(breaches) > 0 {
+		for _, breach := range breaches {
+			fmt.Fprintln(os.Stderr, "Threshold breached:", breach)
+		}
+		os.Exit(1)
+	}
+}
+
+// writeScanResults serializes the collected scan results to stdout or the requested file
+func writeScanResults(document *k8s.ScanResultDocument) error {
+	if outputFile == "" {
+		return k8s.WriteScanResultDocument(os.Stdout, document, outputFormat)
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := k8s.WriteScanResultDocument(file, document, outputFormat); err != nil {
+		return err
+	}
+	fmt.Printf("Scan results written to %s\n", outputFile)
+	return nil
 }
 
 var (
@@ -196,12 +592,45 @@ func createTargetPodsTable(pods [][]string) string {
     return t.String()
 }
 
+// createWaivedFindingsTable renders the waived findings with the waiver covering them
+func createWaivedFindingsTable(rows [][]string) string {
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(tableBorderStyle).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+			if row%2 == 0 {
+				return evenRowStyle
+			}
+			return oddRowStyle
+		}).
+		Headers("Rule", "Finding", "Waived By", "Waiver", "Status")
+
+	for _, row := range rows {
+		t.Row(row...)
+	}
+
+	return t.String()
+}
+
 func init() {
 	scanCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (optional)")
 	scanCmd.Flags().BoolVarP(&dryRun, "dryrun", "d", false, "Perform a dry run without applying any changes")
-	scanCmd.Flags().BoolVar(&native, "native", false, "Scan only native network policies")
+	scanCmd.Flags().BoolVar(&native, "native", false, "Scan native network policies (by default every detected policy engine is scanned)")
 	scanCmd.Flags().BoolVar(&cilium, "cilium", false, "Scan only Cilium network policies (includes cluster wide policies if no namespace is specified)")
+	scanCmd.Flags().BoolVar(&calico, "calico", false, "Scan only Calico network policies and global network policies")
+	scanCmd.Flags().BoolVar(&antrea, "antrea", false, "Scan only Antrea cluster network policies and network policies")
+	scanCmd.Flags().BoolVar(&istio, "istio", false, "Add Istio mTLS and AuthorizationPolicy coverage to the scan results")
 	scanCmd.Flags().StringVarP(&targetPolicy, "target", "t", "", "Scan a specific network policy by name")
 	scanCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	scanCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format for scan results: json, yaml, csv or sarif (never prompts)")
+	scanCmd.Flags().StringVar(&outputFile, "output-file", "", "Write the machine-readable scan results to a file instead of stdout (requires --output)")
+	scanCmd.Flags().BoolVar(&ciMode, "ci", false, "Run non-interactively for CI pipelines (implies --dryrun and never prompts)")
+	scanCmd.Flags().IntVar(&failUnderScore, "fail-under-score", 0, "Exit non-zero when a scan score is below this value (0 disables the check)")
+	scanCmd.Flags().IntVar(&maxUnprotected, "max-unprotected", -1, "Exit non-zero when more unprotected pods than this are found (-1 disables the check)")
+	scanCmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Keep running and report coverage and score changes as pods and policies change")
+	scanCmd.Flags().StringVar(&fromFiles, "from-files", "", "Scan a file or directory of rendered manifests instead of a live cluster (implies --dryrun)")
 	rootCmd.AddCommand(scanCmd)
 }
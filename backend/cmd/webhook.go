@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/deggja/netfetch/backend/pkg/k8s"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var (
+	webhookPort    string
+	webhookTLSCert string
+	webhookTLSKey  string
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Run a validating admission webhook that stops unprotected workloads",
+	Long: `Run a validating admission webhook checking that new pods and deployments are selected by a NetworkPolicy or Cilium policy.
+	Only namespaces labelled netfetch.io/enforce are checked. With netfetch.io/enforce=deny unprotected workloads are rejected,
+	with netfetch.io/enforce=warn they are admitted with a warning.
+	The API server only calls webhooks over TLS, so --tls-cert-file and --tls-key-file are required.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if webhookTLSCert == "" || webhookTLSKey == "" {
+			log.Fatalf("--tls-cert-file and --tls-key-file are required")
+		}
+
+		clientset, err := k8s.GetClientset(kubeconfigPath)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		dynamicClient, err := k8s.GetDynamicClient(kubeconfigPath)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes dynamic client: %v", err)
+		}
+
+		// Answer admission requests from an informer cache so they do not wait on the API server
+		fmt.Println("Loading cluster cache...")
+		clusterCache, err := k8s.NewClusterCache(clientset, dynamicClient, wait.NeverStop)
+		if err != nil {
+			log.Fatalf("Failed to load cluster cache: %v", err)
+		}
+
+		webhook := k8s.NewAdmissionWebhook(clusterCache.Clientset(), clusterCache.DynamicClient())
+		mux := http.NewServeMux()
+		mux.HandleFunc("/validate", k8s.HandleAdmissionRequest(webhook))
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		fmt.Println(HeaderStyle.Render(fmt.Sprintf("Starting admission webhook on :%s", webhookPort)))
+		if err := http.ListenAndServeTLS(":"+webhookPort, webhookTLSCert, webhookTLSKey, mux); err != nil {
+			log.Fatalf("Failed to start webhook server: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	webhookCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (optional)")
+	webhookCmd.Flags().StringVarP(&webhookPort, "port", "p", "8443", "Port for the admission webhook")
+	webhookCmd.Flags().StringVar(&webhookTLSCert, "tls-cert-file", "", "Path to the TLS certificate served to the API server")
+	webhookCmd.Flags().StringVar(&webhookTLSKey, "tls-key-file", "", "Path to the private key of the TLS certificate")
+	rootCmd.AddCommand(webhookCmd)
+}
@@ -0,0 +1,249 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Kinds of the policy.networking.k8s.io cluster admin policies
+const (
+	AdminNetworkPolicyKind         = "AdminNetworkPolicy"
+	BaselineAdminNetworkPolicyKind = "BaselineAdminNetworkPolicy"
+)
+
+// Tiers of the admin policies. AdminNetworkPolicies are evaluated before every other policy and traffic that none
+// of their rules match continues to NetworkPolicies; BaselineAdminNetworkPolicies only apply to traffic that no
+// NetworkPolicy isolates.
+const (
+	adminTier    = "admin"
+	baselineTier = "baseline"
+)
+
+// AdminPolicyModel converts an AdminNetworkPolicy or BaselineAdminNetworkPolicy into the engine's policy model.
+// AdminNetworkPolicies are ordered by priority, lower first, and the first matching Allow or Deny rule decides;
+// a Pass rule hands the traffic to NetworkPolicies. Admin policies never deny traffic none of their rules match,
+// so a direction only counts as isolated when the policy has Deny rules for it.
+func AdminPolicyModel(policy *unstructured.Unstructured) (PolicyModel, error) {
+	spec, _ := nestedMapNoCopy(policy.Object, "spec")
+
+	model := PolicyModel{
+		Engine: PolicyTypeAdminNetworkPolicy,
+		Kind:   policy.GetKind(),
+		Name:   policy.GetName(),
+		Rules:  []PolicyRule{},
+	}
+	switch model.Kind {
+	case AdminNetworkPolicyKind:
+		model.Tier = adminTier
+		model.ordering = &policyOrdering{tierPriority: math.Inf(-1), defaultAction: ActionPass}
+		if priority, found := calicoNumber(spec["priority"]); found {
+			model.Priority = &priority
+		}
+	case BaselineAdminNetworkPolicyKind:
+		model.Tier = baselineTier
+		model.ordering = &policyOrdering{baseline: true, defaultAction: ActionPass}
+	default:
+		return PolicyModel{}, fmt.Errorf("unsupported admin policy kind %q", model.Kind)
+	}
+
+	subject, _ := nestedMapNoCopy(spec, "subject")
+	selects, description, err := adminSubject(subject)
+	if err != nil {
+		return PolicyModel{}, fmt.Errorf("error parsing subject of %s %s: %w", model.Kind, model.Name, err)
+	}
+	model.Selector = description
+	model.selects = selects
+
+	sections := []struct {
+		direction string
+		peerField string
+	}{
+		{DirectionIngress, "from"},
+		{DirectionEgress, "to"},
+	}
+	for _, section := range sections {
+		rawRules, _ := nestedSliceNoCopy(spec, section.direction)
+		for i, rawRule := range rawRules {
+			rule, ok := rawRule.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			action, _ := rule["action"].(string)
+			switch action {
+			case ActionAllow, ActionDeny, ActionPass:
+			default:
+				return PolicyModel{}, fmt.Errorf("error parsing %s rule %d of %s %s: unknown action %q", section.direction, i, model.Kind, model.Name, action)
+			}
+			if action == ActionDeny {
+				if section.direction == DirectionEgress {
+					model.IsolatesEgress = true
+				} else {
+					model.IsolatesIngress = true
+				}
+			}
+
+			rawPeers, _ := nestedSliceNoCopy(rule, section.peerField)
+			peers, err := adminPeers(rawPeers)
+			if err != nil {
+				return PolicyModel{}, fmt.Errorf("error parsing %s rule %d of %s %s: %w", section.direction, i, model.Kind, model.Name, err)
+			}
+			rawPorts, _ := nestedSliceNoCopy(rule, "ports")
+			model.Rules = append(model.Rules, PolicyRule{
+				Policy:    model.ID(),
+				Direction: section.direction,
+				Index:     i,
+				Action:    action,
+				Peers:     peers,
+				Ports:     adminPorts(rawPorts),
+			})
+		}
+	}
+
+	return model, nil
+}
+
+// adminSubject parses the subject of an admin policy, either every pod of the selected namespaces or the
+// selected pods of the selected namespaces.
+func adminSubject(subject map[string]interface{}) (podMatcher, string, error) {
+	if rawNamespaces, found := subject["namespaces"]; found {
+		namespaceSelector, err := adminLabelSelector(rawNamespaces)
+		if err != nil {
+			return nil, "", err
+		}
+		return func(_ corev1.Pod, namespaceLabels map[string]string) bool {
+			return namespaceSelector.Matches(labels.Set(namespaceLabels))
+		}, "pods in namespaces " + selectorDescription(namespaceSelector.String()), nil
+	}
+
+	pods, found := nestedMapNoCopy(subject, "pods")
+	if !found {
+		return nil, "", fmt.Errorf("subject must select namespaces or pods")
+	}
+	namespaceSelector, err := adminLabelSelector(pods["namespaceSelector"])
+	if err != nil {
+		return nil, "", err
+	}
+	podSelector, err := adminLabelSelector(pods["podSelector"])
+	if err != nil {
+		return nil, "", err
+	}
+	return func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+		return namespaceSelector.Matches(labels.Set(namespaceLabels)) && podSelector.Matches(labels.Set(pod.Labels))
+	}, selectorDescription(podSelector.String()) + " in namespaces " + selectorDescription(namespaceSelector.String()), nil
+}
+
+// adminPeers converts the peers of an admin policy rule: namespaces, pods, nodes, networks and domain names.
+// Nodes and domain names are resolved by the network plugin, so they are reported but never match a pod.
+func adminPeers(rawPeers []interface{}) ([]RulePeer, error) {
+	peers := []RulePeer{}
+	for _, rawPeer := range rawPeers {
+		peer, ok := rawPeer.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if rawNamespaces, found := peer["namespaces"]; found {
+			namespaceSelector, err := adminLabelSelector(rawNamespaces)
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, RulePeer{
+				Kind:              PeerNamespace,
+				NamespaceSelector: selectorDescription(namespaceSelector.String()),
+				matches: func(_ corev1.Pod, namespaceLabels map[string]string) bool {
+					return namespaceSelector.Matches(labels.Set(namespaceLabels))
+				},
+			})
+		}
+		if pods, found := nestedMapNoCopy(peer, "pods"); found {
+			namespaceSelector, err := adminLabelSelector(pods["namespaceSelector"])
+			if err != nil {
+				return nil, err
+			}
+			podSelector, err := adminLabelSelector(pods["podSelector"])
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, RulePeer{
+				Kind:              PeerPods,
+				NamespaceSelector: selectorDescription(namespaceSelector.String()),
+				PodSelector:       podSelector.String(),
+				matches: func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+					return namespaceSelector.Matches(labels.Set(namespaceLabels)) && podSelector.Matches(labels.Set(pod.Labels))
+				},
+			})
+		}
+		if rawNodes, found := peer["nodes"]; found {
+			nodeSelector, err := adminLabelSelector(rawNodes)
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, RulePeer{Kind: PeerNodes, NodeSelector: selectorDescription(nodeSelector.String())})
+		}
+		networks, _, _ := unstructured.NestedStringSlice(peer, "networks")
+		for _, cidr := range networks {
+			peers = append(peers, cidrPeer(cidr, nil))
+		}
+		domainNames, _, _ := unstructured.NestedStringSlice(peer, "domainNames")
+		for _, domainName := range domainNames {
+			peers = append(peers, RulePeer{Kind: PeerFQDN, FQDN: domainName})
+		}
+	}
+	return peers, nil
+}
+
+// adminPorts converts the ports of an admin policy rule. No ports means every port.
+func adminPorts(rawPorts []interface{}) []RulePort {
+	rulePorts := []RulePort{}
+	for _, rawPort := range rawPorts {
+		port, ok := rawPort.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if portNumber, found := nestedMapNoCopy(port, "portNumber"); found {
+			number, _ := calicoNumber(portNumber["port"])
+			rulePorts = append(rulePorts, RulePort{Protocol: adminProtocol(portNumber), Port: strconv.Itoa(int(number))})
+		}
+		if namedPort, found := port["namedPort"].(string); found {
+			rulePorts = append(rulePorts, RulePort{Protocol: string(corev1.ProtocolTCP), Port: namedPort})
+		}
+		if portRange, found := nestedMapNoCopy(port, "portRange"); found {
+			start, _ := calicoNumber(portRange["start"])
+			end, _ := calicoNumber(portRange["end"])
+			rulePorts = append(rulePorts, RulePort{Protocol: adminProtocol(portRange), Port: strconv.Itoa(int(start)), EndPort: int32(end)})
+		}
+	}
+	return rulePorts
+}
+
+// adminProtocol returns the protocol of a port, which defaults to TCP.
+func adminProtocol(port map[string]interface{}) string {
+	if protocol, _ := port["protocol"].(string); protocol != "" {
+		return protocol
+	}
+	return string(corev1.ProtocolTCP)
+}
+
+// adminLabelSelector parses a label selector of an admin policy. A missing selector selects nothing, and an
+// empty one selects everything.
+func adminLabelSelector(rawSelector interface{}) (labels.Selector, error) {
+	if rawSelector == nil {
+		return labels.Nothing(), nil
+	}
+	content, err := json.Marshal(rawSelector)
+	if err != nil {
+		return nil, err
+	}
+	var selector metav1.LabelSelector
+	if err := json.Unmarshal(content, &selector); err != nil {
+		return nil, fmt.Errorf("invalid label selector: %w", err)
+	}
+	return metav1.LabelSelectorAsSelector(&selector)
+}
@@ -0,0 +1,169 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// adminTestPolicy builds an AdminNetworkPolicy or BaselineAdminNetworkPolicy.
+func adminTestPolicy(kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "policy.networking.k8s.io/v1alpha1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+}
+
+// namespaceNamed selects a namespace by its name label.
+func namespaceNamed(name string) map[string]interface{} {
+	return map[string]interface{}{"matchLabels": map[string]interface{}{"kubernetes.io/metadata.name": name}}
+}
+
+func TestAdminPolicyModel(t *testing.T) {
+	policy := adminTestPolicy(AdminNetworkPolicyKind, "guard", map[string]interface{}{
+		"priority": int64(20),
+		"subject": map[string]interface{}{"pods": map[string]interface{}{
+			"namespaceSelector": map[string]interface{}{},
+			"podSelector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "db"}},
+		}},
+		"ingress": []interface{}{
+			map[string]interface{}{
+				"action": "Allow",
+				"from":   []interface{}{map[string]interface{}{"namespaces": namespaceNamed("shop")}},
+				"ports": []interface{}{
+					map[string]interface{}{"portNumber": map[string]interface{}{"port": int64(5432)}},
+					map[string]interface{}{"portRange": map[string]interface{}{"protocol": "UDP", "start": int64(5000), "end": int64(5010)}},
+					map[string]interface{}{"namedPort": "metrics"},
+				},
+			},
+		},
+		"egress": []interface{}{
+			map[string]interface{}{
+				"action": "Deny",
+				"to": []interface{}{
+					map[string]interface{}{"networks": []interface{}{"0.0.0.0/0"}},
+					map[string]interface{}{"nodes": map[string]interface{}{}},
+					map[string]interface{}{"domainNames": []interface{}{"*.example.com"}},
+				},
+			},
+		},
+	})
+
+	model, err := AdminPolicyModel(policy)
+	assert.NoError(t, err)
+	assert.Equal(t, "guard", model.ID())
+	assert.Equal(t, adminTier, model.Tier)
+	assert.Equal(t, 20.0, *model.Priority)
+	assert.False(t, model.IsolatesIngress, "allow rules alone do not isolate")
+	assert.True(t, model.IsolatesEgress)
+	assert.Equal(t, "app=db in namespaces (all)", model.Selector)
+
+	db := testPod("shop", "db-0", "10.0.0.1", map[string]string{"app": "db"})
+	assert.True(t, model.Selects(db, map[string]string{"kubernetes.io/metadata.name": "shop"}))
+	assert.False(t, model.Selects(testPod("shop", "web-0", "10.0.0.2", map[string]string{"app": "web"}), nil))
+
+	ingress := model.RulesFor(DirectionIngress)[0]
+	assert.Equal(t, []RulePort{
+		{Protocol: "TCP", Port: "5432"},
+		{Protocol: "UDP", Port: "5000", EndPort: 5010},
+		{Protocol: "TCP", Port: "metrics"},
+	}, ingress.Ports)
+	assert.True(t, ingress.Peers[0].MatchesPod(db, map[string]string{"kubernetes.io/metadata.name": "shop"}))
+
+	egress := model.RulesFor(DirectionEgress)[0]
+	descriptions := []string{}
+	for _, peer := range egress.Peers {
+		descriptions = append(descriptions, peer.String())
+	}
+	assert.Equal(t, []string{"0.0.0.0/0", "nodes (all)", "fqdn *.example.com"}, descriptions)
+
+	_, err = AdminPolicyModel(adminTestPolicy(AdminNetworkPolicyKind, "broken", map[string]interface{}{
+		"subject": map[string]interface{}{"namespaces": map[string]interface{}{}},
+		"ingress": []interface{}{map[string]interface{}{"action": "Drop"}},
+	}))
+	assert.Error(t, err)
+}
+
+func TestCanReachAdminNetworkPolicies(t *testing.T) {
+	adminPolicies := []*unstructured.Unstructured{
+		adminTestPolicy(AdminNetworkPolicyKind, "allow-monitoring", map[string]interface{}{
+			"priority": 5,
+			"subject":  map[string]interface{}{"namespaces": map[string]interface{}{}},
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Allow", "from": []interface{}{map[string]interface{}{"namespaces": namespaceNamed("monitoring")}}},
+			},
+		}),
+		adminTestPolicy(AdminNetworkPolicyKind, "isolate-shop", map[string]interface{}{
+			"priority": 10,
+			"subject":  map[string]interface{}{"namespaces": namespaceNamed("shop")},
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Pass", "from": []interface{}{map[string]interface{}{"namespaces": namespaceNamed("shop")}}},
+				map[string]interface{}{"action": "Deny", "from": []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{}}}},
+			},
+		}),
+		adminTestPolicy(BaselineAdminNetworkPolicyKind, "default", map[string]interface{}{
+			"subject": map[string]interface{}{"namespaces": map[string]interface{}{}},
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Deny", "from": []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{}}}},
+			},
+		}),
+	}
+
+	var policies []PolicyModel
+	for _, policy := range adminPolicies {
+		model, err := AdminPolicyModel(policy)
+		assert.NoError(t, err)
+		policies = append(policies, model)
+	}
+
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	db := testPod("shop", "db-0", "10.0.0.2", map[string]string{"app": "db"})
+	prometheus := testPod("monitoring", "prometheus-0", "10.0.1.1", map[string]string{"app": "prometheus"})
+	attacker := testPod("tenant", "app-0", "10.0.2.1", map[string]string{"app": "app"})
+	namespaces := []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "monitoring"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}},
+	}
+	state := NewClusterState([]corev1.Pod{web, db, prometheus, attacker}, namespaces, policies)
+
+	tests := []struct {
+		name        string
+		source      corev1.Pod
+		destination corev1.Pod
+		allowed     bool
+		reason      string
+	}{
+		{"higher priority allow wins", prometheus, db, true, "allowed by allow-monitoring"},
+		{"deny from other namespaces", attacker, db, false, "denied by isolate-shop"},
+		{"passed traffic reaches the baseline", web, db, false, "denied by default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := state.CanReach(tt.source, tt.destination, 80, "TCP")
+			assert.Equal(t, tt.allowed, verdict.Allowed)
+			assert.Equal(t, tt.reason, verdict.Ingress.Reason)
+		})
+	}
+
+	// A NetworkPolicy allowing the passed traffic takes precedence over the baseline
+	networkPolicies, errs := NativePolicyModels([]netv1.NetworkPolicy{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"},
+		Spec: netv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Ingress:     []netv1.NetworkPolicyIngressRule{{From: []netv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}}},
+		},
+	}})
+	assert.Empty(t, errs)
+	state = NewClusterState(state.Pods, namespaces, append(policies, networkPolicies...))
+	verdict := state.CanReach(web, db, 80, "TCP")
+	assert.True(t, verdict.Allowed)
+	assert.Equal(t, "allowed by shop/db", verdict.Ingress.Reason)
+}
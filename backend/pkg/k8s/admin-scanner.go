@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Group version resources of the cluster admin policies
+var (
+	adminNetworkPolicyResource         = schema.GroupVersionResource{Group: "policy.networking.k8s.io", Version: "v1alpha1", Resource: "adminnetworkpolicies"}
+	baselineAdminNetworkPolicyResource = schema.GroupVersionResource{Group: "policy.networking.k8s.io", Version: "v1alpha1", Resource: "baselineadminnetworkpolicies"}
+)
+
+// LoadAdminPolicyModels reads the AdminNetworkPolicies and BaselineAdminNetworkPolicies of the cluster and converts
+// them into the engine's policy model. Policies that cannot be parsed are skipped and returned as errors.
+// Clusters without the admin policy APIs have no admin policies.
+func LoadAdminPolicyModels(dynamicClient dynamic.Interface) ([]PolicyModel, []error, error) {
+	var models []PolicyModel
+	var errs []error
+	for _, resource := range []schema.GroupVersionResource{adminNetworkPolicyResource, baselineAdminNetworkPolicyResource} {
+		list, err := dynamicClient.Resource(resource).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("error listing %s: %w", resource.Resource, err)
+		}
+		for i := range list.Items {
+			model, err := AdminPolicyModel(&list.Items[i])
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			models = append(models, model)
+		}
+	}
+	return models, errs, nil
+}
+
+// adminNamespaceHasDefaultDeny reports whether an admin policy selecting every pod of the namespace denies
+// ingress from and egress to every namespace.
+func adminNamespaceHasDefaultDeny(models []PolicyModel, namespace corev1.Namespace) bool {
+	return namespaceHasEngineDefaultDeny(models, PolicyTypeAdminNetworkPolicy, namespace)
+}
+
+// namespaceHasEngineDefaultDeny reports whether a policy of the engine selecting every pod of the namespace
+// denies ingress from and egress to every namespace. A policy selecting an unlabelled pod of the namespace is
+// taken to select every pod in it.
+func namespaceHasEngineDefaultDeny(models []PolicyModel, engine string, namespace corev1.Namespace) bool {
+	probe := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace.Name}}
+	namespaceLabels := NamespaceLabels([]corev1.Namespace{namespace})[namespace.Name]
+
+	for _, model := range models {
+		if model.Engine != engine || !model.Selects(probe, namespaceLabels) {
+			continue
+		}
+		if deniesAllNamespaces(model.RulesFor(DirectionIngress)) && deniesAllNamespaces(model.RulesFor(DirectionEgress)) {
+			return true
+		}
+	}
+	return false
+}
+
+// deniesAllNamespaces reports whether one of the rules denies traffic with every namespace, or every peer, on
+// every port.
+func deniesAllNamespaces(rules []PolicyRule) bool {
+	for _, rule := range rules {
+		if rule.Action != ActionDeny || len(rule.Ports) > 0 {
+			continue
+		}
+		for _, peer := range rule.Peers {
+			if peer.Kind == PeerAny || (peer.Kind == PeerNamespace && peer.NamespaceSelector == selectorDescription("")) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestLoadAdminPolicyModels(t *testing.T) {
+	baseline := adminTestPolicy(BaselineAdminNetworkPolicyKind, "default", map[string]interface{}{
+		"subject": map[string]interface{}{"namespaces": map[string]interface{}{}},
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, baseline)
+
+	models, errs, err := LoadAdminPolicyModels(dynamicClient)
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+	assert.Len(t, models, 1)
+	assert.Equal(t, baselineTier, models[0].Tier)
+
+	policy, err := FindAdminNetworkPolicyByName(dynamicClient, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, BaselineAdminNetworkPolicyKind, policy.GetKind())
+	_, err = FindAdminNetworkPolicyByName(dynamicClient, "missing")
+	assert.Error(t, err)
+}
+
+func TestAdminNamespaceHasDefaultDeny(t *testing.T) {
+	shop := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	denyAll := []interface{}{map[string]interface{}{"action": "Deny", "from": []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{}}}}}
+	denyAllEgress := []interface{}{map[string]interface{}{"action": "Deny", "to": []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{}}}}}
+
+	tests := []struct {
+		name     string
+		spec     map[string]interface{}
+		expected bool
+	}{
+		{"denies both directions", map[string]interface{}{"subject": map[string]interface{}{"namespaces": map[string]interface{}{}}, "ingress": denyAll, "egress": denyAllEgress}, true},
+		{"denies ingress only", map[string]interface{}{"subject": map[string]interface{}{"namespaces": map[string]interface{}{}}, "ingress": denyAll}, false},
+		{"other namespace", map[string]interface{}{"subject": map[string]interface{}{"namespaces": namespaceNamed("tenant")}, "ingress": denyAll, "egress": denyAllEgress}, false},
+		{"some pods", map[string]interface{}{
+			"subject": map[string]interface{}{"pods": map[string]interface{}{"namespaceSelector": map[string]interface{}{}, "podSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "db"}}}},
+			"ingress": denyAll, "egress": denyAllEgress,
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := AdminPolicyModel(adminTestPolicy(BaselineAdminNetworkPolicyKind, "default", tt.spec))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, adminNamespaceHasDefaultDeny([]PolicyModel{model}, shop))
+		})
+	}
+}
@@ -0,0 +1,418 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Kinds of the Antrea-native policies
+const (
+	AntreaClusterNetworkPolicyKind = "ClusterNetworkPolicy"
+	AntreaNetworkPolicyKind        = "NetworkPolicy"
+)
+
+// Tiers every Antrea cluster has, with their priorities. Policies without a tier belong to the application tier,
+// and the baseline tier is evaluated after Kubernetes NetworkPolicies.
+var antreaStaticTiers = map[string]float64{
+	"emergency":   50,
+	"securityops": 100,
+	"networkops":  150,
+	"platform":    200,
+	"application": 250,
+	"baseline":    253,
+}
+
+const (
+	antreaDefaultTier  = "application"
+	antreaBaselineTier = "baseline"
+)
+
+// antreaGroups indexes the specs of ClusterGroups by name and of namespaced Groups by namespace/name.
+type antreaGroups map[string]map[string]interface{}
+
+// antreaTiers returns the priority of every tier, static ones included. Tier names are case insensitive.
+func antreaTiers(tiers []*unstructured.Unstructured) map[string]float64 {
+	priorities := make(map[string]float64, len(antreaStaticTiers)+len(tiers))
+	for name, priority := range antreaStaticTiers {
+		priorities[name] = priority
+	}
+	for _, tier := range tiers {
+		spec, _ := nestedMapNoCopy(tier.Object, "spec")
+		if priority, found := calicoNumber(spec["priority"]); found {
+			priorities[strings.ToLower(tier.GetName())] = priority
+		}
+	}
+	return priorities
+}
+
+// newAntreaGroups indexes ClusterGroup and Group objects.
+func newAntreaGroups(groups []*unstructured.Unstructured) antreaGroups {
+	indexed := make(antreaGroups, len(groups))
+	for _, group := range groups {
+		spec, _ := nestedMapNoCopy(group.Object, "spec")
+		indexed[qualifiedName(ObjectReference{Namespace: group.GetNamespace(), Name: group.GetName()})] = spec
+	}
+	return indexed
+}
+
+// AntreaPolicyModels converts an Antrea ClusterNetworkPolicy or NetworkPolicy into the engine's policy model.
+// A policy applying its rules to different pods through rule level appliedTo yields one model per rule.
+// Policies are evaluated by the priority of their tier and then by their own priority, lower first. The first
+// matching Allow or Drop/Reject rule decides, and a Pass rule skips every remaining Antrea policy. Antrea-native
+// policies do not isolate pods by themselves, so a direction only counts as isolated when the policy has Drop or
+// Reject rules for it.
+func AntreaPolicyModels(policy *unstructured.Unstructured, tiers map[string]float64, groups antreaGroups) ([]PolicyModel, error) {
+	spec, _ := nestedMapNoCopy(policy.Object, "spec")
+	namespace := policy.GetNamespace()
+
+	tierName, _ := spec["tier"].(string)
+	tierName = strings.ToLower(tierName)
+	if tierName == "" {
+		tierName = antreaDefaultTier
+	}
+	tierPriority, found := tiers[tierName]
+	if !found {
+		return nil, fmt.Errorf("%s %s references unknown tier %q", policy.GetKind(), qualifiedName(ObjectReference{Namespace: namespace, Name: policy.GetName()}), tierName)
+	}
+
+	base := PolicyModel{
+		Engine:    PolicyTypeAntrea,
+		Kind:      policy.GetKind(),
+		Namespace: namespace,
+		Name:      policy.GetName(),
+		Tier:      tierName,
+		ordering: &policyOrdering{
+			tierPriority:   tierPriority,
+			baseline:       tierName == antreaBaselineTier,
+			defaultAction:  ActionPass,
+			passSkipsTiers: true,
+		},
+	}
+	if priority, found := calicoNumber(spec["priority"]); found {
+		base.Priority = &priority
+	}
+
+	var rules []PolicyRule
+	ruleAppliedTo := map[int][]interface{}{}
+	for _, direction := range []string{DirectionIngress, DirectionEgress} {
+		peerField := "from"
+		if direction == DirectionEgress {
+			peerField = "to"
+		}
+		rawRules, _ := nestedSliceNoCopy(spec, direction)
+		for i, rawRule := range rawRules {
+			rule, ok := rawRule.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			action, err := antreaAction(rule)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s rule %d of %s %s: %w", direction, i, base.Kind, base.ID(), err)
+			}
+
+			rawPeers, _ := nestedSliceNoCopy(rule, peerField)
+			peers, err := antreaPeers(namespace, rawPeers, groups)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s rule %d of %s %s: %w", direction, i, base.Kind, base.ID(), err)
+			}
+			if direction == DirectionEgress {
+				services, _ := nestedSliceNoCopy(rule, "toServices")
+				peers = append(peers, antreaServicePeers(namespace, services)...)
+			}
+			if len(peers) == 0 {
+				// A rule without peers applies to every peer
+				peers = []RulePeer{{Kind: PeerAny}}
+			}
+
+			rawPorts, _ := nestedSliceNoCopy(rule, "ports")
+			if appliedTo, found := nestedSliceNoCopy(rule, "appliedTo"); found {
+				ruleAppliedTo[len(rules)] = appliedTo
+			}
+			rules = append(rules, PolicyRule{
+				Policy:    base.ID(),
+				Direction: direction,
+				Index:     i,
+				Action:    action,
+				Peers:     peers,
+				Ports:     antreaPorts(rawPorts),
+			})
+		}
+	}
+
+	// Policy level appliedTo applies every rule to the same pods
+	if appliedTo, found := nestedSliceNoCopy(spec, "appliedTo"); found {
+		model, err := antreaModel(base, appliedTo, rules, groups)
+		if err != nil {
+			return nil, err
+		}
+		return []PolicyModel{model}, nil
+	}
+
+	models := []PolicyModel{}
+	for i, rule := range rules {
+		appliedTo, found := ruleAppliedTo[i]
+		if !found {
+			return nil, fmt.Errorf("%s %s needs appliedTo on the policy or on every rule", base.Kind, base.ID())
+		}
+		model, err := antreaModel(base, appliedTo, []PolicyRule{rule}, groups)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// antreaModel completes a policy model with the pods its rules apply to.
+func antreaModel(base PolicyModel, appliedTo []interface{}, rules []PolicyRule, groups antreaGroups) (PolicyModel, error) {
+	targets, err := antreaPeers(base.Namespace, appliedTo, groups)
+	if err != nil {
+		return PolicyModel{}, fmt.Errorf("error parsing appliedTo of %s %s: %w", base.Kind, base.ID(), err)
+	}
+
+	model := base
+	model.Rules = rules
+	descriptions := []string{}
+	for _, target := range targets {
+		descriptions = append(descriptions, target.String())
+	}
+	model.Selector = strings.Join(descriptions, ", ")
+	model.selects = func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+		for _, target := range targets {
+			if target.MatchesPod(pod, namespaceLabels) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, rule := range rules {
+		if rule.Action != ActionDeny {
+			continue
+		}
+		if rule.Direction == DirectionEgress {
+			model.IsolatesEgress = true
+		} else {
+			model.IsolatesIngress = true
+		}
+	}
+	return model, nil
+}
+
+// antreaAction maps the action of a rule to the engine's actions. Reject answers the peer but denies the traffic
+// like Drop.
+func antreaAction(rule map[string]interface{}) (string, error) {
+	action, _ := rule["action"].(string)
+	switch action {
+	case "Allow":
+		return ActionAllow, nil
+	case "Drop", "Reject":
+		return ActionDeny, nil
+	case "Pass":
+		return ActionPass, nil
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// antreaPeers converts the from, to or appliedTo peers of a policy: pod and namespace selectors, groups, IP
+// blocks, service accounts, nodes and FQDNs. Selectors of namespaced policies only match pods in the policy
+// namespace unless a namespace selector is given. Nodes, FQDNs and namespaces matched relative to the selected
+// pod are resolved by Antrea at runtime, so they are reported but never match a pod.
+func antreaPeers(policyNamespace string, rawPeers []interface{}, groups antreaGroups) ([]RulePeer, error) {
+	peers := []RulePeer{}
+	for _, rawPeer := range rawPeers {
+		peer, ok := rawPeer.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		_, hasPodSelector := peer["podSelector"]
+		_, hasNamespaceSelector := peer["namespaceSelector"]
+		if hasPodSelector || hasNamespaceSelector {
+			selectorPeer, err := antreaSelectorPeer(peer["podSelector"], peer["namespaceSelector"], policyNamespace)
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, selectorPeer)
+		}
+		if namespaces, found := nestedMapNoCopy(peer, "namespaces"); found {
+			match, _ := namespaces["match"].(string)
+			peers = append(peers, RulePeer{Kind: PeerNamespace, NamespaceSelector: "(" + strings.ToLower(match) + ")"})
+		}
+		if ipBlock, found := nestedMapNoCopy(peer, "ipBlock"); found {
+			cidr, _ := ipBlock["cidr"].(string)
+			except, _, _ := unstructured.NestedStringSlice(ipBlock, "except")
+			peers = append(peers, cidrPeer(cidr, except))
+		}
+		if group, found := peer["group"].(string); found {
+			groupPeers, err := antreaGroupPeers(policyNamespace, group, groups, 0)
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, groupPeers...)
+		}
+		if serviceAccount, found := nestedMapNoCopy(peer, "serviceAccount"); found {
+			peers = append(peers, antreaServiceAccountPeer(serviceAccount))
+		}
+		if rawNodes, found := peer["nodeSelector"]; found {
+			nodeSelector, err := adminLabelSelector(rawNodes)
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, RulePeer{Kind: PeerNodes, NodeSelector: selectorDescription(nodeSelector.String())})
+		}
+		if fqdn, found := peer["fqdn"].(string); found {
+			peers = append(peers, RulePeer{Kind: PeerFQDN, FQDN: fqdn})
+		}
+	}
+	return peers, nil
+}
+
+// antreaSelectorPeer builds a peer from a pod and a namespace selector. Without a namespace selector, pods are
+// matched in scopeNamespace, or in every namespace for cluster scoped policies and groups.
+func antreaSelectorPeer(rawPodSelector interface{}, rawNamespaceSelector interface{}, scopeNamespace string) (RulePeer, error) {
+	podSelector, namespaceSelector := labels.Everything(), labels.Everything()
+	peer := RulePeer{Kind: PeerPods, Namespace: scopeNamespace}
+
+	var err error
+	if rawPodSelector != nil {
+		if podSelector, err = adminLabelSelector(rawPodSelector); err != nil {
+			return RulePeer{}, err
+		}
+		peer.PodSelector = podSelector.String()
+	}
+	if rawNamespaceSelector != nil {
+		if namespaceSelector, err = adminLabelSelector(rawNamespaceSelector); err != nil {
+			return RulePeer{}, err
+		}
+		peer.Namespace = ""
+		peer.NamespaceSelector = selectorDescription(namespaceSelector.String())
+		if rawPodSelector == nil {
+			peer.Kind = PeerNamespace
+		}
+	} else if scopeNamespace == "" {
+		peer.NamespaceSelector = selectorDescription("")
+	}
+
+	scoped := rawNamespaceSelector == nil && scopeNamespace != ""
+	peer.matches = func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+		if scoped && pod.Namespace != scopeNamespace {
+			return false
+		}
+		return namespaceSelector.Matches(labels.Set(namespaceLabels)) && podSelector.Matches(labels.Set(pod.Labels))
+	}
+	return peer, nil
+}
+
+// antreaGroupPeers resolves a ClusterGroup, or a Group in the policy namespace, into peers. Child groups are
+// resolved recursively.
+func antreaGroupPeers(policyNamespace string, name string, groups antreaGroups, depth int) ([]RulePeer, error) {
+	key := qualifiedName(ObjectReference{Namespace: policyNamespace, Name: name})
+	spec, found := groups[key]
+	if !found {
+		return nil, fmt.Errorf("group %s not found", key)
+	}
+	if depth > 2 {
+		return nil, fmt.Errorf("group %s nests too deep", key)
+	}
+
+	peers := []RulePeer{}
+	_, hasPodSelector := spec["podSelector"]
+	_, hasNamespaceSelector := spec["namespaceSelector"]
+	if hasPodSelector || hasNamespaceSelector {
+		peer, err := antreaSelectorPeer(spec["podSelector"], spec["namespaceSelector"], policyNamespace)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, peer)
+	}
+
+	ipBlocks, _ := nestedSliceNoCopy(spec, "ipBlocks")
+	if ipBlock, found := nestedMapNoCopy(spec, "ipBlock"); found {
+		ipBlocks = append(ipBlocks, ipBlock)
+	}
+	for _, rawBlock := range ipBlocks {
+		if block, ok := rawBlock.(map[string]interface{}); ok {
+			cidr, _ := block["cidr"].(string)
+			except, _, _ := unstructured.NestedStringSlice(block, "except")
+			peers = append(peers, cidrPeer(cidr, except))
+		}
+	}
+
+	if reference, found := nestedMapNoCopy(spec, "serviceReference"); found {
+		peers = append(peers, antreaServicePeers(policyNamespace, []interface{}{reference})...)
+	}
+
+	childGroups, _, _ := unstructured.NestedStringSlice(spec, "childGroups")
+	for _, child := range childGroups {
+		childPeers, err := antreaGroupPeers(policyNamespace, child, groups, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, childPeers...)
+	}
+	return peers, nil
+}
+
+// antreaServiceAccountPeer matches the pods running as a service account.
+func antreaServiceAccountPeer(serviceAccount map[string]interface{}) RulePeer {
+	name, _ := serviceAccount["name"].(string)
+	namespace, _ := serviceAccount["namespace"].(string)
+	return RulePeer{
+		Kind:        PeerPods,
+		Namespace:   namespace,
+		PodSelector: "serviceaccount=" + name,
+		matches: func(pod corev1.Pod, _ map[string]string) bool {
+			return pod.Namespace == namespace && podServiceAccount(pod) == name
+		},
+	}
+}
+
+// antreaServicePeers reports the services of toServices or a group's serviceReference. Their endpoints are
+// resolved by Antrea at runtime.
+func antreaServicePeers(policyNamespace string, services []interface{}) []RulePeer {
+	peers := []RulePeer{}
+	for _, rawService := range services {
+		service, ok := rawService.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := service["name"].(string)
+		namespace, _ := service["namespace"].(string)
+		if namespace == "" {
+			namespace = policyNamespace
+		}
+		peers = append(peers, RulePeer{Kind: PeerService, Namespace: namespace, Service: qualifiedName(ObjectReference{Namespace: namespace, Name: name})})
+	}
+	return peers
+}
+
+// antreaPorts converts the ports of a rule. A port may be a number or a named port, and the protocol defaults to
+// TCP. No ports means every port.
+func antreaPorts(rawPorts []interface{}) []RulePort {
+	rulePorts := []RulePort{}
+	for _, rawPort := range rawPorts {
+		port, ok := rawPort.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rulePort := RulePort{Protocol: adminProtocol(port)}
+		switch value := port["port"].(type) {
+		case nil:
+		case string:
+			rulePort.Port = value
+		default:
+			number, _ := calicoNumber(value)
+			rulePort.Port = fmt.Sprint(int(number))
+		}
+		if endPort, found := calicoNumber(port["endPort"]); found {
+			rulePort.EndPort = int32(endPort)
+		}
+		rulePorts = append(rulePorts, rulePort)
+	}
+	return rulePorts
+}
@@ -0,0 +1,234 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// antreaTestObject builds an Antrea-native object, namespaced when namespace is set.
+func antreaTestObject(kind, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "crd.antrea.io/v1beta1",
+		"kind":       kind,
+		"metadata":   metadata,
+		"spec":       spec,
+	}}
+}
+
+// podsLabelled selects pods by a single label.
+func podsLabelled(key, value string) map[string]interface{} {
+	return map[string]interface{}{"matchLabels": map[string]interface{}{key: value}}
+}
+
+func TestAntreaPolicyModels(t *testing.T) {
+	groups := newAntreaGroups([]*unstructured.Unstructured{
+		antreaTestObject("ClusterGroup", "", "frontends", map[string]interface{}{
+			"childGroups": []interface{}{"web", "office"},
+		}),
+		antreaTestObject("ClusterGroup", "", "web", map[string]interface{}{
+			"podSelector":       podsLabelled("app", "web"),
+			"namespaceSelector": namespaceNamed("shop"),
+		}),
+		antreaTestObject("ClusterGroup", "", "office", map[string]interface{}{
+			"ipBlocks": []interface{}{map[string]interface{}{"cidr": "192.168.0.0/16"}},
+		}),
+	})
+	tiers := antreaTiers([]*unstructured.Unstructured{
+		antreaTestObject("Tier", "", "Compliance", map[string]interface{}{"priority": int64(10)}),
+	})
+
+	policy := antreaTestObject(AntreaClusterNetworkPolicyKind, "", "protect-db", map[string]interface{}{
+		"tier":      "compliance",
+		"priority":  5.5,
+		"appliedTo": []interface{}{map[string]interface{}{"podSelector": podsLabelled("app", "db")}},
+		"ingress": []interface{}{
+			map[string]interface{}{
+				"action": "Allow",
+				"from":   []interface{}{map[string]interface{}{"group": "frontends"}},
+				"ports":  []interface{}{map[string]interface{}{"protocol": "TCP", "port": int64(5432)}, map[string]interface{}{"port": "metrics"}},
+			},
+			map[string]interface{}{"action": "Reject"},
+		},
+		"egress": []interface{}{
+			map[string]interface{}{
+				"action": "Pass",
+				"to": []interface{}{
+					map[string]interface{}{"fqdn": "*.example.com"},
+					map[string]interface{}{"nodeSelector": map[string]interface{}{}},
+					map[string]interface{}{"serviceAccount": map[string]interface{}{"name": "backup", "namespace": "ops"}},
+				},
+				"toServices": []interface{}{map[string]interface{}{"name": "dns", "namespace": "kube-system"}},
+			},
+		},
+	})
+
+	models, err := AntreaPolicyModels(policy, tiers, groups)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	model := models[0]
+	assert.Equal(t, PolicyTypeAntrea, model.Engine)
+	assert.Equal(t, "compliance", model.Tier)
+	assert.Equal(t, 10.0, model.ordering.tierPriority)
+	assert.True(t, model.ordering.passSkipsTiers)
+	assert.Equal(t, 5.5, *model.Priority)
+	assert.True(t, model.IsolatesIngress, "reject rules isolate")
+	assert.False(t, model.IsolatesEgress, "pass rules alone do not isolate")
+	assert.Equal(t, "pods app=db in namespaces (all)", model.Selector)
+
+	db := testPod("shop", "db-0", "10.0.0.1", map[string]string{"app": "db"})
+	web := testPod("shop", "web-0", "10.0.0.2", map[string]string{"app": "web"})
+	shopLabels := map[string]string{"kubernetes.io/metadata.name": "shop"}
+	assert.True(t, model.Selects(db, shopLabels))
+	assert.False(t, model.Selects(web, shopLabels))
+
+	ingress := model.RulesFor(DirectionIngress)
+	assert.Equal(t, ActionAllow, ingress[0].Action)
+	assert.Equal(t, []RulePort{{Protocol: "TCP", Port: "5432"}, {Protocol: "TCP", Port: "metrics"}}, ingress[0].Ports)
+	assert.True(t, ingress[0].Peers[0].MatchesPod(web, shopLabels), "child groups are resolved")
+	assert.False(t, ingress[0].Peers[0].MatchesPod(web, map[string]string{"kubernetes.io/metadata.name": "tenant"}))
+	assert.True(t, ingress[0].Peers[1].MatchesIP("192.168.1.1"))
+	assert.Equal(t, ActionDeny, ingress[1].Action)
+	assert.Equal(t, []RulePeer{{Kind: PeerAny}}, ingress[1].Peers)
+
+	descriptions := []string{}
+	for _, peer := range model.RulesFor(DirectionEgress)[0].Peers {
+		descriptions = append(descriptions, peer.String())
+	}
+	assert.Equal(t, []string{"fqdn *.example.com", "nodes (all)", "pods serviceaccount=backup in namespace ops", "service kube-system/dns"}, descriptions)
+
+	_, err = AntreaPolicyModels(antreaTestObject(AntreaClusterNetworkPolicyKind, "", "unknown-tier", map[string]interface{}{
+		"tier":      "missing",
+		"appliedTo": []interface{}{map[string]interface{}{"podSelector": map[string]interface{}{}}},
+	}), tiers, groups)
+	assert.Error(t, err)
+}
+
+func TestAntreaPolicyModelsRuleAppliedTo(t *testing.T) {
+	policy := antreaTestObject(AntreaNetworkPolicyKind, "shop", "per-rule", map[string]interface{}{
+		"priority": int64(1),
+		"ingress": []interface{}{
+			map[string]interface{}{
+				"action":    "Allow",
+				"appliedTo": []interface{}{map[string]interface{}{"podSelector": podsLabelled("app", "db")}},
+				"from":      []interface{}{map[string]interface{}{"podSelector": podsLabelled("app", "web")}},
+			},
+			map[string]interface{}{
+				"action":    "Drop",
+				"appliedTo": []interface{}{map[string]interface{}{"podSelector": map[string]interface{}{}}},
+			},
+		},
+	})
+
+	models, err := AntreaPolicyModels(policy, antreaTiers(nil), antreaGroups{})
+	assert.NoError(t, err)
+	assert.Len(t, models, 2)
+	assert.Equal(t, antreaDefaultTier, models[0].Tier)
+
+	db := testPod("shop", "db-0", "10.0.0.1", map[string]string{"app": "db"})
+	web := testPod("shop", "web-0", "10.0.0.2", map[string]string{"app": "web"})
+	shopLabels := map[string]string{"kubernetes.io/metadata.name": "shop"}
+	assert.True(t, models[0].Selects(db, shopLabels))
+	assert.False(t, models[0].Selects(web, shopLabels))
+	assert.True(t, models[1].Selects(web, shopLabels))
+	assert.False(t, models[1].Selects(testPod("tenant", "app-0", "10.0.1.1", nil), nil), "namespaced policies only apply to their namespace")
+	assert.True(t, models[0].RulesFor(DirectionIngress)[0].Peers[0].MatchesPod(web, shopLabels))
+
+	_, err = AntreaPolicyModels(antreaTestObject(AntreaNetworkPolicyKind, "shop", "no-targets", map[string]interface{}{
+		"ingress": []interface{}{map[string]interface{}{"action": "Allow"}},
+	}), antreaTiers(nil), antreaGroups{})
+	assert.Error(t, err)
+}
+
+func TestCanReachAntreaTiers(t *testing.T) {
+	antreaPolicies := []*unstructured.Unstructured{
+		antreaTestObject(AntreaClusterNetworkPolicyKind, "", "allow-monitoring", map[string]interface{}{
+			"tier":      "securityops",
+			"priority":  int64(1),
+			"appliedTo": []interface{}{map[string]interface{}{"namespaceSelector": map[string]interface{}{}}},
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Allow", "from": []interface{}{map[string]interface{}{"namespaceSelector": namespaceNamed("monitoring")}}},
+			},
+		}),
+		antreaTestObject(AntreaClusterNetworkPolicyKind, "", "isolate-shop", map[string]interface{}{
+			"tier":      "platform",
+			"priority":  int64(1),
+			"appliedTo": []interface{}{map[string]interface{}{"namespaceSelector": namespaceNamed("shop")}},
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Pass", "from": []interface{}{map[string]interface{}{"namespaceSelector": namespaceNamed("shop")}}},
+				map[string]interface{}{"action": "Drop", "from": []interface{}{map[string]interface{}{"namespaceSelector": namespaceNamed("tenant")}}},
+			},
+		}),
+		antreaTestObject(AntreaNetworkPolicyKind, "shop", "allow-tenant", map[string]interface{}{
+			"priority":  int64(1),
+			"appliedTo": []interface{}{map[string]interface{}{"podSelector": map[string]interface{}{}}},
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Allow", "from": []interface{}{map[string]interface{}{"namespaceSelector": map[string]interface{}{}}}},
+			},
+		}),
+		antreaTestObject(AntreaClusterNetworkPolicyKind, "", "default-deny", map[string]interface{}{
+			"tier":      "baseline",
+			"priority":  int64(1),
+			"appliedTo": []interface{}{map[string]interface{}{"namespaceSelector": map[string]interface{}{}}},
+			"ingress":   []interface{}{map[string]interface{}{"action": "Drop"}},
+		}),
+	}
+
+	var policies []PolicyModel
+	for _, policy := range antreaPolicies {
+		models, err := AntreaPolicyModels(policy, antreaTiers(nil), antreaGroups{})
+		assert.NoError(t, err)
+		policies = append(policies, models...)
+	}
+
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	db := testPod("shop", "db-0", "10.0.0.2", map[string]string{"app": "db"})
+	prometheus := testPod("monitoring", "prometheus-0", "10.0.1.1", map[string]string{"app": "prometheus"})
+	attacker := testPod("tenant", "app-0", "10.0.2.1", map[string]string{"app": "app"})
+	namespaces := []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "monitoring"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}},
+	}
+	webPolicy := netv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db-from-web"},
+		Spec: netv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Ingress:     []netv1.NetworkPolicyIngressRule{{From: []netv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}}}},
+		},
+	}
+	nativeModel, err := NativePolicyModel(webPolicy)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		policies    []PolicyModel
+		source      corev1.Pod
+		destination corev1.Pod
+		allowed     bool
+		reason      string
+	}{
+		{"higher tier allow wins", policies, prometheus, db, true, "allowed by allow-monitoring"},
+		{"drop before the application tier", policies, attacker, db, false, "denied by isolate-shop"},
+		{"pass skips the application tier", policies, web, db, false, "denied by default-deny"},
+		{"pass reaches network policies", append(append([]PolicyModel{}, policies...), nativeModel), web, db, true, "allowed by shop/db-from-web"},
+		{"baseline denies other traffic", policies, web, prometheus, false, "denied by default-deny"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewClusterState([]corev1.Pod{web, db, prometheus, attacker}, namespaces, tt.policies)
+			verdict := state.CanReach(tt.source, tt.destination, 80, "TCP")
+			assert.Equal(t, tt.allowed, verdict.Allowed)
+			assert.Equal(t, tt.reason, verdict.Ingress.Reason)
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// antreaGroupVersion is the version of the Antrea-native policy APIs read by the scanner
+var antreaGroupVersion = schema.GroupVersion{Group: "crd.antrea.io", Version: "v1beta1"}
+
+// errAntreaNotInstalled is returned when the Antrea-native policy APIs are not served by the cluster
+var errAntreaNotInstalled = errors.New("Antrea-native policy APIs (crd.antrea.io) are not installed in this cluster")
+
+// Antrea resources read by the scanner
+const (
+	antreaClusterNetworkPolicies = "clusternetworkpolicies"
+	antreaNetworkPolicies        = "networkpolicies"
+	antreaTiersResource          = "tiers"
+	antreaClusterGroupsResource  = "clustergroups"
+	antreaGroupsResource         = "groups"
+)
+
+// antreaCatalog holds the tiers and groups Antrea policies refer to.
+type antreaCatalog struct {
+	tiers  map[string]float64
+	groups antreaGroups
+}
+
+// fetchAntreaObjects lists an Antrea resource in a namespace, or in every namespace if namespace is empty.
+// It reports whether the API exists.
+func fetchAntreaObjects(dynamicClient dynamic.Interface, resource string, namespace string) ([]*unstructured.Unstructured, bool, error) {
+	list, err := dynamicClient.Resource(antreaGroupVersion.WithResource(resource)).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("error listing %s.%s: %w", resource, antreaGroupVersion.Group, err)
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(list.Items))
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+	return objects, true, nil
+}
+
+// loadAntreaCatalog reads the Tiers, ClusterGroups and Groups of the cluster.
+func loadAntreaCatalog(dynamicClient dynamic.Interface) (antreaCatalog, error) {
+	tiers, _, err := fetchAntreaObjects(dynamicClient, antreaTiersResource, metav1.NamespaceAll)
+	if err != nil {
+		return antreaCatalog{}, err
+	}
+	clusterGroups, _, err := fetchAntreaObjects(dynamicClient, antreaClusterGroupsResource, metav1.NamespaceAll)
+	if err != nil {
+		return antreaCatalog{}, err
+	}
+	groups, _, err := fetchAntreaObjects(dynamicClient, antreaGroupsResource, metav1.NamespaceAll)
+	if err != nil {
+		return antreaCatalog{}, err
+	}
+	return antreaCatalog{tiers: antreaTiers(tiers), groups: newAntreaGroups(append(clusterGroups, groups...))}, nil
+}
+
+// antreaPolicyModels converts Antrea policies into the engine's policy model. Policies that cannot be parsed are
+// skipped and returned as errors.
+func antreaPolicyModels(policies []*unstructured.Unstructured, catalog antreaCatalog) ([]PolicyModel, []error) {
+	var models []PolicyModel
+	var errs []error
+	for _, policy := range policies {
+		policyModels, err := AntreaPolicyModels(policy, catalog.tiers, catalog.groups)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		models = append(models, policyModels...)
+	}
+	return models, errs
+}
+
+// LoadAntreaPolicyModels reads the Antrea ClusterNetworkPolicies and NetworkPolicies of the cluster, with the
+// Tiers and groups they refer to, and converts them into the engine's policy model. Policies that cannot be
+// parsed are skipped and returned as errors. It fails when Antrea's policy APIs are not installed.
+func LoadAntreaPolicyModels(dynamicClient dynamic.Interface) ([]PolicyModel, []error, error) {
+	catalog, err := loadAntreaCatalog(dynamicClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var models []PolicyModel
+	var errs []error
+	anyInstalled := false
+	for _, resource := range []string{antreaClusterNetworkPolicies, antreaNetworkPolicies} {
+		policies, installed, err := fetchAntreaObjects(dynamicClient, resource, metav1.NamespaceAll)
+		if err != nil {
+			return nil, nil, err
+		}
+		anyInstalled = anyInstalled || installed
+		policyModels, policyErrs := antreaPolicyModels(policies, catalog)
+		models = append(models, policyModels...)
+		errs = append(errs, policyErrs...)
+	}
+
+	if !anyInstalled {
+		return nil, nil, errAntreaNotInstalled
+	}
+	return models, errs, nil
+}
+
+// fetchAntreaPolicies fetches all Antrea NetworkPolicies within the specified namespace.
+func fetchAntreaPolicies(dynamicClient dynamic.Interface, nsName string, writer *bufio.Writer) ([]*unstructured.Unstructured, error) {
+	policies, _, err := fetchAntreaObjects(dynamicClient, antreaNetworkPolicies, nsName)
+	if err != nil {
+		printToBoth(writer, fmt.Sprintf("Error listing Antrea network policies in namespace %s: %s\n", nsName, err))
+		return nil, fmt.Errorf("error listing Antrea network policies: %w", err)
+	}
+	return policies, nil
+}
+
+// determineAntreaPodCoverage evaluates the Antrea policies against the running pods of a namespace. It returns
+// the evaluations, the unprotected pods and whether a policy denies all traffic of the namespace by default.
+func determineAntreaPodCoverage(clientset kubernetes.Interface, nsName string, models []PolicyModel, writer *bufio.Writer) ([]PodPolicyEvaluation, []string, bool, error) {
+	pods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		printToBoth(writer, fmt.Sprintf("Error listing all pods in namespace %s: %s\n", nsName, err))
+		return nil, nil, false, fmt.Errorf("error listing all pods: %w", err)
+	}
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.TODO(), nsName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("error getting namespace %s: %w", nsName, err)
+	}
+
+	evaluations := EvaluatePods(scannedPods(pods.Items), []corev1.Namespace{*namespace}, models)
+	sortEvaluations(evaluations)
+
+	unprotectedPods := []string{}
+	for _, evaluation := range evaluations {
+		if evaluation.HasFinding(FindingUnprotected) {
+			unprotectedPods = append(unprotectedPods, fmt.Sprintf("%s %s %s", evaluation.Namespace, evaluation.Name, evaluation.IP))
+		}
+	}
+
+	return evaluations, unprotectedPods, namespaceHasEngineDefaultDeny(models, PolicyTypeAntrea, *namespace), nil
+}
+
+// processNamespacePoliciesAntrea processes the Antrea NetworkPolicies of a namespace, together with the
+// ClusterNetworkPolicies, to identify unprotected pods and what every pod is exposed to.
+func processNamespacePoliciesAntrea(dynamicClient dynamic.Interface, clientset kubernetes.Interface, nsName string, clusterModels []PolicyModel, catalog antreaCatalog, writer *bufio.Writer, scanResult *ScanResult, dryRun bool, isCLI bool) error {
+	antreaPolicies, err := fetchAntreaPolicies(dynamicClient, nsName, writer)
+	if err != nil {
+		return err
+	}
+
+	models, errs := antreaPolicyModels(antreaPolicies, catalog)
+	for _, err := range errs {
+		printToBoth(writer, fmt.Sprintf("Error evaluating policy: %s\n", err))
+	}
+	models = append(models, clusterModels...)
+
+	evaluations, unprotectedPods, hasDenyAll, err := determineAntreaPodCoverage(clientset, nsName, models, writer)
+	if err != nil {
+		return err
+	}
+	unprotectedPods = acceptUnprotectedPods(unprotectedPods, scanResult)
+	acceptPodFindings(evaluations, scanResult)
+	scanResult.PodEvaluations = append(scanResult.PodEvaluations, evaluations...)
+
+	if hasDenyAll && !contains(scanResult.HasDenyAll, nsName) {
+		scanResult.HasDenyAll = append(scanResult.HasDenyAll, nsName)
+	}
+
+	if len(unprotectedPods) > 0 {
+		scanResult.UnprotectedPods = append(scanResult.UnprotectedPods, unprotectedPods...)
+
+		if isCLI && !dryRun {
+			if err := handleCLIInteractionsAntrea(nsName, unprotectedPods, dynamicClient, writer, scanResult); err != nil {
+				return err
+			}
+		} else if dryRun {
+			displayUnprotectedPods(nsName, unprotectedPods, writer)
+		}
+	}
+	if isCLI {
+		displayPodExposure(nsName, evaluations, writer)
+	}
+
+	return nil
+}
+
+// handleCLIInteractionsAntrea lists the unprotected pods of a namespace and offers to apply a default deny policy.
+func handleCLIInteractionsAntrea(nsName string, unprotectedPods []string, dynamicClient dynamic.Interface, writer *bufio.Writer, scanResult *ScanResult) error {
+	displayUnprotectedPods(nsName, unprotectedPods, writer)
+	if !interactive {
+		return nil
+	}
+
+	confirm := false
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Do you want to add a default deny all Antrea cluster network policy for the namespace %s?", nsName),
+	}
+	if err := survey.AskOne(prompt, &confirm, nil); err != nil {
+		return fmt.Errorf("failed to prompt for policy application: %s", err)
+	}
+
+	if confirm {
+		if err := CreateAndApplyDefaultDenyAntreaPolicy(nsName, dynamicClient); err != nil {
+			return fmt.Errorf("failed to apply default deny Antrea policy in namespace %s: %s", nsName, err)
+		}
+		scanResult.PolicyChangesMade = true
+	} else {
+		scanResult.UserDeniedPolicies = true
+	}
+	return nil
+}
+
+// CreateAndApplyDefaultDenyAntreaPolicy creates an Antrea ClusterNetworkPolicy in the baseline tier that applies to
+// every pod of the namespace and drops all ingress and egress traffic not allowed by other policies.
+func CreateAndApplyDefaultDenyAntreaPolicy(namespace string, dynamicClient dynamic.Interface) error {
+	policyName := namespace + "-antrea-default-deny-all"
+	denyAllPolicy := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": antreaGroupVersion.String(),
+			"kind":       AntreaClusterNetworkPolicyKind,
+			"metadata": map[string]interface{}{
+				"name": policyName,
+			},
+			"spec": map[string]interface{}{
+				"tier":     antreaBaselineTier,
+				"priority": int64(100),
+				"appliedTo": []interface{}{
+					map[string]interface{}{
+						"namespaceSelector": map[string]interface{}{
+							"matchLabels": map[string]interface{}{"kubernetes.io/metadata.name": namespace},
+						},
+					},
+				},
+				"ingress": []interface{}{map[string]interface{}{"action": "Drop"}},
+				"egress":  []interface{}{map[string]interface{}{"action": "Drop"}},
+			},
+		},
+	}
+
+	resource := antreaGroupVersion.WithResource(antreaClusterNetworkPolicies)
+	_, err := dynamicClient.Resource(resource).Create(context.TODO(), denyAllPolicy, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create default deny all Antrea ClusterNetworkPolicy: %v", err)
+	}
+
+	fmt.Printf("Applied default deny all Antrea ClusterNetworkPolicy to namespace %s\n", namespace)
+	return nil
+}
+
+// ScanAntreaNetworkPolicies scans namespaces for Antrea ClusterNetworkPolicies and NetworkPolicies
+func ScanAntreaNetworkPolicies(specificNamespace string, dryRun bool, returnResult bool, isCLI bool, printScore bool, printMessages bool, kubeconfigPath string) (*ScanResult, error) {
+	var output bytes.Buffer
+
+	scanResult := &ScanResult{PolicyType: PolicyTypeAntrea}
+
+	writer := bufio.NewWriter(&output)
+
+	dynamicClient, clientset, err := initializeDynamicClients(kubeconfigPath)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	namespacesToScan, err := SelectNamespaces(clientset, specificNamespace)
+	if err != nil {
+		return nil, err
+	}
+	scanResult.NamespacesScanned = namespacesToScan
+	if err := useWaivers(clientset, scanResult); err != nil {
+		printToBoth(writer, fmt.Sprintf("Error loading waivers: %s\n", err))
+	}
+
+	catalog, err := loadAntreaCatalog(dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+	clusterPolicies, installed, err := fetchAntreaObjects(dynamicClient, antreaClusterNetworkPolicies, metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	if !installed {
+		return nil, errAntreaNotInstalled
+	}
+	clusterModels, errs := antreaPolicyModels(clusterPolicies, catalog)
+	for _, err := range errs {
+		printToBoth(writer, fmt.Sprintf("Error evaluating policy: %s\n", err))
+	}
+
+	if isCLI {
+		fmt.Println("Policy type: Antrea")
+	}
+
+	for _, nsName := range namespacesToScan {
+		if err := processNamespacePoliciesAntrea(dynamicClient, clientset, nsName, clusterModels, catalog, writer, scanResult, dryRun, isCLI); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if isCLI && output.Len() > 0 {
+		handleOutputAndPromptsAntrea(writer, &output)
+	}
+
+	if printMessages {
+		printToBoth(writer, "\nNetfetch scan completed!\n")
+	}
+
+	scoreResult(scanResult, printScore)
+
+	return scanResult, nil
+}
+
+func handleOutputAndPromptsAntrea(writer *bufio.Writer, output *bytes.Buffer) {
+	if !interactive {
+		return
+	}
+	saveToFile := false
+	prompt := &survey.Confirm{
+		Message: "Do you want to save the output to netfetch-antrea.txt?",
+	}
+	survey.AskOne(prompt, &saveToFile, nil)
+
+	if saveToFile {
+		err := os.WriteFile("netfetch-antrea.txt", output.Bytes(), 0644)
+		if err != nil {
+			printToBoth(writer, fmt.Sprintf("Error writing to file: %s\n", err))
+		} else {
+			printToBoth(writer, "Output file created: netfetch-antrea.txt\n")
+		}
+	} else {
+		printToBoth(writer, "Output file not created.\n")
+	}
+}
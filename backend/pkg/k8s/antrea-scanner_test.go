@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestLoadAntreaPolicyModels(t *testing.T) {
+	objects := []runtime.Object{
+		antreaTestObject("Tier", "", "compliance", map[string]interface{}{"priority": int64(10)}),
+		antreaTestObject("Group", "shop", "web", map[string]interface{}{"podSelector": podsLabelled("app", "web")}),
+		antreaTestObject(AntreaClusterNetworkPolicyKind, "", "compliance", map[string]interface{}{
+			"tier":      "compliance",
+			"priority":  int64(1),
+			"appliedTo": []interface{}{map[string]interface{}{"namespaceSelector": map[string]interface{}{}}},
+			"egress":    []interface{}{map[string]interface{}{"action": "Drop", "to": []interface{}{map[string]interface{}{"ipBlock": map[string]interface{}{"cidr": "169.254.169.254/32"}}}}},
+		}),
+		antreaTestObject(AntreaNetworkPolicyKind, "shop", "db", map[string]interface{}{
+			"priority":  int64(1),
+			"appliedTo": []interface{}{map[string]interface{}{"podSelector": podsLabelled("app", "db")}},
+			"ingress":   []interface{}{map[string]interface{}{"action": "Allow", "from": []interface{}{map[string]interface{}{"group": "web"}}}},
+		}),
+		antreaTestObject(AntreaNetworkPolicyKind, "shop", "broken", map[string]interface{}{
+			"appliedTo": []interface{}{map[string]interface{}{"group": "missing"}},
+		}),
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, objects...)
+
+	models, errs, err := LoadAntreaPolicyModels(dynamicClient)
+	assert.NoError(t, err)
+	assert.Len(t, errs, 1)
+	assert.Len(t, models, 2)
+
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	for _, model := range models {
+		if model.Name == "db" {
+			assert.True(t, model.Rules[0].Peers[0].MatchesPod(web, nil))
+		} else {
+			assert.Equal(t, "compliance", model.Tier)
+			assert.Equal(t, 10.0, model.ordering.tierPriority)
+		}
+	}
+
+	policies, err := fetchAntreaPolicies(dynamicClient, "shop", nil)
+	assert.NoError(t, err)
+	assert.Len(t, policies, 2)
+}
+
+func TestAntreaNamespaceHasDefaultDeny(t *testing.T) {
+	shop := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	dropAll := []interface{}{map[string]interface{}{"action": "Drop"}}
+	appliedToShop := []interface{}{map[string]interface{}{"namespaceSelector": namespaceNamed("shop")}}
+
+	tests := []struct {
+		name     string
+		spec     map[string]interface{}
+		expected bool
+	}{
+		{"drops both directions", map[string]interface{}{"tier": "baseline", "appliedTo": appliedToShop, "ingress": dropAll, "egress": dropAll}, true},
+		{"drops ingress only", map[string]interface{}{"tier": "baseline", "appliedTo": appliedToShop, "ingress": dropAll}, false},
+		{"drops some ports", map[string]interface{}{"tier": "baseline", "appliedTo": appliedToShop, "ingress": dropAll, "egress": []interface{}{
+			map[string]interface{}{"action": "Drop", "ports": []interface{}{map[string]interface{}{"port": int64(25)}}},
+		}}, false},
+		{"some pods", map[string]interface{}{"appliedTo": []interface{}{map[string]interface{}{"podSelector": podsLabelled("app", "db")}}, "ingress": dropAll, "egress": dropAll}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models, err := AntreaPolicyModels(antreaTestObject(AntreaClusterNetworkPolicyKind, "", "default-deny", tt.spec), antreaTiers(nil), antreaGroups{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, namespaceHasEngineDefaultDeny(models, PolicyTypeAntrea, shop))
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package k8s
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Labels Calico adds to the endpoints, namespaces and service accounts it matches selectors against
+const (
+	calicoNamespaceLabel      = "projectcalico.org/namespace"
+	calicoOrchestratorLabel   = "projectcalico.org/orchestrator"
+	calicoServiceAccountLabel = "projectcalico.org/serviceaccount"
+	calicoNameLabel           = "projectcalico.org/name"
+)
+
+// Tiers a Calico policy belongs to when it does not name one. The default tier also holds the Kubernetes
+// NetworkPolicies, so it hands isolation over to the NetworkPolicy layer instead of denying at its end.
+const (
+	calicoDefaultTier      = "default"
+	calicoDefaultTierOrder = 1000000
+)
+
+// calicoTier is the order and end of tier action of a Calico Tier.
+type calicoTier struct {
+	order         float64
+	defaultAction string
+}
+
+// calicoTiers indexes Tier objects by name. Tiers without an order are evaluated after every ordered tier.
+func calicoTiers(tiers []*unstructured.Unstructured) map[string]calicoTier {
+	indexed := map[string]calicoTier{calicoDefaultTier: {order: calicoDefaultTierOrder}}
+	for _, tier := range tiers {
+		spec, _ := nestedMapNoCopy(tier.Object, "spec")
+		order, found := calicoNumber(spec["order"])
+		if !found {
+			order = math.Inf(1)
+		}
+		defaultAction := ActionDeny
+		if action, _ := spec["defaultAction"].(string); action == ActionPass {
+			defaultAction = ActionPass
+		}
+		if tier.GetName() == calicoDefaultTier {
+			defaultAction = ""
+		}
+		indexed[tier.GetName()] = calicoTier{order: order, defaultAction: defaultAction}
+	}
+	return indexed
+}
+
+// CalicoPolicyModel converts a Calico NetworkPolicy or GlobalNetworkPolicy, from either projectcalico.org/v3 or
+// crd.projectcalico.org/v1, into the engine's policy model. Rules are evaluated in order within the tier of the
+// policy; Log rules do not affect the verdict and are skipped. Negated ports and protocols and HTTP match
+// criteria are not evaluated.
+func CalicoPolicyModel(policy *unstructured.Unstructured, tiers map[string]calicoTier) (PolicyModel, error) {
+	spec, _ := nestedMapNoCopy(policy.Object, "spec")
+	namespace := policy.GetNamespace()
+
+	tierName, _ := spec["tier"].(string)
+	if tierName == "" {
+		tierName = calicoDefaultTier
+	}
+	tier, found := tiers[tierName]
+	if !found {
+		// Policies of unknown tiers are treated like ordered tiers without an order
+		tier = calicoTier{order: math.Inf(1), defaultAction: ActionDeny}
+	}
+
+	model := PolicyModel{
+		Engine:    PolicyTypeCalico,
+		Kind:      policy.GetKind(),
+		Namespace: namespace,
+		Name:      policy.GetName(),
+		Tier:      tierName,
+		Rules:     []PolicyRule{},
+		ordering:  &policyOrdering{tierPriority: tier.order, defaultAction: tier.defaultAction},
+	}
+	if order, found := calicoNumber(spec["order"]); found {
+		model.Priority = &order
+	}
+
+	selector, err := calicoSelectorField(spec, "selector")
+	if err != nil {
+		return PolicyModel{}, fmt.Errorf("error parsing %s %s: %w", model.Kind, model.ID(), err)
+	}
+	serviceAccountSelector, err := calicoSelectorField(spec, "serviceAccountSelector")
+	if err != nil {
+		return PolicyModel{}, fmt.Errorf("error parsing %s %s: %w", model.Kind, model.ID(), err)
+	}
+	namespaceSelector, err := calicoSelectorField(spec, "namespaceSelector")
+	if err != nil {
+		return PolicyModel{}, fmt.Errorf("error parsing %s %s: %w", model.Kind, model.ID(), err)
+	}
+
+	model.Selector = selector.String()
+	if _, found := spec["namespaceSelector"]; found && namespace == "" {
+		model.Selector += " in namespaces " + namespaceSelector.String()
+	}
+	model.selects = func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+		if namespace != "" && pod.Namespace != namespace {
+			return false
+		}
+		if namespace == "" && !namespaceSelector.Matches(calicoNamespaceLabels(pod.Namespace, namespaceLabels)) {
+			return false
+		}
+		return selector.Matches(calicoEndpointLabels(pod)) && serviceAccountSelector.Matches(calicoServiceAccountLabels(pod))
+	}
+
+	ingress, _ := nestedSliceNoCopy(spec, "ingress")
+	egress, _ := nestedSliceNoCopy(spec, "egress")
+	types, found, _ := unstructured.NestedStringSlice(spec, "types")
+	if !found || len(types) == 0 {
+		// Calico defaults to Ingress, plus Egress when the policy has egress rules, or only Egress without ingress rules
+		types = []string{"Ingress"}
+		if len(egress) > 0 {
+			types = []string{"Egress"}
+			if len(ingress) > 0 {
+				types = []string{"Ingress", "Egress"}
+			}
+		}
+	}
+	for _, policyType := range types {
+		switch policyType {
+		case "Ingress":
+			model.IsolatesIngress = true
+		case "Egress":
+			model.IsolatesEgress = true
+		}
+	}
+
+	sections := []struct {
+		rules     []interface{}
+		direction string
+		isolated  bool
+		peerField string
+	}{
+		{ingress, DirectionIngress, model.IsolatesIngress, "source"},
+		{egress, DirectionEgress, model.IsolatesEgress, "destination"},
+	}
+	for _, section := range sections {
+		if !section.isolated {
+			continue
+		}
+		for i, rawRule := range section.rules {
+			rule, ok := rawRule.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			action, err := calicoAction(rule)
+			if err != nil {
+				return PolicyModel{}, fmt.Errorf("error parsing %s rule %d of %s %s: %w", section.direction, i, model.Kind, model.ID(), err)
+			}
+			if action == "" {
+				continue
+			}
+
+			entity, _ := nestedMapNoCopy(rule, section.peerField)
+			peers, err := calicoPeers(namespace, entity)
+			if err != nil {
+				return PolicyModel{}, fmt.Errorf("error parsing %s rule %d of %s %s: %w", section.direction, i, model.Kind, model.ID(), err)
+			}
+			destination, _ := nestedMapNoCopy(rule, "destination")
+			model.Rules = append(model.Rules, PolicyRule{
+				Policy:    model.ID(),
+				Direction: section.direction,
+				Index:     i,
+				Action:    action,
+				Peers:     peers,
+				Ports:     calicoPorts(rule["protocol"], destination),
+			})
+		}
+	}
+
+	return model, nil
+}
+
+// calicoAction maps the action of a rule to the engine's actions. Log rules return an empty action.
+func calicoAction(rule map[string]interface{}) (string, error) {
+	action, _ := rule["action"].(string)
+	switch action {
+	case "Allow":
+		return ActionAllow, nil
+	case "Deny":
+		return ActionDeny, nil
+	case "Pass":
+		return ActionPass, nil
+	case "Log":
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// calicoPeers converts the source or destination of a rule. Selectors, namespace selectors, service accounts and
+// nets all have to match; a rule without any of them applies to every peer. Selectors of namespaced policies only
+// match pods in the policy namespace unless a namespace selector is given.
+func calicoPeers(policyNamespace string, entity map[string]interface{}) ([]RulePeer, error) {
+	if reference, found := nestedMapNoCopy(entity, "services"); found {
+		name, _ := reference["name"].(string)
+		namespace, _ := reference["namespace"].(string)
+		if namespace == "" {
+			namespace = policyNamespace
+		}
+		// Service endpoints are resolved by Calico at runtime, so the peer is only reported
+		return []RulePeer{{Kind: PeerService, Namespace: namespace, Service: qualifiedName(ObjectReference{Namespace: namespace, Name: name})}}, nil
+	}
+
+	nets, _, _ := unstructured.NestedStringSlice(entity, "nets")
+	notNets, _, _ := unstructured.NestedStringSlice(entity, "notNets")
+	_, hasSelector := entity["selector"]
+	_, hasNotSelector := entity["notSelector"]
+	_, hasNamespaceSelector := entity["namespaceSelector"]
+	serviceAccounts, hasServiceAccounts := nestedMapNoCopy(entity, "serviceAccounts")
+
+	if !hasSelector && !hasNotSelector && !hasNamespaceSelector && !hasServiceAccounts {
+		switch {
+		case len(nets) > 0:
+			peers := []RulePeer{}
+			for _, cidr := range nets {
+				peers = append(peers, cidrPeer(cidr, notNets))
+			}
+			return peers, nil
+		case len(notNets) > 0:
+			return []RulePeer{cidrPeer("0.0.0.0/0", notNets)}, nil
+		default:
+			return []RulePeer{{Kind: PeerAny}}, nil
+		}
+	}
+
+	selector, err := calicoSelectorField(entity, "selector")
+	if err != nil {
+		return nil, err
+	}
+	namespaceSelector, err := calicoSelectorField(entity, "namespaceSelector")
+	if err != nil {
+		return nil, err
+	}
+	var notSelector *calicoSelector
+	if hasNotSelector {
+		parsed, err := calicoSelectorField(entity, "notSelector")
+		if err != nil {
+			return nil, err
+		}
+		notSelector = &parsed
+	}
+	serviceAccountNames, _, _ := unstructured.NestedStringSlice(serviceAccounts, "names")
+	serviceAccountSelector, err := calicoSelectorField(serviceAccounts, "selector")
+	if err != nil {
+		return nil, err
+	}
+
+	peer := RulePeer{Kind: PeerPods}
+	scopedNamespace := policyNamespace
+	if hasNamespaceSelector {
+		scopedNamespace = ""
+		peer.NamespaceSelector = namespaceSelector.String()
+		if !hasSelector && !hasNotSelector && !hasServiceAccounts {
+			peer.Kind = PeerNamespace
+		}
+	}
+	peer.Namespace = scopedNamespace
+	if hasSelector || hasNotSelector {
+		peer.PodSelector = selector.String()
+		if notSelector != nil {
+			peer.PodSelector = fmt.Sprintf("%s && !(%s)", selector.String(), notSelector.String())
+		}
+	}
+	if hasServiceAccounts {
+		peer.PodSelector = strings.TrimPrefix(peer.PodSelector+" && service accounts "+serviceAccountDescription(serviceAccountNames, serviceAccountSelector), " && ")
+	}
+
+	peer.matches = func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+		if scopedNamespace != "" && pod.Namespace != scopedNamespace {
+			return false
+		}
+		if hasNamespaceSelector && !namespaceSelector.Matches(calicoNamespaceLabels(pod.Namespace, namespaceLabels)) {
+			return false
+		}
+		endpointLabels := calicoEndpointLabels(pod)
+		if !selector.Matches(endpointLabels) || notSelector != nil && notSelector.Matches(endpointLabels) {
+			return false
+		}
+		if hasServiceAccounts {
+			if len(serviceAccountNames) > 0 && !contains(serviceAccountNames, podServiceAccount(pod)) {
+				return false
+			}
+			if !serviceAccountSelector.Matches(calicoServiceAccountLabels(pod)) {
+				return false
+			}
+		}
+		return calicoNetsMatch(nets, notNets, pod.Status.PodIP)
+	}
+	return []RulePeer{peer}, nil
+}
+
+// calicoNetsMatch reports whether ip is in one of nets, or nets is empty, and outside every net of notNets.
+func calicoNetsMatch(nets []string, notNets []string, ip string) bool {
+	if len(nets) == 0 {
+		return len(notNets) == 0 || cidrContains("0.0.0.0/0", notNets, ip) || cidrContains("::/0", notNets, ip)
+	}
+	for _, cidr := range nets {
+		if cidrContains(cidr, notNets, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceAccountDescription renders the service account names or selector of a rule.
+func serviceAccountDescription(names []string, selector calicoSelector) string {
+	if len(names) > 0 {
+		return strings.Join(names, ",")
+	}
+	return selector.String()
+}
+
+// calicoPorts converts the protocol and destination ports of a rule. A protocol without ports covers every port
+// of that protocol, and a rule without either applies to every port.
+func calicoPorts(rawProtocol interface{}, destination map[string]interface{}) []RulePort {
+	protocol := calicoProtocol(rawProtocol)
+	rulePorts := []RulePort{}
+
+	ports, _ := nestedSliceNoCopy(destination, "ports")
+	for _, rawPort := range ports {
+		rulePort := RulePort{Protocol: protocol}
+		switch port := rawPort.(type) {
+		case string:
+			// Ranges are written as "start:end", anything that is not a number is a named port
+			if start, end, found := strings.Cut(port, ":"); found {
+				rulePort.Port = start
+				if endPort, err := strconv.ParseInt(end, 10, 32); err == nil {
+					rulePort.EndPort = int32(endPort)
+				}
+			} else {
+				rulePort.Port = port
+			}
+		default:
+			rulePort.Port = fmt.Sprint(port)
+		}
+		rulePorts = append(rulePorts, rulePort)
+	}
+
+	if len(rulePorts) == 0 && protocol != "" {
+		rulePorts = append(rulePorts, RulePort{Protocol: protocol})
+	}
+	return rulePorts
+}
+
+// calicoProtocol normalizes a protocol given by name or by IANA number.
+func calicoProtocol(rawProtocol interface{}) string {
+	switch protocol := rawProtocol.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.ToUpper(protocol)
+	default:
+		number, _ := calicoNumber(protocol)
+		switch number {
+		case 1:
+			return "ICMP"
+		case 6:
+			return string(corev1.ProtocolTCP)
+		case 17:
+			return string(corev1.ProtocolUDP)
+		case 132:
+			return string(corev1.ProtocolSCTP)
+		default:
+			return fmt.Sprint(protocol)
+		}
+	}
+}
+
+// calicoNumber reads a number decoded from JSON or YAML, or set in code.
+func calicoNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	default:
+		return 0, false
+	}
+}
+
+// calicoSelectorField parses the selector stored in a field, where a missing field selects everything.
+func calicoSelectorField(obj map[string]interface{}, field string) (calicoSelector, error) {
+	expression, _ := obj[field].(string)
+	return parseCalicoSelector(expression)
+}
+
+// calicoEndpointLabels returns the labels Calico matches selectors against for a pod.
+func calicoEndpointLabels(pod corev1.Pod) map[string]string {
+	endpointLabels := make(map[string]string, len(pod.Labels)+3)
+	for key, value := range pod.Labels {
+		endpointLabels[key] = value
+	}
+	endpointLabels[calicoNamespaceLabel] = pod.Namespace
+	endpointLabels[calicoOrchestratorLabel] = "k8s"
+	endpointLabels[calicoServiceAccountLabel] = podServiceAccount(pod)
+	return endpointLabels
+}
+
+// calicoNamespaceLabels returns the labels Calico matches namespace selectors against.
+func calicoNamespaceLabels(name string, namespaceLabels map[string]string) map[string]string {
+	labels := make(map[string]string, len(namespaceLabels)+1)
+	for key, value := range namespaceLabels {
+		labels[key] = value
+	}
+	labels[calicoNameLabel] = name
+	return labels
+}
+
+// calicoServiceAccountLabels returns the labels of the service account of a pod known without reading the
+// ServiceAccount object, which is only its name.
+func calicoServiceAccountLabels(pod corev1.Pod) map[string]string {
+	return map[string]string{calicoNameLabel: podServiceAccount(pod)}
+}
+
+// podServiceAccount returns the service account of a pod, which defaults to "default".
+func podServiceAccount(pod corev1.Pod) string {
+	if pod.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return pod.Spec.ServiceAccountName
+}
@@ -0,0 +1,161 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// calicoTestPolicy builds a Calico policy; an empty namespace makes it a GlobalNetworkPolicy.
+func calicoTestPolicy(namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	kind := "NetworkPolicy"
+	metadata := map[string]interface{}{"name": name}
+	if namespace == "" {
+		kind = "GlobalNetworkPolicy"
+	} else {
+		metadata["namespace"] = namespace
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "projectcalico.org/v3",
+		"kind":       kind,
+		"metadata":   metadata,
+		"spec":       spec,
+	}}
+}
+
+func TestCalicoPolicyModel(t *testing.T) {
+	policy := calicoTestPolicy("shop", "db", map[string]interface{}{
+		"selector": "app == 'db'",
+		"order":    int64(100),
+		"ingress": []interface{}{
+			map[string]interface{}{"action": "Log"},
+			map[string]interface{}{
+				"action":      "Allow",
+				"protocol":    6,
+				"source":      map[string]interface{}{"selector": "app == 'web'"},
+				"destination": map[string]interface{}{"ports": []interface{}{5432, "6000:6010", "metrics"}},
+			},
+		},
+	})
+
+	model, err := CalicoPolicyModel(policy, calicoTiers(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "app == 'db'", model.Selector)
+	assert.Equal(t, calicoDefaultTier, model.Tier)
+	assert.Equal(t, 100.0, *model.Priority)
+	assert.True(t, model.IsolatesIngress)
+	assert.False(t, model.IsolatesEgress)
+
+	assert.Len(t, model.Rules, 1)
+	rule := model.Rules[0]
+	assert.Equal(t, 1, rule.Index)
+	assert.Equal(t, []RulePort{
+		{Protocol: "TCP", Port: "5432"},
+		{Protocol: "TCP", Port: "6000", EndPort: 6010},
+		{Protocol: "TCP", Port: "metrics"},
+	}, rule.Ports)
+
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	other := testPod("other", "web-0", "10.0.0.2", map[string]string{"app": "web"})
+	assert.True(t, rule.Peers[0].MatchesPod(web, nil))
+	assert.False(t, rule.Peers[0].MatchesPod(other, nil))
+
+	global := calicoTestPolicy("", "shop-egress", map[string]interface{}{
+		"namespaceSelector": "projectcalico.org/name == 'shop'",
+		"egress":            []interface{}{map[string]interface{}{"action": "Deny", "destination": map[string]interface{}{"nets": []interface{}{"169.254.169.254/32"}}}},
+	})
+	model, err = CalicoPolicyModel(global, calicoTiers(nil))
+	assert.NoError(t, err)
+	assert.False(t, model.IsolatesIngress)
+	assert.True(t, model.IsolatesEgress)
+	assert.True(t, model.Selects(web, nil))
+	assert.False(t, model.Selects(other, nil))
+	assert.True(t, model.Rules[0].Peers[0].MatchesIP("169.254.169.254"))
+
+	_, err = CalicoPolicyModel(calicoTestPolicy("shop", "broken", map[string]interface{}{"selector": "app = 'db'"}), calicoTiers(nil))
+	assert.Error(t, err)
+}
+
+func TestCanReachCalicoTiers(t *testing.T) {
+	tiers := calicoTiers([]*unstructured.Unstructured{
+		{Object: map[string]interface{}{"kind": "Tier", "metadata": map[string]interface{}{"name": "security"}, "spec": map[string]interface{}{"order": 100}}},
+		{Object: map[string]interface{}{"kind": "Tier", "metadata": map[string]interface{}{"name": "platform"}, "spec": map[string]interface{}{"order": 200, "defaultAction": "Pass"}}},
+	})
+
+	calicoPolicies := []*unstructured.Unstructured{
+		calicoTestPolicy("", "security.db-guard", map[string]interface{}{
+			"tier":     "security",
+			"order":    10,
+			"selector": "app == 'db'",
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Deny", "source": map[string]interface{}{"selector": "app == 'batch'"}},
+				map[string]interface{}{"action": "Pass"},
+			},
+		}),
+		calicoTestPolicy("", "security.batch-egress", map[string]interface{}{
+			"tier":     "security",
+			"selector": "app == 'batch'",
+			"types":    []interface{}{"Egress"},
+		}),
+		calicoTestPolicy("", "platform.web-egress", map[string]interface{}{
+			"tier":     "platform",
+			"selector": "app == 'web'",
+			"egress": []interface{}{
+				map[string]interface{}{"action": "Allow", "protocol": "TCP", "destination": map[string]interface{}{"selector": "app == 'db'", "ports": []interface{}{5432}}},
+			},
+		}),
+		calicoTestPolicy("shop", "db-allow", map[string]interface{}{
+			"selector": "app == 'db'",
+			"ingress": []interface{}{
+				map[string]interface{}{"action": "Allow", "source": map[string]interface{}{"selector": "app in {'web', 'batch'}"}},
+			},
+		}),
+	}
+
+	var policies []PolicyModel
+	for _, policy := range calicoPolicies {
+		model, err := CalicoPolicyModel(policy, tiers)
+		assert.NoError(t, err)
+		policies = append(policies, model)
+	}
+
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	batch := testPod("shop", "batch-0", "10.0.0.2", map[string]string{"app": "batch"})
+	db := testPod("shop", "db-0", "10.0.0.3", map[string]string{"app": "db"})
+	namespaces := []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}}
+	state := NewClusterState([]corev1.Pod{web, batch, db}, namespaces, policies)
+
+	tests := []struct {
+		name        string
+		source      corev1.Pod
+		destination corev1.Pod
+		port        int32
+		allowed     bool
+		reason      string
+	}{
+		{"allowed by tier rule", web, db, 5432, true, "allowed by platform.web-egress"},
+		{"passed to default tier", web, db, 5432, true, "allowed by shop/db-allow"},
+		{"deny in earlier tier wins", batch, db, 5432, false, "denied by security.db-guard"},
+		{"tier default action denies", batch, web, 80, false, "isolated and no egress rule matches in tier security"},
+		{"tier default action passes", web, db, 80, true, "no policy decides egress traffic, allowed by default"},
+		{"default tier isolates", db, db, 80, false, "isolated and no ingress rule matches"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := state.CanReach(tt.source, tt.destination, tt.port, "TCP")
+			assert.Equal(t, tt.allowed, verdict.Allowed)
+			reasons := []string{verdict.Egress.Reason, verdict.Ingress.Reason}
+			assert.Contains(t, reasons, tt.reason)
+		})
+	}
+
+	// Traffic passed by a tier is exposed through the later layers
+	evaluation := EvaluatePod(web, nil, policies)
+	assert.Equal(t, []string{ExposureAll}, evaluation.EgressExposure)
+	evaluation = EvaluatePod(batch, nil, policies)
+	assert.Equal(t, []string{}, evaluation.EgressExposure)
+}
@@ -0,0 +1,301 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Calico policy APIs. projectcalico.org/v3 is served by the Calico API server; crd.projectcalico.org/v1 are the
+// CRDs backing it, which are read when the API server is not installed.
+var (
+	calicoAPIGroupVersion = schema.GroupVersion{Group: "projectcalico.org", Version: "v3"}
+	calicoCRDGroupVersion = schema.GroupVersion{Group: "crd.projectcalico.org", Version: "v1"}
+)
+
+// errCalicoNotInstalled is returned when neither Calico policy API is served by the cluster
+var errCalicoNotInstalled = errors.New("Calico policy APIs (projectcalico.org, crd.projectcalico.org) are not installed in this cluster")
+
+// Calico resources read by the scanner
+const (
+	calicoNetworkPolicies       = "networkpolicies"
+	calicoGlobalNetworkPolicies = "globalnetworkpolicies"
+	calicoTiersResource         = "tiers"
+)
+
+// fetchCalicoObjects lists a Calico resource in every namespace. The projectcalico.org/v3 API is preferred,
+// and the CRDs are read when it is not installed or holds no objects. It reports whether either API exists.
+func fetchCalicoObjects(dynamicClient dynamic.Interface, resource string) ([]*unstructured.Unstructured, bool, error) {
+	installed := false
+	for _, groupVersion := range []schema.GroupVersion{calicoAPIGroupVersion, calicoCRDGroupVersion} {
+		list, err := dynamicClient.Resource(groupVersion.WithResource(resource)).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return nil, false, fmt.Errorf("error listing %s.%s: %w", resource, groupVersion.Group, err)
+		}
+		installed = true
+		if len(list.Items) == 0 {
+			continue
+		}
+
+		objects := make([]*unstructured.Unstructured, 0, len(list.Items))
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+		return objects, true, nil
+	}
+	return nil, installed, nil
+}
+
+// LoadCalicoPolicyModels reads the Calico NetworkPolicies, GlobalNetworkPolicies and Tiers of the cluster and
+// converts the policies into the engine's policy model. Policies that cannot be parsed are skipped and returned
+// as errors. It fails when Calico's policy APIs are not installed.
+func LoadCalicoPolicyModels(dynamicClient dynamic.Interface) ([]PolicyModel, []error, error) {
+	tierObjects, _, err := fetchCalicoObjects(dynamicClient, calicoTiersResource)
+	if err != nil {
+		return nil, nil, err
+	}
+	tiers := calicoTiers(tierObjects)
+
+	var models []PolicyModel
+	var errs []error
+	anyInstalled := false
+	for _, resource := range []string{calicoNetworkPolicies, calicoGlobalNetworkPolicies} {
+		policies, installed, err := fetchCalicoObjects(dynamicClient, resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		anyInstalled = anyInstalled || installed
+		for _, policy := range policies {
+			model, err := CalicoPolicyModel(policy, tiers)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			models = append(models, model)
+		}
+	}
+
+	if !anyInstalled {
+		return nil, nil, errCalicoNotInstalled
+	}
+	return models, errs, nil
+}
+
+// calicoNamespaceHasDefaultDeny reports whether a Calico policy isolates both directions of every pod in the
+// namespace without allowing or passing any traffic. A policy selecting an unlabelled pod of the namespace is
+// taken to select every pod in it.
+func calicoNamespaceHasDefaultDeny(models []PolicyModel, namespace corev1.Namespace) bool {
+	probe := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace.Name}}
+	namespaceLabels := NamespaceLabels([]corev1.Namespace{namespace})[namespace.Name]
+
+	for _, model := range models {
+		if !model.IsolatesIngress || !model.IsolatesEgress || !model.Selects(probe, namespaceLabels) {
+			continue
+		}
+		allowsTraffic := false
+		for _, rule := range model.Rules {
+			if rule.Action == ActionAllow || rule.Action == ActionPass {
+				allowsTraffic = true
+				break
+			}
+		}
+		if !allowsTraffic {
+			return true
+		}
+	}
+	return false
+}
+
+// processNamespacePoliciesCalico evaluates the Calico policies against the running pods of a namespace to identify
+// unprotected pods and what every pod is exposed to.
+func processNamespacePoliciesCalico(dynamicClient dynamic.Interface, clientset kubernetes.Interface, nsName string, models []PolicyModel, writer *bufio.Writer, scanResult *ScanResult, dryRun bool, isCLI bool) error {
+	pods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		printToBoth(writer, fmt.Sprintf("Error listing all pods in namespace %s: %s\n", nsName, err))
+		return fmt.Errorf("error listing all pods: %w", err)
+	}
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.TODO(), nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting namespace %s: %w", nsName, err)
+	}
+
+	evaluations := EvaluatePods(scannedPods(pods.Items), []corev1.Namespace{*namespace}, models)
+	sortEvaluations(evaluations)
+	acceptPodFindings(evaluations, scanResult)
+	scanResult.PodEvaluations = append(scanResult.PodEvaluations, evaluations...)
+
+	unprotectedPods := []string{}
+	for _, evaluation := range evaluations {
+		if evaluation.HasFinding(FindingUnprotected) {
+			unprotectedPods = append(unprotectedPods, fmt.Sprintf("%s %s %s", evaluation.Namespace, evaluation.Name, evaluation.IP))
+		}
+	}
+
+	if calicoNamespaceHasDefaultDeny(models, *namespace) && !contains(scanResult.HasDenyAll, nsName) {
+		scanResult.HasDenyAll = append(scanResult.HasDenyAll, nsName)
+	}
+
+	if len(unprotectedPods) > 0 {
+		scanResult.UnprotectedPods = append(scanResult.UnprotectedPods, unprotectedPods...)
+
+		if isCLI && !dryRun {
+			if err := handleCLIInteractionsCalico(nsName, unprotectedPods, dynamicClient, writer, scanResult); err != nil {
+				return err
+			}
+		} else if dryRun {
+			displayUnprotectedPods(nsName, unprotectedPods, writer)
+		}
+	}
+	if isCLI {
+		displayPodExposure(nsName, evaluations, writer)
+	}
+
+	return nil
+}
+
+// handleCLIInteractionsCalico lists the unprotected pods of a namespace and offers to apply a default deny policy.
+func handleCLIInteractionsCalico(nsName string, unprotectedPods []string, dynamicClient dynamic.Interface, writer *bufio.Writer, scanResult *ScanResult) error {
+	displayUnprotectedPods(nsName, unprotectedPods, writer)
+	if !interactive {
+		return nil
+	}
+
+	confirm := false
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Do you want to add a default deny all Calico network policy to the namespace %s?", nsName),
+	}
+	if err := survey.AskOne(prompt, &confirm, nil); err != nil {
+		return fmt.Errorf("failed to prompt for policy application: %s", err)
+	}
+
+	if confirm {
+		if err := CreateAndApplyDefaultDenyCalicoPolicy(nsName, dynamicClient); err != nil {
+			return fmt.Errorf("failed to apply default deny Calico policy in namespace %s: %s", nsName, err)
+		}
+		scanResult.PolicyChangesMade = true
+	} else {
+		scanResult.UserDeniedPolicies = true
+	}
+	return nil
+}
+
+// CreateAndApplyDefaultDenyCalicoPolicy creates a Calico NetworkPolicy in the default tier that selects every pod of
+// the namespace and denies all ingress and egress traffic not allowed by other policies.
+func CreateAndApplyDefaultDenyCalicoPolicy(namespace string, dynamicClient dynamic.Interface) error {
+	policyName := namespace + "-calico-default-deny-all"
+	denyAllPolicy := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": calicoAPIGroupVersion.String(),
+			"kind":       "NetworkPolicy",
+			"metadata": map[string]interface{}{
+				"name":      policyName,
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"selector": "all()",
+				"types":    []interface{}{"Ingress", "Egress"},
+			},
+		},
+	}
+
+	resource := calicoAPIGroupVersion.WithResource(calicoNetworkPolicies)
+	_, err := dynamicClient.Resource(resource).Namespace(namespace).Create(context.TODO(), denyAllPolicy, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create default deny all Calico NetworkPolicy: %v", err)
+	}
+
+	fmt.Printf("Applied default deny all Calico NetworkPolicy to namespace %s\n", namespace)
+	return nil
+}
+
+// ScanCalicoNetworkPolicies scans namespaces for Calico NetworkPolicies and GlobalNetworkPolicies
+func ScanCalicoNetworkPolicies(specificNamespace string, dryRun bool, returnResult bool, isCLI bool, printScore bool, printMessages bool, kubeconfigPath string) (*ScanResult, error) {
+	var output bytes.Buffer
+
+	scanResult := &ScanResult{PolicyType: PolicyTypeCalico}
+
+	writer := bufio.NewWriter(&output)
+
+	dynamicClient, clientset, err := initializeDynamicClients(kubeconfigPath)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	namespacesToScan, err := SelectNamespaces(clientset, specificNamespace)
+	if err != nil {
+		return nil, err
+	}
+	scanResult.NamespacesScanned = namespacesToScan
+	if err := useWaivers(clientset, scanResult); err != nil {
+		printToBoth(writer, fmt.Sprintf("Error loading waivers: %s\n", err))
+	}
+
+	models, errs, err := LoadCalicoPolicyModels(dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		printToBoth(writer, fmt.Sprintf("Error evaluating policy: %s\n", err))
+	}
+
+	if isCLI {
+		fmt.Println("Policy type: Calico")
+	}
+
+	for _, nsName := range namespacesToScan {
+		if err := processNamespacePoliciesCalico(dynamicClient, clientset, nsName, models, writer, scanResult, dryRun, isCLI); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if isCLI && output.Len() > 0 {
+		handleOutputAndPromptsCalico(writer, &output)
+	}
+
+	if printMessages {
+		printToBoth(writer, "\nNetfetch scan completed!\n")
+	}
+
+	scoreResult(scanResult, printScore)
+
+	return scanResult, nil
+}
+
+func handleOutputAndPromptsCalico(writer *bufio.Writer, output *bytes.Buffer) {
+	if !interactive {
+		return
+	}
+	saveToFile := false
+	prompt := &survey.Confirm{
+		Message: "Do you want to save the output to netfetch-calico.txt?",
+	}
+	survey.AskOne(prompt, &saveToFile, nil)
+
+	if saveToFile {
+		err := os.WriteFile("netfetch-calico.txt", output.Bytes(), 0644)
+		if err != nil {
+			printToBoth(writer, fmt.Sprintf("Error writing to file: %s\n", err))
+		} else {
+			printToBoth(writer, "Output file created: netfetch-calico.txt\n")
+		}
+	} else {
+		printToBoth(writer, "Output file not created.\n")
+	}
+}
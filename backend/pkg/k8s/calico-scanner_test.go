@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestLoadCalicoPolicyModels(t *testing.T) {
+	// Policies only stored as CRDs are read when the projectcalico.org API holds none
+	policy := calicoTestPolicy("shop", "default-deny", map[string]interface{}{"selector": "all()", "types": []interface{}{"Ingress", "Egress"}})
+	policy.SetAPIVersion(calicoCRDGroupVersion.String())
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, policy)
+
+	models, errs, err := LoadCalicoPolicyModels(dynamicClient)
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+	assert.Len(t, models, 1)
+	assert.Equal(t, "shop/default-deny", models[0].ID())
+}
+
+func TestCalicoNamespaceHasDefaultDeny(t *testing.T) {
+	shop := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+
+	tests := []struct {
+		name     string
+		spec     map[string]interface{}
+		expected bool
+	}{
+		{"deny all", map[string]interface{}{"selector": "all()", "types": []interface{}{"Ingress", "Egress"}}, true},
+		{"explicit deny rules", map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"action": "Deny"}}, "egress": []interface{}{map[string]interface{}{"action": "Deny"}}}, true},
+		{"ingress only", map[string]interface{}{"selector": "all()"}, false},
+		{"selects some pods", map[string]interface{}{"selector": "has(app)", "types": []interface{}{"Ingress", "Egress"}}, false},
+		{"allows traffic", map[string]interface{}{"types": []interface{}{"Ingress", "Egress"}, "ingress": []interface{}{map[string]interface{}{"action": "Allow"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := CalicoPolicyModel(calicoTestPolicy("shop", "policy", tt.spec), calicoTiers(nil))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, calicoNamespaceHasDefaultDeny([]PolicyModel{model}, shop))
+		})
+	}
+
+	global, err := CalicoPolicyModel(calicoTestPolicy("", "deny-all", map[string]interface{}{
+		"selector": "projectcalico.org/namespace not in {'kube-system'}",
+		"types":    []interface{}{"Ingress", "Egress"},
+	}), calicoTiers(nil))
+	assert.NoError(t, err)
+	assert.True(t, calicoNamespaceHasDefaultDeny([]PolicyModel{global}, shop))
+}
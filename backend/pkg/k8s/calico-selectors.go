@@ -0,0 +1,336 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// calicoSelector is a parsed Calico selector expression, such as "app == 'web' && has(tier)".
+type calicoSelector struct {
+	expression string
+	matches    func(labels map[string]string) bool
+}
+
+// Matches reports whether the labels satisfy the selector.
+func (s calicoSelector) Matches(labels map[string]string) bool {
+	return s.matches(labels)
+}
+
+// String returns the selector expression, or all() for an empty selector.
+func (s calicoSelector) String() string {
+	if s.expression == "" {
+		return "all()"
+	}
+	return s.expression
+}
+
+// parseCalicoSelector parses Calico's selector language: all(), global(), has(k), !has(k), k == 'v', k != 'v',
+// k in {'a','b'}, k not in {...}, k contains 's', k starts with 's', k ends with 's', negation with !, && and ||
+// and parentheses. An empty selector selects everything. global() only selects non-namespaced endpoints such as
+// host endpoints, so it never selects pods or namespaces.
+func parseCalicoSelector(expression string) (calicoSelector, error) {
+	if strings.TrimSpace(expression) == "" {
+		return calicoSelector{matches: func(map[string]string) bool { return true }}, nil
+	}
+
+	tokens, err := tokenizeCalicoSelector(expression)
+	if err != nil {
+		return calicoSelector{}, fmt.Errorf("invalid selector %q: %w", expression, err)
+	}
+	parser := &calicoSelectorParser{tokens: tokens}
+	matches, err := parser.parseOr()
+	if err == nil && !parser.done() {
+		err = fmt.Errorf("unexpected %q", parser.peek().value)
+	}
+	if err != nil {
+		return calicoSelector{}, fmt.Errorf("invalid selector %q: %w", expression, err)
+	}
+	return calicoSelector{expression: strings.TrimSpace(expression), matches: matches}, nil
+}
+
+// Token kinds of the selector language
+const (
+	calicoTokenWord = iota
+	calicoTokenString
+	calicoTokenOperator
+)
+
+type calicoToken struct {
+	kind  int
+	value string
+}
+
+// tokenizeCalicoSelector splits a selector into label keys and keywords, quoted strings and operators.
+func tokenizeCalicoSelector(expression string) ([]calicoToken, error) {
+	var tokens []calicoToken
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, calicoToken{kind: calicoTokenString, value: string(runes[i+1 : end])})
+			i = end + 1
+		case strings.ContainsRune("(){},", r):
+			tokens = append(tokens, calicoToken{kind: calicoTokenOperator, value: string(r)})
+			i++
+		case r == '&' || r == '|' || r == '=':
+			if i+1 >= len(runes) || runes[i+1] != r {
+				return nil, fmt.Errorf("unexpected %q", string(r))
+			}
+			tokens = append(tokens, calicoToken{kind: calicoTokenOperator, value: string(runes[i : i+2])})
+			i += 2
+		case r == '!':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, calicoToken{kind: calicoTokenOperator, value: "!="})
+				i += 2
+				continue
+			}
+			tokens = append(tokens, calicoToken{kind: calicoTokenOperator, value: "!"})
+			i++
+		case isCalicoLabelRune(r):
+			end := i
+			for end < len(runes) && isCalicoLabelRune(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, calicoToken{kind: calicoTokenWord, value: string(runes[i:end])})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected %q", string(r))
+		}
+	}
+	return tokens, nil
+}
+
+// isCalicoLabelRune reports whether r may appear in a label key.
+func isCalicoLabelRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_./-", r)
+}
+
+// calicoSelectorParser is a recursive descent parser over the tokens of a selector.
+type calicoSelectorParser struct {
+	tokens   []calicoToken
+	position int
+}
+
+func (p *calicoSelectorParser) done() bool {
+	return p.position >= len(p.tokens)
+}
+
+func (p *calicoSelectorParser) peek() calicoToken {
+	if p.done() {
+		return calicoToken{}
+	}
+	return p.tokens[p.position]
+}
+
+// accept consumes the next token if it is the given operator or keyword.
+func (p *calicoSelectorParser) accept(value string) bool {
+	if !p.done() && p.tokens[p.position].kind != calicoTokenString && p.tokens[p.position].value == value {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *calicoSelectorParser) expect(value string) error {
+	if !p.accept(value) {
+		return fmt.Errorf("expected %q", value)
+	}
+	return nil
+}
+
+// next consumes a token of the given kind and returns its value.
+func (p *calicoSelectorParser) next(kind int, description string) (string, error) {
+	if p.done() || p.tokens[p.position].kind != kind {
+		return "", fmt.Errorf("expected %s", description)
+	}
+	p.position++
+	return p.tokens[p.position-1].value, nil
+}
+
+func (p *calicoSelectorParser) parseOr() (func(map[string]string) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(labels map[string]string) bool { return a(labels) || b(labels) }
+	}
+	return left, nil
+}
+
+func (p *calicoSelectorParser) parseAnd() (func(map[string]string) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(labels map[string]string) bool { return a(labels) && b(labels) }
+	}
+	return left, nil
+}
+
+func (p *calicoSelectorParser) parseUnary() (func(map[string]string) bool, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool { return !operand(labels) }, nil
+	}
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+	return p.parseTerm()
+}
+
+// parseTerm parses a function call or a comparison on a label key.
+func (p *calicoSelectorParser) parseTerm() (func(map[string]string) bool, error) {
+	key, err := p.next(calicoTokenWord, "label key or function")
+	if err != nil {
+		return nil, err
+	}
+
+	switch key {
+	case "all", "global":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		selectsAll := key == "all"
+		return func(map[string]string) bool { return selectsAll }, nil
+	case "has":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		label, err := p.next(calicoTokenWord, "label key")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			_, found := labels[label]
+			return found
+		}, nil
+	}
+
+	switch {
+	case p.accept("=="):
+		value, err := p.next(calicoTokenString, "quoted value")
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, found := labels[key]
+			return found && actual == value
+		}, nil
+	case p.accept("!="):
+		value, err := p.next(calicoTokenString, "quoted value")
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, found := labels[key]
+			return !found || actual != value
+		}, nil
+	case p.accept("in"):
+		values, err := p.parseSet()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, found := labels[key]
+			return found && values[actual]
+		}, nil
+	case p.accept("not"):
+		if err := p.expect("in"); err != nil {
+			return nil, err
+		}
+		values, err := p.parseSet()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, found := labels[key]
+			return !found || !values[actual]
+		}, nil
+	case p.accept("contains"):
+		return p.parseStringMatch(key, strings.Contains)
+	case p.accept("starts"):
+		if err := p.expect("with"); err != nil {
+			return nil, err
+		}
+		return p.parseStringMatch(key, strings.HasPrefix)
+	case p.accept("ends"):
+		if err := p.expect("with"); err != nil {
+			return nil, err
+		}
+		return p.parseStringMatch(key, strings.HasSuffix)
+	default:
+		return nil, fmt.Errorf("expected operator after %q", key)
+	}
+}
+
+// parseSet parses {'a', 'b'} into a set of values.
+func (p *calicoSelectorParser) parseSet() (map[string]bool, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	values := make(map[string]bool)
+	if p.accept("}") {
+		return values, nil
+	}
+	for {
+		value, err := p.next(calicoTokenString, "quoted value")
+		if err != nil {
+			return nil, err
+		}
+		values[value] = true
+		if p.accept("}") {
+			return values, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseStringMatch parses the quoted operand of contains, starts with and ends with.
+func (p *calicoSelectorParser) parseStringMatch(key string, match func(string, string) bool) (func(map[string]string) bool, error) {
+	value, err := p.next(calicoTokenString, "quoted value")
+	if err != nil {
+		return nil, err
+	}
+	return func(labels map[string]string) bool {
+		actual, found := labels[key]
+		return found && match(actual, value)
+	}, nil
+}
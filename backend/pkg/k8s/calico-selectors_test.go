@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCalicoSelector(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend", "team": "shop-payments"}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   bool
+	}{
+		{"empty selects all", "", true},
+		{"all", "all()", true},
+		{"global selects no pods", "global()", false},
+		{"equality", "app == 'web'", true},
+		{"double quotes", `app == "db"`, false},
+		{"inequality of missing label", "env != 'prod'", true},
+		{"has", "has(tier)", true},
+		{"negated has", "!has(tier)", false},
+		{"in", "app in {'web', 'api'}", true},
+		{"not in", "app not in {'web'}", false},
+		{"contains", "team contains 'pay'", true},
+		{"starts with", "team starts with 'shop-'", true},
+		{"ends with", "team ends with 'shop'", false},
+		{"and", "app == 'web' && tier == 'backend'", false},
+		{"or", "app == 'db' || tier == 'frontend'", true},
+		{"precedence", "app == 'db' && tier == 'x' || has(team)", true},
+		{"parentheses", "app == 'db' && (tier == 'x' || has(team))", false},
+		{"prefixed keys", "projectcalico.org/namespace == 'shop'", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := parseCalicoSelector(tt.expression)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, selector.Matches(labels))
+		})
+	}
+
+	for _, invalid := range []string{"app = 'web'", "app == web", "has(app", "app in {'a' 'b'}", "app == 'web' &&", "app == 'web"} {
+		_, err := parseCalicoSelector(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Labels Cilium derives for every endpoint from its pod and namespace
+const (
+	ciliumNamespaceLabel        = "io.kubernetes.pod.namespace"
+	ciliumServiceAccountLabel   = "io.cilium.k8s.policy.serviceaccount"
+	ciliumClusterLabel          = "io.cilium.k8s.policy.cluster"
+	ciliumNamespaceLabelsPrefix = "io.cilium.k8s.namespace.labels."
+	ciliumDefaultClusterName    = "default"
+)
+
+// Cilium label sources. Labels of pods come from the k8s source, and "any" matches every source.
+const (
+	ciliumSourceK8s      = "k8s"
+	ciliumSourceAny      = "any"
+	ciliumSourceReserved = "reserved"
+)
+
+// ciliumPodSources are the label sources that refer to labels of the pod itself.
+var ciliumPodSources = map[string]bool{ciliumSourceK8s: true, ciliumSourceAny: true, "container": true, "unspec": true}
+
+// ciliumLabelSources are all label sources Cilium accepts as a key prefix.
+var ciliumLabelSources = map[string]bool{
+	ciliumSourceK8s:      true,
+	ciliumSourceAny:      true,
+	ciliumSourceReserved: true,
+	"container":          true,
+	"unspec":             true,
+	"cidr":               true,
+	"fqdn":               true,
+	"cilium-generated":   true,
+}
+
+// normalizeCiliumLabelKey strips the source prefix of a selector key, so "k8s:app" and "any:app" both select on
+// the pod label "app". Keys of other sources, such as "reserved:health", are rewritten to "<source>.cilium.io/<name>";
+// pods never carry those labels, so selectors requiring them do not select pods.
+func normalizeCiliumLabelKey(key string) string {
+	source, name, found := strings.Cut(key, ":")
+	if !found || !ciliumLabelSources[source] {
+		return key
+	}
+	if ciliumPodSources[source] {
+		return name
+	}
+	return source + ".cilium.io/" + name
+}
+
+// ciliumEndpointLabels returns the labels Cilium matches endpoint selectors against for a pod: the labels of the pod
+// plus the labels Cilium derives from its namespace, the namespace labels and its service account.
+func ciliumEndpointLabels(pod corev1.Pod, namespaceLabels map[string]string) map[string]string {
+	endpointLabels := make(map[string]string, len(pod.Labels)+len(namespaceLabels)+3)
+	for key, value := range pod.Labels {
+		endpointLabels[normalizeCiliumLabelKey(key)] = value
+	}
+	for key, value := range namespaceLabels {
+		endpointLabels[ciliumNamespaceLabelsPrefix+key] = value
+	}
+
+	endpointLabels[ciliumNamespaceLabel] = pod.Namespace
+	endpointLabels[ciliumServiceAccountLabel] = podServiceAccount(pod)
+	endpointLabels[ciliumClusterLabel] = ciliumDefaultClusterName
+	return endpointLabels
+}
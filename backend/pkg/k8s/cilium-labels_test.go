@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeCiliumLabelKey(t *testing.T) {
+	tests := map[string]string{
+		"app":                             "app",
+		"k8s:app":                         "app",
+		"any:app":                         "app",
+		"k8s:io.kubernetes.pod.namespace": "io.kubernetes.pod.namespace",
+		"reserved:health":                 "reserved.cilium.io/health",
+		"app.kubernetes.io/name":          "app.kubernetes.io/name",
+		"custom:key":                      "custom:key",
+	}
+
+	for key, expected := range tests {
+		t.Run(key, func(t *testing.T) {
+			assert.Equal(t, expected, normalizeCiliumLabelKey(key))
+		})
+	}
+}
+
+func TestCiliumEndpointLabels(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api", Labels: map[string]string{"app": "api"}},
+		Spec:       corev1.PodSpec{ServiceAccountName: "api-sa"},
+	}
+
+	endpointLabels := ciliumEndpointLabels(pod, map[string]string{"team": "payments"})
+	assert.Equal(t, "api", endpointLabels["app"])
+	assert.Equal(t, "shop", endpointLabels["io.kubernetes.pod.namespace"])
+	assert.Equal(t, "api-sa", endpointLabels["io.cilium.k8s.policy.serviceaccount"])
+	assert.Equal(t, "payments", endpointLabels["io.cilium.k8s.namespace.labels.team"])
+
+	tests := []struct {
+		name          string
+		selector      map[string]interface{}
+		expectedMatch bool
+	}{
+		{"k8s prefixed namespace", map[string]interface{}{"matchLabels": map[string]interface{}{"k8s:io.kubernetes.pod.namespace": "shop", "k8s:app": "api"}}, true},
+		{"other namespace", map[string]interface{}{"matchLabels": map[string]interface{}{"io.kubernetes.pod.namespace": "grafana"}}, false},
+		{"any prefixed label", map[string]interface{}{"matchLabels": map[string]interface{}{"any:app": "api"}}, true},
+		{"service account", map[string]interface{}{"matchLabels": map[string]interface{}{"io.cilium.k8s.policy.serviceaccount": "api-sa"}}, true},
+		{"namespace labels", map[string]interface{}{"matchLabels": map[string]interface{}{"k8s:io.cilium.k8s.namespace.labels.team": "payments"}}, true},
+		{"reserved label", map[string]interface{}{"matchLabels": map[string]interface{}{"reserved:health": ""}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedMatch, MatchesLabels(endpointLabels, tt.selector))
+		})
+	}
+}
+
+func TestIsPodProtectedByNamespaceScopedClusterwidePolicy(t *testing.T) {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "CiliumClusterwideNetworkPolicy",
+		"metadata": map[string]interface{}{"name": "deny-all-grafana"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"k8s:io.kubernetes.pod.namespace": "grafana"}},
+			"ingress":          []interface{}{map[string]interface{}{}},
+			"egress":           []interface{}{map[string]interface{}{}},
+		},
+	}}
+	models, err := CiliumPolicyModels(policy)
+	assert.NoError(t, err)
+
+	grafana := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "grafana", Name: "grafana-0"}}
+	shop := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api"}}
+	policies := []*unstructured.Unstructured{policy}
+
+	assert.True(t, IsPodProtected(grafana, nil, policies, models, false, map[string]struct{}{}))
+	assert.False(t, IsPodProtected(shop, nil, policies, models, false, map[string]struct{}{}))
+}
@@ -0,0 +1,437 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selector description of policies that select nodes rather than endpoints
+const ciliumNodesSelector = "(nodes)"
+
+// Cilium entities that cover every pod in the cluster
+var ciliumClusterEntities = map[string]bool{"all": true, "cluster": true}
+
+// Cilium entities that cover addresses outside the cluster
+var ciliumWorldEntities = map[string]bool{"all": true, "world": true, "world-ipv4": true, "world-ipv6": true}
+
+// CiliumPolicyModels converts a CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy into the engine's policy model.
+// A policy using "specs" results in one model per spec.
+func CiliumPolicyModels(policy *unstructured.Unstructured) ([]PolicyModel, error) {
+	var specs []map[string]interface{}
+	if spec, found := nestedMapNoCopy(policy.Object, "spec"); found {
+		specs = append(specs, spec)
+	}
+	if list, found := nestedSliceNoCopy(policy.Object, "specs"); found {
+		for _, item := range list {
+			if spec, ok := item.(map[string]interface{}); ok {
+				specs = append(specs, spec)
+			}
+		}
+	}
+
+	var models []PolicyModel
+	for _, spec := range specs {
+		model, err := ciliumSpecModel(policy, spec)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s %s: %w", policy.GetKind(), policy.GetName(), err)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// ciliumSpecModel converts a single Cilium rule spec. Namespaced policies only select and, unless the
+// selector names a namespace, only match endpoints in their own namespace.
+func ciliumSpecModel(policy *unstructured.Unstructured, spec map[string]interface{}) (PolicyModel, error) {
+	namespace := policy.GetNamespace()
+	engine, kind := PolicyTypeCilium, "CiliumNetworkPolicy"
+	if namespace == "" {
+		engine, kind = PolicyTypeCiliumClusterwide, "CiliumClusterwideNetworkPolicy"
+	}
+
+	model := PolicyModel{
+		Engine:    engine,
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      policy.GetName(),
+		Rules:     []PolicyRule{},
+	}
+
+	if _, selectsNodes := spec["nodeSelector"]; selectsNodes {
+		// Policies selecting nodes instead of endpoints do not apply to pods
+		model.Selector = ciliumNodesSelector
+		model.selects = func(corev1.Pod, map[string]string) bool { return false }
+	} else {
+		// A missing endpointSelector selects every endpoint in scope, like an empty one
+		selector := labels.Everything()
+		if rawSelector, found := spec["endpointSelector"]; found {
+			var err error
+			if selector, err = ciliumSelector(rawSelector); err != nil {
+				return PolicyModel{}, fmt.Errorf("invalid endpointSelector: %w", err)
+			}
+		}
+		model.Selector = selectorDescription(selector.String())
+		model.selects = func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+			if namespace != "" && pod.Namespace != namespace {
+				return false
+			}
+			return selector.Matches(labels.Set(ciliumEndpointLabels(pod, namespaceLabels)))
+		}
+	}
+
+	_, hasIngress := spec["ingress"]
+	_, hasIngressDeny := spec["ingressDeny"]
+	_, hasEgress := spec["egress"]
+	_, hasEgressDeny := spec["egressDeny"]
+	model.IsolatesIngress = (hasIngress || hasIngressDeny) && ciliumDefaultDeny(spec, "ingress")
+	model.IsolatesEgress = (hasEgress || hasEgressDeny) && ciliumDefaultDeny(spec, "egress")
+
+	sections := []struct {
+		field     string
+		direction string
+		action    string
+	}{
+		{"ingress", DirectionIngress, ActionAllow},
+		{"ingressDeny", DirectionIngress, ActionDeny},
+		{"egress", DirectionEgress, ActionAllow},
+		{"egressDeny", DirectionEgress, ActionDeny},
+	}
+	for _, section := range sections {
+		rules, _ := nestedSliceNoCopy(spec, section.field)
+		for i, rawRule := range rules {
+			rule, ok := rawRule.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			peers, err := ciliumPeers(namespace, section.direction, rule)
+			if err != nil {
+				return PolicyModel{}, fmt.Errorf("invalid %s rule %d: %w", section.field, i, err)
+			}
+			model.Rules = append(model.Rules, PolicyRule{
+				Policy:    model.ID(),
+				Direction: section.direction,
+				Index:     i,
+				Action:    section.action,
+				Peers:     peers,
+				Ports:     ciliumPorts(rule),
+			})
+		}
+	}
+
+	return model, nil
+}
+
+// ciliumDefaultDeny honors spec.enableDefaultDeny, which lets a policy add rules without isolating the endpoint.
+func ciliumDefaultDeny(spec map[string]interface{}, direction string) bool {
+	enabled, found, _ := unstructured.NestedBool(spec, "enableDefaultDeny", direction)
+	return !found || enabled
+}
+
+// ciliumPeers converts the L3 part of a Cilium rule. A rule with only L4 or L7 parts applies to every peer,
+// while an empty rule ({}) applies to none and only enables default deny for the endpoint.
+func ciliumPeers(policyNamespace string, direction string, rule map[string]interface{}) ([]RulePeer, error) {
+	if len(rule) == 0 {
+		return []RulePeer{}, nil
+	}
+
+	prefix := "from"
+	if direction == DirectionEgress {
+		prefix = "to"
+	}
+
+	peers := []RulePeer{}
+	hasL3 := false
+
+	if endpoints, found := nestedSliceNoCopy(rule, prefix+"Endpoints"); found {
+		hasL3 = true
+		for _, rawSelector := range endpoints {
+			peer, err := ciliumEndpointPeer(policyNamespace, rawSelector)
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, peer)
+		}
+	}
+
+	if entities, found, _ := unstructured.NestedStringSlice(rule, prefix+"Entities"); found {
+		hasL3 = true
+		for _, entity := range entities {
+			peers = append(peers, ciliumEntityPeer(entity))
+		}
+	}
+
+	if cidrs, found, _ := unstructured.NestedStringSlice(rule, prefix+"CIDR"); found {
+		hasL3 = true
+		for _, cidr := range cidrs {
+			peers = append(peers, cidrPeer(cidr, nil))
+		}
+	}
+
+	if cidrSets, found := nestedSliceNoCopy(rule, prefix+"CIDRSet"); found {
+		hasL3 = true
+		for _, rawSet := range cidrSets {
+			set, ok := rawSet.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			cidr, _, _ := unstructured.NestedString(set, "cidr")
+			except, _, _ := unstructured.NestedStringSlice(set, "except")
+			if cidr == "" {
+				// CIDR groups are resolved by the agent and cannot be evaluated here
+				group, _, _ := unstructured.NestedString(set, "cidrGroupRef")
+				peers = append(peers, RulePeer{Kind: PeerCIDR, CIDR: "cidrGroup:" + group})
+				continue
+			}
+			peers = append(peers, cidrPeer(cidr, except))
+		}
+	}
+
+	if direction == DirectionEgress {
+		if fqdns, found := nestedSliceNoCopy(rule, "toFQDNs"); found {
+			hasL3 = true
+			for _, rawFQDN := range fqdns {
+				if fqdn, ok := rawFQDN.(map[string]interface{}); ok {
+					peers = append(peers, ciliumFQDNPeer(fqdn))
+				}
+			}
+		}
+
+		if services, found := nestedSliceNoCopy(rule, "toServices"); found {
+			hasL3 = true
+			for _, rawService := range services {
+				service, ok := rawService.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				peer, err := ciliumServicePeer(policyNamespace, service)
+				if err != nil {
+					return nil, err
+				}
+				peers = append(peers, peer)
+			}
+		}
+	}
+
+	if !hasL3 {
+		return []RulePeer{{Kind: PeerAny}}, nil
+	}
+	return peers, nil
+}
+
+// ciliumFQDNPeer converts a toFQDNs entry. DNS names are resolved by the agent at runtime, so the peer never
+// matches a pod or an address here and is only reported.
+func ciliumFQDNPeer(fqdn map[string]interface{}) RulePeer {
+	if name, _, _ := unstructured.NestedString(fqdn, "matchName"); name != "" {
+		return RulePeer{Kind: PeerFQDN, FQDN: name}
+	}
+	pattern, _, _ := unstructured.NestedString(fqdn, "matchPattern")
+	return RulePeer{Kind: PeerFQDN, FQDN: pattern}
+}
+
+// ciliumServicePeer converts a toServices entry, either a service by name or a selector on service labels.
+// The backends of a service are not resolved, so the peer is only reported.
+func ciliumServicePeer(policyNamespace string, service map[string]interface{}) (RulePeer, error) {
+	if reference, found := nestedMapNoCopy(service, "k8sService"); found {
+		name, _, _ := unstructured.NestedString(reference, "serviceName")
+		namespace, _, _ := unstructured.NestedString(reference, "namespace")
+		if namespace == "" {
+			namespace = policyNamespace
+		}
+		return RulePeer{Kind: PeerService, Namespace: namespace, Service: qualifiedName(ObjectReference{Namespace: namespace, Name: name})}, nil
+	}
+
+	if reference, found := nestedMapNoCopy(service, "k8sServiceSelector"); found {
+		namespace, _, _ := unstructured.NestedString(reference, "namespace")
+		if namespace == "" {
+			namespace = policyNamespace
+		}
+		rawSelector, _ := nestedMapNoCopy(reference, "selector")
+		selector, err := ciliumSelector(rawSelector)
+		if err != nil {
+			return RulePeer{}, fmt.Errorf("invalid k8sServiceSelector: %w", err)
+		}
+		return RulePeer{Kind: PeerService, Namespace: namespace, Service: qualifiedName(ObjectReference{Namespace: namespace, Name: selectorDescription(selector.String())})}, nil
+	}
+
+	return RulePeer{}, fmt.Errorf("toServices entry needs k8sService or k8sServiceSelector")
+}
+
+// ciliumEndpointPeer converts an endpoint selector of a rule. In a namespaced policy it only matches
+// endpoints in the policy namespace unless it selects on the namespace label itself.
+func ciliumEndpointPeer(policyNamespace string, rawSelector interface{}) (RulePeer, error) {
+	selector, err := ciliumSelector(rawSelector)
+	if err != nil {
+		return RulePeer{}, err
+	}
+
+	scopedNamespace := policyNamespace
+	if selectsOnLabel(selector, ciliumNamespaceLabel) {
+		scopedNamespace = ""
+	}
+
+	return RulePeer{
+		Kind:        PeerPods,
+		Namespace:   scopedNamespace,
+		PodSelector: selector.String(),
+		matches: func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+			if scopedNamespace != "" && pod.Namespace != scopedNamespace {
+				return false
+			}
+			return selector.Matches(labels.Set(ciliumEndpointLabels(pod, namespaceLabels)))
+		},
+	}, nil
+}
+
+// ciliumEntityPeer converts a Cilium entity. Only the entities covering cluster pods match pods.
+func ciliumEntityPeer(entity string) RulePeer {
+	peer := RulePeer{Kind: PeerEntity, Entity: entity}
+	if ciliumClusterEntities[entity] {
+		peer.matches = func(corev1.Pod, map[string]string) bool { return true }
+	}
+	return peer
+}
+
+// ciliumPorts converts the toPorts section of a rule. A rule without ports applies to every port.
+func ciliumPorts(rule map[string]interface{}) []RulePort {
+	rulePorts := []RulePort{}
+	toPorts, _ := nestedSliceNoCopy(rule, "toPorts")
+	for _, rawPortRule := range toPorts {
+		portRule, ok := rawPortRule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ports, _ := nestedSliceNoCopy(portRule, "ports")
+		for _, rawPort := range ports {
+			port, ok := rawPort.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rulePort := RulePort{}
+			if protocol, _, _ := unstructured.NestedString(port, "protocol"); protocol != "" && !strings.EqualFold(protocol, "ANY") {
+				rulePort.Protocol = strings.ToUpper(protocol)
+			}
+			if number := fmt.Sprint(port["port"]); port["port"] != nil && number != "0" {
+				rulePort.Port = number
+			}
+			if endPort, found, _ := unstructured.NestedInt64(port, "endPort"); found {
+				rulePort.EndPort = int32(endPort)
+			}
+			rulePorts = append(rulePorts, rulePort)
+		}
+	}
+	return rulePorts
+}
+
+// ciliumSelector converts an unstructured endpoint selector into a label selector.
+func ciliumSelector(rawSelector interface{}) (labels.Selector, error) {
+	selectorMap, ok := rawSelector.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("endpoint selector is not an object")
+	}
+
+	selector, err := parseEndpointSelector(selectorMap)
+	if err != nil {
+		return nil, err
+	}
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+// parseEndpointSelector reads the matchLabels and matchExpressions of an unstructured endpoint selector and
+// normalizes the Cilium source prefixes of its keys. Operators are validated when the selector is converted.
+func parseEndpointSelector(rawSelector map[string]interface{}) (*metav1.LabelSelector, error) {
+	selector := &metav1.LabelSelector{}
+
+	switch matchLabels := rawSelector["matchLabels"].(type) {
+	case nil:
+	case map[string]string:
+		selector.MatchLabels = make(map[string]string, len(matchLabels))
+		for key, value := range matchLabels {
+			selector.MatchLabels[normalizeCiliumLabelKey(key)] = value
+		}
+	case map[string]interface{}:
+		selector.MatchLabels = make(map[string]string, len(matchLabels))
+		for key, value := range matchLabels {
+			strValue, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("value for %s in matchLabels is not a string", key)
+			}
+			selector.MatchLabels[normalizeCiliumLabelKey(key)] = strValue
+		}
+	default:
+		return nil, fmt.Errorf("matchLabels is not a map")
+	}
+
+	rawExpressions, found := rawSelector["matchExpressions"]
+	if !found || rawExpressions == nil {
+		return selector, nil
+	}
+	expressions, ok := rawExpressions.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("matchExpressions is not a list")
+	}
+	for i, rawExpression := range expressions {
+		expression, ok := rawExpression.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("matchExpressions[%d] is not an object", i)
+		}
+		key, _ := expression["key"].(string)
+		operator, _ := expression["operator"].(string)
+		requirement := metav1.LabelSelectorRequirement{Key: normalizeCiliumLabelKey(key), Operator: metav1.LabelSelectorOperator(operator)}
+
+		switch values := expression["values"].(type) {
+		case nil:
+		case []string:
+			requirement.Values = values
+		case []interface{}:
+			for _, value := range values {
+				strValue, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("value %v for %s in matchExpressions is not a string", value, key)
+				}
+				requirement.Values = append(requirement.Values, strValue)
+			}
+		default:
+			return nil, fmt.Errorf("values for %s in matchExpressions is not a list", key)
+		}
+		selector.MatchExpressions = append(selector.MatchExpressions, requirement)
+	}
+
+	return selector, nil
+}
+
+// nestedSliceNoCopy returns a nested slice without deep copying it. Unlike unstructured.NestedSlice it does not
+// panic on values that are not JSON compatible, such as int ports in policies built in code.
+func nestedSliceNoCopy(obj map[string]interface{}, fields ...string) ([]interface{}, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fields...)
+	if err != nil || !found {
+		return nil, false
+	}
+	slice, ok := value.([]interface{})
+	return slice, ok
+}
+
+// nestedMapNoCopy returns a nested map without deep copying it, see nestedSliceNoCopy.
+func nestedMapNoCopy(obj map[string]interface{}, fields ...string) (map[string]interface{}, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fields...)
+	if err != nil || !found {
+		return nil, false
+	}
+	nested, ok := value.(map[string]interface{})
+	return nested, ok
+}
+
+// selectsOnLabel reports whether any requirement of the selector uses the given label key.
+func selectsOnLabel(selector labels.Selector, key string) bool {
+	requirements, _ := selector.Requirements()
+	for _, requirement := range requirements {
+		if requirement.Key() == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCiliumPeers(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction string
+		rule      map[string]interface{}
+		expected  []string
+	}{
+		{"empty rule allows nothing", DirectionIngress, map[string]interface{}{}, []string{}},
+		{
+			"ports only allow every peer", DirectionIngress,
+			map[string]interface{}{"toPorts": []interface{}{map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80"}}}}},
+			[]string{"any"},
+		},
+		{"entities", DirectionIngress, map[string]interface{}{"fromEntities": []interface{}{"world", "cluster"}}, []string{"entity world", "entity cluster"}},
+		{
+			"cidr set", DirectionIngress,
+			map[string]interface{}{"fromCIDRSet": []interface{}{map[string]interface{}{"cidr": "10.0.0.0/8", "except": []interface{}{"10.1.0.0/16"}}}},
+			[]string{"10.0.0.0/8 except 10.1.0.0/16"},
+		},
+		{
+			"fqdns", DirectionEgress,
+			map[string]interface{}{"toFQDNs": []interface{}{map[string]interface{}{"matchName": "api.github.com"}, map[string]interface{}{"matchPattern": "*.amazonaws.com"}}},
+			[]string{"fqdn api.github.com", "fqdn *.amazonaws.com"},
+		},
+		{
+			"services", DirectionEgress,
+			map[string]interface{}{"toServices": []interface{}{
+				map[string]interface{}{"k8sService": map[string]interface{}{"serviceName": "db"}},
+				map[string]interface{}{"k8sServiceSelector": map[string]interface{}{"namespace": "ops", "selector": map[string]interface{}{"matchLabels": map[string]interface{}{"k8s:app": "metrics"}}}},
+			}},
+			[]string{"service shop/db", "service ops/app=metrics"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peers, err := ciliumPeers("shop", tt.direction, tt.rule)
+			assert.NoError(t, err)
+			descriptions := []string{}
+			for _, peer := range peers {
+				descriptions = append(descriptions, peer.String())
+			}
+			assert.Equal(t, tt.expected, descriptions)
+		})
+	}
+
+	_, err := ciliumPeers("shop", DirectionEgress, map[string]interface{}{"toServices": []interface{}{map[string]interface{}{}}})
+	assert.Error(t, err)
+}
+
+func TestCiliumExposure(t *testing.T) {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "CiliumNetworkPolicy",
+		"metadata": map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"ingress": []interface{}{
+				map[string]interface{}{
+					"fromEntities": []interface{}{"world"},
+					"toPorts":      []interface{}{map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "443", "protocol": "TCP"}}}},
+				},
+			},
+			"egress": []interface{}{
+				map[string]interface{}{"toCIDR": []interface{}{"10.20.0.0/16"}},
+				map[string]interface{}{"toFQDNs": []interface{}{map[string]interface{}{"matchName": "api.github.com"}}},
+			},
+		},
+	}}
+
+	models, err := CiliumPolicyModels(policy)
+	assert.NoError(t, err)
+
+	evaluation := EvaluatePod(testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"}), nil, models)
+	assert.Equal(t, []string{"world on 443/TCP"}, evaluation.IngressExposure)
+	assert.Equal(t, []string{"10.20.0.0/16", "fqdn api.github.com"}, evaluation.EgressExposure)
+	assert.Contains(t, evaluation.Findings, FindingIngressOpenToWorld)
+
+	other := EvaluatePod(testPod("shop", "db-0", "10.0.0.2", map[string]string{"app": "db"}), nil, models)
+	assert.Equal(t, []string{ExposureAll}, other.IngressExposure)
+	assert.Equal(t, []string{ExposureAll}, other.EgressExposure)
+}
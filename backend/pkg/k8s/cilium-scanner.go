@@ -6,21 +6,18 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 // Use lipgloss for neat tables in CLI
@@ -110,48 +107,6 @@ func createPoliciesTable(policiesInfo [][]string) string {
 	return t.String()
 }
 
-// GetCiliumDynamicClient returns a dynamic interface to query for Cilium policies
-func GetCiliumDynamicClient(kubeconfigPath string) (dynamic.Interface, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		kubeconfigPath := os.Getenv("KUBECONFIG")
-		if kubeconfigPath == "" {
-			kubeconfigPath = filepath.Join(os.Getenv("HOME"), ".kube", "config")
-		}
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create k8s client config: %s", err)
-		}
-	}
-
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return nil, err
-	}
-	return dynamicClient, nil
-}
-
-// initializeCiliumClients creates and returns initialized dynamic and Kubernetes clientsets.
-func initializeCiliumClients(kubeconfigPath string) (dynamic.Interface, *kubernetes.Clientset, error) {
-	dynamicClient, err := GetCiliumDynamicClient(kubeconfigPath)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error creating dynamic Kubernetes client: %s", err)
-	}
-	if dynamicClient == nil {
-		return nil, nil, fmt.Errorf("failed to create dynamic client: client is nil")
-	}
-
-	clientset, err := GetClientset(kubeconfigPath)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error creating Kubernetes clientset: %s", err)
-	}
-	if clientset == nil {
-		return nil, nil, fmt.Errorf("failed to create clientset: clientset is nil")
-	}
-
-	return dynamicClient, clientset, nil
-}
-
 // fetchCiliumPolicies fetches all Cilium network policies within the specified namespace.
 func fetchCiliumPolicies(dynamicClient dynamic.Interface, nsName string, writer *bufio.Writer) ([]*unstructured.Unstructured, bool, error) {
 	ciliumNPResource := schema.GroupVersionResource{
@@ -188,45 +143,69 @@ func addUniquePodDetail(podDetails []string, detail string) []string {
     return append(podDetails, detail) // add pod if its not in list
 }
 
-// determinePodCoverage identifies unprotected pods in a namespace based on the fetched Cilium policies.
-func determinePodCoverage(clientset *kubernetes.Clientset, nsName string, policies []*unstructured.Unstructured, hasDenyAll bool, writer *bufio.Writer) ([]string, error) {
+// determinePodCoverage identifies unprotected pods in a namespace based on the fetched Cilium policies and their models.
+// Protected pods are added to the protected pod set of the scan.
+func determinePodCoverage(pods []corev1.Pod, namespaceLabels map[string]string, policies []*unstructured.Unstructured, models []PolicyModel, hasDenyAll bool, protectedPods map[string]struct{}) []string {
 	unprotectedPods := []string{}
-
-	pods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		printToBoth(writer, fmt.Sprintf("Error listing all pods in namespace %s: %s\n", nsName, err))
-		return nil, fmt.Errorf("error listing all pods: %w", err)
-	}
-
-	for _, pod := range pods.Items {
-		// Skip pods that are not in running state
-		if pod.Status.Phase != corev1.PodRunning {
-			continue
-		}
+	for _, pod := range scannedPods(pods) {
 		podIdentifier := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
-        if _, exists := globallyProtectedPods[podIdentifier]; !exists {
-            if !IsPodProtected(writer, clientset, pod, policies, hasDenyAll, globallyProtectedPods) {
+        if _, exists := protectedPods[podIdentifier]; !exists {
+            if !IsPodProtected(pod, namespaceLabels, policies, models, hasDenyAll, protectedPods) {
                 unprotectedPodDetails := fmt.Sprintf("%s %s %s", pod.Namespace, pod.Name, pod.Status.PodIP)
                 unprotectedPods = addUniquePodDetail(unprotectedPods, unprotectedPodDetails)
             } else {
-                globallyProtectedPods[podIdentifier] = struct{}{} // Mark the pod as protected globally
+                protectedPods[podIdentifier] = struct{}{} // Mark the pod as protected for the rest of the scan
             }
         }
     }
 
-	return unprotectedPods, nil
+	return unprotectedPods
 }
 
-// processNamespacePoliciesCilium processes Cilium network policies for a given namespace to identify unprotected pods.
-func processNamespacePoliciesCilium(dynamicClient dynamic.Interface, clientset *kubernetes.Clientset, nsName string, writer *bufio.Writer, scanResult *ScanResult, dryRun bool, isCLI bool) error {
+// ciliumPolicyModels converts Cilium policies into the engine's policy model, reporting the ones that cannot be parsed.
+func ciliumPolicyModels(policies []*unstructured.Unstructured, writer *bufio.Writer) []PolicyModel {
+	var models []PolicyModel
+	for _, policy := range policies {
+		policyModels, err := CiliumPolicyModels(policy)
+		if err != nil {
+			printToBoth(writer, fmt.Sprintf("Error evaluating policy: %s\n", err))
+			continue
+		}
+		models = append(models, policyModels...)
+	}
+	return models
+}
+
+// processNamespacePoliciesCilium processes Cilium network policies for a given namespace to identify unprotected pods
+// and evaluates what every pod is exposed to, taking the clusterwide policies into account.
+func processNamespacePoliciesCilium(dynamicClient dynamic.Interface, clientset kubernetes.Interface, nsName string, clusterwidePolicies []*unstructured.Unstructured, clusterwideModels []PolicyModel, protectedPods map[string]struct{}, writer *bufio.Writer, scanResult *ScanResult, dryRun bool, isCLI bool) error {
 	ciliumPolicies, hasDenyAll, err := fetchCiliumPolicies(dynamicClient, nsName, writer)
 	if err != nil {
 		return err
 	}
-
-	unprotectedPods, err := determinePodCoverage(clientset, nsName, ciliumPolicies, hasDenyAll, writer)
+	pods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		return err
+		printToBoth(writer, fmt.Sprintf("Error listing all pods in namespace %s: %s\n", nsName, err))
+		return fmt.Errorf("error listing all pods: %w", err)
+	}
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.TODO(), nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting namespace %s: %w", nsName, err)
+	}
+
+	// The policies are parsed once, and both the coverage and the evaluation of every pod use their models
+	coveringPolicies := append(append([]*unstructured.Unstructured{}, ciliumPolicies...), clusterwidePolicies...)
+	models := append(ciliumPolicyModels(ciliumPolicies, writer), clusterwideModels...)
+	unprotectedPods := determinePodCoverage(pods.Items, namespace.Labels, coveringPolicies, models, hasDenyAll, protectedPods)
+	unprotectedPods = acceptUnprotectedPods(unprotectedPods, scanResult)
+
+	evaluations := EvaluatePods(scannedPods(pods.Items), []corev1.Namespace{*namespace}, models)
+	sortEvaluations(evaluations)
+	acceptPodFindings(evaluations, scanResult)
+	scanResult.PodEvaluations = append(scanResult.PodEvaluations, evaluations...)
+
+	if hasDenyAll && !contains(scanResult.HasDenyAll, nsName) {
+		scanResult.HasDenyAll = append(scanResult.HasDenyAll, nsName)
 	}
 
 	if len(unprotectedPods) > 0 {
@@ -234,11 +213,16 @@ func processNamespacePoliciesCilium(dynamicClient dynamic.Interface, clientset *
 		scanResult.UnprotectedPods = append(scanResult.UnprotectedPods, unprotectedPods...)
 
 		if isCLI && !dryRun {
-			return handleCLIInteractionsCilium(nsName, unprotectedPods, dynamicClient, writer, scanResult, dryRun)
-		} else {
+			if err := handleCLIInteractionsCilium(nsName, unprotectedPods, dynamicClient, writer, scanResult, dryRun); err != nil {
+				return err
+			}
+		} else if dryRun {
 			displayUnprotectedPods(nsName, unprotectedPods, writer)
 		}
 	}
+	if isCLI {
+		displayPodExposure(nsName, evaluations, writer)
+	}
 
 	return nil
 }
@@ -254,7 +238,7 @@ func handleCLIInteractionsCilium(nsName string, unprotectedPods []string, dynami
 	styledHeaderText := HeaderStyle.Render(headerText)
 	printToBoth(writer, styledHeaderText+"\n"+tableOutput+"\n")
 
-	if !dryRun {
+	if !dryRun && interactive {
 		confirm := false
 		prompt := &survey.Confirm{
 			Message: fmt.Sprintf("Do you want to add a default deny all Cilium network policy to the namespace %s?", nsName),
@@ -277,47 +261,15 @@ func handleCLIInteractionsCilium(nsName string, unprotectedPods []string, dynami
 	return nil
 }
 
-// SelectCiliumNamespaces selects namespaces for scanning based on the input criteria
-func SelectCiliumNamespaces(clientset *kubernetes.Clientset, specificNamespace string) ([]string, error) {
-	var namespaces []string
-	if specificNamespace != "" {
-		// Check if the specified namespace exists
-		_, err := clientset.CoreV1().Namespaces().Get(context.TODO(), specificNamespace, metav1.GetOptions{})
-		if err != nil {
-			if k8serrors.IsNotFound(err) {
-				return nil, fmt.Errorf("namespace %s does not exist", specificNamespace)
-			}
-			return nil, fmt.Errorf("error checking namespace %s: %v", specificNamespace, err)
-		}
-		namespaces = append(namespaces, specificNamespace)
-	} else {
-		// List all namespaces and filter out system namespaces
-		nsList, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("error listing namespaces: %v", err)
-		}
-		for _, ns := range nsList.Items {
-			if !IsSystemNamespace(ns.Name) {
-				namespaces = append(namespaces, ns.Name)
-			}
-		}
-	}
-	return namespaces, nil
-}
-
-var hasStartedCiliumScan bool = false
-var globallyProtectedPods = make(map[string]struct{})
-
 // ScanCiliumNetworkPolicies scans namespaces for Cilium network policies
 func ScanCiliumNetworkPolicies(specificNamespace string, dryRun bool, returnResult bool, isCLI bool, printScore bool, printMessages bool, kubeconfigPath string) (*ScanResult, error) {
 	var output bytes.Buffer
 
-	unprotectedPodsCount := 0
-	scanResult := new(ScanResult)
+	scanResult := &ScanResult{PolicyType: PolicyTypeCilium}
 
 	writer := bufio.NewWriter(&output)
 
-	dynamicClient, clientset, err := initializeCiliumClients(kubeconfigPath)
+	dynamicClient, clientset, err := initializeDynamicClients(kubeconfigPath)
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
@@ -325,54 +277,53 @@ func ScanCiliumNetworkPolicies(specificNamespace string, dryRun bool, returnResu
 
 	// Check if a specific namespace is provided
 	var namespacesToScan []string
-	namespacesToScan, err = SelectCiliumNamespaces(clientset, specificNamespace)
+	namespacesToScan, err = SelectNamespaces(clientset, specificNamespace)
 	if err != nil {
 		return nil, err
 	}
+	scanResult.NamespacesScanned = namespacesToScan
+	if err := useWaivers(clientset, scanResult); err != nil {
+		printToBoth(writer, fmt.Sprintf("Error loading waivers: %s\n", err))
+	}
 
-	missingPoliciesOrUncoveredPods := false
-	userDeniedPolicyApplication := false
-
-	if isCLI && !hasStartedCiliumScan {
+	if isCLI {
 		fmt.Println("Policy type: Cilium")
-		hasStartedCiliumScan = true
 	}
 
-	// Process each namespace for policies and unprotected pods
+	// Clusterwide policies select pods in every namespace and are evaluated together with the namespaced ones
+	clusterwidePolicies, err := fetchCiliumClusterwidePolicies(dynamicClient)
+	if err != nil {
+		printToBoth(writer, fmt.Sprintf("Error listing Cilium clusterwide network policies: %s\n", err))
+	}
+	clusterwideModels := ciliumPolicyModels(clusterwidePolicies, writer)
+
+	// Process each namespace for policies and unprotected pods. Every scan starts without protected pods, so a pod
+	// whose policy was deleted since the previous scan is reported again.
+	protectedPods := map[string]struct{}{}
 	for _, nsName := range namespacesToScan {
-		scanResult.UnprotectedPods = []string{}
-		if err := processNamespacePoliciesCilium(dynamicClient, clientset, nsName, writer, scanResult, dryRun, isCLI); err != nil {
+		if err := processNamespacePoliciesCilium(dynamicClient, clientset, nsName, clusterwidePolicies, clusterwideModels, protectedPods, writer, scanResult, dryRun, isCLI); err != nil {
 			return nil, err
 		}
-		unprotectedPodsCount += len(scanResult.UnprotectedPods)
-
-		if len(scanResult.UnprotectedPods) > 0 {
-			missingPoliciesOrUncoveredPods = true
-		}
 	}
 
 	writer.Flush()
-	if output.Len() > 0 {
+	if isCLI && output.Len() > 0 {
 		handleOutputAndPromptsCilium(writer, &output)
 	}
 
-	score := CalculateScore(!missingPoliciesOrUncoveredPods, !userDeniedPolicyApplication, unprotectedPodsCount)
-	scanResult.Score = score
-
 	if printMessages {
 		printToBoth(writer, "\nNetfetch scan completed!\n")
 	}
 
-	if printScore {
-		// Print the final score
-		fmt.Printf("\nYour Netfetch security score is: %d/100\n", score)
-	}
+	scoreResult(scanResult, printScore)
 
-	hasStartedCiliumScan = false
 	return scanResult, nil
 }
 
 func handleOutputAndPromptsCilium(writer *bufio.Writer, output *bytes.Buffer) {
+	if !interactive {
+		return
+	}
 	saveToFile := false
 	prompt := &survey.Confirm{
 		Message: "Do you want to save the output to netfetch-cilium.txt?",
@@ -453,7 +404,7 @@ func handleClusterwideCLIInteractions(writer *bufio.Writer, dynamicClient dynami
 			promptForPolicyCreation = true
 		}
 
-		if promptForPolicyCreation && isCLI && !dryRun {
+		if promptForPolicyCreation && isCLI && !dryRun && interactive {
 			// Prompt to create a default deny-all policy
 			createPolicy := false
 			prompt := &survey.Confirm{
@@ -479,7 +430,8 @@ func handleClusterwideCLIInteractions(writer *bufio.Writer, dynamicClient dynami
 }
 
 // checkPodProtection checks each pod against the given policies to determine if it's protected.
-func checkPodProtection(clientset *kubernetes.Clientset, unstructuredPolicies []*unstructured.Unstructured, appliesToEntireCluster bool, writer *bufio.Writer) ([]string, error) {
+func checkPodProtection(clientset kubernetes.Interface, unstructuredPolicies []*unstructured.Unstructured, appliesToEntireCluster bool, protectedPods map[string]struct{}, writer *bufio.Writer) ([]string, error) {
+	models := ciliumPolicyModels(unstructuredPolicies, writer)
 	unprotectedPods := []string{}
 	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
 	if err != nil {
@@ -487,11 +439,23 @@ func checkPodProtection(clientset *kubernetes.Clientset, unstructuredPolicies []
 		return nil, fmt.Errorf("failed to list pods: %v", err)
 	}
 
-	for _, pod := range pods.Items {
-		if !IsSystemNamespace(pod.Namespace) {
-			if IsPodProtected(writer, clientset, pod, unstructuredPolicies, appliesToEntireCluster, globallyProtectedPods) {
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		printToBoth(writer, fmt.Sprintf("Error listing namespaces: %v\n", err))
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	scannedNamespaces := map[string]bool{}
+	namespaceLabels := map[string]map[string]string{}
+	for _, ns := range namespaces.Items {
+		scannedNamespaces[ns.Name] = activeConfig.IncludesNamespace(ns.Name, ns.Labels)
+		namespaceLabels[ns.Name] = ns.Labels
+	}
+
+	for _, pod := range scannedPods(pods.Items) {
+		if scannedNamespaces[pod.Namespace] {
+			if IsPodProtected(pod, namespaceLabels[pod.Namespace], unstructuredPolicies, models, appliesToEntireCluster, protectedPods) {
 				podIdentifier := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
-				globallyProtectedPods[podIdentifier] = struct{}{}
+				protectedPods[podIdentifier] = struct{}{}
 			} else {
 				unprotectedPodDetails := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
 				unprotectedPods = append(unprotectedPods, unprotectedPodDetails)
@@ -543,7 +507,7 @@ func ScanCiliumClusterwideNetworkPolicies(dynamicClient dynamic.Interface, print
 		return nil, fmt.Errorf("failed to create dynamic client: client is nil")
 	}
 
-	dynamicClient, clientset, err := initializeCiliumClients(kubeconfigPath)
+	dynamicClient, clientset, err := initializeDynamicClients(kubeconfigPath)
 	if err != nil {
 		fmt.Println("Error initializing clients:", err)
 		return nil, err
@@ -555,16 +519,12 @@ func ScanCiliumClusterwideNetworkPolicies(dynamicClient dynamic.Interface, print
 		return nil, err
 	}
 
-	if isCLI && !hasStartedCiliumScan {
-		fmt.Println("Policy type: Cilium")
-		hasStartedCiliumScan = true
-	}
-
 	// Report the detected policies
 	reportClusterwideDetectedPolicies(unstructuredPolicies, writer, isCLI)
 
 	// Initialize the scan result
 	scanResult := &ScanResult{
+		PolicyType:         PolicyTypeCiliumClusterwide,
 		NamespacesScanned:  []string{"cluster-wide"},
 		DeniedNamespaces:   []string{},
 		UnprotectedPods:    []string{},
@@ -584,11 +544,14 @@ func ScanCiliumClusterwideNetworkPolicies(dynamicClient dynamic.Interface, print
 	}
 
 	// Check pod protection
-	unprotectedPods, err := checkPodProtection(clientset, unstructuredPolicies, appliesToEntireCluster, writer)
+	unprotectedPods, err := checkPodProtection(clientset, unstructuredPolicies, appliesToEntireCluster, map[string]struct{}{}, writer)
 	if err != nil {
 		return nil, err
 	}
-	scanResult.UnprotectedPods = unprotectedPods
+	if err := useWaivers(clientset, scanResult); err != nil {
+		printToBoth(writer, fmt.Sprintf("Error loading waivers: %s\n", err))
+	}
+	scanResult.UnprotectedPods = acceptUnprotectedPods(unprotectedPods, scanResult)
 
 	reportPodProtectionStatus(writer, unprotectedPods)
 
@@ -597,15 +560,17 @@ func ScanCiliumClusterwideNetworkPolicies(dynamicClient dynamic.Interface, print
 	}
 
 	writer.Flush()
-	if output.Len() > 0 {
+	if isCLI && output.Len() > 0 {
 		handleOutputAndPromptsClusterwideCilium(writer, &output)
 	}
 
-	hasStartedCiliumScan = true
 	return scanResult, nil
 }
 
 func handleOutputAndPromptsClusterwideCilium(writer *bufio.Writer, output *bytes.Buffer) {
+	if !interactive {
+		return
+	}
 	saveToFile := false
 	prompt := &survey.Confirm{
 		Message: "Do you want to save the output to netfetch-clusterwide-cilium.txt?",
@@ -624,64 +589,50 @@ func handleOutputAndPromptsClusterwideCilium(writer *bufio.Writer, output *bytes
 	}
 }
 
-func isProtectedByDefaultDeny(policy *unstructured.Unstructured, globallyProtectedPods map[string]struct{}, podIdentifier string) bool {
+func isProtectedByDefaultDeny(policy *unstructured.Unstructured, protectedPods map[string]struct{}, podIdentifier string) bool {
 	_, appliesToEntireCluster := IsDefaultDenyAllCiliumClusterwidePolicy(*policy)
 	if appliesToEntireCluster {
-		globallyProtectedPods[podIdentifier] = struct{}{}
+		protectedPods[podIdentifier] = struct{}{}
 		return true
 	}
 	return false
 }
 
-func isProtectedByLabelMatch(policies []*unstructured.Unstructured, pod corev1.Pod, globallyProtectedPods map[string]struct{}, podIdentifier string) bool {
-	for _, policy := range policies {
-		endpointSelector, _, _ := unstructured.NestedMap(policy.UnstructuredContent(), "endpointSelector", "matchLabels")
-		if MatchesLabels(pod.Labels, endpointSelector) {
-			ingress, foundIngress, _ := unstructured.NestedSlice(policy.UnstructuredContent(), "ingress")
-			egress, foundEgress, _ := unstructured.NestedSlice(policy.UnstructuredContent(), "egress")
-
-			// Check for deny-all conditions based on empty ingress/egress
-			if (foundIngress && (IsEmptyOrOnlyContainsEmptyObjects(ingress) || IsSpecificallyEmpty(ingress))) ||
-				(foundEgress && (IsEmptyOrOnlyContainsEmptyObjects(egress) || IsSpecificallyEmpty(egress))) {
-				globallyProtectedPods[podIdentifier] = struct{}{}
-				return true
-			}
-
-			// Additional check for non-empty specific rules
-			if foundIngress && !IsEmptyOrOnlyContainsEmptyObjects(ingress) || foundEgress && !IsEmptyOrOnlyContainsEmptyObjects(egress) {
-				globallyProtectedPods[podIdentifier] = struct{}{}
-				return true
-			}
+// isProtectedByLabelMatch reports whether any of the policy models selects the pod and isolates it in at least one
+// direction.
+func isProtectedByLabelMatch(models []PolicyModel, pod corev1.Pod, namespaceLabels map[string]string, protectedPods map[string]struct{}, podIdentifier string) bool {
+	for _, model := range models {
+		if model.Selects(pod, namespaceLabels) && (model.IsolatesIngress || model.IsolatesEgress) {
+			protectedPods[podIdentifier] = struct{}{}
+			return true
 		}
 	}
 	return false
 }
 
-func IsPodProtected(writer *bufio.Writer, clientset *kubernetes.Clientset, pod corev1.Pod, policies []*unstructured.Unstructured, defaultDenyAllExists bool, globallyProtectedPods map[string]struct{}) bool {
+// IsPodProtected reports whether the policies protect the pod, and adds it to the protected pod set of the scan. The
+// models are the parsed policies, and the namespace labels those of the pod's namespace.
+func IsPodProtected(pod corev1.Pod, namespaceLabels map[string]string, policies []*unstructured.Unstructured, models []PolicyModel, defaultDenyAllExists bool, protectedPods map[string]struct{}) bool {
 	podIdentifier := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
 
 	// Immediate return if already protected
-	if _, protected := globallyProtectedPods[podIdentifier]; protected {
+	if _, protected := protectedPods[podIdentifier]; protected {
 		return true
 	}
 
 	// Apply default deny-all if it exists
 	if defaultDenyAllExists {
-		globallyProtectedPods[podIdentifier] = struct{}{}
+		protectedPods[podIdentifier] = struct{}{}
 		return true
 	}
 
-	// Check each policy for default deny or label match
+	// Check each policy for a cluster wide default deny, then whether any policy selects the pod
 	for _, policy := range policies {
-		if isProtectedByDefaultDeny(policy, globallyProtectedPods, podIdentifier) {
-			return true
-		}
-		if isProtectedByLabelMatch(policies, pod, globallyProtectedPods, podIdentifier) {
+		if isProtectedByDefaultDeny(policy, protectedPods, podIdentifier) {
 			return true
 		}
 	}
-
-	return false
+	return isProtectedByLabelMatch(models, pod, namespaceLabels, protectedPods, podIdentifier)
 }
 
 // Check specifically for a slice that only contains a single empty map ({}), representing a default deny.
@@ -718,39 +669,38 @@ func IsSpecificallyEmpty(slice []interface{}) bool {
 // 	}
 // }
 
-// MatchesLabels checks if the pod's labels match the policy's endpointSelector
+// MatchesLabels checks if the pod's labels match the policy's endpointSelector.
+// The selector may be a full endpointSelector with matchLabels and matchExpressions, or a plain map of labels.
 func MatchesLabels(podLabels map[string]string, policySelector map[string]interface{}) bool {
-	for key, value := range policySelector {
-		if val, ok := value.(string); ok {
-			if podVal, podOk := podLabels[key]; !podOk || podVal != val {
-				return false
-			}
-		} else {
-			fmt.Printf("Policy label value %v is not a string\n", value)
-			return false
-		}
+	selector, err := ciliumSelector(asEndpointSelector(policySelector))
+	if err != nil {
+		fmt.Printf("Invalid endpoint selector %v: %v\n", policySelector, err)
+		return false
 	}
-	return true
+	return selector.Matches(labels.Set(podLabels))
 }
 
-// ConvertEndpointToSelector converts the endpointSelector from a CiliumNetworkPolicy to a label selector string.
-func ConvertEndpointToSelector(endpointSelector map[string]interface{}) (string, error) {
-	matchLabels, found := endpointSelector["matchLabels"].(map[string]interface{})
-	if !found || len(matchLabels) == 0 {
-		return "", nil
+// asEndpointSelector wraps a plain map of labels into an endpointSelector.
+func asEndpointSelector(policySelector map[string]interface{}) map[string]interface{} {
+	if _, found := policySelector["matchExpressions"]; found {
+		return policySelector
 	}
-
-	var selectorParts []string
-	for key, val := range matchLabels {
-		if strVal, ok := val.(string); ok {
-			selectorParts = append(selectorParts, fmt.Sprintf("%s=%s", key, strVal))
-		} else {
-			return "", fmt.Errorf("value for %s in matchLabels is not a string", key)
+	if matchLabels, found := policySelector["matchLabels"]; found {
+		if _, isString := matchLabels.(string); !isString {
+			return policySelector
 		}
 	}
+	return map[string]interface{}{"matchLabels": policySelector}
+}
 
-	labelSelector := strings.Join(selectorParts, ",")
-	return labelSelector, nil
+// ConvertEndpointToSelector converts the endpointSelector from a CiliumNetworkPolicy to a label selector string.
+// Both matchLabels and matchExpressions (In, NotIn, Exists and DoesNotExist) are supported.
+func ConvertEndpointToSelector(endpointSelector map[string]interface{}) (string, error) {
+	selector, err := ciliumSelector(endpointSelector)
+	if err != nil {
+		return "", err
+	}
+	return selector.String(), nil
 }
 
 // CreateAndApplyDefaultDenyCiliumClusterwidePolicy creates and applies a default deny all network policy for Cilium at the cluster level.
@@ -829,6 +779,9 @@ func IsEmptyOrOnlyContainsEmptyObjects(slice []interface{}) bool {
 
 // Helper function to check if the endpointSelector is effectively empty
 func isEndpointSelectorEmpty(selector map[string]interface{}) bool {
+	if expressions, found := selector["matchExpressions"].([]interface{}); found && len(expressions) > 0 {
+		return false
+	}
 	matchLabels, found := selector["matchLabels"].(map[string]interface{})
 	return !found || len(matchLabels) == 0
 }
@@ -884,14 +837,23 @@ func HasDefaultDenyAllCiliumPolicy(policies []*unstructured.Unstructured) bool {
 	return false
 }
 
-// IsDefaultDenyAllCiliumPolicy checks if a single Cilium policy is a default deny-all policy
+// IsDefaultDenyAllCiliumPolicy checks if a single Cilium policy is a default deny-all policy: every spec selects all
+// endpoints, isolates them and has no rule allowing traffic from or to any peer.
 func IsDefaultDenyAllCiliumPolicy(policyUnstructured unstructured.Unstructured) bool {
-	spec, found := policyUnstructured.UnstructuredContent()["spec"].(map[string]interface{})
-	if !found {
+	models, err := CiliumPolicyModels(&policyUnstructured)
+	if err != nil || len(models) == 0 {
 		return false
 	}
 
-	ingress, ingressFound := spec["ingress"]
-	egress, egressFound := spec["egress"]
-	return (!ingressFound || len(ingress.([]interface{})) == 0) && (!egressFound || len(egress.([]interface{})) == 0)
+	for _, model := range models {
+		if model.Selector != selectorDescription("") || !(model.IsolatesIngress || model.IsolatesEgress) {
+			return false
+		}
+		for _, rule := range model.Rules {
+			if rule.Action == ActionAllow && len(rule.Peers) > 0 {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -1,10 +1,18 @@
 package k8s
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestMatchesLabels(t *testing.T) {
@@ -36,6 +44,58 @@ func TestMatchesLabels(t *testing.T) {
 			},
 			expectedMatch: false,
 		},
+		{
+			name: "In and Exists expressions",
+			podLabels: map[string]string{
+				"app":  "test",
+				"tier": "backend",
+			},
+			policyLabels: map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "app", "operator": "In", "values": []interface{}{"test", "other"}},
+					map[string]interface{}{"key": "tier", "operator": "Exists"},
+				},
+			},
+			expectedMatch: true,
+		},
+		{
+			name: "NotIn expression",
+			podLabels: map[string]string{
+				"app": "test",
+			},
+			policyLabels: map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "app", "operator": "NotIn", "values": []interface{}{"test"}},
+				},
+			},
+			expectedMatch: false,
+		},
+		{
+			name: "matchLabels combined with DoesNotExist",
+			podLabels: map[string]string{
+				"app":   "test",
+				"debug": "true",
+			},
+			policyLabels: map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "test"},
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "debug", "operator": "DoesNotExist"},
+				},
+			},
+			expectedMatch: false,
+		},
+		{
+			name: "Unknown operator",
+			podLabels: map[string]string{
+				"app": "test",
+			},
+			policyLabels: map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "app", "operator": "Matches", "values": []interface{}{"test"}},
+				},
+			},
+			expectedMatch: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -72,6 +132,20 @@ func TestConvertEndpointToSelector(t *testing.T) {
 			expectedSelector: "",
 			expectedError:    nil,
 		},
+		{
+			name: "Selector with matchExpressions",
+			endpointSelector: map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "test"},
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "env", "operator": "In", "values": []interface{}{"prod", "staging"}},
+					map[string]interface{}{"key": "legacy", "operator": "DoesNotExist"},
+					map[string]interface{}{"key": "team", "operator": "NotIn", "values": []interface{}{"qa"}},
+					map[string]interface{}{"key": "tier", "operator": "Exists"},
+				},
+			},
+			expectedSelector: "app=test,env in (prod,staging),!legacy,team notin (qa),tier",
+			expectedError:    nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -234,6 +308,43 @@ func TestIsDefaultDenyAllCiliumPolicy(t *testing.T) {
 			},
 			expectedDenyAll: false,
 		},
+		{
+			name: "Empty Rules Allow Nothing",
+			policyUnstructured: unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"endpointSelector": map[string]interface{}{},
+						"ingress":          []interface{}{map[string]interface{}{}},
+						"egress":           []interface{}{map[string]interface{}{}},
+					},
+				},
+			},
+			expectedDenyAll: true,
+		},
+		{
+			name: "Selects Some Endpoints",
+			policyUnstructured: unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+						"ingress":          []interface{}{},
+					},
+				},
+			},
+			expectedDenyAll: false,
+		},
+		{
+			name: "Allows Traffic From Entity",
+			policyUnstructured: unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"spec": map[string]interface{}{
+						"endpointSelector": map[string]interface{}{},
+						"ingress":          []interface{}{map[string]interface{}{"fromEntities": []interface{}{"cluster"}}},
+					},
+				},
+			},
+			expectedDenyAll: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -245,4 +356,101 @@ func TestIsDefaultDenyAllCiliumPolicy(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestIsProtectedByLabelMatch(t *testing.T) {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "CiliumNetworkPolicy",
+		"metadata": map[string]interface{}{"name": "backend", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "tier", "operator": "In", "values": []interface{}{"backend"}},
+				},
+			},
+			"ingress": []interface{}{map[string]interface{}{}},
+		},
+	}}
+
+	backend := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api", Labels: map[string]string{"tier": "backend"}}}
+	frontend := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web", Labels: map[string]string{"tier": "frontend"}}}
+
+	models, err := CiliumPolicyModels(policy)
+	assert.NoError(t, err)
+	protected := make(map[string]struct{})
+	assert.True(t, isProtectedByLabelMatch(models, backend, nil, protected, "shop/api"))
+	assert.False(t, isProtectedByLabelMatch(models, frontend, nil, protected, "shop/web"))
+	assert.Contains(t, protected, "shop/api")
+}
+
+func TestScanCiliumNetworkPoliciesAfterPolicyDeleted(t *testing.T) {
+	shop := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"ingress":          []interface{}{map[string]interface{}{}},
+		},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, policy)
+
+	previousClientset, previousInitialized, previousDynamicClient := clientset, isClientInitialized, sharedDynamicClient
+	UseOfflineClients(fake.NewSimpleClientset(shop, &web), dynamicClient)
+	defer func() {
+		clientset, isClientInitialized, sharedDynamicClient = previousClientset, previousInitialized, previousDynamicClient
+	}()
+
+	result, err := ScanCiliumNetworkPolicies("shop", false, true, false, false, false, "")
+	assert.NoError(t, err)
+	assert.Empty(t, result.UnprotectedPods)
+
+	// A pod protected by an earlier scan is reported once its policy is gone
+	assert.NoError(t, dynamicClient.Resource(ciliumNetworkPolicyResource).Namespace("shop").Delete(context.TODO(), "web", metav1.DeleteOptions{}))
+	result, err = ScanCiliumNetworkPolicies("shop", false, true, false, false, false, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shop web-0 10.0.0.1"}, result.UnprotectedPods)
+}
+
+func TestProcessNamespacePoliciesCiliumReadsNamespaceOnce(t *testing.T) {
+	shop := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "shop"}}}
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	api := testPod("shop", "api-0", "10.0.0.2", map[string]string{"app": "api"})
+	db := testPod("shop", "db-0", "10.0.0.3", map[string]string{"app": "db"})
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"ingress":          []interface{}{map[string]interface{}{}},
+		},
+	}}
+	clusterwide := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumClusterwideNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "shop-api"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "api", "io.cilium.k8s.namespace.labels.team": "shop"}},
+			"egress":           []interface{}{map[string]interface{}{}},
+		},
+	}}
+	clusterwideModels, err := CiliumPolicyModels(clusterwide)
+	assert.NoError(t, err)
+
+	clientset := fake.NewSimpleClientset(shop, &web, &api, &db)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, policy)
+	var output bytes.Buffer
+	scanResult := &ScanResult{}
+	err = processNamespacePoliciesCilium(dynamicClient, clientset, "shop", []*unstructured.Unstructured{clusterwide}, clusterwideModels, map[string]struct{}{}, bufio.NewWriter(&output), scanResult, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shop db-0 10.0.0.3"}, scanResult.UnprotectedPods)
+
+	// The pods and the labels of the namespace are read once, however many pods and policies there are
+	calls := map[string]int{}
+	for _, action := range clientset.Actions() {
+		calls[action.GetVerb()+" "+action.GetResource().Resource]++
+	}
+	assert.Equal(t, map[string]int{"list pods": 1, "get namespaces": 1}, calls)
+}
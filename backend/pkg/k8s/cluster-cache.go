@@ -0,0 +1,423 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	networkingv1client "k8s.io/client-go/kubernetes/typed/networking/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	networkingv1listers "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// clusterCacheSyncTimeout bounds how long the cluster cache waits for its informers to list the cluster
+const clusterCacheSyncTimeout = 2 * time.Minute
+
+// cachedPolicyResources are the custom policy resources the cluster cache watches when the cluster serves them
+var cachedPolicyResources = []schema.GroupVersionResource{
+	ciliumNetworkPolicyResource,
+	ciliumClusterwideNetworkPolicyResource,
+	adminNetworkPolicyResource,
+	baselineAdminNetworkPolicyResource,
+	calicoAPIGroupVersion.WithResource(calicoNetworkPolicies),
+	calicoAPIGroupVersion.WithResource(calicoGlobalNetworkPolicies),
+	calicoAPIGroupVersion.WithResource(calicoTiersResource),
+	calicoCRDGroupVersion.WithResource(calicoNetworkPolicies),
+	calicoCRDGroupVersion.WithResource(calicoGlobalNetworkPolicies),
+	calicoCRDGroupVersion.WithResource(calicoTiersResource),
+	antreaGroupVersion.WithResource(antreaClusterNetworkPolicies),
+	antreaGroupVersion.WithResource(antreaNetworkPolicies),
+	antreaGroupVersion.WithResource(antreaTiersResource),
+	antreaGroupVersion.WithResource(antreaClusterGroupsResource),
+	antreaGroupVersion.WithResource(antreaGroupsResource),
+}
+
+// ClusterCache keeps the pods, namespaces, NetworkPolicies and Cilium, admin, Calico and Antrea policies of a
+// cluster in memory through shared informers, so the dashboard answers requests without listing them from the API
+// server.
+type ClusterCache struct {
+	clientset       kubernetes.Interface
+	dynamicClient   dynamic.Interface
+	pods            corev1listers.PodLister
+	namespaces      corev1listers.NamespaceLister
+	networkPolicies networkingv1listers.NetworkPolicyLister
+	resources       map[schema.GroupVersionResource]cache.GenericLister
+	events          *EventBroker
+
+	subscriptionsMu sync.Mutex
+	// subscriptions collect the namespaces of changed objects for WatchProtection and other watchers
+	subscriptions map[*ChangeSubscription]struct{}
+}
+
+// NewClusterCache starts the informers of the cluster cache and waits until they have listed the cluster. The
+// informers run until stopCh is closed. Custom policy resources the cluster does not serve, or that cannot be
+// listed in time, are not cached and keep being read from the API server.
+func NewClusterCache(clientset kubernetes.Interface, dynamicClient dynamic.Interface, stopCh <-chan struct{}) (*ClusterCache, error) {
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	pods := factory.Core().V1().Pods()
+	namespaces := factory.Core().V1().Namespaces()
+	networkPolicies := factory.Networking().V1().NetworkPolicies()
+
+	clusterCache := &ClusterCache{
+		clientset:       clientset,
+		dynamicClient:   dynamicClient,
+		pods:            pods.Lister(),
+		namespaces:      namespaces.Lister(),
+		networkPolicies: networkPolicies.Lister(),
+		resources:       map[schema.GroupVersionResource]cache.GenericLister{},
+		events:          NewEventBroker(),
+		subscriptions:   map[*ChangeSubscription]struct{}{},
+	}
+
+	typedSynced := []cache.InformerSynced{pods.Informer().HasSynced, namespaces.Informer().HasSynced, networkPolicies.Informer().HasSynced}
+	pods.Informer().AddEventHandler(clusterCache.workloadEventHandler())
+	namespaces.Informer().AddEventHandler(clusterCache.workloadEventHandler())
+	networkPolicies.Informer().AddEventHandler(clusterCache.policyEventHandler("NetworkPolicy"))
+
+	dynamicFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+	dynamicInformers := map[schema.GroupVersionResource]informers.GenericInformer{}
+	for _, resource := range cachedPolicyResources {
+		if resourceServed(clientset, resource) {
+			dynamicInformers[resource] = dynamicFactory.ForResource(resource)
+			dynamicInformers[resource].Informer().AddEventHandler(clusterCache.policyEventHandler(""))
+		}
+	}
+
+	factory.Start(stopCh)
+	dynamicFactory.Start(stopCh)
+
+	syncCtx, cancel := context.WithTimeout(context.Background(), clusterCacheSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-syncCtx.Done():
+		}
+	}()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), typedSynced...) {
+		return nil, fmt.Errorf("timed out waiting for pods, namespaces and network policies to be listed")
+	}
+	for resource, informer := range dynamicInformers {
+		if !cache.WaitForCacheSync(syncCtx.Done(), informer.Informer().HasSynced) {
+			log.Printf("Not caching %s: it could not be listed in time\n", resource.GroupResource())
+			continue
+		}
+		clusterCache.resources[resource] = informer.Lister()
+	}
+
+	return clusterCache, nil
+}
+
+// resourceServed reports whether the discovery API lists the resource.
+func resourceServed(clientset kubernetes.Interface, resource schema.GroupVersionResource) bool {
+	resources, err := clientset.Discovery().ServerResourcesForGroupVersion(resource.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	for _, apiResource := range resources.APIResources {
+		if apiResource.Name == resource.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// UseClusterCache makes GetClientset and GetDynamicClient read from the cache. Writes, and reads of resources the
+// cache does not hold, still go to the API server.
+func UseClusterCache(clusterCache *ClusterCache) {
+	clientset = clusterCache.Clientset()
+	isClientInitialized = true
+	sharedDynamicClient = clusterCache.DynamicClient()
+}
+
+// Clientset returns a clientset answering pod, namespace and NetworkPolicy reads from the cache.
+func (c *ClusterCache) Clientset() kubernetes.Interface {
+	return &cachedClientset{Interface: c.clientset, cache: c}
+}
+
+// DynamicClient returns a dynamic client answering reads of the cached policy resources from the cache.
+func (c *ClusterCache) DynamicClient() dynamic.Interface {
+	return &cachedDynamicClient{Interface: c.dynamicClient, cache: c}
+}
+
+type cachedClientset struct {
+	kubernetes.Interface
+	cache *ClusterCache
+}
+
+func (c *cachedClientset) CoreV1() corev1client.CoreV1Interface {
+	return &cachedCoreV1{CoreV1Interface: c.Interface.CoreV1(), cache: c.cache}
+}
+
+func (c *cachedClientset) NetworkingV1() networkingv1client.NetworkingV1Interface {
+	return &cachedNetworkingV1{NetworkingV1Interface: c.Interface.NetworkingV1(), cache: c.cache}
+}
+
+type cachedCoreV1 struct {
+	corev1client.CoreV1Interface
+	cache *ClusterCache
+}
+
+func (c *cachedCoreV1) Pods(namespace string) corev1client.PodInterface {
+	return &cachedPods{PodInterface: c.CoreV1Interface.Pods(namespace), cache: c.cache, namespace: namespace}
+}
+
+func (c *cachedCoreV1) Namespaces() corev1client.NamespaceInterface {
+	return &cachedNamespaces{NamespaceInterface: c.CoreV1Interface.Namespaces(), cache: c.cache}
+}
+
+type cachedNetworkingV1 struct {
+	networkingv1client.NetworkingV1Interface
+	cache *ClusterCache
+}
+
+func (c *cachedNetworkingV1) NetworkPolicies(namespace string) networkingv1client.NetworkPolicyInterface {
+	return &cachedNetworkPolicies{NetworkPolicyInterface: c.NetworkingV1Interface.NetworkPolicies(namespace), cache: c.cache, namespace: namespace}
+}
+
+// cachedListSelector returns the label selector of a list request, or false when the request uses options the
+// cache cannot answer.
+func cachedListSelector(opts metav1.ListOptions) (labels.Selector, bool, error) {
+	if opts.FieldSelector != "" || opts.Limit > 0 || opts.Continue != "" || opts.Watch {
+		return nil, false, nil
+	}
+	selector, err := labels.Parse(opts.LabelSelector)
+	return selector, true, err
+}
+
+type cachedPods struct {
+	corev1client.PodInterface
+	cache     *ClusterCache
+	namespace string
+}
+
+func (p *cachedPods) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Pod, error) {
+	pod, err := p.cache.pods.Pods(p.namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return pod.DeepCopy(), nil
+}
+
+func (p *cachedPods) List(ctx context.Context, opts metav1.ListOptions) (*corev1.PodList, error) {
+	selector, cached, err := cachedListSelector(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		return p.PodInterface.List(ctx, opts)
+	}
+
+	var pods []*corev1.Pod
+	if p.namespace == "" {
+		pods, err = p.cache.pods.List(selector)
+	} else {
+		pods, err = p.cache.pods.Pods(p.namespace).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &corev1.PodList{Items: make([]corev1.Pod, 0, len(pods))}
+	for _, pod := range pods {
+		list.Items = append(list.Items, *pod.DeepCopy())
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Namespace+"/"+list.Items[i].Name < list.Items[j].Namespace+"/"+list.Items[j].Name
+	})
+	return list, nil
+}
+
+type cachedNamespaces struct {
+	corev1client.NamespaceInterface
+	cache *ClusterCache
+}
+
+func (n *cachedNamespaces) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Namespace, error) {
+	namespace, err := n.cache.namespaces.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return namespace.DeepCopy(), nil
+}
+
+func (n *cachedNamespaces) List(ctx context.Context, opts metav1.ListOptions) (*corev1.NamespaceList, error) {
+	selector, cached, err := cachedListSelector(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		return n.NamespaceInterface.List(ctx, opts)
+	}
+
+	namespaces, err := n.cache.namespaces.List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &corev1.NamespaceList{Items: make([]corev1.Namespace, 0, len(namespaces))}
+	for _, namespace := range namespaces {
+		list.Items = append(list.Items, *namespace.DeepCopy())
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	return list, nil
+}
+
+type cachedNetworkPolicies struct {
+	networkingv1client.NetworkPolicyInterface
+	cache     *ClusterCache
+	namespace string
+}
+
+func (n *cachedNetworkPolicies) Get(ctx context.Context, name string, opts metav1.GetOptions) (*networkingv1.NetworkPolicy, error) {
+	policy, err := n.cache.networkPolicies.NetworkPolicies(n.namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return policy.DeepCopy(), nil
+}
+
+func (n *cachedNetworkPolicies) List(ctx context.Context, opts metav1.ListOptions) (*networkingv1.NetworkPolicyList, error) {
+	selector, cached, err := cachedListSelector(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		return n.NetworkPolicyInterface.List(ctx, opts)
+	}
+
+	var policies []*networkingv1.NetworkPolicy
+	if n.namespace == "" {
+		policies, err = n.cache.networkPolicies.List(selector)
+	} else {
+		policies, err = n.cache.networkPolicies.NetworkPolicies(n.namespace).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &networkingv1.NetworkPolicyList{Items: make([]networkingv1.NetworkPolicy, 0, len(policies))}
+	for _, policy := range policies {
+		list.Items = append(list.Items, *policy.DeepCopy())
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Namespace+"/"+list.Items[i].Name < list.Items[j].Namespace+"/"+list.Items[j].Name
+	})
+	return list, nil
+}
+
+type cachedDynamicClient struct {
+	dynamic.Interface
+	cache *ClusterCache
+}
+
+func (d *cachedDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	lister, found := d.cache.resources[resource]
+	if !found {
+		return d.Interface.Resource(resource)
+	}
+	return &cachedResource{NamespaceableResourceInterface: d.Interface.Resource(resource), lister: lister}
+}
+
+type cachedResource struct {
+	dynamic.NamespaceableResourceInterface
+	lister cache.GenericLister
+}
+
+func (r *cachedResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &cachedNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), lister: r.lister.ByNamespace(namespace)}
+}
+
+func (r *cachedResource) Get(ctx context.Context, name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(subresources) > 0 {
+		return r.NamespaceableResourceInterface.Get(ctx, name, opts, subresources...)
+	}
+	return cachedObject(r.lister.Get(name))
+}
+
+func (r *cachedResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	selector, cached, err := cachedListSelector(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		return r.NamespaceableResourceInterface.List(ctx, opts)
+	}
+	return cachedObjectList(r.lister.List(selector))
+}
+
+type cachedNamespacedResource struct {
+	dynamic.ResourceInterface
+	lister cache.GenericNamespaceLister
+}
+
+func (r *cachedNamespacedResource) Get(ctx context.Context, name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(subresources) > 0 {
+		return r.ResourceInterface.Get(ctx, name, opts, subresources...)
+	}
+	return cachedObject(r.lister.Get(name))
+}
+
+func (r *cachedNamespacedResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	selector, cached, err := cachedListSelector(opts)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		return r.ResourceInterface.List(ctx, opts)
+	}
+	return cachedObjectList(r.lister.List(selector))
+}
+
+// cachedObject returns a copy of an object read from a dynamic informer.
+func cachedObject(object runtime.Object, err error) (*unstructured.Unstructured, error) {
+	if err != nil {
+		return nil, err
+	}
+	unstructuredObject, ok := object.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected cached object type %T", object)
+	}
+	return unstructuredObject.DeepCopy(), nil
+}
+
+// cachedObjectList returns copies of the objects read from a dynamic informer, sorted like the API server sorts
+// them.
+func cachedObjectList(objects []runtime.Object, err error) (*unstructured.UnstructuredList, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{Items: make([]unstructured.Unstructured, 0, len(objects))}
+	for _, object := range objects {
+		unstructuredObject, err := cachedObject(object, nil)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *unstructuredObject)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].GetNamespace()+"/"+list.Items[i].GetName() < list.Items[j].GetNamespace()+"/"+list.Items[j].GetName()
+	})
+	return list, nil
+}
@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusterCache(t *testing.T) {
+	shop := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	db := testPod("shop", "db-0", "10.0.0.2", map[string]string{"app": "db"})
+	policy := &netv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-policy", Namespace: "shop"},
+		Spec:       netv1.NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+	}
+	ciliumPolicy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec":       map[string]interface{}{},
+	}}
+
+	clientset := fake.NewSimpleClientset(shop, &web, &db, policy)
+	clientset.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "cilium.io/v2",
+		APIResources: []metav1.APIResource{{Name: "ciliumnetworkpolicies", Namespaced: true, Kind: "CiliumNetworkPolicy"}},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, ciliumPolicy)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	clusterCache, err := NewClusterCache(clientset, dynamicClient, stopCh)
+	if err != nil {
+		t.Fatalf("Failed to load cluster cache: %v", err)
+	}
+	cachedClientset := clusterCache.Clientset()
+	cachedDynamicClient := clusterCache.DynamicClient()
+
+	// Reads are answered from the cache without reaching the API server
+	clientset.ClearActions()
+	pods, err := cachedClientset.CoreV1().Pods("shop").List(context.TODO(), metav1.ListOptions{LabelSelector: "app=db"})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 1)
+	assert.Equal(t, "db-0", pods.Items[0].Name)
+
+	namespace, err := cachedClientset.CoreV1().Namespaces().Get(context.TODO(), "shop", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "shop", namespace.Name)
+
+	_, err = cachedClientset.NetworkingV1().NetworkPolicies("shop").Get(context.TODO(), "missing", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	namespaces, err := GatherNamespacesWithPolicies(cachedClientset)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shop"}, namespaces)
+
+	vizData, err := gatherVisualizationData(cachedClientset, "shop")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db-0"}, vizData.Policies[0].TargetPods)
+	assert.Empty(t, clientset.Actions())
+
+	ciliumPolicies, err := cachedDynamicClient.Resource(ciliumNetworkPolicyResource).Namespace("shop").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, ciliumPolicies.Items, 1)
+
+	// Writes go to the API server and reach the cache through the informers
+	created := testPod("shop", "web-1", "10.0.0.3", map[string]string{"app": "web"})
+	_, err = cachedClientset.CoreV1().Pods("shop").Create(context.TODO(), &created, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		pods, err := cachedClientset.CoreV1().Pods("shop").List(context.TODO(), metav1.ListOptions{LabelSelector: "app=web"})
+		return err == nil && len(pods.Items) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,412 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ConfigFileName is the configuration file read from the working directory when no other file is given
+const ConfigFileName = ".netfetch.yaml"
+
+// suppressionDateLayout is the format of the expiry date of a suppression
+const suppressionDateLayout = "2006-01-02"
+
+// DefaultExcludedNamespaces are left out of every scan unless the configuration lists its own exclusions.
+var DefaultExcludedNamespaces = []string{"kube-system", "tigera-operator", "kube-public", "kube-node-lease", "gatekeeper-system", "calico-system"}
+
+// Config decides which namespaces and pods the scanners look at and which findings they report.
+type Config struct {
+	Namespaces   NamespaceConfig `yaml:"namespaces"`
+	Pods         PodConfig       `yaml:"pods"`
+	Suppressions []Suppression   `yaml:"suppressions"`
+	Score        ScoreConfig     `yaml:"score"`
+	History      HistoryConfig   `yaml:"history"`
+}
+
+// NamespaceConfig selects the namespaces scanned when no namespace is given. A namespace is scanned when it matches
+// one of the Include globs, or Include is empty, matches none of the Exclude globs and has labels matching Selector.
+// Leaving Exclude unset excludes the DefaultExcludedNamespaces.
+type NamespaceConfig struct {
+	Include  []string `yaml:"include"`
+	Exclude  []string `yaml:"exclude"`
+	Selector string   `yaml:"selector"`
+
+	selector labels.Selector
+}
+
+// PodConfig lists the pods left out of every scan.
+type PodConfig struct {
+	Exclude []PodExclusion `yaml:"exclude"`
+}
+
+// PodExclusion matches pods by label selector, namespace glob, the kind of the workload owning them and whether
+// they use the host network. A pod is excluded when every field that is set matches.
+type PodExclusion struct {
+	Namespace   string `yaml:"namespace"`
+	Selector    string `yaml:"selector"`
+	OwnerKind   string `yaml:"ownerKind"`
+	HostNetwork *bool  `yaml:"hostNetwork"`
+
+	selector labels.Selector
+}
+
+// ScoreConfig sets how the parts of the score are weighed. Weights left out of the configuration file keep their
+// DefaultScoreWeights, and at least one weight must be greater than 0.
+type ScoreConfig struct {
+	Weights ScoreWeights `yaml:"weights"`
+}
+
+// Suppression hides the findings of a rule for the objects matching the Namespace and Name globs until the end of
+// the Expires date. Empty globs match every object. Every suppression must explain why the finding is accepted.
+type Suppression struct {
+	Rule          string `yaml:"rule"`
+	Namespace     string `yaml:"namespace"`
+	Name          string `yaml:"name"`
+	Expires       string `yaml:"expires"`
+	Justification string `yaml:"justification"`
+
+	expires time.Time
+}
+
+// SuppressedFinding is a finding left out of a scan result by a suppression.
+type SuppressedFinding struct {
+	Rule          string `json:"rule" yaml:"rule"`
+	Namespace     string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name          string `json:"name" yaml:"name"`
+	Expires       string `json:"expires" yaml:"expires"`
+	Justification string `json:"justification" yaml:"justification"`
+}
+
+// activeConfig is the configuration every scanner respects
+var activeConfig = DefaultConfig()
+
+// DefaultConfig returns the configuration used without a configuration file. It excludes the
+// DefaultExcludedNamespaces and nothing else.
+func DefaultConfig() *Config {
+	config := &Config{Score: ScoreConfig{Weights: DefaultScoreWeights}}
+	if err := config.compile(); err != nil {
+		panic(err)
+	}
+	return config
+}
+
+// UseConfig makes every scanner respect the configuration. A nil configuration restores the default.
+func UseConfig(config *Config) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	activeConfig = config
+}
+
+// LoadConfig reads a configuration file. A missing file is not an error when optional is set, and the default
+// configuration is returned instead.
+func LoadConfig(configPath string, optional bool) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return DefaultConfig(), nil
+		}
+		return nil, fmt.Errorf("error reading config file %s: %w", configPath, err)
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+// ParseConfig parses and validates a configuration. Unknown fields are rejected so typos do not go unnoticed.
+func ParseConfig(data []byte) (*Config, error) {
+	config := &Config{Score: ScoreConfig{Weights: DefaultScoreWeights}}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+	if err := config.compile(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// compile validates the globs, selectors, suppressions and score weights, and parses them for matching.
+func (c *Config) compile() error {
+	if c.Namespaces.Exclude == nil {
+		c.Namespaces.Exclude = append([]string{}, DefaultExcludedNamespaces...)
+	}
+	for _, pattern := range append(append([]string{}, c.Namespaces.Include...), c.Namespaces.Exclude...) {
+		if err := validateGlob(pattern); err != nil {
+			return fmt.Errorf("namespaces: %w", err)
+		}
+	}
+	selector, err := labels.Parse(c.Namespaces.Selector)
+	if err != nil {
+		return fmt.Errorf("namespaces: invalid selector %q: %w", c.Namespaces.Selector, err)
+	}
+	c.Namespaces.selector = selector
+
+	for i := range c.Pods.Exclude {
+		exclusion := &c.Pods.Exclude[i]
+		if exclusion.Namespace == "" && exclusion.Selector == "" && exclusion.OwnerKind == "" && exclusion.HostNetwork == nil {
+			return fmt.Errorf("pods: exclusion %d matches every pod, set namespace, selector, ownerKind or hostNetwork", i+1)
+		}
+		if err := validateGlob(exclusion.Namespace); err != nil {
+			return fmt.Errorf("pods: exclusion %d: %w", i+1, err)
+		}
+		selector, err := labels.Parse(exclusion.Selector)
+		if err != nil {
+			return fmt.Errorf("pods: exclusion %d: invalid selector %q: %w", i+1, exclusion.Selector, err)
+		}
+		exclusion.selector = selector
+	}
+
+	for i := range c.Suppressions {
+		suppression := &c.Suppressions[i]
+		if !knownRule(suppression.Rule) {
+			return fmt.Errorf("suppressions: suppression %d: unknown rule %q", i+1, suppression.Rule)
+		}
+		if strings.TrimSpace(suppression.Justification) == "" {
+			return fmt.Errorf("suppressions: suppression %d of %s needs a justification", i+1, suppression.Rule)
+		}
+		expires, err := time.Parse(suppressionDateLayout, suppression.Expires)
+		if err != nil {
+			return fmt.Errorf("suppressions: suppression %d of %s needs an expiry date formatted as YYYY-MM-DD", i+1, suppression.Rule)
+		}
+		suppression.expires = expires
+		for _, pattern := range []string{suppression.Namespace, suppression.Name} {
+			if err := validateGlob(pattern); err != nil {
+				return fmt.Errorf("suppressions: suppression %d of %s: %w", i+1, suppression.Rule, err)
+			}
+		}
+	}
+
+	weights := c.Score.Weights
+	if weights.IngressIsolation < 0 || weights.EgressIsolation < 0 || weights.DefaultDeny < 0 || weights.BroadRules < 0 {
+		return fmt.Errorf("score: weights cannot be negative")
+	}
+	if weights == (ScoreWeights{}) {
+		return fmt.Errorf("score: at least one weight must be greater than 0")
+	}
+	return nil
+}
+
+// validateGlob checks that a namespace or name pattern can be matched.
+func validateGlob(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// matchesAny reports whether the value matches one of the globs.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// knownRule reports whether the rule is one of the findings netfetch reports.
+func knownRule(rule string) bool {
+	return ruleIndex(rule) >= 0
+}
+
+// IncludesNamespace reports whether a namespace with the labels is scanned when no namespace is given.
+func (c *Config) IncludesNamespace(name string, namespaceLabels map[string]string) bool {
+	if len(c.Namespaces.Include) > 0 && !matchesAny(c.Namespaces.Include, name) {
+		return false
+	}
+	if matchesAny(c.Namespaces.Exclude, name) {
+		return false
+	}
+	return c.Namespaces.selector.Matches(labels.Set(namespaceLabels))
+}
+
+// ExcludesPod reports whether the pod is left out of every scan.
+func (c *Config) ExcludesPod(pod corev1.Pod) bool {
+	for _, exclusion := range c.Pods.Exclude {
+		if exclusion.Namespace != "" && !matchesAny([]string{exclusion.Namespace}, pod.Namespace) {
+			continue
+		}
+		if !exclusion.selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if exclusion.OwnerKind != "" && !strings.EqualFold(exclusion.OwnerKind, podWorkload(pod).Kind) {
+			continue
+		}
+		if exclusion.HostNetwork != nil && *exclusion.HostNetwork != pod.Spec.HostNetwork {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Suppression returns the suppression hiding the finding of the rule for the object at the given time, or nil when
+// the finding is reported. Expired suppressions hide nothing.
+func (c *Config) Suppression(rule, namespace, name string, now time.Time) *Suppression {
+	for i := range c.Suppressions {
+		suppression := &c.Suppressions[i]
+		if suppression.Rule != rule || suppression.Expired(now) {
+			continue
+		}
+		if suppression.Namespace != "" && !matchesAny([]string{suppression.Namespace}, namespace) {
+			continue
+		}
+		if suppression.Name != "" && !matchesAny([]string{suppression.Name}, name) {
+			continue
+		}
+		return suppression
+	}
+	return nil
+}
+
+// Expired reports whether the expiry date of the suppression has passed. A suppression applies through the whole
+// day it expires on.
+func (s *Suppression) Expired(now time.Time) bool {
+	return !now.UTC().Before(s.expires.AddDate(0, 0, 1))
+}
+
+// ExpiredSuppressions returns the suppressions that no longer hide their findings, so they can be renewed or
+// removed.
+func (c *Config) ExpiredSuppressions(now time.Time) []Suppression {
+	var expired []Suppression
+	for _, suppression := range c.Suppressions {
+		if suppression.Expired(now) {
+			expired = append(expired, suppression)
+		}
+	}
+	return expired
+}
+
+// String describes the findings the suppression hides.
+func (s Suppression) String() string {
+	target := s.Name
+	if target == "" {
+		target = "*"
+	}
+	if s.Namespace != "" {
+		target = s.Namespace + "/" + target
+	}
+	return fmt.Sprintf("%s for %s", s.Rule, target)
+}
+
+// finding records the finding as suppressed by the suppression.
+func (s *Suppression) finding(namespace, name string) SuppressedFinding {
+	return SuppressedFinding{Rule: s.Rule, Namespace: namespace, Name: name, Expires: s.Expires, Justification: s.Justification}
+}
+
+// scannedPods returns the running pods that the active configuration does not exclude.
+func scannedPods(pods []corev1.Pod) []corev1.Pod {
+	scanned := []corev1.Pod{}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning && !activeConfig.ExcludesPod(pod) {
+			scanned = append(scanned, pod)
+		}
+	}
+	return scanned
+}
+
+// acceptUnprotectedPods removes the unprotected pods whose finding is waived or suppressed and records them in the
+// scan result. Accepted pods do not count against the score.
+func acceptUnprotectedPods(unprotectedPods []string, scanResult *ScanResult) []string {
+	reported := []string{}
+	for _, detail := range unprotectedPods {
+		pod := ParsePodDetail(detail)
+		if !acceptFinding(scanResult, RuleUnprotectedPod, pod.Namespace, pod.Name, pod.Name) {
+			reported = append(reported, detail)
+		}
+	}
+	return reported
+}
+
+// acceptPodFindings removes the waived and suppressed findings from the pod evaluations and records them in the
+// scan result.
+func acceptPodFindings(evaluations []PodPolicyEvaluation, scanResult *ScanResult) {
+	for i := range evaluations {
+		evaluations[i].Findings = acceptFindings(evaluations[i].Namespace, evaluations[i].Name, evaluations[i].Findings, podFindingRule, scanResult)
+	}
+}
+
+// acceptMeshFindings removes the waived and suppressed findings from the mesh evaluations and records them in the
+// scan result.
+func acceptMeshFindings(evaluations []PodMeshEvaluation, scanResult *ScanResult) {
+	for i := range evaluations {
+		evaluations[i].Findings = acceptFindings(evaluations[i].Namespace, evaluations[i].Name, evaluations[i].Findings, meshFindingRule, scanResult)
+	}
+}
+
+// acceptFindings returns the findings of a pod that are neither waived nor suppressed.
+func acceptFindings(namespace, pod string, findings []string, ruleOf func(string) string, scanResult *ScanResult) []string {
+	reported := []string{}
+	for _, finding := range findings {
+		if rule := ruleOf(finding); rule != "" && acceptFinding(scanResult, rule, namespace, pod, pod) {
+			continue
+		}
+		reported = append(reported, finding)
+	}
+	return reported
+}
+
+// acceptFinding reports whether the finding of the rule for the named object is accepted, and records it as waived
+// or suppressed. pod names the pod the finding is about, and is empty for findings about a namespace or policy.
+// A waiver of the pod or its namespace takes precedence over the suppressions of the configuration. Findings whose
+// waiver has expired are recorded as waived but not accepted.
+func acceptFinding(scanResult *ScanResult, rule, namespace, name, pod string) bool {
+	now := time.Now()
+	if waiver, found := scanResult.waivers.lookup(namespace, pod); found {
+		waived := waiver.finding(rule, namespace, name, now)
+		recordWaived(scanResult, waived)
+		if !waived.Expired {
+			return true
+		}
+	}
+	if suppression := activeConfig.Suppression(rule, namespace, name, now); suppression != nil {
+		recordSuppressed(scanResult, suppression.finding(namespace, name))
+		return true
+	}
+	return false
+}
+
+// recordSuppressed adds the finding to the suppressed findings of the scan result unless it is already recorded.
+func recordSuppressed(scanResult *ScanResult, finding SuppressedFinding) {
+	for _, recorded := range scanResult.Suppressed {
+		if recorded.Rule == finding.Rule && recorded.Namespace == finding.Namespace && recorded.Name == finding.Name {
+			return
+		}
+	}
+	scanResult.Suppressed = append(scanResult.Suppressed, finding)
+}
+
+// podFindingRule returns the rule reporting a finding of a pod policy evaluation.
+func podFindingRule(finding string) string {
+	switch finding {
+	case FindingUnprotected:
+		return RuleUnprotectedPod
+	case FindingIngressOnlyIsolated, FindingEgressOnlyIsolated:
+		return RulePartiallyIsolatedPod
+	default:
+		return RuleOverlyBroadRule
+	}
+}
+
+// meshFindingRule returns the rule reporting a finding of a pod mesh evaluation. Pods outside the mesh are not a
+// finding of their own.
+func meshFindingRule(finding string) string {
+	switch finding {
+	case FindingL4WithoutL7Authorization:
+		return RuleMissingL7Authorization
+	case FindingMTLSNotStrict:
+		return RuleMTLSNotStrict
+	default:
+		return ""
+	}
+}
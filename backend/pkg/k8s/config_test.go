@@ -0,0 +1,204 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testConfig = `
+namespaces:
+  include: ["team-*", "shop"]
+  exclude: ["team-sandbox"]
+  selector: "netfetch.io/scan!=false"
+pods:
+  exclude:
+    - ownerKind: DaemonSet
+      hostNetwork: true
+    - namespace: shop
+      selector: app=debug
+suppressions:
+  - rule: NETFETCH001
+    namespace: shop
+    name: legacy-*
+    expires: 2030-06-30
+    justification: Legacy frontend, isolation tracked in SHOP-12
+  - rule: NETFETCH002
+    namespace: team-a
+    expires: 2020-01-01
+    justification: Migration window
+`
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+	assert.Len(t, config.Pods.Exclude, 2)
+	assert.Len(t, config.Suppressions, 2)
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "Unknown field",
+			config: "namespaces:\n  inclde: [shop]\n",
+			err:    "field inclde not found",
+		},
+		{
+			name:   "Invalid glob",
+			config: "namespaces:\n  exclude: [\"team-[\"]\n",
+			err:    "invalid pattern",
+		},
+		{
+			name:   "Invalid namespace selector",
+			config: "namespaces:\n  selector: \"a in (\"\n",
+			err:    "invalid selector",
+		},
+		{
+			name:   "Pod exclusion matching every pod",
+			config: "pods:\n  exclude:\n    - {}\n",
+			err:    "matches every pod",
+		},
+		{
+			name:   "Unknown rule",
+			config: "suppressions:\n  - rule: NETFETCH999\n    expires: 2030-01-01\n    justification: test\n",
+			err:    "unknown rule",
+		},
+		{
+			name:   "Suppression without justification",
+			config: "suppressions:\n  - rule: NETFETCH001\n    expires: 2030-01-01\n",
+			err:    "needs a justification",
+		},
+		{
+			name:   "Suppression without expiry date",
+			config: "suppressions:\n  - rule: NETFETCH001\n    justification: test\n",
+			err:    "needs an expiry date",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(test.config))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestIncludesNamespace(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		config    *Config
+		namespace string
+		labels    map[string]string
+		expected  bool
+	}{
+		{name: "Default excludes system namespaces", config: DefaultConfig(), namespace: "kube-system", expected: false},
+		{name: "Default includes other namespaces", config: DefaultConfig(), namespace: "shop", expected: true},
+		{name: "Included by glob", config: config, namespace: "team-a", expected: true},
+		{name: "Not included", config: config, namespace: "billing", expected: false},
+		{name: "Excluded by glob", config: config, namespace: "team-sandbox", expected: false},
+		{name: "Excluded by selector", config: config, namespace: "shop", labels: map[string]string{"netfetch.io/scan": "false"}, expected: false},
+		{name: "Explicit exclusions replace the defaults", config: &Config{Namespaces: NamespaceConfig{Exclude: []string{}}, Score: ScoreConfig{Weights: DefaultScoreWeights}}, namespace: "kube-system", expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.NoError(t, test.config.compile())
+			assert.Equal(t, test.expected, test.config.IncludesNamespace(test.namespace, test.labels))
+		})
+	}
+}
+
+func TestExcludesPod(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+
+	controller := true
+	exporter := testPod("monitoring", "node-exporter-x1", "10.0.0.1", map[string]string{"app": "node-exporter"})
+	exporter.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "node-exporter", Controller: &controller}}
+	exporter.Spec.HostNetwork = true
+
+	agent := testPod("monitoring", "agent-x1", "10.0.0.2", map[string]string{"app": "agent"})
+	agent.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent", Controller: &controller}}
+
+	tests := []struct {
+		name     string
+		pod      corev1.Pod
+		expected bool
+	}{
+		{name: "DaemonSet on the host network", pod: exporter, expected: true},
+		{name: "DaemonSet on the pod network", pod: agent, expected: false},
+		{name: "Label in namespace", pod: testPod("shop", "debug", "", map[string]string{"app": "debug"}), expected: true},
+		{name: "Label in other namespace", pod: testPod("web", "debug", "", map[string]string{"app": "debug"}), expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, config.ExcludesPod(test.pod))
+		})
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+	assert.NoError(t, err)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	suppression := config.Suppression(RuleUnprotectedPod, "shop", "legacy-web-0", now)
+	if assert.NotNil(t, suppression) {
+		assert.Equal(t, "Legacy frontend, isolation tracked in SHOP-12", suppression.Justification)
+	}
+	assert.Nil(t, config.Suppression(RuleUnprotectedPod, "shop", "web-0", now))
+	assert.Nil(t, config.Suppression(RulePartiallyIsolatedPod, "shop", "legacy-web-0", now))
+
+	// Suppressions apply through the day they expire on
+	assert.NotNil(t, config.Suppression(RuleUnprotectedPod, "shop", "legacy-web-0", time.Date(2030, 6, 30, 23, 0, 0, 0, time.UTC)))
+	assert.Nil(t, config.Suppression(RuleUnprotectedPod, "shop", "legacy-web-0", time.Date(2030, 7, 1, 0, 0, 0, 0, time.UTC)))
+
+	assert.Nil(t, config.Suppression(RuleNamespaceWithoutDenyAll, "team-a", "team-a", now))
+	expired := config.ExpiredSuppressions(now)
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, "NETFETCH002 for team-a/*", expired[0].String())
+	}
+}
+
+func TestSuppressFindings(t *testing.T) {
+	config, err := ParseConfig([]byte(`
+suppressions:
+  - rule: NETFETCH001
+    namespace: shop
+    name: legacy-*
+    expires: 2099-01-01
+    justification: Legacy frontend
+  - rule: NETFETCH002
+    namespace: shop
+    expires: 2099-01-01
+    justification: Shared namespace
+`))
+	assert.NoError(t, err)
+	UseConfig(config)
+	defer UseConfig(nil)
+
+	result := &ScanResult{PolicyType: PolicyTypeKubernetes, NamespacesScanned: []string{"shop", "web"}}
+	result.UnprotectedPods = acceptUnprotectedPods([]string{"shop legacy-0 10.0.0.1", "shop web-0 10.0.0.2"}, result)
+	evaluations := []PodPolicyEvaluation{{Namespace: "shop", Name: "legacy-0", Findings: []string{FindingUnprotected}}}
+	acceptPodFindings(evaluations, result)
+
+	assert.Equal(t, []string{"shop web-0 10.0.0.2"}, result.UnprotectedPods)
+	assert.Equal(t, []string{}, evaluations[0].Findings)
+	assert.Equal(t, []SuppressedFinding{{Rule: RuleUnprotectedPod, Namespace: "shop", Name: "legacy-0", Expires: "2099-01-01", Justification: "Legacy frontend"}}, result.Suppressed)
+
+	entry := NewScanResultDocument(result).Results[0]
+	assert.Equal(t, []string{"web"}, entry.NamespacesWithoutDenyAll)
+	assert.Len(t, entry.Suppressed, 2)
+	assert.Equal(t, RuleNamespaceWithoutDenyAll, entry.Suppressed[1].Rule)
+}
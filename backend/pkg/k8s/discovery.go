@@ -0,0 +1,189 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// policyEngineOrder is the order in which detected policy engines are reported and scanned
+var policyEngineOrder = []string{PolicyTypeKubernetes, PolicyTypeAdminNetworkPolicy, PolicyTypeCilium, PolicyTypeCalico, PolicyTypeAntrea}
+
+// policyEngineNames are the human readable names of the policy engines
+var policyEngineNames = map[string]string{
+	PolicyTypeKubernetes:         "Kubernetes NetworkPolicy",
+	PolicyTypeAdminNetworkPolicy: "AdminNetworkPolicy",
+	PolicyTypeCilium:             "Cilium",
+	PolicyTypeCalico:             "Calico",
+	PolicyTypeAntrea:             "Antrea",
+}
+
+// policyEngineGroups maps the API groups serving policies to the engine they belong to
+var policyEngineGroups = map[string]string{
+	"networking.k8s.io":              PolicyTypeKubernetes,
+	adminNetworkPolicyResource.Group: PolicyTypeAdminNetworkPolicy,
+	"cilium.io":                      PolicyTypeCilium,
+	calicoAPIGroupVersion.Group:      PolicyTypeCalico,
+	calicoCRDGroupVersion.Group:      PolicyTypeCalico,
+	antreaGroupVersion.Group:         PolicyTypeAntrea,
+}
+
+// cniDaemonSets identifies the CNI of a cluster by the DaemonSet running its agent. When several match, as with
+// Cilium chained to the AWS VPC CNI, the first one enforces policies.
+var cniDaemonSets = []struct {
+	daemonSet string
+	cni       string
+}{
+	{"cilium", "cilium"},
+	{"calico-node", "calico"},
+	{"canal", "canal"},
+	{"antrea-agent", "antrea"},
+	{"ovnkube-node", "ovn-kubernetes"},
+	{"kube-router", "kube-router"},
+	{"weave-net", "weave"},
+	{"kindnet", "kindnet"},
+	{"kube-flannel-ds", "flannel"},
+	{"kube-flannel", "flannel"},
+}
+
+// cniEnforcedEngines lists the policy engines each known CNI enforces
+var cniEnforcedEngines = map[string][]string{
+	"cilium":         {PolicyTypeKubernetes, PolicyTypeCilium},
+	"calico":         {PolicyTypeKubernetes, PolicyTypeAdminNetworkPolicy, PolicyTypeCalico},
+	"canal":          {PolicyTypeKubernetes, PolicyTypeCalico},
+	"antrea":         {PolicyTypeKubernetes, PolicyTypeAdminNetworkPolicy, PolicyTypeAntrea},
+	"ovn-kubernetes": {PolicyTypeKubernetes, PolicyTypeAdminNetworkPolicy},
+	"kube-router":    {PolicyTypeKubernetes},
+	"weave":          {PolicyTypeKubernetes},
+	"kindnet":        {PolicyTypeKubernetes},
+	"flannel":        {},
+}
+
+// PolicyEngines describes the network policy APIs served by a cluster and the CNI enforcing them.
+type PolicyEngines struct {
+	// CNI is empty when no known CNI agent was found
+	CNI       string
+	installed map[string]bool
+}
+
+// DetectPolicyEngines uses the discovery API to find the policy APIs the cluster serves and looks for the
+// DaemonSet of a known CNI. A CNI that cannot be found, for example without permission to list DaemonSets,
+// leaves CNI empty.
+func DetectPolicyEngines(clientset kubernetes.Interface) (PolicyEngines, error) {
+	engines := PolicyEngines{installed: map[string]bool{}}
+
+	groups, err := clientset.Discovery().ServerGroups()
+	if err != nil {
+		return engines, fmt.Errorf("error discovering API groups: %w", err)
+	}
+	for _, group := range groups.Groups {
+		if engine, found := policyEngineGroups[group.Name]; found {
+			engines.installed[engine] = true
+		}
+	}
+
+	daemonSets, err := clientset.AppsV1().DaemonSets("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return engines, nil
+	}
+	names := map[string]bool{}
+	for _, daemonSet := range daemonSets.Items {
+		names[daemonSet.Name] = true
+	}
+	for _, known := range cniDaemonSets {
+		if names[known.daemonSet] {
+			engines.CNI = known.cni
+			break
+		}
+	}
+	return engines, nil
+}
+
+// Installed reports whether the cluster serves the API of the policy engine.
+func (e PolicyEngines) Installed(policyType string) bool {
+	return e.installed[policyType]
+}
+
+// Enforced reports whether the CNI enforces the policies of the engine. It is false when the CNI is unknown.
+func (e PolicyEngines) Enforced(policyType string) bool {
+	for _, engine := range cniEnforcedEngines[e.CNI] {
+		if engine == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// Warnings lists the installed policy engines the detected CNI does not enforce. Nothing is reported when the
+// CNI is unknown.
+func (e PolicyEngines) Warnings() []string {
+	if _, known := cniEnforcedEngines[e.CNI]; !known {
+		return nil
+	}
+
+	var warnings []string
+	for _, engine := range e.installedEngines() {
+		if !e.Enforced(engine) {
+			warnings = append(warnings, fmt.Sprintf("the %s API is served but the %s CNI does not enforce it", policyEngineNames[engine], e.CNI))
+		}
+	}
+	return warnings
+}
+
+// installedEngines returns the installed policy engines in report order.
+func (e PolicyEngines) installedEngines() []string {
+	engines := []string{}
+	for _, engine := range policyEngineOrder {
+		if e.installed[engine] {
+			engines = append(engines, engine)
+		}
+	}
+	return engines
+}
+
+// Summary renders the detected policy engines and whether the CNI enforces them as a table.
+func (e PolicyEngines) Summary() string {
+	cni := e.CNI
+	if cni == "" {
+		cni = "unknown"
+	}
+	_, knownCNI := cniEnforcedEngines[e.CNI]
+
+	rows := [][]string{}
+	for _, engine := range e.installedEngines() {
+		enforced := "unknown"
+		if knownCNI {
+			enforced = "no"
+			if e.Enforced(engine) {
+				enforced = "yes"
+			}
+		}
+		rows = append(rows, []string{policyEngineNames[engine], enforced})
+	}
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("99"))).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == 0:
+				return HeaderStyle
+			case row%2 == 0:
+				return EvenRowStyle
+			default:
+				return OddRowStyle
+			}
+		}).
+		Headers("Policy Engine", fmt.Sprintf("Enforced by CNI (%s)", cni))
+
+	for _, row := range rows {
+		t.Row(row...)
+	}
+
+	return t.String()
+}
@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetectPolicyEngines(t *testing.T) {
+	daemonSet := func(namespace, name string) runtime.Object {
+		return &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+
+	tests := []struct {
+		name              string
+		groupVersions     []string
+		daemonSets        []runtime.Object
+		expectedInstalled []string
+		expectedCNI       string
+		expectedWarnings  []string
+	}{
+		{
+			name:              "cilium",
+			groupVersions:     []string{"networking.k8s.io/v1", "cilium.io/v2"},
+			daemonSets:        []runtime.Object{daemonSet("kube-system", "cilium"), daemonSet("kube-system", "aws-node")},
+			expectedInstalled: []string{PolicyTypeKubernetes, PolicyTypeCilium},
+			expectedCNI:       "cilium",
+		},
+		{
+			name:              "calico APIs on antrea",
+			groupVersions:     []string{"networking.k8s.io/v1", "crd.projectcalico.org/v1", "crd.antrea.io/v1beta1", "policy.networking.k8s.io/v1alpha1"},
+			daemonSets:        []runtime.Object{daemonSet("kube-system", "antrea-agent")},
+			expectedInstalled: []string{PolicyTypeKubernetes, PolicyTypeAdminNetworkPolicy, PolicyTypeCalico, PolicyTypeAntrea},
+			expectedCNI:       "antrea",
+			expectedWarnings:  []string{"the Calico API is served but the antrea CNI does not enforce it"},
+		},
+		{
+			name:              "flannel",
+			groupVersions:     []string{"networking.k8s.io/v1"},
+			daemonSets:        []runtime.Object{daemonSet("kube-flannel", "kube-flannel-ds")},
+			expectedInstalled: []string{PolicyTypeKubernetes},
+			expectedCNI:       "flannel",
+			expectedWarnings:  []string{"the Kubernetes NetworkPolicy API is served but the flannel CNI does not enforce it"},
+		},
+		{
+			name:              "unknown CNI",
+			groupVersions:     []string{"networking.k8s.io/v1", "cilium.io/v2"},
+			expectedInstalled: []string{PolicyTypeKubernetes, PolicyTypeCilium},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.daemonSets...)
+			for _, groupVersion := range tt.groupVersions {
+				clientset.Resources = append(clientset.Resources, &metav1.APIResourceList{GroupVersion: groupVersion})
+			}
+
+			engines, err := DetectPolicyEngines(clientset)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedInstalled, engines.installedEngines())
+			assert.Equal(t, tt.expectedCNI, engines.CNI)
+			assert.Equal(t, tt.expectedWarnings, engines.Warnings())
+		})
+	}
+}
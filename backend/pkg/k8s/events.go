@@ -0,0 +1,445 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Types of the events pushed to dashboard clients
+const (
+	EventPodUnprotected = "pod-unprotected"
+	EventPodProtected   = "pod-protected"
+	EventPolicyAdded    = "policy-added"
+	EventPolicyChanged  = "policy-changed"
+	EventPolicyDeleted  = "policy-deleted"
+	EventScore          = "score"
+)
+
+// protectionScanDelay lets bursts of changes, such as a rollout, settle before the cluster is scanned again
+const protectionScanDelay = time.Second
+
+// eventSubscriberBuffer is the number of events a slow subscriber may fall behind before events are dropped
+const eventSubscriberBuffer = 256
+
+// ClusterEvent is a change of the cluster pushed to dashboard clients.
+type ClusterEvent struct {
+	Type      string `json:"type"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	Score     int    `json:"score,omitempty"`
+	// Unprotected is the number of unprotected pods of a score event
+	Unprotected int `json:"unprotected,omitempty"`
+}
+
+// String describes the event in a single line.
+func (e ClusterEvent) String() string {
+	name := e.Name
+	if e.Namespace != "" {
+		name = e.Namespace + "/" + e.Name
+	}
+	switch e.Type {
+	case EventPodUnprotected:
+		return fmt.Sprintf("Pod %s (%s) is no longer protected by a network policy", name, e.IP)
+	case EventPodProtected:
+		return fmt.Sprintf("Pod %s (%s) is now protected by a network policy", name, e.IP)
+	case EventPolicyAdded:
+		return fmt.Sprintf("%s %s was added", e.Kind, name)
+	case EventPolicyChanged:
+		return fmt.Sprintf("%s %s was changed", e.Kind, name)
+	case EventPolicyDeleted:
+		return fmt.Sprintf("%s %s was deleted", e.Kind, name)
+	case EventScore:
+		return fmt.Sprintf("Netfetch score: %d/100 with %d unprotected pods", e.Score, e.Unprotected)
+	}
+	return fmt.Sprintf("%s %s %s", e.Type, e.Kind, name)
+}
+
+// EventBroker fans cluster events out to every subscriber.
+type EventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan ClusterEvent]struct{}
+}
+
+// NewEventBroker creates an event broker without subscribers.
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subscribers: map[chan ClusterEvent]struct{}{}}
+}
+
+// Subscribe returns a channel receiving every event published from now on, and a function ending the
+// subscription.
+func (b *EventBroker) Subscribe() (<-chan ClusterEvent, func()) {
+	events := make(chan ClusterEvent, eventSubscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[events] = struct{}{}
+	b.mu.Unlock()
+
+	return events, func() {
+		b.mu.Lock()
+		delete(b.subscribers, events)
+		b.mu.Unlock()
+	}
+}
+
+// Publish sends the events to every subscriber. Events are dropped for subscribers that fell too far behind
+// rather than blocking the informers.
+func (b *EventBroker) Publish(events ...ClusterEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subscriber := range b.subscribers {
+		for _, event := range events {
+			select {
+			case subscriber <- event:
+			default:
+			}
+		}
+	}
+}
+
+// policyEventHandler publishes an event for every policy added, changed or deleted after the initial list, and
+// notifies that the protection of pods may have changed.
+func (c *ClusterCache) policyEventHandler(kind string) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				c.publishPolicyEvent(EventPolicyAdded, kind, obj)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.publishPolicyEvent(EventPolicyChanged, kind, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			c.publishPolicyEvent(EventPolicyDeleted, kind, obj)
+		},
+	}
+}
+
+// workloadEventHandler notifies that the protection of pods may have changed whenever a pod or namespace is
+// added, changed or deleted after the initial list.
+func (c *ClusterCache) workloadEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				c.notifyChanged(changedNamespace(obj))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) { c.notifyChanged(changedNamespace(newObj)) },
+		DeleteFunc: func(obj interface{}) { c.notifyChanged(changedNamespace(obj)) },
+	}
+}
+
+func (c *ClusterCache) publishPolicyEvent(eventType, kind string, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	if kind == "" {
+		if typed, ok := obj.(interface{ GetKind() string }); ok {
+			kind = typed.GetKind()
+		}
+	}
+
+	c.events.Publish(ClusterEvent{Type: eventType, Kind: kind, Namespace: accessor.GetNamespace(), Name: accessor.GetName()})
+	c.notifyChanged(accessor.GetNamespace())
+}
+
+// changedNamespace returns the namespace whose pods a changed object can protect: the namespace of a pod, or the
+// namespace itself when its labels change. It is empty for objects it cannot read.
+func changedNamespace(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if namespace, ok := obj.(*corev1.Namespace); ok {
+		return namespace.Name
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetNamespace()
+}
+
+// ChangeSubscription collects the namespaces whose pods, policies or labels changed in the cluster cache.
+type ChangeSubscription struct {
+	changed    chan struct{}
+	mu         sync.Mutex
+	namespaces map[string]bool
+	all        bool
+}
+
+// Changed is signalled when the cache changed since the last Drain.
+func (s *ChangeSubscription) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Drain returns the namespaces that changed since the last Drain, and whether a cluster scoped object, such as a
+// cluster wide policy, changed, which can change the protection of every namespace.
+func (s *ChangeSubscription) Drain() ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	namespaces := make([]string, 0, len(s.namespaces))
+	for namespace := range s.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	all := s.all
+	s.namespaces, s.all = map[string]bool{}, false
+	return namespaces, all
+}
+
+// add records a change of the namespace, or of a cluster scoped object when it is empty.
+func (s *ChangeSubscription) add(namespace string) {
+	s.mu.Lock()
+	if namespace == "" {
+		s.all = true
+	} else {
+		s.namespaces[namespace] = true
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// SubscribeChanges returns a subscription collecting the changes of the cache from now on, and a function ending
+// the subscription.
+func (c *ClusterCache) SubscribeChanges() (*ChangeSubscription, func()) {
+	subscription := &ChangeSubscription{changed: make(chan struct{}, 1), namespaces: map[string]bool{}}
+	c.subscriptionsMu.Lock()
+	c.subscriptions[subscription] = struct{}{}
+	c.subscriptionsMu.Unlock()
+
+	return subscription, func() {
+		c.subscriptionsMu.Lock()
+		delete(c.subscriptions, subscription)
+		c.subscriptionsMu.Unlock()
+	}
+}
+
+// notifyChanged records the change of the namespace, or of a cluster scoped object when it is empty, in every
+// change subscription.
+func (c *ClusterCache) notifyChanged(namespace string) {
+	c.subscriptionsMu.Lock()
+	defer c.subscriptionsMu.Unlock()
+	for subscription := range c.subscriptions {
+		subscription.add(namespace)
+	}
+}
+
+// Events returns the broker publishing the changes of the cluster.
+func (c *ClusterCache) Events() *EventBroker {
+	return c.events
+}
+
+// WatchProtection scans the namespace, or every selected namespace when empty, with each of the policy engines and
+// publishes the pods that become unprotected or protected as the cached cluster changes. A pod is protected when the
+// policies of any engine protect it, and the score is the lowest score of the engines. A change only rescans the
+// namespace it happened in, unless a cluster scoped object changed. The score is published after the first scan
+// and whenever it changes. It runs until stopCh is closed.
+func (c *ClusterCache) WatchProtection(namespace, kubeconfigPath string, engines []string, stopCh <-chan struct{}) {
+	if len(engines) == 0 {
+		engines = []string{PolicyTypeKubernetes}
+	}
+	changes, unsubscribe := c.SubscribeChanges()
+	defer unsubscribe()
+
+	watch := &protectionWatch{namespace: namespace, kubeconfigPath: kubeconfigPath, engines: engines, results: map[string]map[string]*ScanResult{}}
+	var previous map[string]ClusterEvent
+	previousScore, previousUnprotected := -1, -1
+	changed, all := []string(nil), true
+	for {
+		watch.rescan(c.Clientset(), changed, all)
+		current, score := watch.protection()
+
+		var events []ClusterEvent
+		if previous != nil {
+			for key, event := range current {
+				if _, found := previous[key]; !found {
+					events = append(events, event)
+				}
+			}
+			for key, event := range previous {
+				if _, found := current[key]; !found {
+					event.Type = EventPodProtected
+					events = append(events, event)
+				}
+			}
+		}
+		sort.Slice(events, func(i, j int) bool {
+			return events[i].Namespace+"/"+events[i].Name < events[j].Namespace+"/"+events[j].Name
+		})
+		if score != previousScore || len(current) != previousUnprotected {
+			events = append(events, ClusterEvent{Type: EventScore, Score: score, Unprotected: len(current)})
+		}
+		c.events.Publish(events...)
+
+		previous = current
+		previousScore, previousUnprotected = score, len(current)
+
+		select {
+		case <-stopCh:
+			return
+		case <-changes.Changed():
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(protectionScanDelay):
+		}
+		changed, all = changes.Drain()
+	}
+}
+
+// protectionWatch holds the latest scan of every watched namespace with each policy engine, so a change only
+// rescans the namespaces it affects.
+type protectionWatch struct {
+	namespace      string
+	kubeconfigPath string
+	engines        []string
+	// results are the scan results of each namespace, by engine
+	results map[string]map[string]*ScanResult
+}
+
+// rescan scans the namespaces again, or every watched namespace when all is set. Namespaces that were deleted or
+// are no longer selected are forgotten, and a namespace that fails to scan keeps its previous results.
+func (w *protectionWatch) rescan(clientset kubernetes.Interface, namespaces []string, all bool) {
+	if all {
+		selected, err := SelectNamespaces(clientset, w.namespace)
+		if err != nil {
+			log.Printf("Error selecting namespaces for live updates: %v\n", err)
+			return
+		}
+		for nsName := range w.results {
+			if !contains(selected, nsName) {
+				delete(w.results, nsName)
+			}
+		}
+		namespaces = selected
+	}
+
+	for _, nsName := range namespaces {
+		if w.namespace != "" && nsName != w.namespace {
+			continue
+		}
+		namespace, err := clientset.CoreV1().Namespaces().Get(context.TODO(), nsName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) || (err == nil && w.namespace == "" && !activeConfig.IncludesNamespace(nsName, namespace.Labels)) {
+			delete(w.results, nsName)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error getting namespace %s for live updates: %v\n", nsName, err)
+			continue
+		}
+
+		results, err := w.scanNamespace(nsName)
+		if err != nil {
+			log.Printf("Error scanning namespace %s for live updates: %v\n", nsName, err)
+			continue
+		}
+		w.results[nsName] = results
+	}
+}
+
+// scanNamespace scans the namespace with each of the policy engines. It fails when any engine fails to scan.
+func (w *protectionWatch) scanNamespace(nsName string) (map[string]*ScanResult, error) {
+	results := map[string]*ScanResult{}
+	for _, engine := range w.engines {
+		scan, found := engineScanners[engine]
+		if !found {
+			continue
+		}
+		result, err := scan(nsName, false, true, false, false, false, w.kubeconfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning %s network policies: %w", engine, err)
+		}
+		results[engine] = result
+	}
+	return results, nil
+}
+
+// protection returns the pods no engine protects, keyed by pod, and the lowest score of the engines over every
+// watched namespace.
+func (w *protectionWatch) protection() (map[string]ClusterEvent, int) {
+	namespaces := make([]string, 0, len(w.results))
+	for nsName := range w.results {
+		namespaces = append(namespaces, nsName)
+	}
+	sort.Strings(namespaces)
+
+	unprotected := map[string]ClusterEvent{}
+	merged := map[string]*ScanResult{}
+	for _, nsName := range namespaces {
+		var namespaceUnprotected map[string]ClusterEvent
+		for _, engine := range w.engines {
+			result, found := w.results[nsName][engine]
+			if !found {
+				continue
+			}
+			if merged[engine] == nil {
+				merged[engine] = &ScanResult{PolicyType: engine}
+			}
+			mergeScanResult(merged[engine], result)
+
+			engineUnprotected := unprotectedPodEvents(result.UnprotectedPods)
+			if namespaceUnprotected == nil {
+				namespaceUnprotected = engineUnprotected
+				continue
+			}
+			for key := range namespaceUnprotected {
+				if _, found := engineUnprotected[key]; !found {
+					delete(namespaceUnprotected, key)
+				}
+			}
+		}
+		for key, event := range namespaceUnprotected {
+			unprotected[key] = event
+		}
+	}
+
+	score := 100
+	for _, result := range merged {
+		if engineScore, _ := CalculateScore(result); engineScore < score {
+			score = engineScore
+		}
+	}
+	return unprotected, score
+}
+
+// mergeScanResult adds the namespaces and pods of a namespace scan to a scan of several namespaces, so it can be
+// scored as a whole.
+func mergeScanResult(merged *ScanResult, result *ScanResult) {
+	merged.NamespacesScanned = append(merged.NamespacesScanned, result.NamespacesScanned...)
+	merged.HasDenyAll = append(merged.HasDenyAll, result.HasDenyAll...)
+	merged.UnprotectedPods = append(merged.UnprotectedPods, result.UnprotectedPods...)
+	merged.PodEvaluations = append(merged.PodEvaluations, result.PodEvaluations...)
+	if result.waivers != nil {
+		merged.waivers = result.waivers
+	}
+}
+
+// unprotectedPodEvents turns the unprotected pods of a scan result into events keyed by pod.
+func unprotectedPodEvents(unprotectedPods []string) map[string]ClusterEvent {
+	events := make(map[string]ClusterEvent, len(unprotectedPods))
+	for _, podDetail := range unprotectedPods {
+		record := ParsePodDetail(podDetail)
+		events[record.Namespace+"/"+record.Name] = ClusterEvent{Type: EventPodUnprotected, Kind: "Pod", Namespace: record.Namespace, Name: record.Name, IP: record.IP}
+	}
+	return events
+}
@@ -0,0 +1,203 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEventBroker(t *testing.T) {
+	broker := NewEventBroker()
+	first, unsubscribeFirst := broker.Subscribe()
+	second, unsubscribeSecond := broker.Subscribe()
+	defer unsubscribeSecond()
+
+	broker.Publish(ClusterEvent{Type: EventScore, Score: 42})
+	assert.Equal(t, ClusterEvent{Type: EventScore, Score: 42}, <-first)
+	assert.Equal(t, ClusterEvent{Type: EventScore, Score: 42}, <-second)
+
+	// Unsubscribed channels no longer receive events
+	unsubscribeFirst()
+	broker.Publish(ClusterEvent{Type: EventScore, Score: 50})
+	assert.Empty(t, first)
+	assert.Equal(t, 50, (<-second).Score)
+
+	// A subscriber that falls behind misses events instead of blocking publishers
+	for i := 0; i < eventSubscriberBuffer+10; i++ {
+		broker.Publish(ClusterEvent{Type: EventScore, Score: i})
+	}
+	assert.Len(t, second, eventSubscriberBuffer)
+}
+
+func TestUnprotectedPodEvents(t *testing.T) {
+	events := unprotectedPodEvents([]string{"shop web-0 10.0.0.1", "shop db-0 10.0.0.2"})
+	assert.Equal(t, map[string]ClusterEvent{
+		"shop/web-0": {Type: EventPodUnprotected, Kind: "Pod", Namespace: "shop", Name: "web-0", IP: "10.0.0.1"},
+		"shop/db-0":  {Type: EventPodUnprotected, Kind: "Pod", Namespace: "shop", Name: "db-0", IP: "10.0.0.2"},
+	}, events)
+}
+
+func TestClusterCacheEvents(t *testing.T) {
+	shop := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	existing := &netv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "shop"}}
+	clientset := fake.NewSimpleClientset(shop, existing)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	clusterCache, err := NewClusterCache(clientset, dynamicClient, stopCh)
+	if err != nil {
+		t.Fatalf("Failed to load cluster cache: %v", err)
+	}
+	events, unsubscribe := clusterCache.Events().Subscribe()
+	defer unsubscribe()
+
+	// Policies listed when the cache starts are not reported as added
+	created := &netv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "shop"}}
+	_, err = clientset.NetworkingV1().NetworkPolicies("shop").Create(context.TODO(), created, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	select {
+	case event := <-events:
+		assert.Equal(t, ClusterEvent{Type: EventPolicyAdded, Kind: "NetworkPolicy", Namespace: "shop", Name: "deny-all"}, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event published for the created policy")
+	}
+
+	err = clientset.NetworkingV1().NetworkPolicies("shop").Delete(context.TODO(), "existing", metav1.DeleteOptions{})
+	assert.NoError(t, err)
+	select {
+	case event := <-events:
+		assert.Equal(t, ClusterEvent{Type: EventPolicyDeleted, Kind: "NetworkPolicy", Namespace: "shop", Name: "existing"}, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event published for the deleted policy")
+	}
+}
+
+func TestWatchProtection(t *testing.T) {
+	shop := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	fakeClientset := fake.NewSimpleClientset(shop, &web)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	clusterCache, err := NewClusterCache(fakeClientset, dynamicClient, stopCh)
+	if err != nil {
+		t.Fatalf("Failed to load cluster cache: %v", err)
+	}
+	previousClientset, previousInitialized, previousDynamicClient := clientset, isClientInitialized, sharedDynamicClient
+	UseClusterCache(clusterCache)
+	defer func() {
+		clientset, isClientInitialized, sharedDynamicClient = previousClientset, previousInitialized, previousDynamicClient
+	}()
+
+	events, unsubscribe := clusterCache.Events().Subscribe()
+	defer unsubscribe()
+	go clusterCache.WatchProtection("shop", "", nil, stopCh)
+
+	next := func() ClusterEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(10 * time.Second):
+			t.Fatal("No event published")
+			return ClusterEvent{}
+		}
+	}
+
+	// The first scan only reports the score
+	initial := next()
+	assert.Equal(t, EventScore, initial.Type)
+	assert.Equal(t, 1, initial.Unprotected)
+
+	denyAll := &netv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "shop"},
+		Spec:       netv1.NetworkPolicySpec{PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeIngress, netv1.PolicyTypeEgress}},
+	}
+	_, err = fakeClientset.NetworkingV1().NetworkPolicies("shop").Create(context.TODO(), denyAll, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, EventPolicyAdded, next().Type)
+	protected := next()
+	assert.Equal(t, ClusterEvent{Type: EventPodProtected, Kind: "Pod", Namespace: "shop", Name: "web-0", IP: "10.0.0.1"}, protected)
+	assert.Equal(t, "Pod shop/web-0 (10.0.0.1) is now protected by a network policy", protected.String())
+	score := next()
+	assert.Equal(t, EventScore, score.Type)
+	assert.Equal(t, 0, score.Unprotected)
+}
+
+func TestProtectionWatch(t *testing.T) {
+	shop := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	db := testPod("shop", "db-0", "10.0.0.2", map[string]string{"app": "db"})
+	ciliumPolicy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"ingress":          []interface{}{map[string]interface{}{}},
+		},
+	}}
+
+	fakeClientset := fake.NewSimpleClientset(shop, &web, &db)
+	previousClientset, previousInitialized, previousDynamicClient := clientset, isClientInitialized, sharedDynamicClient
+	UseOfflineClients(fakeClientset, dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, ciliumPolicy))
+	defer func() {
+		clientset, isClientInitialized, sharedDynamicClient = previousClientset, previousInitialized, previousDynamicClient
+	}()
+
+	native := &protectionWatch{engines: []string{PolicyTypeKubernetes}, results: map[string]map[string]*ScanResult{}}
+	native.rescan(fakeClientset, nil, true)
+	unprotected, _ := native.protection()
+	assert.Len(t, unprotected, 2)
+
+	// A pod is protected as soon as the policies of one engine protect it
+	watch := &protectionWatch{engines: []string{PolicyTypeKubernetes, PolicyTypeCilium}, results: map[string]map[string]*ScanResult{}}
+	watch.rescan(fakeClientset, nil, true)
+	unprotected, score := watch.protection()
+	assert.Equal(t, map[string]ClusterEvent{
+		"shop/db-0": {Type: EventPodUnprotected, Kind: "Pod", Namespace: "shop", Name: "db-0", IP: "10.0.0.2"},
+	}, unprotected)
+	assert.Less(t, score, 100)
+
+	// Only the changed namespace is scanned again, and a deleted namespace is forgotten
+	assert.NoError(t, fakeClientset.CoreV1().Namespaces().Delete(context.TODO(), "shop", metav1.DeleteOptions{}))
+	watch.rescan(fakeClientset, []string{"shop"}, false)
+	unprotected, score = watch.protection()
+	assert.Empty(t, unprotected)
+	assert.Equal(t, 100, score)
+}
+
+func TestChangeSubscription(t *testing.T) {
+	clusterCache := &ClusterCache{subscriptions: map[*ChangeSubscription]struct{}{}}
+	changes, unsubscribe := clusterCache.SubscribeChanges()
+
+	clusterCache.notifyChanged("shop")
+	clusterCache.notifyChanged("web")
+	clusterCache.notifyChanged("shop")
+	assert.Len(t, changes.Changed(), 1)
+	namespaces, all := changes.Drain()
+	assert.Equal(t, []string{"shop", "web"}, namespaces)
+	assert.False(t, all)
+
+	// Cluster scoped objects change every namespace
+	clusterCache.notifyChanged("")
+	namespaces, all = changes.Drain()
+	assert.Empty(t, namespaces)
+	assert.True(t, all)
+
+	unsubscribe()
+	clusterCache.notifyChanged("shop")
+	namespaces, _ = changes.Drain()
+	assert.Empty(t, namespaces)
+}
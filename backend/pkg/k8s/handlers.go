@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,8 +20,8 @@ func setNoCacheHeaders(w http.ResponseWriter) {
 	w.Header().Set("Expires", "0")
 }
 
-// HandleScanRequest handles the HTTP request for scanning network policies
-func HandleScanRequest(kubeconfigPath string) http.HandlerFunc {
+// HandleScanRequest handles the HTTP request for scanning network policies and records the scan in the history
+func HandleScanRequest(kubeconfigPath string, history *History) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         namespace := r.URL.Query().Get("namespace")
 
@@ -29,6 +31,9 @@ func HandleScanRequest(kubeconfigPath string) http.HandlerFunc {
             http.Error(w, err.Error(), http.StatusInternalServerError)
             return
         }
+        if err := history.Record(HistorySourceDashboard, CurrentCluster(kubeconfigPath), namespace, NewScanResultDocument(result)); err != nil {
+            log.Printf("Error recording scan in the history: %v\n", err)
+        }
 
         // Respond with JSON
         w.Header().Set("Content-Type", "application/json")
@@ -60,7 +65,7 @@ func HandleNamespaceListRequest(kubeconfigPath string) http.HandlerFunc {
 
         var namespaceList []string
         for _, ns := range namespaces.Items {
-            if !IsSystemNamespace(ns.Name) {
+            if activeConfig.IncludesNamespace(ns.Name, ns.Labels) {
                 namespaceList = append(namespaceList, ns.Name)
             }
         }
@@ -204,6 +209,66 @@ func HandleClusterVisualizationRequest(kubeconfigPath string) http.HandlerFunc {
     }
 }
 
+// HandleReachabilityMatrixRequest serves the namespace and workload reachability matrix as JSON or CSV
+func HandleReachabilityMatrixRequest(kubeconfigPath string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+            return
+        }
+
+        namespace := r.URL.Query().Get("namespace")
+        format := r.URL.Query().Get("format")
+        if format == "" {
+            format = OutputFormatJSON
+        }
+        if format != OutputFormatJSON && format != OutputFormatCSV {
+            http.Error(w, "format must be json or csv", http.StatusBadRequest)
+            return
+        }
+
+        var port int32
+        protocol := ""
+        if portParam := r.URL.Query().Get("port"); portParam != "" {
+            var err error
+            port, protocol, err = ParsePortProtocol(portParam)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+        }
+
+        clientset, err := GetClientset(kubeconfigPath)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        dynamicClient, err := GetDynamicClient(kubeconfigPath)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+
+        state, err := LoadClusterState(clientset, dynamicClient)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        matrix := state.BuildReachabilityMatrix(namespace, port, protocol)
+
+        setNoCacheHeaders(w)
+        if format == OutputFormatCSV {
+            w.Header().Set("Content-Type", "text/csv")
+            w.Header().Set("Content-Disposition", "attachment; filename=netfetch-reachability.csv")
+        } else {
+            w.Header().Set("Content-Type", "application/json")
+        }
+        if err := WriteReachabilityMatrix(w, matrix, format); err != nil {
+            http.Error(w, "Failed to encode reachability matrix", http.StatusInternalServerError)
+        }
+    }
+}
+
 // HandlePodInfoRequest handles the HTTP request for serving pod information.
 func HandlePodInfoRequest(kubeconfigPath string) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
@@ -341,3 +406,51 @@ func HandlePolicyYAMLRequest(kubeconfigPath string) http.HandlerFunc {
         w.Write([]byte(yamlData))
     }
 }
+
+// eventsKeepAliveInterval is how often an idle event stream sends a comment, so proxies do not close it
+const eventsKeepAliveInterval = 30 * time.Second
+
+// HandleEventsRequest streams the changes of the cluster to the dashboard as server-sent events.
+func HandleEventsRequest(clusterCache *ClusterCache) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+            return
+        }
+
+        flusher, ok := w.(http.Flusher)
+        if !ok {
+            http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+            return
+        }
+
+        w.Header().Set("Content-Type", "text/event-stream")
+        w.Header().Set("Cache-Control", "no-cache")
+        w.Header().Set("Connection", "keep-alive")
+        w.WriteHeader(http.StatusOK)
+        flusher.Flush()
+
+        events, unsubscribe := clusterCache.Events().Subscribe()
+        defer unsubscribe()
+
+        keepAlive := time.NewTicker(eventsKeepAliveInterval)
+        defer keepAlive.Stop()
+
+        for {
+            select {
+            case <-r.Context().Done():
+                return
+            case event := <-events:
+                data, err := json.Marshal(event)
+                if err != nil {
+                    continue
+                }
+                fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+                flusher.Flush()
+            case <-keepAlive.C:
+                fmt.Fprint(w, ": keepalive\n\n")
+                flusher.Flush()
+            }
+        }
+    }
+}
@@ -0,0 +1,333 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// HistoryFileName is the JSON lines file of the history directory every scan is appended to
+const HistoryFileName = "scans.jsonl"
+
+// Sources of the scans kept in the history
+const (
+	HistorySourceCLI       = "cli"
+	HistorySourceDashboard = "dashboard"
+	// HistorySourceManifests marks scans of rendered manifests instead of a live cluster
+	HistorySourceManifests = "manifests"
+)
+
+// HistoryConfig sets where scans are kept. An empty Directory keeps them in DefaultHistoryDirectory.
+type HistoryConfig struct {
+	Directory string `yaml:"directory"`
+	Disabled  bool   `yaml:"disabled"`
+}
+
+// HistoryRecord is a scan kept in the history. Cluster is the kubeconfig context the scan ran against, and is empty
+// for scans of manifests. Namespace is set when the scan was limited to a single namespace.
+type HistoryRecord struct {
+	Source    string `json:"source"`
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	ScanResultDocument
+}
+
+// HistoryFilter selects the recorded scans of a score series.
+type HistoryFilter struct {
+	// Cluster selects the scans of a cluster, and every cluster when empty
+	Cluster string
+	// Namespace selects the scans limited to the namespace, and the scans of the whole cluster when empty
+	Namespace string
+	// Source selects the scans of a source, and the scans of the cli and the dashboard when empty
+	Source string
+	// Since leaves out the scans made before it
+	Since time.Time
+}
+
+// ScorePoint is the outcome of a past scan in the score series.
+type ScorePoint struct {
+	ScannedAt   string         `json:"scannedAt"`
+	Source      string         `json:"source"`
+	Cluster     string         `json:"cluster,omitempty"`
+	Namespace   string         `json:"namespace,omitempty"`
+	Score       int            `json:"score"`
+	Engines     map[string]int `json:"engines"`
+	Unprotected int            `json:"unprotected"`
+}
+
+// History appends scans to a JSON lines file and reads them back.
+type History struct {
+	path     string
+	disabled bool
+	mu       sync.Mutex
+}
+
+// DefaultHistoryDirectory returns the directory scans are kept in unless the configuration sets another.
+func DefaultHistoryDirectory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".netfetch", "history")
+	}
+	return filepath.Join(home, ".netfetch", "history")
+}
+
+// NewHistory returns the history kept in the directory, or in DefaultHistoryDirectory when it is empty. The directory
+// is created when the first scan is recorded.
+func NewHistory(directory string) *History {
+	if directory == "" {
+		directory = DefaultHistoryDirectory()
+	}
+	return &History{path: filepath.Join(directory, HistoryFileName)}
+}
+
+// ConfiguredHistory returns the history of the active configuration. Recording into it does nothing when the
+// configuration disables the history.
+func ConfiguredHistory() *History {
+	history := NewHistory(activeConfig.History.Directory)
+	history.disabled = activeConfig.History.Disabled
+	return history
+}
+
+// Path returns the file the history is kept in.
+func (h *History) Path() string {
+	return h.path
+}
+
+// CurrentCluster names the cluster a scan runs against: the current context of the kubeconfig, or in-cluster when
+// netfetch runs in a pod without a kubeconfig. It follows the order GetClientset reads the configuration in, and is
+// empty when the kubeconfig cannot be read.
+func CurrentCluster(kubeconfigPath string) string {
+	if kubeconfigPath == "" {
+		if _, err := rest.InClusterConfig(); err == nil {
+			return "in-cluster"
+		}
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfigPath
+	config, err := rules.Load()
+	if err != nil {
+		return ""
+	}
+	return config.CurrentContext
+}
+
+// Record appends the scan of the cluster to the history. Documents without results are not recorded.
+func (h *History) Record(source string, cluster string, namespace string, document *ScanResultDocument) error {
+	if h.disabled || document == nil || len(document.Results) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(HistoryRecord{Source: source, Cluster: cluster, Namespace: namespace, ScanResultDocument: *document})
+	if err != nil {
+		return fmt.Errorf("error encoding scan for the history: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("error creating history directory: %w", err)
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening history file: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing history file: %w", err)
+	}
+	return file.Close()
+}
+
+// Records reads every scan of the history, oldest first. A missing history has no scans, and lines that cannot be
+// read, such as one cut off by a crash, are skipped.
+func (h *History) Records() ([]HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records := []HistoryRecord{}
+	file, err := os.Open(h.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return records, nil
+		}
+		return nil, fmt.Errorf("error opening history file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record HistoryRecord
+			if json.Unmarshal(line, &record) == nil {
+				records = append(records, record)
+			}
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading history file: %w", err)
+		}
+	}
+}
+
+// ScoreSeries returns the score of every recorded scan the filter selects, oldest first. The score of a scan is the
+// lowest score of its scored engines, and scans without a scored engine, such as targeted policy scans, are left
+// out.
+func ScoreSeries(records []HistoryRecord, filter HistoryFilter) []ScorePoint {
+	points := []ScorePoint{}
+	for _, record := range records {
+		if !filter.selects(record) {
+			continue
+		}
+		scannedAt, err := time.Parse(time.RFC3339, record.GeneratedAt)
+		if err != nil || scannedAt.Before(filter.Since) {
+			continue
+		}
+
+		point := ScorePoint{
+			ScannedAt:   record.GeneratedAt,
+			Source:      record.Source,
+			Cluster:     record.Cluster,
+			Namespace:   record.Namespace,
+			Score:       -1,
+			Engines:     map[string]int{},
+			Unprotected: CountUnprotectedPods(&record.ScanResultDocument),
+		}
+		for _, entry := range record.Results {
+			if !isScoredResult(entry) {
+				continue
+			}
+			point.Engines[entry.PolicyType] = entry.Score
+			if point.Score < 0 || entry.Score < point.Score {
+				point.Score = entry.Score
+			}
+		}
+		if point.Score >= 0 {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// selects reports whether the filter selects the scan, apart from its time. Scans of manifests describe what would
+// be deployed rather than a cluster, so they are only selected when asked for.
+func (f HistoryFilter) selects(record HistoryRecord) bool {
+	if record.Namespace != f.Namespace || (f.Cluster != "" && record.Cluster != f.Cluster) {
+		return false
+	}
+	if f.Source == "" {
+		return record.Source != HistorySourceManifests
+	}
+	return record.Source == f.Source
+}
+
+// ValidateHistorySource checks that the source is empty or one of the sources scans are recorded with.
+func ValidateHistorySource(source string) error {
+	switch source {
+	case "", HistorySourceCLI, HistorySourceDashboard, HistorySourceManifests:
+		return nil
+	default:
+		return fmt.Errorf("unknown source %q, must be one of: %s, %s, %s", source, HistorySourceCLI, HistorySourceDashboard, HistorySourceManifests)
+	}
+}
+
+// ParseHistorySince parses the start of a score series, given as a date like 2026-01-31 or an RFC 3339 time. An
+// empty value starts the series at the first recorded scan.
+func ParseHistorySince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if since, err := time.Parse(suppressionDateLayout, value); err == nil {
+		return since, nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use a date like 2026-01-31 or an RFC 3339 time", value)
+	}
+	return since, nil
+}
+
+// HandleHistoryRequest serves the score series of the recorded scans as JSON. The cluster, namespace, source and
+// since query parameters select the scans like the history command does, and the scans of the cluster the
+// dashboard is connected to are served unless another cluster is given.
+func HandleHistoryRequest(history *History, cluster string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		filter := HistoryFilter{Cluster: cluster, Namespace: query.Get("namespace"), Source: query.Get("source")}
+		if query.Has("cluster") {
+			filter.Cluster = query.Get("cluster")
+		}
+		if err := ValidateHistorySource(filter.Source); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		since, err := ParseHistorySince(query.Get("since"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Since = since
+		records, err := history.Records()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := WriteScoreSeries(w, ScoreSeries(records, filter), OutputFormatJSON); err != nil {
+			log.Printf("Error writing score series: %v\n", err)
+		}
+	}
+}
+
+// WriteScoreSeries serializes the score series to w as JSON or CSV.
+func WriteScoreSeries(w io.Writer, points []ScorePoint, format string) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(points)
+	case OutputFormatCSV:
+		return writeScoreSeriesCSV(w, points)
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of: %s, %s", format, OutputFormatJSON, OutputFormatCSV)
+	}
+}
+
+// writeScoreSeriesCSV writes one row per scan and engine, so each engine can be charted in a spreadsheet.
+func writeScoreSeriesCSV(w io.Writer, points []ScorePoint) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write([]string{"scanned_at", "source", "cluster", "namespace", "policy_type", "score", "unprotected"}); err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		for _, policyType := range sortedKeys(point.Engines) {
+			row := []string{point.ScannedAt, point.Source, point.Cluster, point.Namespace, policyType, fmt.Sprint(point.Engines[policyType]), fmt.Sprint(point.Unprotected)}
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
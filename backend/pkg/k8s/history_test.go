@@ -0,0 +1,139 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func historyDocument(generatedAt string, scores map[string]int) *ScanResultDocument {
+	document := &ScanResultDocument{APIVersion: ScanResultAPIVersion, Kind: "ScanResult", GeneratedAt: generatedAt}
+	for _, policyType := range sortedKeys(scores) {
+		document.Results = append(document.Results, ScanResultEntry{
+			PolicyType:        policyType,
+			NamespacesScanned: []string{"shop"},
+			UnprotectedPods:   []PodRecord{{Namespace: "shop", Name: "web-0"}},
+			Score:             scores[policyType],
+		})
+	}
+	return document
+}
+
+func TestHistory(t *testing.T) {
+	history := NewHistory(filepath.Join(t.TempDir(), "history"))
+
+	records, err := history.Records()
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	assert.NoError(t, history.Record(HistorySourceCLI, "prod", "", historyDocument("2026-09-01T10:00:00Z", map[string]int{PolicyTypeKubernetes: 40, PolicyTypeCilium: 55})))
+	assert.NoError(t, history.Record(HistorySourceDashboard, "prod", "shop", historyDocument("2026-09-15T10:00:00Z", map[string]int{PolicyTypeKubernetes: 60})))
+	assert.NoError(t, history.Record(HistorySourceCLI, "prod", "", &ScanResultDocument{GeneratedAt: "2026-09-20T10:00:00Z"}))
+
+	// A line cut off by a crash is skipped
+	file, err := os.OpenFile(history.Path(), os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = file.WriteString("{\"source\":\"cli\",\"gener\n")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	assert.NoError(t, history.Record(HistorySourceCLI, "prod", "", historyDocument("2026-10-01T10:00:00Z", map[string]int{PolicyTypeKubernetes: 72, PolicyTypeCilium: 80})))
+	assert.NoError(t, history.Record(HistorySourceCLI, "staging", "", historyDocument("2026-10-02T10:00:00Z", map[string]int{PolicyTypeKubernetes: 20})))
+	assert.NoError(t, history.Record(HistorySourceManifests, "", "", historyDocument("2026-10-03T10:00:00Z", map[string]int{PolicyTypeKubernetes: 100})))
+
+	records, err = history.Records()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 5) {
+		assert.Equal(t, HistorySourceDashboard, records[1].Source)
+		assert.Equal(t, "prod", records[1].Cluster)
+		assert.Equal(t, "shop", records[1].Namespace)
+		assert.Equal(t, 60, records[1].Results[0].Score)
+	}
+
+	assert.Equal(t, []ScorePoint{
+		{ScannedAt: "2026-09-01T10:00:00Z", Source: HistorySourceCLI, Cluster: "prod", Score: 40, Engines: map[string]int{PolicyTypeCilium: 55, PolicyTypeKubernetes: 40}, Unprotected: 1},
+		{ScannedAt: "2026-10-01T10:00:00Z", Source: HistorySourceCLI, Cluster: "prod", Score: 72, Engines: map[string]int{PolicyTypeCilium: 80, PolicyTypeKubernetes: 72}, Unprotected: 1},
+	}, ScoreSeries(records, HistoryFilter{Cluster: "prod"}))
+
+	since, err := ParseHistorySince("2026-09-02")
+	assert.NoError(t, err)
+	assert.Len(t, ScoreSeries(records, HistoryFilter{Cluster: "prod", Since: since}), 1)
+	assert.Len(t, ScoreSeries(records, HistoryFilter{Cluster: "prod", Namespace: "shop"}), 1)
+
+	// Scans of every cluster are selected without a cluster, but scans of manifests only when asked for
+	assert.Len(t, ScoreSeries(records, HistoryFilter{}), 3)
+	assert.Len(t, ScoreSeries(records, HistoryFilter{Source: HistorySourceCLI}), 3)
+	manifests := ScoreSeries(records, HistoryFilter{Source: HistorySourceManifests})
+	if assert.Len(t, manifests, 1) {
+		assert.Equal(t, 100, manifests[0].Score)
+	}
+
+	var output bytes.Buffer
+	assert.NoError(t, WriteScoreSeries(&output, ScoreSeries(records, HistoryFilter{Namespace: "shop"}), OutputFormatCSV))
+	assert.Equal(t, "scanned_at,source,cluster,namespace,policy_type,score,unprotected\n2026-09-15T10:00:00Z,dashboard,prod,shop,kubernetes,60,1\n", output.String())
+}
+
+func TestDisabledHistory(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "history")
+	config, err := ParseConfig([]byte("history:\n  directory: " + directory + "\n  disabled: true\n"))
+	assert.NoError(t, err)
+	UseConfig(config)
+	defer UseConfig(nil)
+
+	history := ConfiguredHistory()
+	assert.Equal(t, filepath.Join(directory, HistoryFileName), history.Path())
+	assert.NoError(t, history.Record(HistorySourceCLI, "prod", "", historyDocument("2026-10-01T10:00:00Z", map[string]int{PolicyTypeKubernetes: 72})))
+	_, err = os.Stat(directory)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHandleHistoryRequest(t *testing.T) {
+	history := NewHistory(t.TempDir())
+	assert.NoError(t, history.Record(HistorySourceCLI, "prod", "", historyDocument("2026-09-01T10:00:00Z", map[string]int{PolicyTypeKubernetes: 40})))
+	assert.NoError(t, history.Record(HistorySourceDashboard, "prod", "", historyDocument("2026-10-01T10:00:00Z", map[string]int{PolicyTypeKubernetes: 72})))
+	assert.NoError(t, history.Record(HistorySourceCLI, "staging", "", historyDocument("2026-10-02T10:00:00Z", map[string]int{PolicyTypeKubernetes: 20})))
+	assert.NoError(t, history.Record(HistorySourceManifests, "", "", historyDocument("2026-10-03T10:00:00Z", map[string]int{PolicyTypeKubernetes: 100})))
+	handler := HandleHistoryRequest(history, "prod")
+
+	tests := []struct {
+		name     string
+		url      string
+		status   int
+		expected []int
+	}{
+		{name: "Every scan", url: "/history", status: http.StatusOK, expected: []int{40, 72}},
+		{name: "Since a date", url: "/history?since=2026-09-15", status: http.StatusOK, expected: []int{72}},
+		{name: "Other namespace", url: "/history?namespace=web", status: http.StatusOK, expected: []int{}},
+		{name: "Source", url: "/history?source=dashboard", status: http.StatusOK, expected: []int{72}},
+		{name: "Other cluster", url: "/history?cluster=staging", status: http.StatusOK, expected: []int{20}},
+		{name: "Every cluster", url: "/history?cluster=", status: http.StatusOK, expected: []int{40, 72, 20}},
+		{name: "Manifests", url: "/history?cluster=&source=manifests", status: http.StatusOK, expected: []int{100}},
+		{name: "Invalid source", url: "/history?source=ci", status: http.StatusBadRequest},
+		{name: "Invalid since", url: "/history?since=last-month", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler(recorder, httptest.NewRequest(http.MethodGet, test.url, nil))
+			assert.Equal(t, test.status, recorder.Code)
+			if test.status != http.StatusOK {
+				return
+			}
+
+			var points []ScorePoint
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &points))
+			scores := []int{}
+			for _, point := range points {
+				scores = append(scores, point.Score)
+			}
+			assert.Equal(t, test.expected, scores)
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Kinds of the security.istio.io policies
+const (
+	IstioPeerAuthenticationKind  = "PeerAuthentication"
+	IstioAuthorizationPolicyKind = "AuthorizationPolicy"
+)
+
+// istioRootNamespace is the Istio root namespace, whose policies without a selector apply to the whole mesh
+const istioRootNamespace = "istio-system"
+
+// Markers of pods enrolled in the mesh, either with a sidecar or through ambient mode
+const (
+	istioSidecarContainer        = "istio-proxy"
+	istioSidecarStatusAnnotation = "sidecar.istio.io/status"
+	istioSidecarInjectLabel      = "sidecar.istio.io/inject"
+	istioInjectionLabel          = "istio-injection"
+	istioRevisionLabel           = "istio.io/rev"
+	istioDataplaneModeLabel      = "istio.io/dataplane-mode"
+	istioDataplaneModeAmbient    = "ambient"
+)
+
+// mTLS modes of a PeerAuthentication. Workloads without any PeerAuthentication accept plain text and mTLS.
+const (
+	MTLSModeUnset      = "UNSET"
+	MTLSModeDisable    = "DISABLE"
+	MTLSModePermissive = "PERMISSIVE"
+	MTLSModeStrict     = "STRICT"
+)
+
+// Findings reported for a pod after its service mesh policies have been evaluated
+const (
+	FindingNotInMesh                = "not in the service mesh"
+	FindingMTLSNotStrict            = "mTLS not strict"
+	FindingL4WithoutL7Authorization = "L4 policy without L7 authorization"
+)
+
+// PodMeshEvaluation is the Istio coverage of a pod: whether it runs in the mesh, the mTLS mode its
+// PeerAuthentications enforce and the AuthorizationPolicies that apply to it.
+type PodMeshEvaluation struct {
+	Namespace          string   `json:"namespace" yaml:"namespace"`
+	Name               string   `json:"name" yaml:"name"`
+	InMesh             bool     `json:"inMesh" yaml:"inMesh"`
+	MTLSMode           string   `json:"mtlsMode" yaml:"mtlsMode"`
+	StrictMTLS         bool     `json:"strictMtls" yaml:"strictMtls"`
+	PeerAuthentication string   `json:"peerAuthentication,omitempty" yaml:"peerAuthentication,omitempty"`
+	AllowPolicies      []string `json:"allowPolicies" yaml:"allowPolicies"`
+	DenyPolicies       []string `json:"denyPolicies" yaml:"denyPolicies"`
+	L4Covered          bool     `json:"l4Covered" yaml:"l4Covered"`
+	L7Covered          bool     `json:"l7Covered" yaml:"l7Covered"`
+	Findings           []string `json:"findings" yaml:"findings"`
+}
+
+// HasFinding reports whether the evaluation contains the given finding.
+func (e PodMeshEvaluation) HasFinding(finding string) bool {
+	for _, f := range e.Findings {
+		if f == finding {
+			return true
+		}
+	}
+	return false
+}
+
+// istioWorkloadPolicy is the part of a PeerAuthentication or AuthorizationPolicy deciding which workloads it
+// applies to.
+type istioWorkloadPolicy struct {
+	namespace string
+	name      string
+	// selector is nil for policies applying to every workload of their namespace
+	selector labels.Selector
+	// targeted policies attach to gateways or services through targetRefs instead of selecting pods
+	targeted bool
+}
+
+// appliesTo reports whether the policy applies to the pod. Policies in the root namespace without a selector
+// apply to the whole mesh.
+func (p istioWorkloadPolicy) appliesTo(pod corev1.Pod, rootNamespace string) bool {
+	if p.targeted || (p.namespace != pod.Namespace && p.namespace != rootNamespace) {
+		return false
+	}
+	return p.selector == nil || p.selector.Matches(labels.Set(pod.Labels))
+}
+
+type istioPeerAuthentication struct {
+	istioWorkloadPolicy
+	mode      string
+	portModes map[string]string
+}
+
+type istioAuthorizationPolicy struct {
+	istioWorkloadPolicy
+	action string
+}
+
+// MeshPolicies holds the Istio PeerAuthentications and AuthorizationPolicies of a cluster.
+type MeshPolicies struct {
+	rootNamespace         string
+	peerAuthentications   []istioPeerAuthentication
+	authorizationPolicies []istioAuthorizationPolicy
+}
+
+// NewMeshPolicies parses PeerAuthentication and AuthorizationPolicy objects. Policies that cannot be parsed are
+// skipped and returned as errors.
+func NewMeshPolicies(objects []*unstructured.Unstructured, rootNamespace string) (*MeshPolicies, []error) {
+	policies := &MeshPolicies{rootNamespace: rootNamespace}
+	var errs []error
+	for _, object := range objects {
+		spec, _ := nestedMapNoCopy(object.Object, "spec")
+		workloadPolicy, err := istioWorkloadSelector(object, spec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		switch object.GetKind() {
+		case IstioPeerAuthenticationKind:
+			peerAuthentication := istioPeerAuthentication{istioWorkloadPolicy: workloadPolicy, mode: MTLSModeUnset, portModes: map[string]string{}}
+			if mode, _, _ := unstructured.NestedString(spec, "mtls", "mode"); mode != "" {
+				peerAuthentication.mode = strings.ToUpper(mode)
+			}
+			portLevel, _ := nestedMapNoCopy(spec, "portLevelMtls")
+			for port, rawSettings := range portLevel {
+				if settings, ok := rawSettings.(map[string]interface{}); ok {
+					if mode, _ := settings["mode"].(string); mode != "" {
+						peerAuthentication.portModes[port] = strings.ToUpper(mode)
+					}
+				}
+			}
+			policies.peerAuthentications = append(policies.peerAuthentications, peerAuthentication)
+		case IstioAuthorizationPolicyKind:
+			// Policies without an action allow the traffic their rules match
+			action, _ := spec["action"].(string)
+			if action == "" {
+				action = "ALLOW"
+			}
+			policies.authorizationPolicies = append(policies.authorizationPolicies, istioAuthorizationPolicy{istioWorkloadPolicy: workloadPolicy, action: strings.ToUpper(action)})
+		default:
+			errs = append(errs, fmt.Errorf("unsupported Istio kind %q", object.GetKind()))
+		}
+	}
+
+	// Evaluate policies in a stable order so the first matching policy is always the same one
+	sort.SliceStable(policies.peerAuthentications, func(i, j int) bool {
+		return policies.peerAuthentications[i].namespace+"/"+policies.peerAuthentications[i].name < policies.peerAuthentications[j].namespace+"/"+policies.peerAuthentications[j].name
+	})
+	return policies, errs
+}
+
+// istioWorkloadSelector parses the selector and target references of an Istio policy.
+func istioWorkloadSelector(object *unstructured.Unstructured, spec map[string]interface{}) (istioWorkloadPolicy, error) {
+	policy := istioWorkloadPolicy{namespace: object.GetNamespace(), name: object.GetName()}
+	_, hasTargetRef := spec["targetRef"]
+	_, hasTargetRefs := spec["targetRefs"]
+	policy.targeted = hasTargetRef || hasTargetRefs
+
+	if selector, found := nestedMapNoCopy(spec, "selector"); found {
+		matchLabels, _, err := unstructured.NestedStringMap(selector, "matchLabels")
+		if err != nil {
+			return istioWorkloadPolicy{}, fmt.Errorf("error parsing selector of %s %s/%s: %w", object.GetKind(), policy.namespace, policy.name, err)
+		}
+		policy.selector = labels.SelectorFromSet(matchLabels)
+	}
+	return policy, nil
+}
+
+// EvaluatePod computes the mesh coverage of a pod. l4Covered tells whether network policies isolate the pod,
+// which turns missing authorization policies into a finding.
+func (m *MeshPolicies) EvaluatePod(pod corev1.Pod, namespaceLabels map[string]string, l4Covered bool) PodMeshEvaluation {
+	evaluation := PodMeshEvaluation{
+		Namespace:     pod.Namespace,
+		Name:          pod.Name,
+		InMesh:        podInMesh(pod, namespaceLabels),
+		AllowPolicies: []string{},
+		DenyPolicies:  []string{},
+		L4Covered:     l4Covered,
+		Findings:      []string{},
+	}
+
+	if evaluation.InMesh {
+		mode, portModes, source := m.mtlsMode(pod)
+		evaluation.MTLSMode = mode
+		evaluation.PeerAuthentication = source
+		evaluation.StrictMTLS = mode == MTLSModeStrict
+		for _, portMode := range portModes {
+			if portMode != MTLSModeStrict && portMode != MTLSModeUnset {
+				evaluation.StrictMTLS = false
+			}
+		}
+
+		for _, policy := range m.authorizationPolicies {
+			if !policy.appliesTo(pod, m.rootNamespace) {
+				continue
+			}
+			switch policy.action {
+			case "ALLOW":
+				evaluation.AllowPolicies = append(evaluation.AllowPolicies, policy.namespace+"/"+policy.name)
+			case "DENY":
+				evaluation.DenyPolicies = append(evaluation.DenyPolicies, policy.namespace+"/"+policy.name)
+			}
+		}
+		evaluation.L7Covered = len(evaluation.AllowPolicies) > 0 || len(evaluation.DenyPolicies) > 0
+	}
+
+	if !evaluation.InMesh {
+		evaluation.Findings = append(evaluation.Findings, FindingNotInMesh)
+	} else if !evaluation.StrictMTLS {
+		evaluation.Findings = append(evaluation.Findings, FindingMTLSNotStrict)
+	}
+	if l4Covered && !evaluation.L7Covered {
+		evaluation.Findings = append(evaluation.Findings, FindingL4WithoutL7Authorization)
+	}
+	return evaluation
+}
+
+// mtlsMode resolves the mTLS mode of a pod from the most specific PeerAuthentication: one selecting the
+// workload, then one for its namespace, then the mesh wide one in the root namespace. UNSET inherits the mode
+// of the next level, and the mode is PERMISSIVE when no PeerAuthentication sets one. It also returns the port
+// level modes of the workload policy and the policy that decided the mode.
+func (m *MeshPolicies) mtlsMode(pod corev1.Pod) (string, map[string]string, string) {
+	var workload, namespace, mesh *istioPeerAuthentication
+	for i := range m.peerAuthentications {
+		policy := &m.peerAuthentications[i]
+		switch {
+		case policy.targeted:
+		case policy.namespace == pod.Namespace && policy.selector != nil:
+			if workload == nil && policy.selector.Matches(labels.Set(pod.Labels)) {
+				workload = policy
+			}
+		case policy.namespace == pod.Namespace && policy.selector == nil:
+			if namespace == nil {
+				namespace = policy
+			}
+		case policy.namespace == m.rootNamespace && policy.selector == nil:
+			if mesh == nil {
+				mesh = policy
+			}
+		}
+	}
+
+	var portModes map[string]string
+	if workload != nil {
+		portModes = workload.portModes
+	}
+	for _, policy := range []*istioPeerAuthentication{workload, namespace, mesh} {
+		if policy != nil && policy.mode != MTLSModeUnset {
+			return policy.mode, portModes, policy.namespace + "/" + policy.name
+		}
+	}
+	return MTLSModePermissive, portModes, ""
+}
+
+// podInMesh reports whether the pod has an Istio sidecar, or is enrolled in sidecar injection or ambient mode on
+// its own or through its namespace. Injection labels count so that rendered manifests, whose pods are injected
+// at admission, are evaluated like the pods they become.
+func podInMesh(pod corev1.Pod, namespaceLabels map[string]string) bool {
+	if _, found := pod.Annotations[istioSidecarStatusAnnotation]; found {
+		return true
+	}
+	for _, containers := range [][]corev1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for _, container := range containers {
+			if container.Name == istioSidecarContainer {
+				return true
+			}
+		}
+	}
+
+	if inject, found := pod.Labels[istioSidecarInjectLabel]; found {
+		return inject == "true"
+	}
+	if namespaceLabels[istioInjectionLabel] == "enabled" || namespaceLabels[istioRevisionLabel] != "" {
+		return true
+	}
+	if mode, found := pod.Labels[istioDataplaneModeLabel]; found {
+		return mode == istioDataplaneModeAmbient
+	}
+	return namespaceLabels[istioDataplaneModeLabel] == istioDataplaneModeAmbient
+}
@@ -0,0 +1,202 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// istioTestObject builds a security.istio.io policy.
+func istioTestObject(kind, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "security.istio.io/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec":       spec,
+	}}
+}
+
+// meshPod builds a pod with an injected Istio sidecar.
+func meshPod(namespace, name string, podLabels map[string]string) corev1.Pod {
+	pod := testPod(namespace, name, "10.0.0.1", podLabels)
+	pod.Annotations = map[string]string{istioSidecarStatusAnnotation: "{}"}
+	return pod
+}
+
+func TestMeshPoliciesEvaluatePod(t *testing.T) {
+	mtls := func(mode string) map[string]interface{} {
+		return map[string]interface{}{"mtls": map[string]interface{}{"mode": mode}}
+	}
+	web := map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}}
+	meshStrict := istioTestObject(IstioPeerAuthenticationKind, "istio-system", "default", mtls("STRICT"))
+
+	tests := []struct {
+		name             string
+		objects          []*unstructured.Unstructured
+		pod              corev1.Pod
+		namespaceLabels  map[string]string
+		l4Covered        bool
+		expectedInMesh   bool
+		expectedMode     string
+		expectedStrict   bool
+		expectedSource   string
+		expectedAllow    []string
+		expectedDeny     []string
+		expectedFindings []string
+	}{
+		{
+			name:             "no policies",
+			pod:              meshPod("shop", "web-0", map[string]string{"app": "web"}),
+			expectedInMesh:   true,
+			expectedMode:     MTLSModePermissive,
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{FindingMTLSNotStrict},
+		},
+		{
+			name:             "mesh wide strict",
+			objects:          []*unstructured.Unstructured{meshStrict},
+			pod:              meshPod("shop", "web-0", map[string]string{"app": "web"}),
+			expectedInMesh:   true,
+			expectedMode:     MTLSModeStrict,
+			expectedStrict:   true,
+			expectedSource:   "istio-system/default",
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{},
+		},
+		{
+			name: "namespace overrides mesh",
+			objects: []*unstructured.Unstructured{
+				meshStrict,
+				istioTestObject(IstioPeerAuthenticationKind, "shop", "default", mtls("PERMISSIVE")),
+			},
+			pod:              meshPod("shop", "web-0", map[string]string{"app": "web"}),
+			expectedInMesh:   true,
+			expectedMode:     MTLSModePermissive,
+			expectedSource:   "shop/default",
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{FindingMTLSNotStrict},
+		},
+		{
+			name: "unset workload inherits namespace",
+			objects: []*unstructured.Unstructured{
+				istioTestObject(IstioPeerAuthenticationKind, "shop", "default", mtls("STRICT")),
+				istioTestObject(IstioPeerAuthenticationKind, "shop", "web", map[string]interface{}{"selector": web, "mtls": map[string]interface{}{"mode": "UNSET"}}),
+			},
+			pod:              meshPod("shop", "web-0", map[string]string{"app": "web"}),
+			expectedInMesh:   true,
+			expectedMode:     MTLSModeStrict,
+			expectedStrict:   true,
+			expectedSource:   "shop/default",
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{},
+		},
+		{
+			name: "port level exception",
+			objects: []*unstructured.Unstructured{
+				istioTestObject(IstioPeerAuthenticationKind, "shop", "web", map[string]interface{}{
+					"selector":      web,
+					"mtls":          map[string]interface{}{"mode": "STRICT"},
+					"portLevelMtls": map[string]interface{}{"8080": map[string]interface{}{"mode": "DISABLE"}},
+				}),
+			},
+			pod:              meshPod("shop", "web-0", map[string]string{"app": "web"}),
+			expectedInMesh:   true,
+			expectedMode:     MTLSModeStrict,
+			expectedSource:   "shop/web",
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{FindingMTLSNotStrict},
+		},
+		{
+			name: "authorization policies",
+			objects: []*unstructured.Unstructured{
+				meshStrict,
+				istioTestObject(IstioAuthorizationPolicyKind, "shop", "allow-web", map[string]interface{}{"selector": web}),
+				istioTestObject(IstioAuthorizationPolicyKind, "shop", "allow-db", map[string]interface{}{"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "db"}}}),
+				istioTestObject(IstioAuthorizationPolicyKind, "istio-system", "deny-debug", map[string]interface{}{"action": "DENY"}),
+				istioTestObject(IstioAuthorizationPolicyKind, "shop", "audit", map[string]interface{}{"action": "AUDIT"}),
+				istioTestObject(IstioAuthorizationPolicyKind, "payments", "allow-all", map[string]interface{}{}),
+			},
+			pod:              meshPod("shop", "web-0", map[string]string{"app": "web"}),
+			l4Covered:        true,
+			expectedInMesh:   true,
+			expectedMode:     MTLSModeStrict,
+			expectedStrict:   true,
+			expectedSource:   "istio-system/default",
+			expectedAllow:    []string{"shop/allow-web"},
+			expectedDeny:     []string{"istio-system/deny-debug"},
+			expectedFindings: []string{},
+		},
+		{
+			name: "targeted policies do not select pods",
+			objects: []*unstructured.Unstructured{
+				meshStrict,
+				istioTestObject(IstioAuthorizationPolicyKind, "shop", "gateway", map[string]interface{}{
+					"targetRefs": []interface{}{map[string]interface{}{"kind": "Gateway", "name": "shop"}},
+				}),
+			},
+			pod:              meshPod("shop", "web-0", map[string]string{"app": "web"}),
+			l4Covered:        true,
+			expectedInMesh:   true,
+			expectedMode:     MTLSModeStrict,
+			expectedStrict:   true,
+			expectedSource:   "istio-system/default",
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{FindingL4WithoutL7Authorization},
+		},
+		{
+			name:             "not in mesh",
+			objects:          []*unstructured.Unstructured{meshStrict},
+			pod:              testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"}),
+			l4Covered:        true,
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{FindingNotInMesh, FindingL4WithoutL7Authorization},
+		},
+		{
+			name:             "ambient namespace",
+			objects:          []*unstructured.Unstructured{meshStrict},
+			pod:              testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"}),
+			namespaceLabels:  map[string]string{istioDataplaneModeLabel: istioDataplaneModeAmbient},
+			expectedInMesh:   true,
+			expectedMode:     MTLSModeStrict,
+			expectedStrict:   true,
+			expectedSource:   "istio-system/default",
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{},
+		},
+		{
+			name:             "injection disabled on the pod",
+			objects:          []*unstructured.Unstructured{meshStrict},
+			pod:              testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web", istioSidecarInjectLabel: "false"}),
+			namespaceLabels:  map[string]string{istioInjectionLabel: "enabled"},
+			expectedAllow:    []string{},
+			expectedDeny:     []string{},
+			expectedFindings: []string{FindingNotInMesh},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, errs := NewMeshPolicies(tt.objects, istioRootNamespace)
+			assert.Empty(t, errs)
+
+			evaluation := policies.EvaluatePod(tt.pod, tt.namespaceLabels, tt.l4Covered)
+			assert.Equal(t, tt.expectedInMesh, evaluation.InMesh)
+			assert.Equal(t, tt.expectedMode, evaluation.MTLSMode)
+			assert.Equal(t, tt.expectedStrict, evaluation.StrictMTLS)
+			assert.Equal(t, tt.expectedSource, evaluation.PeerAuthentication)
+			assert.Equal(t, tt.expectedAllow, evaluation.AllowPolicies)
+			assert.Equal(t, tt.expectedDeny, evaluation.DenyPolicies)
+			assert.Equal(t, tt.expectedFindings, evaluation.Findings)
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// Istio security APIs. security.istio.io/v1 is served since Istio 1.22, and v1beta1 is read on older meshes.
+var (
+	istioGroupVersion     = schema.GroupVersion{Group: "security.istio.io", Version: "v1"}
+	istioBetaGroupVersion = schema.GroupVersion{Group: "security.istio.io", Version: "v1beta1"}
+)
+
+// errIstioNotInstalled is returned when neither version of the Istio security APIs is served by the cluster
+var errIstioNotInstalled = errors.New("Istio security APIs (security.istio.io) are not installed in this cluster")
+
+// Istio resources read by the scanner
+const (
+	istioPeerAuthentications   = "peerauthentications"
+	istioAuthorizationPolicies = "authorizationpolicies"
+)
+
+// fetchIstioObjects lists an Istio security resource in every namespace. The v1 API is preferred, and v1beta1 is
+// read when it is not installed or holds no objects. It reports whether either API exists.
+func fetchIstioObjects(dynamicClient dynamic.Interface, resource string) ([]*unstructured.Unstructured, bool, error) {
+	installed := false
+	for _, groupVersion := range []schema.GroupVersion{istioGroupVersion, istioBetaGroupVersion} {
+		list, err := dynamicClient.Resource(groupVersion.WithResource(resource)).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return nil, false, fmt.Errorf("error listing %s.%s: %w", resource, groupVersion.Group, err)
+		}
+		installed = true
+		if len(list.Items) == 0 {
+			continue
+		}
+
+		objects := make([]*unstructured.Unstructured, 0, len(list.Items))
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+		return objects, true, nil
+	}
+	return nil, installed, nil
+}
+
+// LoadMeshPolicies reads the PeerAuthentications and AuthorizationPolicies of the cluster. Policies that cannot
+// be parsed are skipped and returned as errors. It fails when Istio's security APIs are not installed.
+func LoadMeshPolicies(dynamicClient dynamic.Interface) (*MeshPolicies, []error, error) {
+	var objects []*unstructured.Unstructured
+	anyInstalled := false
+	for _, resource := range []string{istioPeerAuthentications, istioAuthorizationPolicies} {
+		resourceObjects, installed, err := fetchIstioObjects(dynamicClient, resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		anyInstalled = anyInstalled || installed
+		objects = append(objects, resourceObjects...)
+	}
+	if !anyInstalled {
+		return nil, nil, errIstioNotInstalled
+	}
+
+	policies, errs := NewMeshPolicies(objects, istioRootNamespace)
+	return policies, errs, nil
+}
+
+// ScanIstioCoverage adds the Istio dimension to the finished scans of every engine: for every running pod of the
+// scanned namespaces it reports whether mTLS is STRICT and which ALLOW and DENY AuthorizationPolicies apply, and
+// flags pods the network policies of any engine isolate but no AuthorizationPolicy covers. The evaluations are
+// added to the first result that scanned namespaces, so every pod is reported once.
+func ScanIstioCoverage(scanResults []*ScanResult, isCLI bool, kubeconfigPath string) error {
+	var scanResult *ScanResult
+	var namespacesScanned []string
+	seen := map[string]bool{}
+	// Pods a scan found isolated in at least one direction have L4 coverage
+	l4Covered := map[string]bool{}
+	for _, result := range scanResults {
+		if result == nil || len(result.NamespacesScanned) == 0 {
+			continue
+		}
+		if scanResult == nil && result.PolicyType != PolicyTypeCiliumClusterwide {
+			scanResult = result
+		}
+		for _, nsName := range result.NamespacesScanned {
+			if !seen[nsName] {
+				seen[nsName] = true
+				namespacesScanned = append(namespacesScanned, nsName)
+			}
+		}
+		for _, evaluation := range result.PodEvaluations {
+			if !evaluation.HasFinding(FindingUnprotected) {
+				l4Covered[evaluation.Namespace+"/"+evaluation.Name] = true
+			}
+		}
+	}
+	if scanResult == nil {
+		return nil
+	}
+
+	var output bytes.Buffer
+	writer := bufio.NewWriter(&output)
+
+	dynamicClient, clientset, err := initializeDynamicClients(kubeconfigPath)
+	if err != nil {
+		return err
+	}
+
+	policies, errs, err := LoadMeshPolicies(dynamicClient)
+	if err != nil {
+		return err
+	}
+	for _, err := range errs {
+		printToBoth(writer, fmt.Sprintf("Error evaluating policy: %s\n", err))
+	}
+
+	for _, nsName := range namespacesScanned {
+		namespace, err := clientset.CoreV1().Namespaces().Get(context.TODO(), nsName, metav1.GetOptions{})
+		if err != nil {
+			// Results such as the cluster wide Cilium scan do not list real namespaces
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("error getting namespace %s: %w", nsName, err)
+		}
+		pods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("error listing pods in namespace %s: %w", nsName, err)
+		}
+
+		namespaceLabels := NamespaceLabels([]corev1.Namespace{*namespace})[nsName]
+		evaluations := []PodMeshEvaluation{}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1.PodRunning || activeConfig.ExcludesPod(pod) {
+				continue
+			}
+			evaluations = append(evaluations, policies.EvaluatePod(pod, namespaceLabels, l4Covered[pod.Namespace+"/"+pod.Name]))
+		}
+		sort.Slice(evaluations, func(i, j int) bool {
+			return evaluations[i].Name < evaluations[j].Name
+		})
+		acceptMeshFindings(evaluations, scanResult)
+		scanResult.MeshEvaluations = append(scanResult.MeshEvaluations, evaluations...)
+
+		if isCLI {
+			displayMeshCoverage(nsName, evaluations, writer)
+		}
+	}
+
+	writer.Flush()
+	return nil
+}
+
+// displayMeshCoverage prints the mTLS mode, authorization policies and findings of the pods in a namespace.
+func displayMeshCoverage(nsName string, evaluations []PodMeshEvaluation, writer *bufio.Writer) {
+	if len(evaluations) == 0 {
+		return
+	}
+
+	rows := [][]string{}
+	for _, evaluation := range evaluations {
+		mtls := "not in mesh"
+		if evaluation.InMesh {
+			mtls = evaluation.MTLSMode
+			if evaluation.MTLSMode == MTLSModeStrict && !evaluation.StrictMTLS {
+				mtls += " (port exceptions)"
+			}
+		}
+
+		authorization := []string{}
+		for _, policy := range evaluation.AllowPolicies {
+			authorization = append(authorization, "ALLOW "+policy)
+		}
+		for _, policy := range evaluation.DenyPolicies {
+			authorization = append(authorization, "DENY "+policy)
+		}
+		rows = append(rows, []string{evaluation.Name, mtls, exposureDescription(authorization), strings.Join(evaluation.Findings, "\n")})
+	}
+
+	headerText := fmt.Sprintf("Istio coverage in namespace %s:", nsName)
+	printToBoth(writer, HeaderStyle.Render(headerText)+"\n")
+	printToBoth(writer, createMeshCoverageTable(rows)+"\n")
+}
+
+func createMeshCoverageTable(coverageInfo [][]string) string {
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("99"))).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == 0:
+				return HeaderStyle
+			case row%2 == 0:
+				return EvenRowStyle
+			default:
+				return OddRowStyle
+			}
+		}).
+		Headers("Pod Name", "mTLS", "Authorization Policies", "Findings")
+
+	for _, row := range coverageInfo {
+		t.Row(row...)
+	}
+
+	return t.String()
+}
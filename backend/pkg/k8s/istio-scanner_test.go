@@ -0,0 +1,30 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestLoadMeshPolicies(t *testing.T) {
+	// Meshes older than Istio 1.22 only serve v1beta1
+	peerAuthentication := istioTestObject(IstioPeerAuthenticationKind, "shop", "default", map[string]interface{}{
+		"mtls": map[string]interface{}{"mode": "STRICT"},
+	})
+	peerAuthentication.SetAPIVersion(istioBetaGroupVersion.String())
+	authorizationPolicy := istioTestObject(IstioAuthorizationPolicyKind, "shop", "allow-web", map[string]interface{}{
+		"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, peerAuthentication, authorizationPolicy)
+
+	policies, errs, err := LoadMeshPolicies(dynamicClient)
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+
+	evaluation := policies.EvaluatePod(meshPod("shop", "web-0", map[string]string{"app": "web"}), nil, true)
+	assert.True(t, evaluation.StrictMTLS)
+	assert.Equal(t, []string{"shop/allow-web"}, evaluation.AllowPolicies)
+	assert.Empty(t, evaluation.Findings)
+}
@@ -0,0 +1,279 @@
+package k8s
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsContentType is the content type of the Prometheus text exposition format
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricSample is one value of a metric. labels holds label names and values in pairs, in exposition order.
+type metricSample struct {
+	labels []string
+	value  float64
+}
+
+// metricFamily is a gauge and its samples.
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+// engineScanners are the scans run in the background for each policy engine. Native network policies are scanned
+// together with admin network policies.
+var engineScanners = map[string]func(specificNamespace string, dryRun bool, returnResult bool, isCLI bool, printScore bool, printMessages bool, kubeconfigPath string) (*ScanResult, error){
+	PolicyTypeKubernetes: ScanNetworkPolicies,
+	PolicyTypeCilium:     ScanCiliumNetworkPolicies,
+	PolicyTypeCalico:     ScanCalicoNetworkPolicies,
+	PolicyTypeAntrea:     ScanAntreaNetworkPolicies,
+}
+
+// MetricsExporter scans the cluster on an interval and serves the results of the latest scan as Prometheus
+// metrics.
+type MetricsExporter struct {
+	kubeconfigPath string
+	engines        []string
+
+	mu        sync.RWMutex
+	results   []*ScanResult
+	scannedAt time.Time
+}
+
+// NewMetricsExporter creates an exporter scanning native network policies and every other installed policy engine
+// it has a scanner for.
+func NewMetricsExporter(kubeconfigPath string, engines PolicyEngines) *MetricsExporter {
+	return &MetricsExporter{kubeconfigPath: kubeconfigPath, engines: ScannedEngines(engines)}
+}
+
+// ScannedEngines returns native network policies and every other installed policy engine with a scanner, in report
+// order.
+func ScannedEngines(engines PolicyEngines) []string {
+	scanned := []string{}
+	for _, engine := range policyEngineOrder {
+		if _, found := engineScanners[engine]; found && (engine == PolicyTypeKubernetes || engines.Installed(engine)) {
+			scanned = append(scanned, engine)
+		}
+	}
+	return scanned
+}
+
+// scanEngines scans every non-system namespace with each engine without prompting or printing the score. An engine
+// that fails to scan is logged and left out of the results.
+func scanEngines(engines []string, kubeconfigPath string) []*ScanResult {
+	var results []*ScanResult
+	for _, engine := range engines {
+		result, err := engineScanners[engine]("", false, true, false, false, false, kubeconfigPath)
+		if err != nil {
+			log.Printf("Error scanning %s network policies: %v\n", engine, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Refresh scans the cluster and replaces the results served as metrics. An engine that fails to scan is left out
+// until the next refresh.
+func (e *MetricsExporter) Refresh() {
+	results := scanEngines(e.engines, e.kubeconfigPath)
+
+	e.mu.Lock()
+	e.results = results
+	e.scannedAt = time.Now()
+	e.mu.Unlock()
+}
+
+// Run refreshes the metrics immediately and then on every interval until stopCh is closed.
+func (e *MetricsExporter) Run(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.Refresh()
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HandleMetricsRequest serves the metrics of the latest scan in the Prometheus text format.
+func HandleMetricsRequest(exporter *MetricsExporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		exporter.mu.RLock()
+		results, scannedAt := exporter.results, exporter.scannedAt
+		exporter.mu.RUnlock()
+		if scannedAt.IsZero() {
+			http.Error(w, "The first scan has not completed yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", MetricsContentType)
+		if err := WriteMetrics(w, scannedAt, results...); err != nil {
+			log.Printf("Error writing metrics: %v\n", err)
+		}
+	}
+}
+
+// WriteMetrics writes the gauges describing the scan results in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer, scannedAt time.Time, results ...*ScanResult) error {
+	writer := bufio.NewWriter(w)
+	for _, family := range scanMetrics(scannedAt, results) {
+		fmt.Fprintf(writer, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(writer, "# TYPE %s gauge\n", family.name)
+		for _, sample := range family.samples {
+			fmt.Fprintf(writer, "%s%s %s\n", family.name, formatMetricLabels(sample.labels), strconv.FormatFloat(sample.value, 'f', -1, 64))
+		}
+	}
+	return writer.Flush()
+}
+
+// scanMetrics computes the gauges of the scan results. A pod counts as unprotected only when no scanned engine
+// protects it, and the score is the lowest score of the scanned engines.
+func scanMetrics(scannedAt time.Time, results []*ScanResult) []metricFamily {
+	score := metricFamily{name: "netfetch_score", help: "Lowest Netfetch security score of the scanned policy engines, from 0 to 100."}
+	namespaceScore := metricFamily{name: "netfetch_namespace_score", help: "Netfetch security score the policy engine gives the namespace, from 0 to 100."}
+	unprotected := metricFamily{name: "netfetch_unprotected_pods", help: "Running pods in the namespace that no policy engine protects."}
+	defaultDeny := metricFamily{name: "netfetch_namespace_has_default_deny", help: "Whether the policy engine has a default deny policy covering the namespace."}
+	selected := metricFamily{name: "netfetch_policy_selected_pods", help: "Running pods selected by the network policy."}
+	lastScan := metricFamily{name: "netfetch_last_scan_timestamp_seconds", help: "Unix time of the scan the metrics were computed from."}
+
+	policyPods := map[[2]string]map[string]bool{}
+	lowestScore := -1
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if lowestScore < 0 || result.Score < lowestScore {
+			lowestScore = result.Score
+		}
+
+		for _, namespace := range result.NamespacesScanned {
+			if namespace == "cluster-wide" {
+				continue
+			}
+			hasDefaultDeny := 0.0
+			if contains(result.HasDenyAll, namespace) {
+				hasDefaultDeny = 1
+			}
+			defaultDeny.samples = append(defaultDeny.samples, metricSample{labels: []string{"namespace", namespace, "engine", result.PolicyType}, value: hasDefaultDeny})
+		}
+		if result.ScoreBreakdown != nil {
+			for _, scored := range result.ScoreBreakdown.Namespaces {
+				namespaceScore.samples = append(namespaceScore.samples, metricSample{labels: []string{"namespace", scored.Namespace, "engine", result.PolicyType}, value: float64(scored.Score)})
+			}
+		}
+		for _, policy := range result.PoliciesSelectingNothing {
+			policyPods[[2]string{policy, result.PolicyType}] = map[string]bool{}
+		}
+		for _, evaluation := range result.PodEvaluations {
+			pod := evaluation.Namespace + "/" + evaluation.Name
+			for _, policy := range append(append([]string{}, evaluation.IngressPolicies...), evaluation.EgressPolicies...) {
+				key := [2]string{policy, result.PolicyType}
+				if policyPods[key] == nil {
+					policyPods[key] = map[string]bool{}
+				}
+				policyPods[key][pod] = true
+			}
+		}
+	}
+
+	if lowestScore >= 0 {
+		score.samples = append(score.samples, metricSample{value: float64(lowestScore)})
+	}
+
+	namespaces, pods := unprotectedByEveryEngine(results)
+	unprotectedPods := map[string]int{}
+	for _, pod := range pods {
+		unprotectedPods[pod.Namespace]++
+	}
+	for _, namespace := range namespaces {
+		unprotected.samples = append(unprotected.samples, metricSample{labels: []string{"namespace", namespace}, value: float64(unprotectedPods[namespace])})
+	}
+
+	for key, pods := range policyPods {
+		selected.samples = append(selected.samples, metricSample{labels: []string{"policy", key[0], "engine", key[1]}, value: float64(len(pods))})
+	}
+
+	lastScan.samples = append(lastScan.samples, metricSample{value: float64(scannedAt.Unix())})
+
+	families := []metricFamily{score, namespaceScore, unprotected, defaultDeny, selected, lastScan}
+	for _, family := range families {
+		sort.SliceStable(family.samples, func(i, j int) bool {
+			return strings.Join(family.samples[i].labels, "\x00") < strings.Join(family.samples[j].labels, "\x00")
+		})
+	}
+	return families
+}
+
+// formatMetricLabels renders label pairs as {name="value",...}, escaping backslashes, quotes and newlines.
+func formatMetricLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], escaper.Replace(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// unprotectedByEveryEngine returns the namespaces the results scanned and the pods that every engine scanning their
+// namespace reports as unprotected, both sorted.
+func unprotectedByEveryEngine(results []*ScanResult) ([]string, []PodRecord) {
+	scanningEngines := map[string]int{}
+	unprotectedBy := map[PodRecord]int{}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, namespace := range result.NamespacesScanned {
+			if namespace != "cluster-wide" {
+				scanningEngines[namespace]++
+			}
+		}
+		for _, detail := range result.UnprotectedPods {
+			unprotectedBy[ParsePodDetail(detail)]++
+		}
+	}
+
+	pods := []PodRecord{}
+	for pod, engines := range unprotectedBy {
+		if engines >= scanningEngines[pod.Namespace] {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return sortedKeys(scanningEngines), pods
+}
+
+// sortedKeys returns the keys of the map in ascending order.
+func sortedKeys(values map[string]int) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
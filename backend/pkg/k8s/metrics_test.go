@@ -0,0 +1,126 @@
+package k8s
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	native := &ScanResult{
+		PolicyType:               PolicyTypeKubernetes,
+		NamespacesScanned:        []string{"shop", "web"},
+		HasDenyAll:               []string{"web"},
+		UnprotectedPods:          []string{"shop api-0 10.0.0.1", "shop db-0 10.0.0.2"},
+		PoliciesSelectingNothing: []string{"web/stale"},
+		PodEvaluations: []PodPolicyEvaluation{
+			{Namespace: "web", Name: "frontend-0", IngressPolicies: []string{"web/deny-all"}, EgressPolicies: []string{"web/deny-all"}},
+			{Namespace: "web", Name: "frontend-1", IngressPolicies: []string{"web/deny-all", "web/allow-http"}},
+		},
+		Score: 60,
+		ScoreBreakdown: &ScoreBreakdown{Namespaces: []NamespaceScore{
+			{Namespace: "shop", Score: 20},
+			{Namespace: "web", Score: 100},
+		}},
+	}
+	cilium := &ScanResult{
+		PolicyType:        PolicyTypeCilium,
+		NamespacesScanned: []string{"shop"},
+		UnprotectedPods:   []string{"shop api-0 10.0.0.1"},
+		PodEvaluations: []PodPolicyEvaluation{
+			{Namespace: "shop", Name: "db-0", IngressPolicies: []string{"shop/db"}},
+		},
+		Score: 80,
+		ScoreBreakdown: &ScoreBreakdown{Namespaces: []NamespaceScore{
+			{Namespace: "shop", Score: 80},
+		}},
+	}
+
+	var output bytes.Buffer
+	err := WriteMetrics(&output, time.Unix(1700000000, 0), native, cilium)
+	assert.NoError(t, err)
+	assert.Equal(t, `# HELP netfetch_score Lowest Netfetch security score of the scanned policy engines, from 0 to 100.
+# TYPE netfetch_score gauge
+netfetch_score 60
+# HELP netfetch_namespace_score Netfetch security score the policy engine gives the namespace, from 0 to 100.
+# TYPE netfetch_namespace_score gauge
+netfetch_namespace_score{namespace="shop",engine="cilium"} 80
+netfetch_namespace_score{namespace="shop",engine="kubernetes"} 20
+netfetch_namespace_score{namespace="web",engine="kubernetes"} 100
+# HELP netfetch_unprotected_pods Running pods in the namespace that no policy engine protects.
+# TYPE netfetch_unprotected_pods gauge
+netfetch_unprotected_pods{namespace="shop"} 1
+netfetch_unprotected_pods{namespace="web"} 0
+# HELP netfetch_namespace_has_default_deny Whether the policy engine has a default deny policy covering the namespace.
+# TYPE netfetch_namespace_has_default_deny gauge
+netfetch_namespace_has_default_deny{namespace="shop",engine="cilium"} 0
+netfetch_namespace_has_default_deny{namespace="shop",engine="kubernetes"} 0
+netfetch_namespace_has_default_deny{namespace="web",engine="kubernetes"} 1
+# HELP netfetch_policy_selected_pods Running pods selected by the network policy.
+# TYPE netfetch_policy_selected_pods gauge
+netfetch_policy_selected_pods{policy="shop/db",engine="cilium"} 1
+netfetch_policy_selected_pods{policy="web/allow-http",engine="kubernetes"} 1
+netfetch_policy_selected_pods{policy="web/deny-all",engine="kubernetes"} 2
+netfetch_policy_selected_pods{policy="web/stale",engine="kubernetes"} 0
+# HELP netfetch_last_scan_timestamp_seconds Unix time of the scan the metrics were computed from.
+# TYPE netfetch_last_scan_timestamp_seconds gauge
+netfetch_last_scan_timestamp_seconds 1700000000
+`, output.String())
+}
+
+func TestFormatMetricLabels(t *testing.T) {
+	assert.Equal(t, "", formatMetricLabels(nil))
+	assert.Equal(t, `{policy="a\\b \"c\"\nd",engine="calico"}`, formatMetricLabels([]string{"policy", "a\\b \"c\"\nd", "engine", "calico"}))
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	shop := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	db := testPod("shop", "db-0", "10.0.0.2", map[string]string{"app": "db"})
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"ingress":          []interface{}{map[string]interface{}{}},
+		},
+	}}
+
+	previousClientset, previousInitialized, previousDynamicClient := clientset, isClientInitialized, sharedDynamicClient
+	UseOfflineClients(fake.NewSimpleClientset(shop, &web, &db), dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, policy))
+	defer func() {
+		clientset, isClientInitialized, sharedDynamicClient = previousClientset, previousInitialized, previousDynamicClient
+	}()
+
+	// The dashboard scans for live events and metrics at the same time, which must not share scan state
+	exporters := []*MetricsExporter{
+		{engines: []string{PolicyTypeKubernetes, PolicyTypeCilium}},
+		{engines: []string{PolicyTypeCilium}},
+		{engines: []string{PolicyTypeCilium}},
+	}
+	var wg sync.WaitGroup
+	for _, exporter := range exporters {
+		wg.Add(1)
+		go func(exporter *MetricsExporter) {
+			defer wg.Done()
+			exporter.Refresh()
+		}(exporter)
+	}
+	wg.Wait()
+
+	for _, exporter := range exporters {
+		cilium := exporter.results[len(exporter.results)-1]
+		assert.Equal(t, PolicyTypeCilium, cilium.PolicyType)
+		assert.Equal(t, []string{"shop db-0 10.0.0.2"}, cilium.UnprotectedPods)
+	}
+}
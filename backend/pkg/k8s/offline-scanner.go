@@ -0,0 +1,422 @@
+package k8s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// offlineListKinds maps the resources the scanners list through the dynamic client to their list kinds
+var offlineListKinds = map[schema.GroupVersionResource]string{
+	{Group: "", Version: "v1", Resource: "namespaces"}:                                "NamespaceList",
+	{Group: "", Version: "v1", Resource: "pods"}:                                      "PodList",
+	{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}:          "NetworkPolicyList",
+	{Group: "cilium.io", Version: "v2", Resource: "ciliumnetworkpolicies"}:            "CiliumNetworkPolicyList",
+	{Group: "cilium.io", Version: "v2", Resource: "ciliumclusterwidenetworkpolicies"}: "CiliumClusterwideNetworkPolicyList",
+	calicoAPIGroupVersion.WithResource(calicoNetworkPolicies):                         "NetworkPolicyList",
+	calicoAPIGroupVersion.WithResource(calicoGlobalNetworkPolicies):                   "GlobalNetworkPolicyList",
+	calicoAPIGroupVersion.WithResource(calicoTiersResource):                           "TierList",
+	calicoCRDGroupVersion.WithResource(calicoNetworkPolicies):                         "NetworkPolicyList",
+	calicoCRDGroupVersion.WithResource(calicoGlobalNetworkPolicies):                   "GlobalNetworkPolicyList",
+	calicoCRDGroupVersion.WithResource(calicoTiersResource):                           "TierList",
+	adminNetworkPolicyResource:                                                        "AdminNetworkPolicyList",
+	baselineAdminNetworkPolicyResource:                                                "BaselineAdminNetworkPolicyList",
+	antreaGroupVersion.WithResource(antreaClusterNetworkPolicies):                     "ClusterNetworkPolicyList",
+	antreaGroupVersion.WithResource(antreaNetworkPolicies):                            "NetworkPolicyList",
+	antreaGroupVersion.WithResource(antreaTiersResource):                              "TierList",
+	antreaGroupVersion.WithResource(antreaClusterGroupsResource):                      "ClusterGroupList",
+	antreaGroupVersion.WithResource(antreaGroupsResource):                             "GroupList",
+	istioGroupVersion.WithResource(istioPeerAuthentications):                          "PeerAuthenticationList",
+	istioGroupVersion.WithResource(istioAuthorizationPolicies):                        "AuthorizationPolicyList",
+	istioBetaGroupVersion.WithResource(istioPeerAuthentications):                      "PeerAuthenticationList",
+	istioBetaGroupVersion.WithResource(istioAuthorizationPolicies):                    "AuthorizationPolicyList",
+}
+
+// offlineClusterScopedKinds are the kinds read from manifests that are not namespaced
+var offlineClusterScopedKinds = map[string]bool{
+	"Namespace":                      true,
+	"CiliumClusterwideNetworkPolicy": true,
+	"GlobalNetworkPolicy":            true,
+	"Tier":                           true,
+	AdminNetworkPolicyKind:           true,
+	BaselineAdminNetworkPolicyKind:   true,
+	AntreaClusterNetworkPolicyKind:   true,
+	"ClusterGroup":                   true,
+}
+
+// OfflineManifests holds the objects read from a directory of rendered manifests.
+type OfflineManifests struct {
+	Namespaces      []corev1.Namespace
+	Pods            []corev1.Pod
+	NetworkPolicies []networkingv1.NetworkPolicy
+	CustomResources []*unstructured.Unstructured
+	SkippedKinds    []string
+}
+
+// UseOfflineClients makes GetClientset and GetDynamicClient return the given clients,
+// so every scanner runs unchanged against them instead of a live API server.
+func UseOfflineClients(offlineClientset kubernetes.Interface, dynamicClient dynamic.Interface) {
+	clientset = offlineClientset
+	isClientInitialized = true
+	sharedDynamicClient = dynamicClient
+}
+
+// LoadManifests reads every YAML or JSON file at path (a file or a directory) and
+// expands workload templates into synthetic running pods.
+func LoadManifests(path string) (*OfflineManifests, error) {
+	files, err := manifestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no manifest files found in %s", path)
+	}
+
+	manifests := &OfflineManifests{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading manifest %s: %w", file, err)
+		}
+		objects, err := decodeManifests(content)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding manifest %s: %w", file, err)
+		}
+		for _, obj := range objects {
+			if err := manifests.add(obj); err != nil {
+				return nil, fmt.Errorf("error loading %s %s from %s: %w", obj.GetKind(), obj.GetName(), file, err)
+			}
+		}
+	}
+
+	manifests.ensureNamespaces()
+	return manifests, nil
+}
+
+// manifestFiles returns the manifest files found at path in a stable order.
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifests from %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking manifest directory %s: %w", path, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// decodeManifests splits a multi-document YAML or JSON file into objects, flattening List kinds.
+func decodeManifests(content []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		raw := map[string]interface{}{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				objects = append(objects, &list.Items[i])
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// add sorts a decoded object into the manifest set, expanding workloads into pods.
+func (m *OfflineManifests) add(obj *unstructured.Unstructured) error {
+	if obj.GetNamespace() == "" && !offlineClusterScopedKinds[obj.GetKind()] {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+
+	// Calico and Antrea reuse kind names such as NetworkPolicy, so their objects are recognized by API group
+	group := obj.GroupVersionKind().Group
+	if group == calicoAPIGroupVersion.Group || group == calicoCRDGroupVersion.Group || group == antreaGroupVersion.Group {
+		switch obj.GetKind() {
+		case "NetworkPolicy", "GlobalNetworkPolicy", "Tier", AntreaClusterNetworkPolicyKind, "ClusterGroup", "Group":
+			m.CustomResources = append(m.CustomResources, obj)
+		default:
+			if !contains(m.SkippedKinds, obj.GetKind()) {
+				m.SkippedKinds = append(m.SkippedKinds, obj.GetKind())
+			}
+		}
+		return nil
+	}
+
+	switch obj.GetKind() {
+	case "Namespace":
+		var ns corev1.Namespace
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ns); err != nil {
+			return err
+		}
+		m.Namespaces = append(m.Namespaces, ns)
+	case "Pod":
+		var pod corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod); err != nil {
+			return err
+		}
+		if pod.Status.Phase == "" {
+			pod.Status.Phase = corev1.PodRunning
+		}
+		m.Pods = append(m.Pods, pod)
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &deployment); err != nil {
+			return err
+		}
+		m.addSyntheticPods(deployment.ObjectMeta, "Deployment", deployment.Spec.Template, replicaCount(deployment.Spec.Replicas))
+	case "StatefulSet":
+		var statefulSet appsv1.StatefulSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &statefulSet); err != nil {
+			return err
+		}
+		m.addSyntheticPods(statefulSet.ObjectMeta, "StatefulSet", statefulSet.Spec.Template, replicaCount(statefulSet.Spec.Replicas))
+	case "ReplicaSet":
+		var replicaSet appsv1.ReplicaSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &replicaSet); err != nil {
+			return err
+		}
+		m.addSyntheticPods(replicaSet.ObjectMeta, "ReplicaSet", replicaSet.Spec.Template, replicaCount(replicaSet.Spec.Replicas))
+	case "DaemonSet":
+		var daemonSet appsv1.DaemonSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &daemonSet); err != nil {
+			return err
+		}
+		m.addSyntheticPods(daemonSet.ObjectMeta, "DaemonSet", daemonSet.Spec.Template, 1)
+	case "Job":
+		var job batchv1.Job
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job); err != nil {
+			return err
+		}
+		m.addSyntheticPods(job.ObjectMeta, "Job", job.Spec.Template, 1)
+	case "CronJob":
+		var cronJob batchv1.CronJob
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &cronJob); err != nil {
+			return err
+		}
+		m.addSyntheticPods(cronJob.ObjectMeta, "CronJob", cronJob.Spec.JobTemplate.Spec.Template, 1)
+	case "NetworkPolicy":
+		var policy networkingv1.NetworkPolicy
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &policy); err != nil {
+			return err
+		}
+		m.NetworkPolicies = append(m.NetworkPolicies, policy)
+	case "CiliumNetworkPolicy", "CiliumClusterwideNetworkPolicy", AdminNetworkPolicyKind, BaselineAdminNetworkPolicyKind,
+		IstioPeerAuthenticationKind, IstioAuthorizationPolicyKind:
+		m.CustomResources = append(m.CustomResources, obj)
+	default:
+		if !contains(m.SkippedKinds, obj.GetKind()) {
+			m.SkippedKinds = append(m.SkippedKinds, obj.GetKind())
+		}
+	}
+	return nil
+}
+
+// replicaCount returns the desired replicas of a workload, defaulting to one like the API server does.
+func replicaCount(replicas *int32) int {
+	if replicas == nil {
+		return 1
+	}
+	return int(*replicas)
+}
+
+// addSyntheticPods creates running pods from a workload template so the coverage logic can evaluate them.
+func (m *OfflineManifests) addSyntheticPods(owner metav1.ObjectMeta, ownerKind string, template corev1.PodTemplateSpec, replicas int) {
+	isController := true
+	for i := 0; i < replicas; i++ {
+		pod := corev1.Pod{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("%s-%d", owner.Name, i),
+				Namespace:   owner.Namespace,
+				Labels:      template.Labels,
+				Annotations: template.Annotations,
+				OwnerReferences: []metav1.OwnerReference{{
+					Kind:       ownerKind,
+					Name:       owner.Name,
+					Controller: &isController,
+				}},
+			},
+			Spec:   template.Spec,
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		m.Pods = append(m.Pods, pod)
+	}
+}
+
+// ensureNamespaces creates a namespace object for every namespace referenced by a manifest.
+func (m *OfflineManifests) ensureNamespaces() {
+	known := make(map[string]bool)
+	for i := range m.Namespaces {
+		ns := &m.Namespaces[i]
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		ns.Labels["kubernetes.io/metadata.name"] = ns.Name
+		known[ns.Name] = true
+	}
+
+	var referenced []string
+	for _, pod := range m.Pods {
+		referenced = append(referenced, pod.Namespace)
+	}
+	for _, policy := range m.NetworkPolicies {
+		referenced = append(referenced, policy.Namespace)
+	}
+	for _, obj := range m.CustomResources {
+		if obj.GetNamespace() != "" {
+			referenced = append(referenced, obj.GetNamespace())
+		}
+	}
+
+	for _, name := range referenced {
+		if known[name] {
+			continue
+		}
+		known[name] = true
+		m.Namespaces = append(m.Namespaces, corev1.Namespace{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"kubernetes.io/metadata.name": name},
+			},
+		})
+	}
+}
+
+// Clients returns a fake clientset and dynamic client serving the loaded manifests.
+func (m *OfflineManifests) Clients() (kubernetes.Interface, dynamic.Interface, error) {
+	var typedObjects []runtime.Object
+	var dynamicObjects []runtime.Object
+
+	for i := range m.Namespaces {
+		ns := m.Namespaces[i].DeepCopy()
+		ns.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}
+		typedObjects = append(typedObjects, ns)
+		obj, err := toUnstructured(ns)
+		if err != nil {
+			return nil, nil, err
+		}
+		dynamicObjects = append(dynamicObjects, obj)
+	}
+	for i := range m.Pods {
+		pod := m.Pods[i].DeepCopy()
+		pod.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}
+		typedObjects = append(typedObjects, pod)
+		obj, err := toUnstructured(pod)
+		if err != nil {
+			return nil, nil, err
+		}
+		dynamicObjects = append(dynamicObjects, obj)
+	}
+	for i := range m.NetworkPolicies {
+		policy := m.NetworkPolicies[i].DeepCopy()
+		policy.TypeMeta = metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"}
+		typedObjects = append(typedObjects, policy)
+		obj, err := toUnstructured(policy)
+		if err != nil {
+			return nil, nil, err
+		}
+		dynamicObjects = append(dynamicObjects, obj)
+	}
+	for _, obj := range m.CustomResources {
+		dynamicObjects = append(dynamicObjects, obj.DeepCopy())
+	}
+
+	offlineClientset := fake.NewSimpleClientset(typedObjects...)
+	offlineClientset.Resources = m.apiResources()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, dynamicObjects...)
+	return offlineClientset, dynamicClient, nil
+}
+
+// apiResources describes the loaded manifests to the discovery API: the core and networking APIs are always
+// served, and a custom policy API is served when the manifests contain objects of it.
+func (m *OfflineManifests) apiResources() []*metav1.APIResourceList {
+	present := map[schema.GroupVersionKind]bool{}
+	for _, obj := range m.CustomResources {
+		present[obj.GroupVersionKind()] = true
+	}
+
+	resources := map[string]*metav1.APIResourceList{}
+	for gvr, listKind := range offlineListKinds {
+		kind := strings.TrimSuffix(listKind, "List")
+		if gvr.Group != "" && gvr.Group != networkingv1.GroupName && !present[gvr.GroupVersion().WithKind(kind)] {
+			continue
+		}
+		groupVersion := gvr.GroupVersion().String()
+		if resources[groupVersion] == nil {
+			resources[groupVersion] = &metav1.APIResourceList{GroupVersion: groupVersion}
+		}
+		resources[groupVersion].APIResources = append(resources[groupVersion].APIResources, metav1.APIResource{Name: gvr.Resource, Kind: kind})
+	}
+
+	lists := make([]*metav1.APIResourceList, 0, len(resources))
+	for _, list := range resources {
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].GroupVersion < lists[j].GroupVersion
+	})
+	return lists
+}
+
+// toUnstructured converts a typed object into its unstructured form for the dynamic client.
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
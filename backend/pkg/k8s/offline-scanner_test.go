@@ -0,0 +1,146 @@
+package k8s
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const offlineTestManifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: shop
+spec:
+  template:
+    metadata:
+      labels:
+        app: db
+    spec:
+      containers:
+      - name: db
+        image: postgres
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: db-policy
+  namespace: shop
+spec:
+  podSelector:
+    matchLabels:
+      app: db
+---
+apiVersion: projectcalico.org/v3
+kind: NetworkPolicy
+metadata:
+  name: web-allow
+  namespace: shop
+spec:
+  selector: app == 'web'
+---
+apiVersion: crd.antrea.io/v1beta1
+kind: ClusterNetworkPolicy
+metadata:
+  name: block-metadata
+spec:
+  priority: 1
+  appliedTo:
+  - namespaceSelector: {}
+  egress:
+  - action: Drop
+    to:
+    - ipBlock:
+        cidr: 169.254.169.254/32
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`
+
+func TestLoadManifests(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shop.yaml"), []byte(offlineTestManifests), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	manifests, err := LoadManifests(dir)
+	if err != nil {
+		t.Fatalf("Failed to load manifests: %v", err)
+	}
+
+	assert.Len(t, manifests.Pods, 3, "deployment replicas and statefulset should expand into pods")
+	assert.Len(t, manifests.NetworkPolicies, 1)
+	assert.Len(t, manifests.CustomResources, 2, "Calico and Antrea policies must not be read as Kubernetes NetworkPolicies")
+	assert.Equal(t, []string{"ConfigMap"}, manifests.SkippedKinds)
+
+	var namespaceNames []string
+	for _, ns := range manifests.Namespaces {
+		namespaceNames = append(namespaceNames, ns.Name)
+	}
+	assert.Equal(t, []string{"shop"}, namespaceNames)
+
+	offlineClientset, offlineDynamicClient, err := manifests.Clients()
+	if err != nil {
+		t.Fatalf("Failed to create offline clients: %v", err)
+	}
+
+	// The synthetic pods must be selectable the same way the scanners select live pods
+	pods, err := offlineClientset.CoreV1().Pods("shop").List(context.TODO(), metav1.ListOptions{LabelSelector: "app=db"})
+	if err != nil {
+		t.Fatalf("Failed to list pods: %v", err)
+	}
+	assert.Len(t, pods.Items, 1)
+	assert.Equal(t, "db-0", pods.Items[0].Name)
+
+	policies, err := offlineClientset.NetworkingV1().NetworkPolicies("shop").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list network policies: %v", err)
+	}
+	allPods, err := offlineClientset.CoreV1().Pods("shop").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list pods: %v", err)
+	}
+	assert.Equal(t, map[string]bool{"db-0": true}, findCoveredPods(policies.Items, allPods.Items, "shop", nil, &ScanResult{}))
+
+	calicoModels, _, err := LoadCalicoPolicyModels(offlineDynamicClient)
+	if err != nil {
+		t.Fatalf("Failed to load Calico policies: %v", err)
+	}
+	assert.Len(t, calicoModels, 1)
+
+	antreaModels, _, err := LoadAntreaPolicyModels(offlineDynamicClient)
+	if err != nil {
+		t.Fatalf("Failed to load Antrea policies: %v", err)
+	}
+	assert.Len(t, antreaModels, 1)
+
+	// Discovery serves the policy APIs of the loaded objects so their engines are scanned by default
+	engines, err := DetectPolicyEngines(offlineClientset)
+	if err != nil {
+		t.Fatalf("Failed to detect policy engines: %v", err)
+	}
+	assert.Equal(t, []string{PolicyTypeKubernetes, PolicyTypeCalico, PolicyTypeAntrea}, engines.installedEngines())
+}
@@ -0,0 +1,270 @@
+package k8s
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Policy types reported in scan results
+const (
+	PolicyTypeKubernetes        = "kubernetes"
+	PolicyTypeCilium            = "cilium"
+	PolicyTypeCiliumClusterwide = "cilium-clusterwide"
+	PolicyTypeCalico            = "calico"
+	PolicyTypeAntrea            = "antrea"
+	// AdminNetworkPolicies and BaselineAdminNetworkPolicies are evaluated together with native policies
+	PolicyTypeAdminNetworkPolicy         = "admin-network-policy"
+	PolicyTypeBaselineAdminNetworkPolicy = "baseline-admin-network-policy"
+)
+
+// Machine-readable output formats supported by the scan command
+const (
+	OutputFormatJSON  = "json"
+	OutputFormatYAML  = "yaml"
+	OutputFormatCSV   = "csv"
+	OutputFormatSARIF = "sarif"
+)
+
+// ScanResultAPIVersion is the schema version of the machine-readable scan result document.
+// Bump it whenever a field is renamed or removed.
+const ScanResultAPIVersion = "netfetch.io/v1alpha1"
+
+// ScanResultDocument is the versioned, machine-readable form of one or more scan results.
+type ScanResultDocument struct {
+	APIVersion  string            `json:"apiVersion" yaml:"apiVersion"`
+	Kind        string            `json:"kind" yaml:"kind"`
+	GeneratedAt string            `json:"generatedAt" yaml:"generatedAt"`
+	Results     []ScanResultEntry `json:"results" yaml:"results"`
+}
+
+// ScanResultEntry holds the outcome of a scan for a single policy type.
+type ScanResultEntry struct {
+	PolicyType               string                `json:"policyType" yaml:"policyType"`
+	NamespacesScanned        []string              `json:"namespacesScanned" yaml:"namespacesScanned"`
+	NamespacesDenyAll        []string              `json:"namespacesWithDenyAll" yaml:"namespacesWithDenyAll"`
+	NamespacesWithoutDenyAll []string              `json:"namespacesWithoutDenyAll" yaml:"namespacesWithoutDenyAll"`
+	UnprotectedPods          []PodRecord           `json:"unprotectedPods" yaml:"unprotectedPods"`
+	PoliciesSelectingNothing []ObjectReference     `json:"policiesSelectingNothing" yaml:"policiesSelectingNothing"`
+	PodEvaluations           []PodPolicyEvaluation `json:"podEvaluations" yaml:"podEvaluations"`
+	MeshEvaluations          []PodMeshEvaluation   `json:"meshEvaluations,omitempty" yaml:"meshEvaluations,omitempty"`
+	Suppressed               []SuppressedFinding   `json:"suppressed" yaml:"suppressed"`
+	Waived                   []WaivedFinding       `json:"waived" yaml:"waived"`
+	Score                    int                   `json:"score" yaml:"score"`
+	ScoreBreakdown           *ScoreBreakdown       `json:"scoreBreakdown,omitempty" yaml:"scoreBreakdown,omitempty"`
+	AllPodsProtected         bool                  `json:"allPodsProtected" yaml:"allPodsProtected"`
+	PolicyChangesMade        bool                  `json:"policyChangesMade" yaml:"policyChangesMade"`
+	UserDeniedPolicies       bool                  `json:"userDeniedPolicies" yaml:"userDeniedPolicies"`
+}
+
+// ObjectReference identifies the Kubernetes object a finding refers to.
+type ObjectReference struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name      string `json:"name" yaml:"name"`
+}
+
+// PodRecord is the structured form of a pod reported by a scan.
+type PodRecord struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	IP        string `json:"ip,omitempty" yaml:"ip,omitempty"`
+}
+
+// ParsePodDetail converts the "namespace name ip" and "namespace/name" strings used by the scanners into a PodRecord.
+func ParsePodDetail(detail string) PodRecord {
+	fields := strings.Fields(detail)
+	if len(fields) == 1 {
+		if namespace, name, found := strings.Cut(fields[0], "/"); found {
+			return PodRecord{Namespace: namespace, Name: name}
+		}
+		return PodRecord{Name: fields[0]}
+	}
+
+	record := PodRecord{}
+	if len(fields) > 0 {
+		record.Namespace = fields[0]
+	}
+	if len(fields) > 1 {
+		record.Name = fields[1]
+	}
+	if len(fields) > 2 {
+		record.IP = fields[2]
+	}
+	return record
+}
+
+// NewScanResultDocument builds a versioned document from the given scan results. Namespaces without a default deny
+// policy and policies selecting nothing are left out when their finding is waived or suppressed.
+func NewScanResultDocument(results ...*ScanResult) *ScanResultDocument {
+	document := &ScanResultDocument{
+		APIVersion:  ScanResultAPIVersion,
+		Kind:        "ScanResult",
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Results:     []ScanResultEntry{},
+	}
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		entry := ScanResultEntry{
+			PolicyType:               result.PolicyType,
+			NamespacesScanned:        nonNilStrings(result.NamespacesScanned),
+			NamespacesDenyAll:        nonNilStrings(result.HasDenyAll),
+			NamespacesWithoutDenyAll: []string{},
+			UnprotectedPods:          []PodRecord{},
+			PoliciesSelectingNothing: []ObjectReference{},
+			PodEvaluations:           []PodPolicyEvaluation{},
+			Score:                    result.Score,
+			ScoreBreakdown:           result.ScoreBreakdown,
+			AllPodsProtected:         result.AllPodsProtected,
+			PolicyChangesMade:        result.PolicyChangesMade,
+			UserDeniedPolicies:       result.UserDeniedPolicies,
+		}
+		for _, detail := range result.UnprotectedPods {
+			entry.UnprotectedPods = append(entry.UnprotectedPods, ParsePodDetail(detail))
+		}
+
+		// Findings about namespaces and policies are accepted here, next to the ones the scan already accepted
+		accepted := &ScanResult{
+			Suppressed: append([]SuppressedFinding{}, result.Suppressed...),
+			Waived:     append([]WaivedFinding{}, result.Waived...),
+			waivers:    result.waivers,
+		}
+		for _, namespace := range result.NamespacesScanned {
+			if namespace == "cluster-wide" || contains(result.HasDenyAll, namespace) {
+				continue
+			}
+			if !acceptFinding(accepted, RuleNamespaceWithoutDenyAll, namespace, namespace, "") {
+				entry.NamespacesWithoutDenyAll = append(entry.NamespacesWithoutDenyAll, namespace)
+			}
+		}
+		for _, policy := range result.PoliciesSelectingNothing {
+			reference := policyReference(result.PolicyType, policy)
+			if !acceptFinding(accepted, RulePolicySelectsNothing, reference.Namespace, reference.Name, "") {
+				entry.PoliciesSelectingNothing = append(entry.PoliciesSelectingNothing, reference)
+			}
+		}
+		entry.Suppressed = accepted.Suppressed
+		entry.Waived = accepted.Waived
+		entry.PodEvaluations = append(entry.PodEvaluations, result.PodEvaluations...)
+		entry.MeshEvaluations = result.MeshEvaluations
+		document.Results = append(document.Results, entry)
+	}
+
+	return document
+}
+
+// policyReference converts a "namespace/name" or cluster scoped "name" policy identifier into an ObjectReference.
+func policyReference(policyType string, policy string) ObjectReference {
+	kind := "NetworkPolicy"
+	switch policyType {
+	case PolicyTypeCilium:
+		kind = "CiliumNetworkPolicy"
+	case PolicyTypeCiliumClusterwide:
+		kind = "CiliumClusterwideNetworkPolicy"
+	case PolicyTypeCalico:
+		kind = "GlobalNetworkPolicy"
+	case PolicyTypeAntrea:
+		kind = AntreaClusterNetworkPolicyKind
+	case PolicyTypeAdminNetworkPolicy:
+		kind = AdminNetworkPolicyKind
+	case PolicyTypeBaselineAdminNetworkPolicy:
+		kind = BaselineAdminNetworkPolicyKind
+	}
+
+	if namespace, name, found := strings.Cut(policy, "/"); found {
+		if policyType == PolicyTypeCalico || policyType == PolicyTypeAntrea {
+			kind = "NetworkPolicy"
+		}
+		return ObjectReference{Kind: kind, Namespace: namespace, Name: name}
+	}
+	return ObjectReference{Kind: kind, Name: policy}
+}
+
+// nonNilStrings makes sure empty lists are serialized as [] instead of null.
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// ValidateOutputFormat checks that format is one of the supported machine-readable formats.
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case OutputFormatJSON, OutputFormatYAML, OutputFormatCSV, OutputFormatSARIF:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of: %s, %s, %s, %s", format, OutputFormatJSON, OutputFormatYAML, OutputFormatCSV, OutputFormatSARIF)
+	}
+}
+
+// WriteScanResultDocument serializes the document to w in the requested format.
+func WriteScanResultDocument(w io.Writer, document *ScanResultDocument, format string) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+	case OutputFormatYAML:
+		yamlBytes, err := yaml.Marshal(document)
+		if err != nil {
+			return fmt.Errorf("error encoding scan result as YAML: %w", err)
+		}
+		_, err = w.Write(yamlBytes)
+		return err
+	case OutputFormatCSV:
+		return writeScanResultCSV(w, document)
+	case OutputFormatSARIF:
+		return WriteSARIF(w, document)
+	default:
+		return ValidateOutputFormat(format)
+	}
+}
+
+// writeScanResultCSV writes one row per unprotected pod so the result can be opened in a spreadsheet, followed by a
+// row per pod whose finding is waived. Unprotected pods with an expired waiver have the status waiver-expired.
+func writeScanResultCSV(w io.Writer, document *ScanResultDocument) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write([]string{"policy_type", "namespace", "pod", "ip", "score", "status"}); err != nil {
+		return err
+	}
+
+	for _, entry := range document.Results {
+		expired := map[string]bool{}
+		for _, waived := range entry.Waived {
+			if waived.Rule == RuleUnprotectedPod && waived.Expired {
+				expired[waived.Namespace+"/"+waived.Name] = true
+			}
+		}
+		for _, pod := range entry.UnprotectedPods {
+			status := "unprotected"
+			if expired[pod.Namespace+"/"+pod.Name] {
+				status = "waiver-expired"
+			}
+			row := []string{entry.PolicyType, pod.Namespace, pod.Name, pod.IP, fmt.Sprint(entry.Score), status}
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+		}
+		for _, waived := range entry.Waived {
+			if waived.Rule != RuleUnprotectedPod || waived.Expired {
+				continue
+			}
+			row := []string{entry.PolicyType, waived.Namespace, waived.Name, "", fmt.Sprint(entry.Score), "waived"}
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,103 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePodDetail(t *testing.T) {
+	tests := []struct {
+		name     string
+		detail   string
+		expected PodRecord
+	}{
+		{
+			name:     "Namespaced scan detail",
+			detail:   "shop web-0 10.0.0.12",
+			expected: PodRecord{Namespace: "shop", Name: "web-0", IP: "10.0.0.12"},
+		},
+		{
+			name:     "Namespaced scan detail without IP",
+			detail:   "shop web-0 ",
+			expected: PodRecord{Namespace: "shop", Name: "web-0"},
+		},
+		{
+			name:     "Cluster wide scan detail",
+			detail:   "shop/web-0",
+			expected: PodRecord{Namespace: "shop", Name: "web-0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, ParsePodDetail(test.detail))
+		})
+	}
+}
+
+func TestWriteScanResultDocument(t *testing.T) {
+	document := NewScanResultDocument(&ScanResult{
+		PolicyType:        PolicyTypeKubernetes,
+		NamespacesScanned: []string{"shop"},
+		UnprotectedPods:   []string{"shop web-0 10.0.0.12"},
+		Score:             49,
+	})
+
+	var jsonOutput bytes.Buffer
+	if err := WriteScanResultDocument(&jsonOutput, document, OutputFormatJSON); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	var decoded ScanResultDocument
+	if err := json.Unmarshal(jsonOutput.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON: %v", err)
+	}
+	assert.Equal(t, ScanResultAPIVersion, decoded.APIVersion)
+	assert.Equal(t, []PodRecord{{Namespace: "shop", Name: "web-0", IP: "10.0.0.12"}}, decoded.Results[0].UnprotectedPods)
+
+	var csvOutput bytes.Buffer
+	if err := WriteScanResultDocument(&csvOutput, document, OutputFormatCSV); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	assert.Equal(t, "policy_type,namespace,pod,ip,score,status\nkubernetes,shop,web-0,10.0.0.12,49,unprotected\n", csvOutput.String())
+
+	assert.Error(t, WriteScanResultDocument(&bytes.Buffer{}, document, "xml"))
+}
+
+func TestWriteSARIF(t *testing.T) {
+	document := NewScanResultDocument(&ScanResult{
+		PolicyType:               PolicyTypeKubernetes,
+		NamespacesScanned:        []string{"shop", "payments"},
+		HasDenyAll:               []string{"payments"},
+		UnprotectedPods:          []string{"shop web-0 10.0.0.12"},
+		PoliciesSelectingNothing: []string{"payments/legacy"},
+		MeshEvaluations: []PodMeshEvaluation{
+			{Namespace: "payments", Name: "api-0", InMesh: true, MTLSMode: MTLSModeStrict, StrictMTLS: true, L4Covered: true, Findings: []string{FindingL4WithoutL7Authorization}},
+		},
+	})
+
+	var output bytes.Buffer
+	if err := WriteSARIF(&output, document); err != nil {
+		t.Fatalf("Failed to write SARIF: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(output.Bytes(), &log); err != nil {
+		t.Fatalf("Failed to decode SARIF: %v", err)
+	}
+	assert.Equal(t, "2.1.0", log.Version)
+
+	results := log.Runs[0].Results
+	if assert.Len(t, results, 4) {
+		assert.Equal(t, RuleUnprotectedPod, results[0].RuleID)
+		assert.Equal(t, "Pod/shop/web-0", results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+		assert.Equal(t, RuleNamespaceWithoutDenyAll, results[1].RuleID)
+		assert.Equal(t, "kubernetes/Namespace/shop", results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		assert.Equal(t, RulePolicySelectsNothing, results[2].RuleID)
+		assert.Equal(t, "NetworkPolicy/payments/legacy", results[2].Locations[0].LogicalLocations[0].FullyQualifiedName)
+		assert.Equal(t, RuleMissingL7Authorization, results[3].RuleID)
+		assert.Equal(t, "Pod/payments/api-0", results[3].Locations[0].LogicalLocations[0].FullyQualifiedName)
+	}
+}
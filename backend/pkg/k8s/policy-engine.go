@@ -0,0 +1,868 @@
+package k8s
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// Traffic directions evaluated by the policy engine
+const (
+	DirectionIngress = "ingress"
+	DirectionEgress  = "egress"
+)
+
+// Rule actions. Native NetworkPolicies only ever allow traffic.
+const (
+	ActionAllow = "Allow"
+	ActionDeny  = "Deny"
+	ActionPass  = "Pass"
+)
+
+// Peer kinds a rule can allow traffic from or to
+const (
+	PeerAny       = "any"
+	PeerPods      = "pods"
+	PeerCIDR      = "cidr"
+	PeerEntity    = "entity"
+	PeerFQDN      = "fqdn"
+	PeerService   = "service"
+	PeerNamespace = "namespaces"
+	PeerNodes     = "nodes"
+)
+
+// Findings reported for a pod after its policies have been evaluated
+const (
+	FindingUnprotected              = "unprotected"
+	FindingIngressOnlyIsolated      = "ingress-only isolated"
+	FindingEgressOnlyIsolated       = "egress-only isolated"
+	FindingIngressOpenToAll         = "ingress open to all sources"
+	FindingEgressOpenToAll          = "egress open to all destinations"
+	FindingIngressOpenToWorld       = "ingress open to 0.0.0.0/0"
+	FindingEgressOpenToWorld        = "egress open to 0.0.0.0/0"
+	FindingIngressFromAllNamespaces = "ingress open to all namespaces"
+	FindingEgressToAllNamespaces    = "egress open to all namespaces"
+)
+
+// Exposures summarizing a direction that is open to every peer, or to addresses outside the cluster
+const (
+	ExposureAll   = "all"
+	ExposureWorld = "world"
+)
+
+// podMatcher decides whether a pod, given the labels of its namespace, matches a selector.
+type podMatcher func(pod corev1.Pod, namespaceLabels map[string]string) bool
+
+// PolicyModel is a policy normalized for the engine: which pods it selects, which directions it
+// isolates and which rules it applies to them.
+type PolicyModel struct {
+	Engine          string       `json:"engine" yaml:"engine"`
+	Kind            string       `json:"kind" yaml:"kind"`
+	Namespace       string       `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name            string       `json:"name" yaml:"name"`
+	Selector        string       `json:"selector" yaml:"selector"`
+	Tier            string       `json:"tier,omitempty" yaml:"tier,omitempty"`
+	Priority        *float64     `json:"priority,omitempty" yaml:"priority,omitempty"`
+	IsolatesIngress bool         `json:"isolatesIngress" yaml:"isolatesIngress"`
+	IsolatesEgress  bool         `json:"isolatesEgress" yaml:"isolatesEgress"`
+	Rules           []PolicyRule `json:"rules" yaml:"rules"`
+	selects         podMatcher
+	ordering        *policyOrdering
+}
+
+// policyOrdering places a policy of an engine with ordered, first match semantics, such as Calico, in the
+// evaluation. Policies without ordering form the NetworkPolicy layer, where deny rules win over allow rules.
+// Ordered tiers are evaluated by tier priority before that layer, or after it for baseline tiers. Within a
+// tier, policies are evaluated by priority and their rules in order; the first Allow or Deny rule that matches
+// decides, and a Pass rule skips the rest of the tier.
+type policyOrdering struct {
+	tierPriority float64
+	baseline     bool
+	// defaultAction applies when policies of the tier isolate the pod but none of their rules match. Without a
+	// default action the isolation carries over to the NetworkPolicy layer.
+	defaultAction string
+	// passSkipsTiers makes a Pass rule skip every remaining tier instead of only the rest of its own.
+	passSkipsTiers bool
+}
+
+// PolicyRule is a single rule of a policy in one direction.
+type PolicyRule struct {
+	Policy    string     `json:"policy" yaml:"policy"`
+	Direction string     `json:"direction" yaml:"direction"`
+	Index     int        `json:"index" yaml:"index"`
+	Action    string     `json:"action" yaml:"action"`
+	Peers     []RulePeer `json:"peers" yaml:"peers"`
+	Ports     []RulePort `json:"ports" yaml:"ports"`
+}
+
+// RulePeer is one peer a rule applies to. Only the fields relevant to its kind are set.
+type RulePeer struct {
+	Kind              string   `json:"kind" yaml:"kind"`
+	Namespace         string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	NamespaceSelector string   `json:"namespaceSelector,omitempty" yaml:"namespaceSelector,omitempty"`
+	PodSelector       string   `json:"podSelector,omitempty" yaml:"podSelector,omitempty"`
+	CIDR              string   `json:"cidr,omitempty" yaml:"cidr,omitempty"`
+	Except            []string `json:"except,omitempty" yaml:"except,omitempty"`
+	Entity            string   `json:"entity,omitempty" yaml:"entity,omitempty"`
+	FQDN              string   `json:"fqdn,omitempty" yaml:"fqdn,omitempty"`
+	Service           string   `json:"service,omitempty" yaml:"service,omitempty"`
+	NodeSelector      string   `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
+	matches           podMatcher
+}
+
+// RulePort is a port or port range a rule applies to. An empty Port means every port of the protocol.
+type RulePort struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Port     string `json:"port,omitempty" yaml:"port,omitempty"`
+	EndPort  int32  `json:"endPort,omitempty" yaml:"endPort,omitempty"`
+}
+
+// PodPolicyEvaluation is the outcome of evaluating every policy that selects a pod.
+type PodPolicyEvaluation struct {
+	Namespace       string       `json:"namespace" yaml:"namespace"`
+	Name            string       `json:"name" yaml:"name"`
+	IP              string       `json:"ip,omitempty" yaml:"ip,omitempty"`
+	IngressIsolated bool         `json:"ingressIsolated" yaml:"ingressIsolated"`
+	EgressIsolated  bool         `json:"egressIsolated" yaml:"egressIsolated"`
+	IngressPolicies []string     `json:"ingressPolicies" yaml:"ingressPolicies"`
+	EgressPolicies  []string     `json:"egressPolicies" yaml:"egressPolicies"`
+	IngressRules    []PolicyRule `json:"ingressRules" yaml:"ingressRules"`
+	EgressRules     []PolicyRule `json:"egressRules" yaml:"egressRules"`
+	IngressExposure []string     `json:"ingressExposure" yaml:"ingressExposure"`
+	EgressExposure  []string     `json:"egressExposure" yaml:"egressExposure"`
+	Findings        []string     `json:"findings" yaml:"findings"`
+}
+
+// ID returns namespace/name for namespaced policies and name for cluster scoped ones.
+func (p PolicyModel) ID() string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return p.Namespace + "/" + p.Name
+}
+
+// Reference returns the object reference of the policy.
+func (p PolicyModel) Reference() ObjectReference {
+	return ObjectReference{Kind: p.Kind, Namespace: p.Namespace, Name: p.Name}
+}
+
+// Isolates reports whether the policy isolates the pods it selects in the given direction.
+func (p PolicyModel) Isolates(direction string) bool {
+	if direction == DirectionEgress {
+		return p.IsolatesEgress
+	}
+	return p.IsolatesIngress
+}
+
+// Selects reports whether the policy applies to the pod.
+func (p PolicyModel) Selects(pod corev1.Pod, namespaceLabels map[string]string) bool {
+	return p.selects != nil && p.selects(pod, namespaceLabels)
+}
+
+// RulesFor returns the rules of the policy for a single direction.
+func (p PolicyModel) RulesFor(direction string) []PolicyRule {
+	var rules []PolicyRule
+	for _, rule := range p.Rules {
+		if rule.Direction == direction {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// MatchesPod reports whether the peer covers the given pod.
+func (p RulePeer) MatchesPod(pod corev1.Pod, namespaceLabels map[string]string) bool {
+	if p.Kind == PeerAny {
+		return true
+	}
+	return p.matches != nil && p.matches(pod, namespaceLabels)
+}
+
+// MatchesIP reports whether the peer covers the given IP address, used for peers outside the cluster.
+func (p RulePeer) MatchesIP(ip string) bool {
+	switch p.Kind {
+	case PeerAny:
+		return true
+	case PeerEntity:
+		return ciliumWorldEntities[p.Entity]
+	case PeerCIDR:
+		return cidrContains(p.CIDR, p.Except, ip)
+	default:
+		return false
+	}
+}
+
+// Matches reports whether the port range covers the given port and protocol on the destination pod.
+// Named ports are resolved against the container ports of the destination.
+func (p RulePort) Matches(port int32, protocol string, destination *corev1.Pod) bool {
+	if p.Protocol != "" && !strings.EqualFold(p.Protocol, protocol) {
+		return false
+	}
+	if p.Port == "" {
+		return true
+	}
+
+	number, err := strconv.Atoi(p.Port)
+	if err != nil {
+		if destination == nil {
+			return false
+		}
+		for _, container := range destination.Spec.Containers {
+			for _, containerPort := range container.Ports {
+				if containerPort.Name == p.Port && containerPort.ContainerPort == port {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if p.EndPort > 0 {
+		return port >= int32(number) && port <= p.EndPort
+	}
+	return port == int32(number)
+}
+
+// String renders the port as port/protocol, port-endPort/protocol or any/protocol. An empty protocol renders as ANY.
+func (p RulePort) String() string {
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = "ANY"
+	}
+	switch {
+	case p.Port == "":
+		return "any/" + protocol
+	case p.EndPort > 0:
+		return fmt.Sprintf("%s-%d/%s", p.Port, p.EndPort, protocol)
+	default:
+		return p.Port + "/" + protocol
+	}
+}
+
+// String renders a short, human readable description of the peer.
+func (p RulePeer) String() string {
+	switch p.Kind {
+	case PeerAny:
+		return "any"
+	case PeerCIDR:
+		if len(p.Except) > 0 {
+			return fmt.Sprintf("%s except %s", p.CIDR, strings.Join(p.Except, ","))
+		}
+		return p.CIDR
+	case PeerEntity:
+		return "entity " + p.Entity
+	case PeerFQDN:
+		return "fqdn " + p.FQDN
+	case PeerService:
+		return "service " + p.Service
+	case PeerNamespace:
+		return "namespaces " + selectorDescription(p.NamespaceSelector)
+	case PeerNodes:
+		return "nodes " + selectorDescription(p.NodeSelector)
+	default:
+		pods := "pods " + selectorDescription(p.PodSelector)
+		if p.NamespaceSelector != "" {
+			return pods + " in namespaces " + selectorDescription(p.NamespaceSelector)
+		}
+		if p.Namespace != "" {
+			return pods + " in namespace " + p.Namespace
+		}
+		return pods
+	}
+}
+
+// String renders the rule as "<action> <direction> from/to <peers> on <ports>".
+func (r PolicyRule) String() string {
+	preposition := "from"
+	if r.Direction == DirectionEgress {
+		preposition = "to"
+	}
+
+	peers := []string{}
+	for _, peer := range r.Peers {
+		peers = append(peers, peer.String())
+	}
+	if len(peers) == 0 {
+		peers = append(peers, "any")
+	}
+
+	ports := []string{}
+	for _, port := range r.Ports {
+		ports = append(ports, port.String())
+	}
+	if len(ports) == 0 {
+		ports = append(ports, "any port")
+	}
+
+	return fmt.Sprintf("%s %s %s %s on %s", strings.ToLower(r.Action), r.Direction, preposition, strings.Join(peers, ", "), strings.Join(ports, ", "))
+}
+
+// selectorDescription renders an empty selector as "(all)".
+func selectorDescription(selector string) string {
+	if selector == "" {
+		return "(all)"
+	}
+	return selector
+}
+
+// NativePolicyModel converts a Kubernetes NetworkPolicy into the engine's policy model.
+func NativePolicyModel(policy networkingv1.NetworkPolicy) (PolicyModel, error) {
+	podSelector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+	if err != nil {
+		return PolicyModel{}, fmt.Errorf("error parsing pod selector of policy %s: %w", policy.Name, err)
+	}
+
+	model := PolicyModel{
+		Engine:    PolicyTypeKubernetes,
+		Kind:      "NetworkPolicy",
+		Namespace: policy.Namespace,
+		Name:      policy.Name,
+		Selector:  selectorDescription(podSelector.String()),
+		Rules:     []PolicyRule{},
+		selects: func(pod corev1.Pod, _ map[string]string) bool {
+			return pod.Namespace == policy.Namespace && podSelector.Matches(labels.Set(pod.Labels))
+		},
+	}
+
+	// Policies without policyTypes always isolate ingress, and isolate egress only when they have egress rules
+	if len(policy.Spec.PolicyTypes) == 0 {
+		model.IsolatesIngress = true
+		model.IsolatesEgress = len(policy.Spec.Egress) > 0
+	}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		switch policyType {
+		case networkingv1.PolicyTypeIngress:
+			model.IsolatesIngress = true
+		case networkingv1.PolicyTypeEgress:
+			model.IsolatesEgress = true
+		}
+	}
+
+	if model.IsolatesIngress {
+		for i, rule := range policy.Spec.Ingress {
+			peers, err := nativePeers(policy.Namespace, rule.From)
+			if err != nil {
+				return PolicyModel{}, fmt.Errorf("error parsing ingress rule %d of policy %s: %w", i, policy.Name, err)
+			}
+			model.Rules = append(model.Rules, PolicyRule{
+				Policy:    model.ID(),
+				Direction: DirectionIngress,
+				Index:     i,
+				Action:    ActionAllow,
+				Peers:     peers,
+				Ports:     nativePorts(rule.Ports),
+			})
+		}
+	}
+	if model.IsolatesEgress {
+		for i, rule := range policy.Spec.Egress {
+			peers, err := nativePeers(policy.Namespace, rule.To)
+			if err != nil {
+				return PolicyModel{}, fmt.Errorf("error parsing egress rule %d of policy %s: %w", i, policy.Name, err)
+			}
+			model.Rules = append(model.Rules, PolicyRule{
+				Policy:    model.ID(),
+				Direction: DirectionEgress,
+				Index:     i,
+				Action:    ActionAllow,
+				Peers:     peers,
+				Ports:     nativePorts(rule.Ports),
+			})
+		}
+	}
+
+	return model, nil
+}
+
+// nativePeers converts the from/to peers of a NetworkPolicy rule. No peers means every peer.
+func nativePeers(policyNamespace string, peers []networkingv1.NetworkPolicyPeer) ([]RulePeer, error) {
+	if len(peers) == 0 {
+		return []RulePeer{{Kind: PeerAny}}, nil
+	}
+
+	var rulePeers []RulePeer
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			rulePeers = append(rulePeers, cidrPeer(peer.IPBlock.CIDR, peer.IPBlock.Except))
+			continue
+		}
+
+		rulePeer := RulePeer{Kind: PeerPods, Namespace: policyNamespace}
+		var podSelector, namespaceSelector labels.Selector = labels.Everything(), nil
+		if peer.PodSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+			if err != nil {
+				return nil, err
+			}
+			podSelector = selector
+			rulePeer.PodSelector = selector.String()
+		}
+		if peer.NamespaceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+			if err != nil {
+				return nil, err
+			}
+			namespaceSelector = selector
+			rulePeer.Namespace = ""
+			rulePeer.NamespaceSelector = selectorDescription(selector.String())
+			if peer.PodSelector == nil {
+				rulePeer.Kind = PeerNamespace
+			}
+		}
+
+		rulePeer.matches = func(pod corev1.Pod, namespaceLabels map[string]string) bool {
+			if namespaceSelector == nil {
+				if pod.Namespace != policyNamespace {
+					return false
+				}
+			} else if !namespaceSelector.Matches(labels.Set(namespaceLabels)) {
+				return false
+			}
+			return podSelector.Matches(labels.Set(pod.Labels))
+		}
+		rulePeers = append(rulePeers, rulePeer)
+	}
+	return rulePeers, nil
+}
+
+// cidrPeer builds a peer for an IP block, matching pods by their IP address.
+func cidrPeer(cidr string, except []string) RulePeer {
+	return RulePeer{
+		Kind:   PeerCIDR,
+		CIDR:   cidr,
+		Except: except,
+		matches: func(pod corev1.Pod, _ map[string]string) bool {
+			return cidrContains(cidr, except, pod.Status.PodIP)
+		},
+	}
+}
+
+// cidrContains reports whether ip is inside cidr but outside every excepted block.
+func cidrContains(cidr string, except []string, ip string) bool {
+	address := net.ParseIP(ip)
+	if address == nil {
+		return false
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || !network.Contains(address) {
+		return false
+	}
+	for _, excluded := range except {
+		_, excludedNetwork, err := net.ParseCIDR(excluded)
+		if err == nil && excludedNetwork.Contains(address) {
+			return false
+		}
+	}
+	return true
+}
+
+// nativePorts converts the ports of a NetworkPolicy rule. No ports means every port.
+func nativePorts(ports []networkingv1.NetworkPolicyPort) []RulePort {
+	rulePorts := []RulePort{}
+	for _, port := range ports {
+		rulePort := RulePort{Protocol: string(corev1.ProtocolTCP)}
+		if port.Protocol != nil {
+			rulePort.Protocol = string(*port.Protocol)
+		}
+		if port.Port != nil {
+			rulePort.Port = port.Port.String()
+		}
+		if port.EndPort != nil {
+			rulePort.EndPort = *port.EndPort
+		}
+		rulePorts = append(rulePorts, rulePort)
+	}
+	return rulePorts
+}
+
+// NativePolicyModels converts a list of NetworkPolicies, skipping and reporting the ones that cannot be parsed.
+func NativePolicyModels(policies []networkingv1.NetworkPolicy) ([]PolicyModel, []error) {
+	var models []PolicyModel
+	var errs []error
+	for _, policy := range policies {
+		model, err := NativePolicyModel(policy)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		models = append(models, model)
+	}
+	return models, errs
+}
+
+// NamespaceLabels indexes namespace labels by namespace name.
+func NamespaceLabels(namespaces []corev1.Namespace) map[string]map[string]string {
+	namespaceLabels := make(map[string]map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		nsLabels := make(map[string]string, len(ns.Labels)+1)
+		for key, value := range ns.Labels {
+			nsLabels[key] = value
+		}
+		// The API server sets this label on every namespace; make sure it exists for older clusters too
+		nsLabels["kubernetes.io/metadata.name"] = ns.Name
+		namespaceLabels[ns.Name] = nsLabels
+	}
+	return namespaceLabels
+}
+
+// EvaluatePod computes the ingress and egress isolation of a pod and the rules that apply to it.
+func EvaluatePod(pod corev1.Pod, namespaceLabels map[string]string, policies []PolicyModel) PodPolicyEvaluation {
+	evaluation := PodPolicyEvaluation{
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		IP:              pod.Status.PodIP,
+		IngressPolicies: []string{},
+		EgressPolicies:  []string{},
+		IngressRules:    []PolicyRule{},
+		EgressRules:     []PolicyRule{},
+	}
+
+	selecting := []PolicyModel{}
+	for _, policy := range policies {
+		if !policy.Selects(pod, namespaceLabels) {
+			continue
+		}
+		selecting = append(selecting, policy)
+		if policy.IsolatesIngress {
+			evaluation.IngressIsolated = true
+			evaluation.IngressPolicies = append(evaluation.IngressPolicies, policy.ID())
+			evaluation.IngressRules = append(evaluation.IngressRules, policy.RulesFor(DirectionIngress)...)
+		}
+		if policy.IsolatesEgress {
+			evaluation.EgressIsolated = true
+			evaluation.EgressPolicies = append(evaluation.EgressPolicies, policy.ID())
+			evaluation.EgressRules = append(evaluation.EgressRules, policy.RulesFor(DirectionEgress)...)
+		}
+	}
+
+	ingressAllows, ingressOpen := effectiveAllowRules(DirectionIngress, selecting)
+	egressAllows, egressOpen := effectiveAllowRules(DirectionEgress, selecting)
+	evaluation.IngressExposure = exposure(ingressOpen, ingressAllows)
+	evaluation.EgressExposure = exposure(egressOpen, egressAllows)
+	evaluation.Findings = podFindings(evaluation, ingressAllows, egressAllows)
+	return evaluation
+}
+
+// effectiveAllowRules walks the policies selecting a pod in evaluation order, ordered tiers, the NetworkPolicy
+// layer and baseline tiers, and returns the allow rules of every layer traffic can reach in one direction.
+// A layer isolating the pod, or denying every peer, stops the walk, except for the traffic of its Pass rules,
+// which continues to the next layers and counts as allowed if no later layer isolates the pod. open reports that
+// traffic matching no rule is allowed because no layer isolates the pod.
+func effectiveAllowRules(direction string, policies []PolicyModel) ([]PolicyRule, bool) {
+	var tiers, networkPolicies, baselineTiers []PolicyModel
+	for _, policy := range policies {
+		switch {
+		case policy.ordering == nil:
+			networkPolicies = append(networkPolicies, policy)
+		case policy.ordering.baseline:
+			baselineTiers = append(baselineTiers, policy)
+		default:
+			tiers = append(tiers, policy)
+		}
+	}
+
+	allows := []PolicyRule{}
+	var passing []PolicyRule
+	restricted, layerIsolated := false, false
+	// walk evaluates one layer and reports whether traffic continues past it
+	walk := func(layer []PolicyModel, isolates bool) bool {
+		var passes []PolicyRule
+		for _, policy := range layer {
+			for _, rule := range policy.RulesFor(direction) {
+				switch rule.Action {
+				case ActionAllow:
+					allows = append(allows, rule)
+				case ActionPass:
+					passes = append(passes, rule)
+				}
+			}
+		}
+		if !isolates {
+			return true
+		}
+		restricted, passing = true, passes
+		return len(passes) > 0
+	}
+
+	layers := append(groupTiers(tiers), networkPolicies)
+	baselineStart := len(layers)
+	layers = append(layers, groupTiers(baselineTiers)...)
+	for i, layer := range layers {
+		isolates := false
+		for _, policy := range layer {
+			if !policy.Isolates(direction) {
+				continue
+			}
+			switch {
+			case deniesEveryPeer(policy.RulesFor(direction)):
+				// Traffic no earlier rule allowed ends at a rule denying everything
+				isolates = true
+			case policy.ordering == nil:
+				isolates = true
+			case policy.ordering.defaultAction == ActionDeny:
+				isolates = true
+			case policy.ordering.defaultAction == "":
+				// Isolation without a default action carries over to the NetworkPolicy layer
+				layerIsolated = true
+			}
+		}
+		if i == baselineStart-1 {
+			isolates = isolates || layerIsolated
+		}
+		if !walk(layer, isolates) {
+			return allows, false
+		}
+	}
+
+	if !restricted {
+		return allows, true
+	}
+	for _, rule := range passing {
+		rule.Action = ActionAllow
+		allows = append(allows, rule)
+	}
+	return allows, false
+}
+
+// deniesEveryPeer reports whether one of the rules denies traffic with every peer on every port.
+func deniesEveryPeer(rules []PolicyRule) bool {
+	for _, rule := range rules {
+		if rule.Action != ActionDeny || len(rule.Ports) > 0 {
+			continue
+		}
+		for _, peer := range rule.Peers {
+			if peer.Kind == PeerAny {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupTiers sorts policies of ordered engines and splits them into their tiers.
+func groupTiers(policies []PolicyModel) [][]PolicyModel {
+	sortOrderedPolicies(policies)
+
+	var tiers [][]PolicyModel
+	for start := 0; start < len(policies); {
+		end := start
+		for end < len(policies) && policies[end].Tier == policies[start].Tier && policies[end].ordering.tierPriority == policies[start].ordering.tierPriority {
+			end++
+		}
+		tiers = append(tiers, policies[start:end])
+		start = end
+	}
+	return tiers
+}
+
+// exposure lists the peers, with their ports, that the allow rules of a direction open the pod to. An open
+// direction is exposed to all peers, and an isolated direction without allow rules to none.
+// Deny rules are not subtracted, so the exposure is an upper bound.
+func exposure(open bool, rules []PolicyRule) []string {
+	if open {
+		return []string{ExposureAll}
+	}
+
+	exposures := []string{}
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Action != ActionAllow {
+			continue
+		}
+		ports := []string{}
+		for _, port := range rule.Ports {
+			ports = append(ports, port.String())
+		}
+		for _, peer := range rule.Peers {
+			entry := peerExposure(peer)
+			if entry == ExposureAll && len(ports) == 0 {
+				return []string{ExposureAll}
+			}
+			if len(ports) > 0 {
+				entry += " on " + strings.Join(ports, ", ")
+			}
+			if !seen[entry] {
+				seen[entry] = true
+				exposures = append(exposures, entry)
+			}
+		}
+	}
+	return exposures
+}
+
+// peerExposure names what a peer exposes the pod to, collapsing every peer covering the internet to "world".
+func peerExposure(peer RulePeer) string {
+	switch {
+	case peer.Kind == PeerAny, peer.Kind == PeerEntity && peer.Entity == "all":
+		return ExposureAll
+	case peer.Kind == PeerEntity && ciliumWorldEntities[peer.Entity]:
+		return ExposureWorld
+	case peer.Kind == PeerCIDR && isWorldCIDR(peer.CIDR) && len(peer.Except) == 0:
+		return ExposureWorld
+	default:
+		return peer.String()
+	}
+}
+
+// EvaluatePods evaluates every pod against the given policies.
+func EvaluatePods(pods []corev1.Pod, namespaces []corev1.Namespace, policies []PolicyModel) []PodPolicyEvaluation {
+	namespaceLabels := NamespaceLabels(namespaces)
+	evaluations := make([]PodPolicyEvaluation, 0, len(pods))
+	for _, pod := range pods {
+		evaluations = append(evaluations, EvaluatePod(pod, namespaceLabels[pod.Namespace], policies))
+	}
+	return evaluations
+}
+
+// podFindings summarizes the isolation state of an evaluated pod and the overly broad rules among the rules
+// effectively allowing its traffic.
+func podFindings(evaluation PodPolicyEvaluation, ingressAllows []PolicyRule, egressAllows []PolicyRule) []string {
+	findings := []string{}
+	switch {
+	case !evaluation.IngressIsolated && !evaluation.EgressIsolated:
+		return append(findings, FindingUnprotected)
+	case evaluation.IngressIsolated && !evaluation.EgressIsolated:
+		findings = append(findings, FindingIngressOnlyIsolated)
+	case !evaluation.IngressIsolated && evaluation.EgressIsolated:
+		findings = append(findings, FindingEgressOnlyIsolated)
+	}
+
+	findings = append(findings, broadRuleFindings(ingressAllows, FindingIngressOpenToAll, FindingIngressOpenToWorld, FindingIngressFromAllNamespaces)...)
+	findings = append(findings, broadRuleFindings(egressAllows, FindingEgressOpenToAll, FindingEgressOpenToWorld, FindingEgressToAllNamespaces)...)
+	return findings
+}
+
+// broadRuleFindings flags allow rules that open a direction to any peer, the whole internet or every namespace.
+func broadRuleFindings(rules []PolicyRule, openToAll string, openToWorld string, allNamespaces string) []string {
+	found := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Action != ActionAllow {
+			continue
+		}
+		for _, peer := range rule.Peers {
+			switch {
+			case peer.Kind == PeerAny, peer.Kind == PeerEntity && peer.Entity == "all":
+				found[openToAll] = true
+			case peer.Kind == PeerCIDR && isWorldCIDR(peer.CIDR), peer.Kind == PeerEntity && ciliumWorldEntities[peer.Entity]:
+				found[openToWorld] = true
+			case peer.Kind == PeerNamespace && peer.NamespaceSelector == selectorDescription(""), peer.Kind == PeerEntity && ciliumClusterEntities[peer.Entity]:
+				found[allNamespaces] = true
+			}
+		}
+	}
+
+	var findings []string
+	for _, finding := range []string{openToAll, openToWorld, allNamespaces} {
+		if found[finding] {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// isWorldCIDR reports whether the CIDR covers every IPv4 or IPv6 address.
+func isWorldCIDR(cidr string) bool {
+	return cidr == "0.0.0.0/0" || cidr == "::/0"
+}
+
+// HasFinding reports whether an evaluation carries the given finding.
+func (e PodPolicyEvaluation) HasFinding(finding string) bool {
+	return contains(e.Findings, finding)
+}
+
+// sortOrderedPolicies orders policies of ordered engines by tier and by their priority within the tier.
+// Policies without a priority come last in their tier, and ties are broken by name.
+func sortOrderedPolicies(policies []PolicyModel) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		a, b := policies[i], policies[j]
+		if a.ordering.tierPriority != b.ordering.tierPriority {
+			return a.ordering.tierPriority < b.ordering.tierPriority
+		}
+		if a.Tier != b.Tier {
+			return a.Tier < b.Tier
+		}
+		if (a.Priority == nil) != (b.Priority == nil) {
+			return a.Priority != nil
+		}
+		if a.Priority != nil && *a.Priority != *b.Priority {
+			return *a.Priority < *b.Priority
+		}
+		return a.ID() < b.ID()
+	})
+}
+
+// sortEvaluations orders evaluations by namespace and pod name for stable output.
+func sortEvaluations(evaluations []PodPolicyEvaluation) {
+	sort.Slice(evaluations, func(i, j int) bool {
+		if evaluations[i].Namespace != evaluations[j].Namespace {
+			return evaluations[i].Namespace < evaluations[j].Namespace
+		}
+		return evaluations[i].Name < evaluations[j].Name
+	})
+}
+
+// displayPodExposure prints what every pod of a namespace selected by a policy is exposed to, with its findings.
+// Pods without any policy are already listed as unprotected and are left out.
+func displayPodExposure(nsName string, evaluations []PodPolicyEvaluation, writer *bufio.Writer) {
+	rows := [][]string{}
+	for _, evaluation := range evaluations {
+		if evaluation.HasFinding(FindingUnprotected) {
+			continue
+		}
+		rows = append(rows, []string{
+			evaluation.Name,
+			exposureDescription(evaluation.IngressExposure),
+			exposureDescription(evaluation.EgressExposure),
+			strings.Join(evaluation.Findings, "\n"),
+		})
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	headerText := fmt.Sprintf("Pod exposure in namespace %s:", nsName)
+	printToBoth(writer, HeaderStyle.Render(headerText)+"\n")
+	printToBoth(writer, createFindingsTable(rows)+"\n")
+}
+
+// exposureDescription renders the exposure of a direction for the exposure table.
+func exposureDescription(exposures []string) string {
+	if len(exposures) == 0 {
+		return "none"
+	}
+	return strings.Join(exposures, "\n")
+}
+
+func createFindingsTable(findingsInfo [][]string) string {
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("99"))).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == 0:
+				return HeaderStyle
+			case row%2 == 0:
+				return EvenRowStyle
+			default:
+				return OddRowStyle
+			}
+		}).
+		Headers("Pod Name", "Ingress Exposure", "Egress Exposure", "Findings")
+
+	for _, row := range findingsInfo {
+		t.Row(row...)
+	}
+
+	return t.String()
+}
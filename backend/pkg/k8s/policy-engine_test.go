@@ -0,0 +1,182 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testPod(namespace, name, ip string, podLabels map[string]string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:  "app",
+			Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+	}
+}
+
+func TestNativePolicyModel(t *testing.T) {
+	udp := corev1.ProtocolUDP
+	endPort := int32(5440)
+	port := intstr.FromInt(5432)
+
+	policy := netv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"},
+		Spec: netv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Ingress: []netv1.NetworkPolicyIngressRule{{
+				From: []netv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+					{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}}},
+				},
+				Ports: []netv1.NetworkPolicyPort{{Port: &port, EndPort: &endPort}},
+			}},
+			Egress: []netv1.NetworkPolicyEgressRule{{
+				To:    []netv1.NetworkPolicyPeer{{IPBlock: &netv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}}},
+				Ports: []netv1.NetworkPolicyPort{{Protocol: &udp}},
+			}},
+		},
+	}
+
+	model, err := NativePolicyModel(policy)
+	assert.NoError(t, err)
+	assert.Equal(t, "shop/db", model.ID())
+	assert.True(t, model.IsolatesIngress)
+	assert.True(t, model.IsolatesEgress, "policies with egress rules and no policyTypes isolate egress")
+	assert.Len(t, model.Rules, 2)
+
+	nsLabels := map[string]map[string]string{"shop": {"team": "dev"}, "ops": {"team": "ops"}}
+	db := testPod("shop", "db-0", "10.0.0.5", map[string]string{"app": "db"})
+	web := testPod("shop", "web-0", "10.0.0.6", map[string]string{"app": "web"})
+	otherWeb := testPod("other", "web-0", "10.0.0.7", map[string]string{"app": "web"})
+	monitor := testPod("ops", "monitor-0", "10.0.0.8", map[string]string{"app": "monitor"})
+
+	assert.True(t, model.Selects(db, nsLabels["shop"]))
+	assert.False(t, model.Selects(web, nsLabels["shop"]))
+
+	ingress := model.RulesFor(DirectionIngress)[0]
+	assert.True(t, ingress.Peers[0].MatchesPod(web, nsLabels["shop"]))
+	assert.False(t, ingress.Peers[0].MatchesPod(otherWeb, nsLabels["other"]), "pod selectors without namespace selector only match the policy namespace")
+	assert.True(t, ingress.Peers[1].MatchesPod(monitor, nsLabels["ops"]))
+	assert.True(t, ingress.Ports[0].Matches(5435, "TCP", &db))
+	assert.False(t, ingress.Ports[0].Matches(5441, "TCP", &db))
+	assert.False(t, ingress.Ports[0].Matches(5432, "UDP", &db))
+	assert.Equal(t, "allow ingress from pods app=web in namespace shop, namespaces team=ops on 5432-5440/TCP", ingress.String())
+
+	egress := model.RulesFor(DirectionEgress)[0]
+	assert.True(t, egress.Peers[0].MatchesIP("10.2.3.4"))
+	assert.False(t, egress.Peers[0].MatchesIP("10.1.3.4"))
+	assert.True(t, egress.Ports[0].Matches(53, "UDP", nil))
+}
+
+func TestNativePolicyModelPolicyTypes(t *testing.T) {
+	tests := []struct {
+		name            string
+		spec            netv1.NetworkPolicySpec
+		isolatesIngress bool
+		isolatesEgress  bool
+		rules           int
+	}{
+		{
+			name:            "no policy types and no rules isolates ingress",
+			spec:            netv1.NetworkPolicySpec{},
+			isolatesIngress: true,
+		},
+		{
+			name:           "egress policy type only",
+			spec:           netv1.NetworkPolicySpec{PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeEgress}},
+			isolatesEgress: true,
+		},
+		{
+			name: "egress rules are ignored without the egress policy type",
+			spec: netv1.NetworkPolicySpec{
+				PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeIngress},
+				Egress:      []netv1.NetworkPolicyEgressRule{{}},
+			},
+			isolatesIngress: true,
+		},
+		{
+			name: "both policy types",
+			spec: netv1.NetworkPolicySpec{
+				PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeIngress, netv1.PolicyTypeEgress},
+				Ingress:     []netv1.NetworkPolicyIngressRule{{}},
+				Egress:      []netv1.NetworkPolicyEgressRule{{}},
+			},
+			isolatesIngress: true,
+			isolatesEgress:  true,
+			rules:           2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := NativePolicyModel(netv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "p"}, Spec: tt.spec})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.isolatesIngress, model.IsolatesIngress)
+			assert.Equal(t, tt.isolatesEgress, model.IsolatesEgress)
+			assert.Len(t, model.Rules, tt.rules)
+		})
+	}
+}
+
+func TestEvaluatePods(t *testing.T) {
+	port := intstr.FromString("http")
+	policies := []netv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-ingress"},
+			Spec: netv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Ingress:     []netv1.NetworkPolicyIngressRule{{Ports: []netv1.NetworkPolicyPort{{Port: &port}}}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "worker-egress"},
+			Spec: netv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "worker"}},
+				PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeEgress},
+				Egress: []netv1.NetworkPolicyEgressRule{{
+					To: []netv1.NetworkPolicyPeer{{IPBlock: &netv1.IPBlock{CIDR: "0.0.0.0/0"}}},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"},
+			Spec: netv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+				PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeIngress, netv1.PolicyTypeEgress},
+				Ingress: []netv1.NetworkPolicyIngressRule{{
+					From: []netv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+				}},
+			},
+		},
+	}
+	models, errs := NativePolicyModels(policies)
+	assert.Empty(t, errs)
+
+	pods := []corev1.Pod{
+		testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"}),
+		testPod("shop", "worker-0", "10.0.0.2", map[string]string{"app": "worker"}),
+		testPod("shop", "db-0", "10.0.0.3", map[string]string{"app": "db"}),
+		testPod("shop", "cache-0", "10.0.0.4", map[string]string{"app": "cache"}),
+	}
+	namespaces := []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}}
+
+	evaluations := EvaluatePods(pods, namespaces, models)
+	assert.Len(t, evaluations, 4)
+
+	assert.Equal(t, []string{FindingIngressOnlyIsolated, FindingIngressOpenToAll}, evaluations[0].Findings)
+	assert.Equal(t, []string{"shop/web-ingress"}, evaluations[0].IngressPolicies)
+	assert.True(t, evaluations[0].IngressRules[0].Ports[0].Matches(8080, "TCP", &pods[0]), "named ports resolve against the container ports")
+
+	assert.Equal(t, []string{FindingEgressOnlyIsolated, FindingEgressOpenToWorld}, evaluations[1].Findings)
+	assert.Equal(t, []string{FindingIngressFromAllNamespaces}, evaluations[2].Findings)
+	assert.True(t, evaluations[2].IngressIsolated && evaluations[2].EgressIsolated)
+	assert.Empty(t, evaluations[2].EgressRules)
+	assert.Equal(t, []string{FindingUnprotected}, evaluations[3].Findings)
+}
@@ -0,0 +1,240 @@
+package k8s
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Verdicts of a namespace pair in the reachability matrix
+const (
+	MatrixVerdictAllow   = "allow"
+	MatrixVerdictPartial = "partial"
+	MatrixVerdictDeny    = "deny"
+)
+
+// ReachabilityMatrix is the allow/deny matrix between the namespaces and workloads in scope.
+type ReachabilityMatrix struct {
+	APIVersion      string                  `json:"apiVersion" yaml:"apiVersion"`
+	Kind            string                  `json:"kind" yaml:"kind"`
+	GeneratedAt     string                  `json:"generatedAt" yaml:"generatedAt"`
+	Namespace       string                  `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Port            int32                   `json:"port,omitempty" yaml:"port,omitempty"`
+	Protocol        string                  `json:"protocol" yaml:"protocol"`
+	Namespaces      []string                `json:"namespaces" yaml:"namespaces"`
+	Workloads       []WorkloadRef           `json:"workloads" yaml:"workloads"`
+	NamespaceMatrix []NamespaceReachability `json:"namespaceMatrix" yaml:"namespaceMatrix"`
+	WorkloadMatrix  []WorkloadReachability  `json:"workloadMatrix" yaml:"workloadMatrix"`
+}
+
+// WorkloadRef identifies the controller owning a set of pods, or a bare pod.
+type WorkloadRef struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Kind      string `json:"kind" yaml:"kind"`
+	Name      string `json:"name" yaml:"name"`
+	Pods      int    `json:"pods" yaml:"pods"`
+}
+
+// NamespaceReachability summarizes the workload pairs between two namespaces.
+type NamespaceReachability struct {
+	Source       string `json:"source" yaml:"source"`
+	Destination  string `json:"destination" yaml:"destination"`
+	Verdict      string `json:"verdict" yaml:"verdict"`
+	AllowedPairs int    `json:"allowedPairs" yaml:"allowedPairs"`
+	TotalPairs   int    `json:"totalPairs" yaml:"totalPairs"`
+}
+
+// WorkloadReachability is the verdict for connections from one workload to another.
+type WorkloadReachability struct {
+	Source      string `json:"source" yaml:"source"`
+	Destination string `json:"destination" yaml:"destination"`
+	Allowed     bool   `json:"allowed" yaml:"allowed"`
+	Egress      string `json:"egress" yaml:"egress"`
+	Ingress     string `json:"ingress" yaml:"ingress"`
+}
+
+// String returns namespace/Kind/name.
+func (w WorkloadRef) String() string {
+	return w.Namespace + "/" + w.Kind + "/" + w.Name
+}
+
+// podWorkload returns the workload owning a pod. Pods created by a Deployment are attributed to the
+// Deployment rather than to its ReplicaSet.
+func podWorkload(pod corev1.Pod) WorkloadRef {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if owner.Kind == "ReplicaSet" {
+			if hash := pod.Labels["pod-template-hash"]; hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+				return WorkloadRef{Namespace: pod.Namespace, Kind: "Deployment", Name: strings.TrimSuffix(owner.Name, "-"+hash)}
+			}
+		}
+		return WorkloadRef{Namespace: pod.Namespace, Kind: owner.Kind, Name: owner.Name}
+	}
+	return WorkloadRef{Namespace: pod.Namespace, Kind: "Pod", Name: pod.Name}
+}
+
+// BuildReachabilityMatrix evaluates every pair of workloads in scope. With a namespace, only pairs with
+// a source or destination in that namespace are evaluated. Namespaces the configuration excludes are left out
+// unless requested, and so are the pods it excludes.
+// Each workload is represented by its first pod, and a port of 0 asks whether any port is reachable.
+func (s *ClusterState) BuildReachabilityMatrix(namespace string, port int32, protocol string) *ReachabilityMatrix {
+	matrix := &ReachabilityMatrix{
+		APIVersion:      ScanResultAPIVersion,
+		Kind:            "ReachabilityMatrix",
+		GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
+		Namespace:       namespace,
+		Port:            port,
+		Protocol:        protocol,
+		Namespaces:      []string{},
+		Workloads:       []WorkloadRef{},
+		NamespaceMatrix: []NamespaceReachability{},
+		WorkloadMatrix:  []WorkloadReachability{},
+	}
+
+	// Group pods by workload, keeping the first pod as representative
+	representatives := make(map[string]corev1.Pod)
+	workloads := make(map[string]*WorkloadRef)
+	for _, pod := range s.Pods {
+		if pod.Namespace != namespace && !activeConfig.IncludesNamespace(pod.Namespace, s.namespaceLabels[pod.Namespace]) {
+			continue
+		}
+		if activeConfig.ExcludesPod(pod) {
+			continue
+		}
+		workload := podWorkload(pod)
+		key := workload.String()
+		if existing, found := workloads[key]; found {
+			existing.Pods++
+			continue
+		}
+		workload.Pods = 1
+		workloads[key] = &workload
+		representatives[key] = pod
+	}
+
+	keys := make([]string, 0, len(workloads))
+	for key := range workloads {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	namespaceSet := make(map[string]bool)
+	for _, key := range keys {
+		matrix.Workloads = append(matrix.Workloads, *workloads[key])
+		namespaceSet[workloads[key].Namespace] = true
+	}
+	for ns := range namespaceSet {
+		matrix.Namespaces = append(matrix.Namespaces, ns)
+	}
+	sort.Strings(matrix.Namespaces)
+
+	namespacePairs := make(map[[2]string]*NamespaceReachability)
+	for _, sourceKey := range keys {
+		for _, destinationKey := range keys {
+			source, destination := workloads[sourceKey], workloads[destinationKey]
+			if namespace != "" && source.Namespace != namespace && destination.Namespace != namespace {
+				continue
+			}
+
+			verdict := s.CanReach(representatives[sourceKey], representatives[destinationKey], port, protocol)
+			matrix.WorkloadMatrix = append(matrix.WorkloadMatrix, WorkloadReachability{
+				Source:      sourceKey,
+				Destination: destinationKey,
+				Allowed:     verdict.Allowed,
+				Egress:      verdict.Egress.Reason,
+				Ingress:     verdict.Ingress.Reason,
+			})
+
+			pair := [2]string{source.Namespace, destination.Namespace}
+			cell, found := namespacePairs[pair]
+			if !found {
+				cell = &NamespaceReachability{Source: source.Namespace, Destination: destination.Namespace}
+				namespacePairs[pair] = cell
+			}
+			cell.TotalPairs++
+			if verdict.Allowed {
+				cell.AllowedPairs++
+			}
+		}
+	}
+
+	for _, sourceNamespace := range matrix.Namespaces {
+		for _, destinationNamespace := range matrix.Namespaces {
+			cell, found := namespacePairs[[2]string{sourceNamespace, destinationNamespace}]
+			if !found {
+				continue
+			}
+			switch cell.AllowedPairs {
+			case 0:
+				cell.Verdict = MatrixVerdictDeny
+			case cell.TotalPairs:
+				cell.Verdict = MatrixVerdictAllow
+			default:
+				cell.Verdict = MatrixVerdictPartial
+			}
+			matrix.NamespaceMatrix = append(matrix.NamespaceMatrix, *cell)
+		}
+	}
+
+	return matrix
+}
+
+// NamespaceVerdict returns the verdict for traffic from one namespace to another, or an empty string if the pair is out of scope.
+func (m *ReachabilityMatrix) NamespaceVerdict(source, destination string) string {
+	for _, cell := range m.NamespaceMatrix {
+		if cell.Source == source && cell.Destination == destination {
+			return cell.Verdict
+		}
+	}
+	return ""
+}
+
+// WriteReachabilityMatrix serializes the matrix to w as JSON or CSV.
+func WriteReachabilityMatrix(w io.Writer, matrix *ReachabilityMatrix, format string) error {
+	switch format {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(matrix)
+	case OutputFormatCSV:
+		return writeReachabilityMatrixCSV(w, matrix)
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of: %s, %s", format, OutputFormatJSON, OutputFormatCSV)
+	}
+}
+
+// writeReachabilityMatrixCSV writes one row per namespace pair followed by one row per workload pair.
+func writeReachabilityMatrixCSV(w io.Writer, matrix *ReachabilityMatrix) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write([]string{"level", "source", "destination", "verdict", "allowed_pairs", "total_pairs"}); err != nil {
+		return err
+	}
+
+	for _, cell := range matrix.NamespaceMatrix {
+		row := []string{"namespace", cell.Source, cell.Destination, cell.Verdict, fmt.Sprint(cell.AllowedPairs), fmt.Sprint(cell.TotalPairs)}
+		if err := csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, cell := range matrix.WorkloadMatrix {
+		verdict, allowed := MatrixVerdictDeny, "0"
+		if cell.Allowed {
+			verdict, allowed = MatrixVerdictAllow, "1"
+		}
+		row := []string{"workload", cell.Source, cell.Destination, verdict, allowed, "1"}
+		if err := csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
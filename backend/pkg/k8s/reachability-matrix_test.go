@@ -0,0 +1,72 @@
+package k8s
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodWorkload(t *testing.T) {
+	controller := true
+	deploymentPod := testPod("shop", "web-5d8f9-abcde", "", map[string]string{"pod-template-hash": "5d8f9"})
+	deploymentPod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d8f9", Controller: &controller}}
+	statefulSetPod := testPod("shop", "db-0", "", nil)
+	statefulSetPod.OwnerReferences = []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db", Controller: &controller}}
+	barePod := testPod("shop", "debug", "", nil)
+
+	assert.Equal(t, "shop/Deployment/web", podWorkload(deploymentPod).String())
+	assert.Equal(t, "shop/StatefulSet/db", podWorkload(statefulSetPod).String())
+	assert.Equal(t, "shop/Pod/debug", podWorkload(barePod).String())
+}
+
+func TestBuildReachabilityMatrix(t *testing.T) {
+	// Tenant a denies all ingress, tenant b allows ingress from its own namespace only
+	policies, errs := NativePolicyModels([]netv1.NetworkPolicy{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "deny-all"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-b", Name: "same-namespace"},
+			Spec: netv1.NetworkPolicySpec{
+				Ingress: []netv1.NetworkPolicyIngressRule{{From: []netv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}}},
+			},
+		},
+	})
+	assert.Empty(t, errs)
+
+	pods := []corev1.Pod{
+		testPod("tenant-a", "api", "10.0.0.1", nil),
+		testPod("tenant-b", "api", "10.0.0.2", nil),
+		testPod("tenant-b", "worker", "10.0.0.3", nil),
+		testPod("kube-system", "coredns", "10.0.0.4", nil),
+	}
+	namespaces := []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	}
+	state := NewClusterState(pods, namespaces, policies)
+
+	matrix := state.BuildReachabilityMatrix("", 0, "")
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, matrix.Namespaces, "system namespaces are excluded unless requested")
+	assert.Len(t, matrix.WorkloadMatrix, 9)
+	assert.Equal(t, MatrixVerdictDeny, matrix.NamespaceVerdict("tenant-b", "tenant-a"))
+	assert.Equal(t, MatrixVerdictDeny, matrix.NamespaceVerdict("tenant-a", "tenant-a"))
+	assert.Equal(t, MatrixVerdictDeny, matrix.NamespaceVerdict("tenant-a", "tenant-b"))
+	assert.Equal(t, MatrixVerdictAllow, matrix.NamespaceVerdict("tenant-b", "tenant-b"))
+
+	scoped := state.BuildReachabilityMatrix("tenant-a", 0, "")
+	assert.Len(t, scoped.WorkloadMatrix, 5, "only pairs with a source or destination in the namespace are evaluated")
+
+	var buffer bytes.Buffer
+	assert.NoError(t, WriteReachabilityMatrix(&buffer, matrix, OutputFormatCSV))
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Equal(t, "level,source,destination,verdict,allowed_pairs,total_pairs", lines[0])
+	assert.Contains(t, lines, "namespace,tenant-b,tenant-b,allow,4,4")
+	assert.Len(t, lines, 1+4+9)
+
+	assert.Error(t, WriteReachabilityMatrix(&buffer, matrix, OutputFormatYAML))
+}
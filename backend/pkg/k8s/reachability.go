@@ -0,0 +1,367 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Group version resources of the Cilium policies evaluated by the reachability engine
+var (
+	ciliumNetworkPolicyResource            = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumnetworkpolicies"}
+	ciliumClusterwideNetworkPolicyResource = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumclusterwidenetworkpolicies"}
+)
+
+// ClusterState is an in-memory snapshot of the pods, namespaces and policies the reachability engine evaluates.
+type ClusterState struct {
+	Pods            []corev1.Pod
+	Namespaces      []corev1.Namespace
+	Policies        []PolicyModel
+	Warnings        []string
+	namespaceLabels map[string]map[string]string
+}
+
+// DirectionVerdict explains whether one side of a connection allows it.
+type DirectionVerdict struct {
+	Direction string            `json:"direction" yaml:"direction"`
+	Pod       string            `json:"pod" yaml:"pod"`
+	Isolated  bool              `json:"isolated" yaml:"isolated"`
+	Allowed   bool              `json:"allowed" yaml:"allowed"`
+	Policies  []ObjectReference `json:"policies" yaml:"policies"`
+	AllowedBy []PolicyRule      `json:"allowedBy" yaml:"allowedBy"`
+	DeniedBy  []PolicyRule      `json:"deniedBy" yaml:"deniedBy"`
+	Reason    string            `json:"reason" yaml:"reason"`
+}
+
+// ReachabilityVerdict is the answer to whether a source pod can open a connection to a destination pod.
+// A connection is allowed when the egress of the source and the ingress of the destination both allow it.
+type ReachabilityVerdict struct {
+	Source      string           `json:"source" yaml:"source"`
+	Destination string           `json:"destination" yaml:"destination"`
+	Port        int32            `json:"port,omitempty" yaml:"port,omitempty"`
+	Protocol    string           `json:"protocol" yaml:"protocol"`
+	Allowed     bool             `json:"allowed" yaml:"allowed"`
+	Egress      DirectionVerdict `json:"egress" yaml:"egress"`
+	Ingress     DirectionVerdict `json:"ingress" yaml:"ingress"`
+}
+
+// NewClusterState builds a cluster state from already converted policies.
+func NewClusterState(pods []corev1.Pod, namespaces []corev1.Namespace, policies []PolicyModel) *ClusterState {
+	return &ClusterState{
+		Pods:            pods,
+		Namespaces:      namespaces,
+		Policies:        policies,
+		namespaceLabels: NamespaceLabels(namespaces),
+	}
+}
+
+// LoadClusterState reads the pods, namespaces, NetworkPolicies and, when installed, the admin network policies and
+// the Cilium, Calico and Antrea policies of the cluster.
+// Policies that cannot be evaluated are skipped and reported in Warnings.
+func LoadClusterState(clientset kubernetes.Interface, dynamicClient dynamic.Interface) (*ClusterState, error) {
+	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+	networkPolicies, err := clientset.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing network policies: %w", err)
+	}
+
+	runningPods := []corev1.Pod{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			runningPods = append(runningPods, pod)
+		}
+	}
+
+	var warnings []string
+	policies, errs := NativePolicyModels(networkPolicies.Items)
+	for _, err := range errs {
+		warnings = append(warnings, err.Error())
+	}
+
+	if dynamicClient != nil {
+		for _, resource := range []schema.GroupVersionResource{ciliumNetworkPolicyResource, ciliumClusterwideNetworkPolicyResource} {
+			list, err := dynamicClient.Resource(resource).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				// Cilium is not installed in this cluster
+				if k8serrors.IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("error listing %s: %w", resource.Resource, err)
+			}
+			for i := range list.Items {
+				models, err := CiliumPolicyModels(&list.Items[i])
+				if err != nil {
+					warnings = append(warnings, err.Error())
+					continue
+				}
+				policies = append(policies, models...)
+			}
+		}
+
+		calicoModels, errs, err := LoadCalicoPolicyModels(dynamicClient)
+		if err != nil && !errors.Is(err, errCalicoNotInstalled) {
+			return nil, err
+		}
+		for _, err := range errs {
+			warnings = append(warnings, err.Error())
+		}
+		policies = append(policies, calicoModels...)
+
+		antreaModels, errs, err := LoadAntreaPolicyModels(dynamicClient)
+		if err != nil && !errors.Is(err, errAntreaNotInstalled) {
+			return nil, err
+		}
+		for _, err := range errs {
+			warnings = append(warnings, err.Error())
+		}
+		policies = append(policies, antreaModels...)
+
+		adminModels, errs, err := LoadAdminPolicyModels(dynamicClient)
+		if err != nil {
+			return nil, err
+		}
+		for _, err := range errs {
+			warnings = append(warnings, err.Error())
+		}
+		policies = append(policies, adminModels...)
+	}
+
+	state := NewClusterState(runningPods, namespaces.Items, policies)
+	state.Warnings = warnings
+	return state, nil
+}
+
+// FindPod looks up a running pod by namespace and name.
+func (s *ClusterState) FindPod(namespace, name string) (*corev1.Pod, error) {
+	for i := range s.Pods {
+		if s.Pods[i].Namespace == namespace && s.Pods[i].Name == name {
+			return &s.Pods[i], nil
+		}
+	}
+	return nil, fmt.Errorf("running pod %s/%s not found", namespace, name)
+}
+
+// ParsePodReference splits "namespace/pod" into its parts. A bare pod name refers to the default namespace.
+func ParsePodReference(reference string) (string, string, error) {
+	namespace, name, found := strings.Cut(reference, "/")
+	if !found {
+		namespace, name = metav1.NamespaceDefault, reference
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid pod reference %q, expected namespace/pod", reference)
+	}
+	return namespace, name, nil
+}
+
+// ParsePortProtocol parses "5432", "5432/TCP" or "53/udp". The protocol defaults to TCP.
+func ParsePortProtocol(value string) (int32, string, error) {
+	portText, protocol, found := strings.Cut(value, "/")
+	if !found {
+		protocol = string(corev1.ProtocolTCP)
+	}
+	protocol = strings.ToUpper(protocol)
+	switch corev1.Protocol(protocol) {
+	case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+	default:
+		return 0, "", fmt.Errorf("invalid protocol %q, must be TCP, UDP or SCTP", protocol)
+	}
+
+	port, err := strconv.ParseInt(portText, 10, 32)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, "", fmt.Errorf("invalid port %q, must be between 1 and 65535", portText)
+	}
+	return int32(port), protocol, nil
+}
+
+// CanReach evaluates whether source can connect to destination on the given port and protocol.
+// A port of 0 asks whether any port is reachable.
+func (s *ClusterState) CanReach(source, destination corev1.Pod, port int32, protocol string) ReachabilityVerdict {
+	verdict := ReachabilityVerdict{
+		Source:      source.Namespace + "/" + source.Name,
+		Destination: destination.Namespace + "/" + destination.Name,
+		Port:        port,
+		Protocol:    protocol,
+	}
+
+	verdict.Egress = s.evaluateDirection(DirectionEgress, source, destination, destination, port, protocol)
+	verdict.Ingress = s.evaluateDirection(DirectionIngress, destination, source, destination, port, protocol)
+	verdict.Allowed = verdict.Egress.Allowed && verdict.Ingress.Allowed
+	return verdict
+}
+
+// evaluateDirection decides whether the policies selecting pod allow traffic with peer in one direction.
+// Ordered tiers are evaluated first, then the NetworkPolicy layer, where deny rules take precedence over allow
+// rules, and finally baseline tiers. Without an isolating policy everything is allowed.
+func (s *ClusterState) evaluateDirection(direction string, pod, peer, destination corev1.Pod, port int32, protocol string) DirectionVerdict {
+	verdict := DirectionVerdict{
+		Direction: direction,
+		Pod:       pod.Namespace + "/" + pod.Name,
+		Policies:  []ObjectReference{},
+		AllowedBy: []PolicyRule{},
+		DeniedBy:  []PolicyRule{},
+	}
+
+	podNamespaceLabels := s.namespaceLabels[pod.Namespace]
+	peerNamespaceLabels := s.namespaceLabels[peer.Namespace]
+
+	var tiers, networkPolicies, baselineTiers []PolicyModel
+	layerIsolated := false
+	for _, policy := range s.Policies {
+		if !policy.Selects(pod, podNamespaceLabels) {
+			continue
+		}
+		if policy.Isolates(direction) {
+			verdict.Isolated = true
+			verdict.Policies = appendReference(verdict.Policies, policy.Reference())
+			// Tiers with a default action decide on their own when none of their rules match
+			if policy.ordering == nil || policy.ordering.defaultAction == "" {
+				layerIsolated = true
+			}
+		}
+
+		switch {
+		case policy.ordering == nil:
+			networkPolicies = append(networkPolicies, policy)
+		case policy.ordering.baseline:
+			baselineTiers = append(baselineTiers, policy)
+		default:
+			tiers = append(tiers, policy)
+		}
+	}
+
+	if evaluateTiers(&verdict, tiers, peer, peerNamespaceLabels, destination, port, protocol) {
+		return verdict
+	}
+
+	for _, policy := range networkPolicies {
+		for _, rule := range policy.RulesFor(direction) {
+			if !ruleMatches(rule, peer, peerNamespaceLabels, destination, port, protocol) {
+				continue
+			}
+			switch rule.Action {
+			case ActionDeny:
+				verdict.DeniedBy = append(verdict.DeniedBy, rule)
+			case ActionAllow:
+				verdict.AllowedBy = append(verdict.AllowedBy, rule)
+			}
+		}
+	}
+
+	switch {
+	case len(verdict.DeniedBy) > 0:
+		verdict.Reason = "denied by " + verdict.DeniedBy[0].Policy
+		return verdict
+	case layerIsolated && len(verdict.AllowedBy) > 0:
+		verdict.Allowed = true
+		verdict.Reason = "allowed by " + verdict.AllowedBy[0].Policy
+		return verdict
+	case layerIsolated:
+		verdict.Reason = "isolated and no " + direction + " rule matches"
+		return verdict
+	}
+
+	if evaluateTiers(&verdict, baselineTiers, peer, peerNamespaceLabels, destination, port, protocol) {
+		return verdict
+	}
+	verdict.Allowed = true
+	verdict.Reason = "not isolated, no policy selects the pod for " + direction
+	if verdict.Isolated {
+		verdict.Reason = "no policy decides " + direction + " traffic, allowed by default"
+	}
+	return verdict
+}
+
+// evaluateTiers walks the policies of ordered tiers and reports whether they decided the verdict. The first
+// matching Allow or Deny rule decides, a Pass rule skips the rest of its tier, or every remaining tier for engines
+// like Antrea, and a tier whose policies isolate the pod applies its default action when none of their rules match.
+func evaluateTiers(verdict *DirectionVerdict, policies []PolicyModel, peer corev1.Pod, peerNamespaceLabels map[string]string, destination corev1.Pod, port int32, protocol string) bool {
+	for _, tier := range groupTiers(policies) {
+		passed, isolated := false, false
+	tierPolicies:
+		for _, policy := range tier {
+			isolated = isolated || policy.Isolates(verdict.Direction)
+			for _, rule := range policy.RulesFor(verdict.Direction) {
+				if !ruleMatches(rule, peer, peerNamespaceLabels, destination, port, protocol) {
+					continue
+				}
+				switch rule.Action {
+				case ActionAllow:
+					verdict.AllowedBy = append(verdict.AllowedBy, rule)
+					verdict.Allowed = true
+					verdict.Reason = "allowed by " + rule.Policy
+					return true
+				case ActionDeny:
+					verdict.DeniedBy = append(verdict.DeniedBy, rule)
+					verdict.Reason = "denied by " + rule.Policy
+					return true
+				case ActionPass:
+					if policy.ordering.passSkipsTiers {
+						return false
+					}
+					passed = true
+					break tierPolicies
+				}
+			}
+		}
+
+		if !passed && isolated && tier[0].ordering.defaultAction == ActionDeny {
+			verdict.Reason = fmt.Sprintf("isolated and no %s rule matches in tier %s", verdict.Direction, tier[0].Tier)
+			return true
+		}
+	}
+	return false
+}
+
+// ruleMatches reports whether a rule covers traffic with the peer pod on the given port of the destination.
+// Only allow rules match a query for any port when they are restricted to some ports.
+func ruleMatches(rule PolicyRule, peer corev1.Pod, peerNamespaceLabels map[string]string, destination corev1.Pod, port int32, protocol string) bool {
+	peerMatched := false
+	for _, rulePeer := range rule.Peers {
+		if rulePeer.MatchesPod(peer, peerNamespaceLabels) {
+			peerMatched = true
+			break
+		}
+	}
+	if !peerMatched {
+		return false
+	}
+
+	if len(rule.Ports) == 0 {
+		return true
+	}
+	if port == 0 {
+		return rule.Action == ActionAllow
+	}
+	for _, rulePort := range rule.Ports {
+		if rulePort.Matches(port, protocol, &destination) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendReference adds ref to refs unless it is already present.
+func appendReference(refs []ObjectReference, ref ObjectReference) []ObjectReference {
+	for _, existing := range refs {
+		if existing == ref {
+			return refs
+		}
+	}
+	return append(refs, ref)
+}
@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestCiliumPolicyModels(t *testing.T) {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "db", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "db"}},
+			"ingress": []interface{}{
+				map[string]interface{}{
+					"fromEndpoints": []interface{}{
+						map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+						map[string]interface{}{"matchLabels": map[string]interface{}{"io.kubernetes.pod.namespace": "ops"}},
+					},
+					"toPorts": []interface{}{
+						map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "5432", "protocol": "TCP"}}},
+					},
+				},
+			},
+			"egressDeny": []interface{}{
+				map[string]interface{}{"toEntities": []interface{}{"world"}},
+			},
+		},
+	}}
+
+	models, err := CiliumPolicyModels(policy)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+
+	model := models[0]
+	assert.Equal(t, PolicyTypeCilium, model.Engine)
+	assert.True(t, model.IsolatesIngress)
+	assert.True(t, model.IsolatesEgress, "deny rules isolate the endpoint as well")
+	assert.Len(t, model.Rules, 2)
+
+	db := testPod("shop", "db-0", "10.0.0.1", map[string]string{"app": "db"})
+	web := testPod("shop", "web-0", "10.0.0.2", map[string]string{"app": "web"})
+	otherWeb := testPod("other", "web-0", "10.0.0.3", map[string]string{"app": "web"})
+	monitor := testPod("ops", "monitor-0", "10.0.0.4", map[string]string{"app": "monitor"})
+
+	assert.True(t, model.Selects(db, nil))
+	ingress := model.RulesFor(DirectionIngress)[0]
+	assert.True(t, ingress.Peers[0].MatchesPod(web, nil))
+	assert.False(t, ingress.Peers[0].MatchesPod(otherWeb, nil), "endpoint selectors of namespaced policies are scoped to the policy namespace")
+	assert.True(t, ingress.Peers[1].MatchesPod(monitor, nil))
+
+	egressDeny := model.RulesFor(DirectionEgress)[0]
+	assert.Equal(t, ActionDeny, egressDeny.Action)
+	assert.True(t, egressDeny.Peers[0].MatchesIP("8.8.8.8"))
+	assert.False(t, egressDeny.Peers[0].MatchesPod(web, nil))
+}
+
+func TestCanReach(t *testing.T) {
+	port := intstr.FromInt(5432)
+	networkPolicies := []netv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"},
+			Spec: netv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+				Ingress: []netv1.NetworkPolicyIngressRule{{
+					From:  []netv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}},
+					Ports: []netv1.NetworkPolicyPort{{Port: &port}},
+				}},
+			},
+		},
+	}
+	policies, errs := NativePolicyModels(networkPolicies)
+	assert.Empty(t, errs)
+
+	denyEgress := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "CiliumNetworkPolicy",
+		"metadata": map[string]interface{}{"name": "batch-deny", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "batch"}},
+			"egress":           []interface{}{map[string]interface{}{}},
+			"egressDeny": []interface{}{
+				map[string]interface{}{"toEndpoints": []interface{}{map[string]interface{}{"matchLabels": map[string]interface{}{"app": "db"}}}},
+			},
+		},
+	}}
+	ciliumPolicies, err := CiliumPolicyModels(denyEgress)
+	assert.NoError(t, err)
+	policies = append(policies, ciliumPolicies...)
+
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	batch := testPod("shop", "batch-0", "10.0.0.2", map[string]string{"app": "batch"})
+	db := testPod("shop", "db-0", "10.0.0.3", map[string]string{"app": "db"})
+	namespaces := []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "shop"}}}
+	state := NewClusterState([]corev1.Pod{web, batch, db}, namespaces, policies)
+
+	tests := []struct {
+		name        string
+		source      corev1.Pod
+		destination corev1.Pod
+		port        int32
+		allowed     bool
+		reason      string
+	}{
+		{"allowed by ingress rule", web, db, 5432, true, "allowed by shop/db"},
+		{"port not allowed", web, db, 80, false, "isolated and no ingress rule matches"},
+		{"destination not isolated", db, web, 80, true, "not isolated, no policy selects the pod for ingress"},
+		{"egress deny takes precedence", batch, db, 5432, false, "denied by shop/batch-deny"},
+		{"any port", web, db, 0, true, "allowed by shop/db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := state.CanReach(tt.source, tt.destination, tt.port, "TCP")
+			assert.Equal(t, tt.allowed, verdict.Allowed)
+			reasons := []string{verdict.Egress.Reason, verdict.Ingress.Reason}
+			assert.Contains(t, reasons, tt.reason)
+		})
+	}
+}
+
+func TestParsePortProtocol(t *testing.T) {
+	port, protocol, err := ParsePortProtocol("5432/tcp")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5432), port)
+	assert.Equal(t, "TCP", protocol)
+
+	port, protocol, err = ParsePortProtocol("53/UDP")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(53), port)
+	assert.Equal(t, "UDP", protocol)
+
+	_, protocol, err = ParsePortProtocol("80")
+	assert.NoError(t, err)
+	assert.Equal(t, "TCP", protocol)
+
+	_, _, err = ParsePortProtocol("70000/TCP")
+	assert.Error(t, err)
+	_, _, err = ParsePortProtocol("80/ICMP")
+	assert.Error(t, err)
+}
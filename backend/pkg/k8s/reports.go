@@ -0,0 +1,309 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Resources of the reports written by the operator
+var (
+	reportGroupVersion            = schema.GroupVersion{Group: "netfetch.io", Version: "v1alpha1"}
+	netfetchReportResource        = reportGroupVersion.WithResource("netfetchreports")
+	clusterNetfetchReportResource = reportGroupVersion.WithResource("clusternetfetchreports")
+)
+
+const (
+	// ReportName is the name of the NetfetchReport of every namespace and of the ClusterNetfetchReport
+	ReportName = "netfetch"
+	// reportManagedByLabel marks the reports written by netfetch, so stale ones can be found and deleted
+	reportManagedByLabel = "app.kubernetes.io/managed-by"
+	reportManagedByValue = "netfetch"
+)
+
+// BuildReports turns scan results into a NetfetchReport for every scanned namespace and a ClusterNetfetchReport. A
+// pod is listed as unprotected when no scanned engine protects it, and a namespace has the lowest score any scanned
+// engine gives it.
+func BuildReports(results []*ScanResult, scanTime time.Time) (*unstructured.Unstructured, []*unstructured.Unstructured) {
+	namespaces, unprotectedPods := unprotectedByEveryEngine(results)
+	timestamp := scanTime.UTC().Format(time.RFC3339)
+
+	podsByNamespace := map[string][]interface{}{}
+	for _, pod := range unprotectedPods {
+		podsByNamespace[pod.Namespace] = append(podsByNamespace[pod.Namespace], map[string]interface{}{"name": pod.Name, "ip": pod.IP})
+	}
+
+	reports := []*unstructured.Unstructured{}
+	namespaceSummaries := []interface{}{}
+	withoutDefaultDeny := int64(0)
+	for _, namespace := range namespaces {
+		engines := []interface{}{}
+		hasDefaultDeny := false
+		score := int64(-1)
+		for _, result := range results {
+			if result == nil || !contains(result.NamespacesScanned, namespace) {
+				continue
+			}
+			engineDefaultDeny := contains(result.HasDenyAll, namespace)
+			hasDefaultDeny = hasDefaultDeny || engineDefaultDeny
+			engine := map[string]interface{}{
+				"name":        result.PolicyType,
+				"defaultDeny": engineDefaultDeny,
+				"unprotected": int64(countPodsInNamespace(result.UnprotectedPods, namespace)),
+			}
+			engineScore := int64(result.Score)
+			if namespaceScore := findNamespaceScore(result.ScoreBreakdown, namespace); namespaceScore != nil {
+				engineScore = int64(namespaceScore.Score)
+				engine["deductions"] = reportDeductions(namespaceScore.Deductions)
+			}
+			engine["score"] = engineScore
+			if score < 0 || engineScore < score {
+				score = engineScore
+			}
+			engines = append(engines, engine)
+		}
+		if !hasDefaultDeny {
+			withoutDefaultDeny++
+		}
+
+		pods := podsByNamespace[namespace]
+		if pods == nil {
+			pods = []interface{}{}
+		}
+		if score < 0 {
+			score = 0
+		}
+
+		report := newReport("NetfetchReport", namespace, timestamp)
+		report.Object["score"] = score
+		report.Object["summary"] = map[string]interface{}{"unprotected": int64(len(pods)), "defaultDeny": hasDefaultDeny}
+		report.Object["engines"] = engines
+		report.Object["unprotectedPods"] = pods
+		reports = append(reports, report)
+
+		namespaceSummaries = append(namespaceSummaries, map[string]interface{}{"name": namespace, "score": score, "unprotected": int64(len(pods)), "defaultDeny": hasDefaultDeny})
+	}
+
+	clusterEngines := []interface{}{}
+	lowestScore := int64(-1)
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if lowestScore < 0 || int64(result.Score) < lowestScore {
+			lowestScore = int64(result.Score)
+		}
+		engine := map[string]interface{}{
+			"name":        result.PolicyType,
+			"score":       int64(result.Score),
+			"unprotected": int64(len(result.UnprotectedPods)),
+		}
+		if result.ScoreBreakdown != nil {
+			engine["deductions"] = reportDeductions(result.ScoreBreakdown.Deductions)
+		}
+		clusterEngines = append(clusterEngines, engine)
+	}
+	if lowestScore < 0 {
+		lowestScore = 0
+	}
+
+	clusterReport := newReport("ClusterNetfetchReport", "", timestamp)
+	clusterReport.Object["score"] = lowestScore
+	clusterReport.Object["summary"] = map[string]interface{}{
+		"namespaces":                   int64(len(namespaces)),
+		"namespacesWithoutDefaultDeny": withoutDefaultDeny,
+		"unprotected":                  int64(len(unprotectedPods)),
+	}
+	clusterReport.Object["engines"] = clusterEngines
+	clusterReport.Object["namespaces"] = namespaceSummaries
+	return clusterReport, reports
+}
+
+// findNamespaceScore returns the score of the namespace in the breakdown, or nil when it was not scored.
+func findNamespaceScore(breakdown *ScoreBreakdown, namespace string) *NamespaceScore {
+	if breakdown == nil {
+		return nil
+	}
+	for i := range breakdown.Namespaces {
+		if breakdown.Namespaces[i].Namespace == namespace {
+			return &breakdown.Namespaces[i]
+		}
+	}
+	return nil
+}
+
+// reportDeductions converts score deductions into their unstructured form.
+func reportDeductions(deductions []ScoreDeduction) []interface{} {
+	converted := []interface{}{}
+	for _, deduction := range deductions {
+		converted = append(converted, map[string]interface{}{"part": deduction.Part, "points": deduction.Points, "reason": deduction.Reason})
+	}
+	return converted
+}
+
+// newReport creates an empty report of the kind, cluster scoped when namespace is empty.
+func newReport(kind, namespace, timestamp string) *unstructured.Unstructured {
+	report := &unstructured.Unstructured{Object: map[string]interface{}{"scanTime": timestamp}}
+	report.SetAPIVersion(reportGroupVersion.String())
+	report.SetKind(kind)
+	report.SetName(ReportName)
+	report.SetNamespace(namespace)
+	report.SetLabels(map[string]string{reportManagedByLabel: reportManagedByValue})
+	return report
+}
+
+// countPodsInNamespace counts the pod details of a scan result that belong to the namespace.
+func countPodsInNamespace(podDetails []string, namespace string) int {
+	count := 0
+	for _, detail := range podDetails {
+		if ParsePodDetail(detail).Namespace == namespace {
+			count++
+		}
+	}
+	return count
+}
+
+// ApplyReports creates or replaces the reports and deletes the NetfetchReports written by netfetch for namespaces
+// that no longer exist or that the configuration excludes. Reports of other namespaces missing from the scan are
+// left as they are.
+func ApplyReports(dynamicClient dynamic.Interface, clientset kubernetes.Interface, clusterReport *unstructured.Unstructured, reports []*unstructured.Unstructured) error {
+	if err := applyReport(dynamicClient.Resource(clusterNetfetchReportResource), clusterReport); err != nil {
+		return err
+	}
+
+	current := map[string]bool{}
+	for _, report := range reports {
+		if err := applyReport(dynamicClient.Resource(netfetchReportResource).Namespace(report.GetNamespace()), report); err != nil {
+			return err
+		}
+		current[report.GetNamespace()] = true
+	}
+
+	existing, err := dynamicClient.Resource(netfetchReportResource).Namespace("").List(context.TODO(), metav1.ListOptions{
+		LabelSelector: reportManagedByLabel + "=" + reportManagedByValue,
+	})
+	if err != nil {
+		return fmt.Errorf("error listing NetfetchReports: %w", err)
+	}
+	for _, report := range existing.Items {
+		if current[report.GetNamespace()] {
+			continue
+		}
+		stale, err := staleReportNamespace(clientset, report.GetNamespace())
+		if err != nil {
+			return err
+		}
+		if !stale {
+			continue
+		}
+		err = dynamicClient.Resource(netfetchReportResource).Namespace(report.GetNamespace()).Delete(context.TODO(), report.GetName(), metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting stale NetfetchReport in namespace %s: %w", report.GetNamespace(), err)
+		}
+	}
+	return nil
+}
+
+// staleReportNamespace reports whether the namespace of a report no longer exists or is excluded by the
+// configuration.
+func staleReportNamespace(clientset kubernetes.Interface, namespace string) (bool, error) {
+	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error getting namespace %s: %w", namespace, err)
+	}
+	return !activeConfig.IncludesNamespace(ns.Name, ns.Labels), nil
+}
+
+// applyReport creates the report, or replaces the existing one.
+func applyReport(client dynamic.ResourceInterface, report *unstructured.Unstructured) error {
+	existing, err := client.Get(context.TODO(), report.GetName(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if _, err := client.Create(context.TODO(), report, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating %s %s: %w", report.GetKind(), reportID(report), err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting %s %s: %w", report.GetKind(), reportID(report), err)
+	}
+
+	report.SetResourceVersion(existing.GetResourceVersion())
+	if _, err := client.Update(context.TODO(), report, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating %s %s: %w", report.GetKind(), reportID(report), err)
+	}
+	return nil
+}
+
+func reportID(report *unstructured.Unstructured) string {
+	if report.GetNamespace() == "" {
+		return report.GetName()
+	}
+	return report.GetNamespace() + "/" + report.GetName()
+}
+
+// ReportController keeps the NetfetchReports and the ClusterNetfetchReport of a cluster up to date. It rescans the
+// cluster cache whenever pods or policies change, and on every resync interval.
+type ReportController struct {
+	clusterCache   *ClusterCache
+	kubeconfigPath string
+	engines        []string
+	resyncInterval time.Duration
+}
+
+// NewReportController creates a controller writing reports for native network policies and every other installed
+// policy engine with a scanner.
+func NewReportController(clusterCache *ClusterCache, kubeconfigPath string, engines PolicyEngines, resyncInterval time.Duration) *ReportController {
+	return &ReportController{
+		clusterCache:   clusterCache,
+		kubeconfigPath: kubeconfigPath,
+		engines:        ScannedEngines(engines),
+		resyncInterval: resyncInterval,
+	}
+}
+
+// Run writes the reports, then rewrites them after every change of the cluster cache and every resync interval until
+// stopCh is closed.
+func (c *ReportController) Run(stopCh <-chan struct{}) {
+	changes, unsubscribe := c.clusterCache.SubscribeChanges()
+	defer unsubscribe()
+
+	resync := time.NewTicker(c.resyncInterval)
+	defer resync.Stop()
+	for {
+		if err := c.Reconcile(); err != nil {
+			log.Printf("Error writing Netfetch reports: %v\n", err)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-resync.C:
+		case <-changes.Changed():
+			// Let a burst of changes settle and handle it with a single scan
+			time.Sleep(protectionScanDelay)
+		}
+		changes.Drain()
+	}
+}
+
+// Reconcile scans the cluster and writes the reports. The reports are left as they are when an engine fails to
+// scan, so a failing engine does not empty them.
+func (c *ReportController) Reconcile() error {
+	results := scanEngines(c.engines, c.kubeconfigPath)
+	if len(results) < len(c.engines) {
+		return fmt.Errorf("%d of %d policy engines failed to scan, keeping the previous reports", len(c.engines)-len(results), len(c.engines))
+	}
+	clusterReport, reports := BuildReports(results, time.Now())
+	return ApplyReports(c.clusterCache.dynamicClient, c.clusterCache.clientset, clusterReport, reports)
+}
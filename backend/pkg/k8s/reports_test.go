@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestBuildReports(t *testing.T) {
+	native := &ScanResult{
+		PolicyType:        PolicyTypeKubernetes,
+		NamespacesScanned: []string{"shop", "web"},
+		HasDenyAll:        []string{"web"},
+		UnprotectedPods:   []string{"shop api-0 10.0.0.1", "shop db-0 10.0.0.2"},
+		Score:             48,
+		ScoreBreakdown: &ScoreBreakdown{Namespaces: []NamespaceScore{{
+			Namespace:  "shop",
+			Score:      30,
+			Deductions: []ScoreDeduction{{Part: ScoreIngressIsolation, Points: 70, Reason: "2 of 2 pods are not isolated for ingress"}},
+		}}},
+	}
+	cilium := &ScanResult{
+		PolicyType:        PolicyTypeCilium,
+		NamespacesScanned: []string{"shop"},
+		UnprotectedPods:   []string{"shop api-0 10.0.0.1"},
+		Score:             49,
+	}
+
+	clusterReport, reports := BuildReports([]*ScanResult{native, cilium}, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	assert.Len(t, reports, 2)
+
+	shop := reports[0]
+	assert.Equal(t, "NetfetchReport", shop.GetKind())
+	assert.Equal(t, "shop", shop.GetNamespace())
+	assert.Equal(t, ReportName, shop.GetName())
+	assert.Equal(t, "2024-05-01T12:00:00Z", shop.Object["scanTime"])
+	assert.Equal(t, int64(30), shop.Object["score"])
+	assert.Equal(t, map[string]interface{}{"unprotected": int64(1), "defaultDeny": false}, shop.Object["summary"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "api-0", "ip": "10.0.0.1"}}, shop.Object["unprotectedPods"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": PolicyTypeKubernetes, "defaultDeny": false, "unprotected": int64(2), "score": int64(30), "deductions": []interface{}{
+			map[string]interface{}{"part": ScoreIngressIsolation, "points": 70.0, "reason": "2 of 2 pods are not isolated for ingress"},
+		}},
+		map[string]interface{}{"name": PolicyTypeCilium, "defaultDeny": false, "unprotected": int64(1), "score": int64(49)},
+	}, shop.Object["engines"])
+
+	web := reports[1]
+	assert.Equal(t, map[string]interface{}{"unprotected": int64(0), "defaultDeny": true}, web.Object["summary"])
+	assert.Equal(t, []interface{}{}, web.Object["unprotectedPods"])
+
+	assert.Equal(t, "ClusterNetfetchReport", clusterReport.GetKind())
+	assert.Equal(t, "", clusterReport.GetNamespace())
+	assert.Equal(t, int64(48), clusterReport.Object["score"])
+	assert.Equal(t, map[string]interface{}{"namespaces": int64(2), "namespacesWithoutDefaultDeny": int64(1), "unprotected": int64(1)}, clusterReport.Object["summary"])
+}
+
+func TestApplyReports(t *testing.T) {
+	listKinds := map[schema.GroupVersionResource]string{
+		netfetchReportResource:        "NetfetchReportList",
+		clusterNetfetchReportResource: "ClusterNetfetchReportList",
+	}
+	stale := newReport("NetfetchReport", "removed", "2024-05-01T11:00:00Z")
+	unmanaged := newReport("NetfetchReport", "other", "2024-05-01T11:00:00Z")
+	unmanaged.SetLabels(nil)
+	existing := newReport("NetfetchReport", "shop", "2024-05-01T11:00:00Z")
+	existing.Object["score"] = int64(1)
+	// The report of a namespace that still exists is kept when the namespace is missing from a scan
+	missing := newReport("NetfetchReport", "payments", "2024-05-01T11:00:00Z")
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, stale, unmanaged, existing, missing)
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	)
+
+	native := &ScanResult{PolicyType: PolicyTypeKubernetes, NamespacesScanned: []string{"shop", "web"}, Score: 50}
+	clusterReport, reports := BuildReports([]*ScanResult{native}, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, ApplyReports(dynamicClient, clientset, clusterReport, reports))
+
+	list, err := dynamicClient.Resource(netfetchReportResource).Namespace("").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	namespaces := []string{}
+	for _, report := range list.Items {
+		namespaces = append(namespaces, report.GetNamespace())
+	}
+	assert.ElementsMatch(t, []string{"other", "payments", "shop", "web"}, namespaces)
+
+	shop, err := dynamicClient.Resource(netfetchReportResource).Namespace("shop").Get(context.TODO(), ReportName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	score, _, _ := unstructured.NestedInt64(shop.Object, "score")
+	assert.Equal(t, int64(50), score)
+	assert.Equal(t, "2024-05-01T12:00:00Z", shop.Object["scanTime"])
+
+	cluster, err := dynamicClient.Resource(clusterNetfetchReportResource).Get(context.TODO(), ReportName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	summary, _, _ := unstructured.NestedMap(cluster.Object, "summary")
+	assert.Equal(t, int64(2), summary["namespaces"])
+}
+
+func TestReconcileKeepsReportsWhenAScanFails(t *testing.T) {
+	listKinds := map[schema.GroupVersionResource]string{
+		netfetchReportResource:        "NetfetchReportList",
+		clusterNetfetchReportResource: "ClusterNetfetchReportList",
+	}
+	existing := newReport("NetfetchReport", "shop", "2024-05-01T11:00:00Z")
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, existing)
+	fakeClientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}})
+	fakeClientset.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+
+	previousClientset, previousInitialized, previousDynamicClient := clientset, isClientInitialized, sharedDynamicClient
+	UseOfflineClients(fakeClientset, dynamicClient)
+	defer func() {
+		clientset, isClientInitialized, sharedDynamicClient = previousClientset, previousInitialized, previousDynamicClient
+	}()
+
+	controller := &ReportController{
+		clusterCache: &ClusterCache{clientset: fakeClientset, dynamicClient: dynamicClient},
+		engines:      []string{PolicyTypeKubernetes},
+	}
+	assert.Error(t, controller.Reconcile())
+
+	report, err := dynamicClient.Resource(netfetchReportResource).Namespace("shop").Get(context.TODO(), ReportName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-01T11:00:00Z", report.Object["scanTime"])
+	_, err = dynamicClient.Resource(clusterNetfetchReportResource).Get(context.TODO(), ReportName, metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+}
@@ -0,0 +1,346 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// SARIF rule identifiers for netfetch findings
+const (
+	RuleUnprotectedPod          = "NETFETCH001"
+	RuleNamespaceWithoutDenyAll = "NETFETCH002"
+	RulePolicySelectsNothing    = "NETFETCH003"
+	RulePartiallyIsolatedPod    = "NETFETCH004"
+	RuleOverlyBroadRule         = "NETFETCH005"
+	RuleMissingL7Authorization  = "NETFETCH006"
+	RuleMTLSNotStrict           = "NETFETCH007"
+	sarifVersion                = "2.1.0"
+	sarifSchema                 = "https://json.schemastore.org/sarif-2.1.0.json"
+	netfetchInformationURI      = "https://github.com/deggja/netfetch"
+)
+
+// sarifRules describes every finding netfetch can report, in rule index order.
+var sarifRules = []sarifRule{
+	{
+		ID:               RuleUnprotectedPod,
+		Name:             "UnprotectedPod",
+		ShortDescription: sarifMessage{Text: "Pod is not targeted by any network policy"},
+		FullDescription:  sarifMessage{Text: "The pod is running without being selected by a network policy, so all ingress and egress traffic to it is allowed."},
+		Help:             sarifMessage{Text: "Add a default deny network policy to the namespace or a policy selecting the pod."},
+		DefaultConfiguration: sarifConfiguration{
+			Level: "error",
+		},
+		Properties: sarifRuleProperties{SecuritySeverity: "7.5", Tags: []string{"security", "kubernetes", "network-policy"}},
+	},
+	{
+		ID:               RuleNamespaceWithoutDenyAll,
+		Name:             "NamespaceWithoutDefaultDeny",
+		ShortDescription: sarifMessage{Text: "Namespace has no default deny network policy"},
+		FullDescription:  sarifMessage{Text: "New workloads in the namespace are not isolated until a policy explicitly selects them."},
+		Help:             sarifMessage{Text: "Add a network policy with an empty pod selector and no ingress or egress rules."},
+		DefaultConfiguration: sarifConfiguration{
+			Level: "warning",
+		},
+		Properties: sarifRuleProperties{SecuritySeverity: "5.0", Tags: []string{"security", "kubernetes", "network-policy"}},
+	},
+	{
+		ID:               RulePolicySelectsNothing,
+		Name:             "PolicySelectsNothing",
+		ShortDescription: sarifMessage{Text: "Network policy does not select any pods"},
+		FullDescription:  sarifMessage{Text: "The policy selector matches no pods, which usually means a label typo or a leftover policy."},
+		Help:             sarifMessage{Text: "Fix the selector of the policy or remove it."},
+		DefaultConfiguration: sarifConfiguration{
+			Level: "note",
+		},
+		Properties: sarifRuleProperties{SecuritySeverity: "3.0", Tags: []string{"kubernetes", "network-policy", "maintainability"}},
+	},
+	{
+		ID:               RulePartiallyIsolatedPod,
+		Name:             "PartiallyIsolatedPod",
+		ShortDescription: sarifMessage{Text: "Pod is isolated for ingress or egress only"},
+		FullDescription:  sarifMessage{Text: "The pod is selected by network policies that isolate only one direction, so all traffic in the other direction is allowed."},
+		Help:             sarifMessage{Text: "Add the missing direction to the policyTypes of a policy selecting the pod."},
+		DefaultConfiguration: sarifConfiguration{
+			Level: "warning",
+		},
+		Properties: sarifRuleProperties{SecuritySeverity: "5.0", Tags: []string{"security", "kubernetes", "network-policy"}},
+	},
+	{
+		ID:               RuleOverlyBroadRule,
+		Name:             "OverlyBroadRule",
+		ShortDescription: sarifMessage{Text: "Pod is allowed traffic from or to any peer"},
+		FullDescription:  sarifMessage{Text: "A rule applied to the pod allows every peer, every namespace or 0.0.0.0/0, which defeats the isolation of the pod."},
+		Help:             sarifMessage{Text: "Restrict the peers of the rule to the workloads or address ranges that need access."},
+		DefaultConfiguration: sarifConfiguration{
+			Level: "warning",
+		},
+		Properties: sarifRuleProperties{SecuritySeverity: "5.0", Tags: []string{"security", "kubernetes", "network-policy"}},
+	},
+	{
+		ID:               RuleMissingL7Authorization,
+		Name:             "MissingL7Authorization",
+		ShortDescription: sarifMessage{Text: "Pod has network policies but no Istio authorization policy"},
+		FullDescription:  sarifMessage{Text: "The pod is isolated by network policies, but no ALLOW or DENY AuthorizationPolicy restricts which identities and requests may reach it."},
+		Help:             sarifMessage{Text: "Add the pod to the mesh and apply an AuthorizationPolicy selecting it."},
+		DefaultConfiguration: sarifConfiguration{
+			Level: "warning",
+		},
+		Properties: sarifRuleProperties{SecuritySeverity: "4.0", Tags: []string{"security", "kubernetes", "istio"}},
+	},
+	{
+		ID:               RuleMTLSNotStrict,
+		Name:             "MTLSNotStrict",
+		ShortDescription: sarifMessage{Text: "Pod in the mesh accepts plain text traffic"},
+		FullDescription:  sarifMessage{Text: "The PeerAuthentications applying to the pod do not enforce STRICT mTLS on every port, so peers can connect without a verified identity."},
+		Help:             sarifMessage{Text: "Apply a PeerAuthentication with mtls.mode STRICT to the namespace or the mesh."},
+		DefaultConfiguration: sarifConfiguration{
+			Level: "warning",
+		},
+		Properties: sarifRuleProperties{SecuritySeverity: "4.0", Tags: []string{"security", "kubernetes", "istio"}},
+	},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	Name                 string              `json:"name"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	FullDescription      sarifMessage        `json:"fullDescription"`
+	Help                 sarifMessage        `json:"help"`
+	DefaultConfiguration sarifConfiguration  `json:"defaultConfiguration"`
+	Properties           sarifRuleProperties `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProperties struct {
+	SecuritySeverity string   `json:"security-severity"`
+	Tags             []string `json:"tags"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           int                `json:"ruleIndex"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	Properties          map[string]string  `json:"properties,omitempty"`
+}
+
+// sarifSuppression marks a result as accepted, so code-scanning tools do not raise it as an alert.
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF serializes the findings of a scan result document as a SARIF 2.1.0 log.
+func WriteSARIF(w io.Writer, document *ScanResultDocument) error {
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "netfetch",
+				InformationURI: netfetchInformationURI,
+				Rules:          sarifRules,
+			}},
+			Results: sarifResults(document),
+		}},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+// sarifResults converts every finding in the document into a SARIF result, skipping duplicates
+// reported by more than one scan. Waived findings are included as suppressed results, and findings
+// whose waiver has expired carry the expiry date in their properties.
+func sarifResults(document *ScanResultDocument) []sarifResult {
+	results := []sarifResult{}
+	seen := make(map[string]bool)
+	var expiredWaivers map[string]WaivedFinding
+
+	add := func(ruleIndex int, ref ObjectReference, policyType string, message string, suppressions ...sarifSuppression) {
+		rule := sarifRules[ruleIndex]
+		fingerprint := fmt.Sprintf("%s/%s/%s/%s/%s", rule.ID, policyType, ref.Kind, ref.Namespace, ref.Name)
+		if seen[fingerprint] {
+			return
+		}
+		seen[fingerprint] = true
+
+		properties := map[string]string{"policyType": policyType}
+		if waived, found := expiredWaivers[rule.ID+"/"+qualifiedName(ref)]; found {
+			properties["waiverExpired"] = waived.Expires
+		}
+		results = append(results, sarifResult{
+			RuleID:              rule.ID,
+			RuleIndex:           ruleIndex,
+			Level:               rule.DefaultConfiguration.Level,
+			Message:             sarifMessage{Text: message},
+			Locations:           []sarifLocation{objectLocation(ref)},
+			PartialFingerprints: map[string]string{"netfetchFinding/v1": fingerprint},
+			Suppressions:        suppressions,
+			Properties:          properties,
+		})
+	}
+
+	for _, entry := range document.Results {
+		expiredWaivers = map[string]WaivedFinding{}
+		for _, waived := range entry.Waived {
+			// A waived finding of a rule SARIF has no descriptor for cannot be reported
+			index := ruleIndex(waived.Rule)
+			if index < 0 {
+				continue
+			}
+			ref := findingReference(waived.Rule, entry.PolicyType, waived.Namespace, waived.Name)
+			if waived.Expired {
+				expiredWaivers[waived.Rule+"/"+qualifiedName(ref)] = waived
+				continue
+			}
+			suppression := sarifSuppression{Kind: "inSource", Status: "accepted", Justification: waived.Waiver}
+			message := fmt.Sprintf("%s %s is waived by the %s annotation of %s %s.", sarifRules[index].Name, qualifiedName(ref), WaiverAnnotation, waived.WaivedBy.Kind, qualifiedName(waived.WaivedBy))
+			add(index, ref, entry.PolicyType, message, suppression)
+		}
+
+		for _, pod := range entry.UnprotectedPods {
+			ref := ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+			add(0, ref, entry.PolicyType, fmt.Sprintf("Pod %s/%s is not targeted by any %s network policy.", pod.Namespace, pod.Name, entry.PolicyType))
+		}
+		for _, namespace := range entry.NamespacesWithoutDenyAll {
+			ref := ObjectReference{Kind: "Namespace", Name: namespace}
+			add(1, ref, entry.PolicyType, fmt.Sprintf("Namespace %s has no default deny all %s network policy.", namespace, entry.PolicyType))
+		}
+		for _, policy := range entry.PoliciesSelectingNothing {
+			add(2, policy, entry.PolicyType, fmt.Sprintf("%s %s does not select any pods.", policy.Kind, qualifiedName(policy)))
+		}
+		for _, evaluation := range entry.PodEvaluations {
+			ref := ObjectReference{Kind: "Pod", Namespace: evaluation.Namespace, Name: evaluation.Name}
+			broadRules := []string{}
+			for _, finding := range evaluation.Findings {
+				switch finding {
+				case FindingUnprotected:
+				case FindingIngressOnlyIsolated, FindingEgressOnlyIsolated:
+					add(3, ref, entry.PolicyType, fmt.Sprintf("Pod %s/%s is %s.", evaluation.Namespace, evaluation.Name, finding))
+				default:
+					broadRules = append(broadRules, finding)
+				}
+			}
+			if len(broadRules) > 0 {
+				add(4, ref, entry.PolicyType, fmt.Sprintf("Pod %s/%s has %s.", evaluation.Namespace, evaluation.Name, strings.Join(broadRules, " and ")))
+			}
+		}
+		for _, evaluation := range entry.MeshEvaluations {
+			ref := ObjectReference{Kind: "Pod", Namespace: evaluation.Namespace, Name: evaluation.Name}
+			if evaluation.HasFinding(FindingL4WithoutL7Authorization) {
+				add(5, ref, entry.PolicyType, fmt.Sprintf("Pod %s/%s is isolated by network policies but not covered by an Istio AuthorizationPolicy.", evaluation.Namespace, evaluation.Name))
+			}
+			if evaluation.HasFinding(FindingMTLSNotStrict) {
+				add(6, ref, entry.PolicyType, fmt.Sprintf("Pod %s/%s does not enforce STRICT mTLS on every port.", evaluation.Namespace, evaluation.Name))
+			}
+		}
+	}
+
+	return results
+}
+
+// ruleIndex returns the index of the rule with the ID in sarifRules.
+func ruleIndex(id string) int {
+	for i, rule := range sarifRules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findingReference returns the object a finding of the rule refers to: the namespace for namespaces without a
+// default deny policy, the policy for policies selecting nothing, and the pod otherwise.
+func findingReference(rule, policyType, namespace, name string) ObjectReference {
+	switch rule {
+	case RuleNamespaceWithoutDenyAll:
+		return ObjectReference{Kind: "Namespace", Name: name}
+	case RulePolicySelectsNothing:
+		if namespace == "" {
+			return policyReference(policyType, name)
+		}
+		return policyReference(policyType, namespace+"/"+name)
+	}
+	return ObjectReference{Kind: "Pod", Namespace: namespace, Name: name}
+}
+
+// objectLocation points a SARIF result at the Kubernetes object reference of a finding.
+func objectLocation(ref ObjectReference) sarifLocation {
+	uri := path.Join("kubernetes", ref.Kind, ref.Name)
+	if ref.Namespace != "" {
+		uri = path.Join("kubernetes", ref.Namespace, ref.Kind, ref.Name)
+	}
+
+	return sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}},
+		LogicalLocations: []sarifLogicalLocation{{
+			Name:               ref.Name,
+			FullyQualifiedName: ref.Kind + "/" + qualifiedName(ref),
+			Kind:               "resource",
+		}},
+	}
+}
+
+// qualifiedName returns namespace/name for namespaced objects and name for cluster scoped ones.
+func qualifiedName(ref ObjectReference) string {
+	if ref.Namespace == "" {
+		return ref.Name
+	}
+	return ref.Namespace + "/" + ref.Name
+}
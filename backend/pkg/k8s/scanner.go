@@ -18,7 +18,9 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -45,14 +47,24 @@ func StripANSICodes(str string) string {
 
 // Struct to represent scan results in dashboard
 type ScanResult struct {
-	NamespacesScanned  []string
-	DeniedNamespaces   []string
-	UnprotectedPods    []string
-	PolicyChangesMade  bool
-	UserDeniedPolicies bool
-	HasDenyAll         []string
-	Score              int
-	AllPodsProtected   bool
+	PolicyType               string
+	NamespacesScanned        []string
+	DeniedNamespaces         []string
+	UnprotectedPods          []string
+	PolicyChangesMade        bool
+	UserDeniedPolicies       bool
+	HasDenyAll               []string
+	PoliciesSelectingNothing []string
+	PodEvaluations           []PodPolicyEvaluation
+	MeshEvaluations          []PodMeshEvaluation
+	Suppressed               []SuppressedFinding
+	Waived                   []WaivedFinding
+	Score                    int
+	ScoreBreakdown           *ScoreBreakdown
+	AllPodsProtected         bool
+
+	// waivers are the waiver annotations of the cluster the scan honors
+	waivers *waiverIndex
 }
 
 // Check if error scanning is related to network issues
@@ -74,7 +86,7 @@ func isNetworkError(err error) bool {
 }
 
 // Initialize client
-func InitializeClient(kubeconfigPath string) (*kubernetes.Clientset, error) {
+func InitializeClient(kubeconfigPath string) (kubernetes.Interface, error) {
 	clientset, err := GetClientset(kubeconfigPath)
 	if err != nil {
 		fmt.Printf("Error creating Kubernetes client: %s\n", err)
@@ -83,8 +95,29 @@ func InitializeClient(kubeconfigPath string) (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
+// initializeDynamicClients creates the dynamic client and clientset the policy engine scanners share.
+func initializeDynamicClients(kubeconfigPath string) (dynamic.Interface, kubernetes.Interface, error) {
+	dynamicClient, err := GetDynamicClient(kubeconfigPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating dynamic Kubernetes client: %s", err)
+	}
+	if dynamicClient == nil {
+		return nil, nil, fmt.Errorf("failed to create dynamic client: client is nil")
+	}
+
+	clientset, err := GetClientset(kubeconfigPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating Kubernetes clientset: %s", err)
+	}
+	if clientset == nil {
+		return nil, nil, fmt.Errorf("failed to create clientset: clientset is nil")
+	}
+
+	return dynamicClient, clientset, nil
+}
+
 // Select which namespace to scan
-func SelectNamespaces(clientset *kubernetes.Clientset, specificNamespace string) ([]string, error) {
+func SelectNamespaces(clientset kubernetes.Interface, specificNamespace string) ([]string, error) {
 	var namespaces []string
 	if specificNamespace != "" {
 		_, err := clientset.CoreV1().Namespaces().Get(context.TODO(), specificNamespace, metav1.GetOptions{})
@@ -104,7 +137,7 @@ func SelectNamespaces(clientset *kubernetes.Clientset, specificNamespace string)
 			return nil, fmt.Errorf("error listing namespaces: %w", err)
 		}
 		for _, ns := range nsList.Items {
-			if !IsSystemNamespace(ns.Name) {
+			if activeConfig.IncludesNamespace(ns.Name, ns.Labels) {
 				namespaces = append(namespaces, ns.Name)
 			}
 		}
@@ -112,8 +145,20 @@ func SelectNamespaces(clientset *kubernetes.Clientset, specificNamespace string)
 	return namespaces, nil
 }
 
+// interactive controls whether the scanners may prompt the user. It is disabled in CI mode and when a
+// machine-readable output format is requested.
+var interactive = true
+
+// SetInteractive enables or disables every prompt of the CLI scanners
+func SetInteractive(enabled bool) {
+	interactive = enabled
+}
+
 // promptForPolicyApplication asks the user whether to apply a default deny policy
 func promptForPolicyApplication(namespace string, writer *bufio.Writer) bool {
+	if !interactive {
+		return false
+	}
 	var confirm bool
 	prompt := &survey.Confirm{
 		Message: fmt.Sprintf("Do you want to add a default deny all network policy to the namespace %s?", namespace),
@@ -126,47 +171,37 @@ func promptForPolicyApplication(namespace string, writer *bufio.Writer) bool {
 	return confirm
 }
 
-// Fetches all network policies for a namespace and returns a map of covered pods
-func fetchCoveredPods(clientset *kubernetes.Clientset, nsName string, writer *bufio.Writer) (map[string]bool, error) {
+// Matches the network policies of a namespace against its pods and returns a map of covered pods
+func findCoveredPods(policies []networkingv1.NetworkPolicy, pods []v1.Pod, nsName string, writer *bufio.Writer, scanResult *ScanResult) map[string]bool {
 	coveredPods := make(map[string]bool)
-	policies, err := clientset.NetworkingV1().NetworkPolicies(nsName).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		printToBoth(writer, fmt.Sprintf("\nError listing network policies in namespace %s: %s\n", nsName, err))
-		return nil, fmt.Errorf("error listing network policies: %w", err)
+	if hasDefaultDenyAllPolicy(policies) && !contains(scanResult.HasDenyAll, nsName) {
+		scanResult.HasDenyAll = append(scanResult.HasDenyAll, nsName)
 	}
 
-	for _, policy := range policies.Items {
+	for _, policy := range policies {
 		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
 		if err != nil {
 			printToBoth(writer, fmt.Sprintf("Error parsing selector for policy %s: %s\n", policy.Name, err))
 			continue
 		}
-		pods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
-		if err != nil {
-			printToBoth(writer, fmt.Sprintf("Error listing pods for policy %s: %s\n", policy.Name, err))
-			continue
+		selectsPods := false
+		for _, pod := range pods {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				coveredPods[pod.Name] = true
+				selectsPods = true
+			}
 		}
-		for _, pod := range pods.Items {
-			coveredPods[pod.Name] = true
+		if !selectsPods {
+			scanResult.PoliciesSelectingNothing = append(scanResult.PoliciesSelectingNothing, nsName+"/"+policy.Name)
 		}
 	}
-	return coveredPods, nil
+	return coveredPods
 }
 
-// Fetches all pods in a namespace and determines which are unprotected
-func determineUnprotectedPods(clientset *kubernetes.Clientset, nsName string, coveredPods map[string]bool, writer *bufio.Writer, scanResult *ScanResult) ([]string, error) {
+// Determines which pods of a namespace are unprotected
+func determineUnprotectedPods(pods []v1.Pod, nsName string, coveredPods map[string]bool, scanResult *ScanResult) []string {
 	unprotectedPods := []string{}
-	allPods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		printToBoth(writer, fmt.Sprintf("Error listing all pods in namespace %s: %s\n", nsName, err))
-		return nil, fmt.Errorf("error listing all pods: %w", err)
-	}
-
-	for _, pod := range allPods.Items {
-		// Skip pods that are not in running state
-		if pod.Status.Phase != v1.PodRunning {
-			continue
-		}
+	for _, pod := range scannedPods(pods) {
 		if !coveredPods[pod.Name] {
 			podDetail := fmt.Sprintf("%s %s %s", nsName, pod.Name, pod.Status.PodIP)
 			if !containsPodDetail(scanResult.UnprotectedPods, podDetail) {
@@ -174,7 +209,20 @@ func determineUnprotectedPods(clientset *kubernetes.Clientset, nsName string, co
 			}
 		}
 	}
-	return unprotectedPods, nil
+	return unprotectedPods
+}
+
+// Evaluates the ingress and egress rules of every network policy, and of the cluster admin policies, against the running pods of a namespace
+func evaluateNamespacePolicies(namespace v1.Namespace, policies []networkingv1.NetworkPolicy, pods []v1.Pod, adminModels []PolicyModel, writer *bufio.Writer) []PodPolicyEvaluation {
+	models, errs := NativePolicyModels(policies)
+	for _, err := range errs {
+		printToBoth(writer, fmt.Sprintf("Error evaluating policy: %s\n", err))
+	}
+	models = append(models, adminModels...)
+
+	evaluations := EvaluatePods(scannedPods(pods), []v1.Namespace{namespace}, models)
+	sortEvaluations(evaluations)
+	return evaluations
 }
 
 // This function just displays unprotected pods without prompting for any actions
@@ -224,21 +272,48 @@ func handleCLIInteractions(nsName string, unprotectedPods []string, writer *bufi
 	}
 }
 
-func processNamespacePolicies(clientset *kubernetes.Clientset, nsName string, writer *bufio.Writer, isCLI bool, dryRun bool, scanResult *ScanResult, kubeconfigPath string) error {
-	// Fetch covered pods
-	coveredPods, err := fetchCoveredPods(clientset, nsName, writer)
+func processNamespacePolicies(clientset kubernetes.Interface, nsName string, adminModels []PolicyModel, writer *bufio.Writer, isCLI bool, dryRun bool, scanResult *ScanResult, kubeconfigPath string) error {
+	// List the policies and pods of the namespace once, every selector is evaluated against these lists
+	policies, err := clientset.NetworkingV1().NetworkPolicies(nsName).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		return fmt.Errorf("fetching covered pods failed for namespace %s: %w", nsName, err)
+		printToBoth(writer, fmt.Sprintf("\nError listing network policies in namespace %s: %s\n", nsName, err))
+		return fmt.Errorf("error listing network policies in namespace %s: %w", nsName, err)
 	}
-
-	// Determine unprotected pods
-	unprotectedPods, err := determineUnprotectedPods(clientset, nsName, coveredPods, writer, scanResult)
+	pods, err := clientset.CoreV1().Pods(nsName).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		return fmt.Errorf("determining unprotected pods failed for namespace %s: %w", nsName, err)
+		printToBoth(writer, fmt.Sprintf("Error listing all pods in namespace %s: %s\n", nsName, err))
+		return fmt.Errorf("error listing all pods in namespace %s: %w", nsName, err)
+	}
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.TODO(), nsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting namespace %s: %w", nsName, err)
+	}
+
+	coveredPods := findCoveredPods(policies.Items, pods.Items, nsName, writer, scanResult)
+
+	// Evaluate ingress and egress isolation of every pod in the namespace
+	evaluations := evaluateNamespacePolicies(*namespace, policies.Items, pods.Items, adminModels, writer)
+
+	// Pods isolated by admin policies are covered even without a NetworkPolicy selecting them
+	if len(adminModels) > 0 {
+		for _, evaluation := range evaluations {
+			if !evaluation.HasFinding(FindingUnprotected) {
+				coveredPods[evaluation.Name] = true
+			}
+		}
+		if adminNamespaceHasDefaultDeny(adminModels, *namespace) && !contains(scanResult.HasDenyAll, nsName) {
+			scanResult.HasDenyAll = append(scanResult.HasDenyAll, nsName)
+		}
 	}
 
+	// Determine unprotected pods
+	unprotectedPods := determineUnprotectedPods(pods.Items, nsName, coveredPods, scanResult)
+	unprotectedPods = acceptUnprotectedPods(unprotectedPods, scanResult)
+
 	// Always add pods to result for visibility
 	scanResult.UnprotectedPods = append(scanResult.UnprotectedPods, unprotectedPods...)
+	acceptPodFindings(evaluations, scanResult)
+	scanResult.PodEvaluations = append(scanResult.PodEvaluations, evaluations...)
 	scanResult.DeniedNamespaces = append(scanResult.DeniedNamespaces, nsName)
 
 	// Only handle CLI interactions if it's CLI mode and not a dry run
@@ -248,19 +323,19 @@ func processNamespacePolicies(clientset *kubernetes.Clientset, nsName string, wr
 		// If it's a dry run, we just display the data without prompting for any actions
 		displayUnprotectedPods(nsName, unprotectedPods, writer)
 	}
+	if isCLI {
+		displayPodExposure(nsName, evaluations, writer)
+	}
 
 	return nil
 }
 
-var hasStartedNativeScan bool = false
-
 // ScanNetworkPolicies scans namespaces for network policies
 func ScanNetworkPolicies(specificNamespace string, dryRun bool, returnResult bool, isCLI bool, printScore bool, printMessages bool, kubeconfigPath string) (*ScanResult, error) {
 	var output bytes.Buffer
 	var namespacesToScan []string
 
-	unprotectedPodsCount := 0
-	scanResult := new(ScanResult)
+	scanResult := &ScanResult{PolicyType: PolicyTypeKubernetes}
 
 	writer := bufio.NewWriter(&output)
 
@@ -273,24 +348,39 @@ func ScanNetworkPolicies(specificNamespace string, dryRun bool, returnResult boo
 	if err != nil {
 		return nil, err
 	}
+	scanResult.NamespacesScanned = namespacesToScan
+	if err := useWaivers(clientset, scanResult); err != nil {
+		printToBoth(writer, fmt.Sprintf("Error loading waivers: %s\n", err))
+	}
 
-	missingPoliciesOrUncoveredPods := false
-	userDeniedPolicyApplication := false
 	deniedNamespaces := []string{}
 
-	if isCLI && !hasStartedNativeScan {
+	if isCLI {
 		fmt.Println("Policy type: Kubernetes")
-		hasStartedNativeScan = true
+	}
+
+	// Cluster admin policies are evaluated together with the network policies of every namespace
+	var adminModels []PolicyModel
+	if dynamicClient, err := GetDynamicClient(kubeconfigPath); err == nil {
+		models, errs, err := LoadAdminPolicyModels(dynamicClient)
+		if err != nil {
+			printToBoth(writer, fmt.Sprintf("Error loading admin network policies: %s\n", err))
+		}
+		for _, err := range errs {
+			printToBoth(writer, fmt.Sprintf("Error evaluating policy: %s\n", err))
+		}
+		adminModels = models
+	}
+	if isCLI && len(adminModels) > 0 {
+		fmt.Printf("Including %d AdminNetworkPolicies and BaselineAdminNetworkPolicies\n", len(adminModels))
 	}
 
 	for _, nsName := range namespacesToScan {
-		err := processNamespacePolicies(clientset, nsName, writer, isCLI, dryRun, scanResult, kubeconfigPath)
+		err := processNamespacePolicies(clientset, nsName, adminModels, writer, isCLI, dryRun, scanResult, kubeconfigPath)
 		if err != nil {
 			fmt.Printf("Error processing namespace %s: %v\n", nsName, err)
 			continue
 		}
-		unprotectedPodsCount += len(scanResult.UnprotectedPods)
-
 		// Check if namespace is already marked as denied
 		if !contains(deniedNamespaces, nsName) {
 			deniedNamespaces = append(deniedNamespaces, nsName)
@@ -298,28 +388,24 @@ func ScanNetworkPolicies(specificNamespace string, dryRun bool, returnResult boo
 	}
 
 	writer.Flush()
-	if output.Len() > 0 {
+	if isCLI && output.Len() > 0 {
 		handleOutputAndPrompts(writer, &output)
 	}
 
-	score := CalculateScore(!missingPoliciesOrUncoveredPods, !userDeniedPolicyApplication, unprotectedPodsCount)
-	scanResult.Score = score
-
 	if printMessages {
 		printToBoth(writer, "\nNetfetch scan completed!\n")
 	}
 
-	if printScore {
-		// Print the final score
-		fmt.Printf("\nYour Netfetch security score is: %d/100\n", score)
-	}
+	scoreResult(scanResult, printScore)
 
-	hasStartedNativeScan = false
 	return scanResult, nil
 }
 
 // handleOutputAndPrompts manages saving scan results to a file and outputting
 func handleOutputAndPrompts(writer *bufio.Writer, output *bytes.Buffer) {
+	if !interactive {
+		return
+	}
 	saveToFile := false
 	prompt := &survey.Confirm{
 		Message: "Do you want to save the output to netfetch.txt?",
@@ -380,48 +466,50 @@ func hasDefaultDenyAllPolicy(policies []networkingv1.NetworkPolicy) bool {
 
 // isDefaultDenyAllPolicy checks if a single network policy is a default deny all policy
 func isDefaultDenyAllPolicy(policy networkingv1.NetworkPolicy) bool {
-	return len(policy.Spec.Ingress) == 0 && len(policy.Spec.Egress) == 0
+	selectsAllPods := len(policy.Spec.PodSelector.MatchLabels) == 0 && len(policy.Spec.PodSelector.MatchExpressions) == 0
+	return selectsAllPods && len(policy.Spec.Ingress) == 0 && len(policy.Spec.Egress) == 0
 }
 
-// isSystemNamespace checks if the given namespace is a system namespace
+// IsSystemNamespace checks if the given namespace is one of the DefaultExcludedNamespaces. The scanners decide which
+// namespaces to scan with the active configuration instead.
 func IsSystemNamespace(namespace string) bool {
-	switch namespace {
-	case "kube-system", "tigera-operator", "kube-public", "kube-node-lease", "gatekeeper-system", "calico-system":
-		return true
-	default:
-		return false
-	}
+	return contains(DefaultExcludedNamespaces, namespace)
 }
 
-// Scoring logic
-func CalculateScore(hasPolicies bool, hasDenyAll bool, unprotectedPodsCount int) int {
-    score := 50 // Start with a base score of 50
-
-    // if hasDenyAll {
-    //     score += 20 // Add 20 points for having deny-all policies
-    // } else if !hasPolicies {
-    //     score -= 20 // Subtract 20 points if there are no policies at all
-    // }
+var (
+	isClientInitialized = false
+	clientset           kubernetes.Interface
+	// sharedDynamicClient is returned by GetDynamicClient when scanning manifests or serving from the cluster cache
+	sharedDynamicClient dynamic.Interface
+)
 
-    // Deduct score based on the number of unprotected pods
-    score -= unprotectedPodsCount
+// GetDynamicClient returns a dynamic interface to query the policies of every engine. It uses the provided
+// kubeconfig, the in-cluster configuration or the default kubeconfig, in that order.
+func GetDynamicClient(kubeconfigPath string) (dynamic.Interface, error) {
+	if sharedDynamicClient != nil {
+		return sharedDynamicClient, nil
+	}
 
-    if score > 100 {
-        score = 100
-    } else if score < 1 {
-        score = 1
-    }
+	var config *rest.Config
+	var err error
+	if kubeconfigPath != "" {
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+	} else if config, err = rest.InClusterConfig(); err != nil {
+		kubeconfigPath = os.Getenv("KUBECONFIG")
+		if kubeconfigPath == "" {
+			kubeconfigPath = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+		}
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create k8s client config: %s", err)
+	}
 
-    return score
+	return dynamic.NewForConfig(config)
 }
 
-var (
-	isClientInitialized = false
-	clientset           *kubernetes.Clientset
-)
-
 // GetClientset creates a new Kubernetes clientset
-func GetClientset(kubeconfigPath string) (*kubernetes.Clientset, error) {
+func GetClientset(kubeconfigPath string) (kubernetes.Interface, error) {
 	if isClientInitialized {
 		return clientset, nil
 	}
@@ -1,6 +1,8 @@
 package k8s
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"testing"
 
@@ -78,32 +80,6 @@ func TestIsSystemNamespace(t *testing.T) {
 	}
 }
 
-func TestCalculateScore(t *testing.T) {
-    // Test case 1: Policies exist, deny all exists, no unprotected pods
-    score1 := CalculateScore(true, true, 0)
-    if score1 != 70 { // 50 base + 20 for deny-all
-        t.Fatalf("Expected score to be 70, got %d", score1)
-    }
-
-    // Test case 2: No policies, no deny all, 5 unprotected pods
-    score2 := CalculateScore(false, false, 5)
-    if score2 != 25 { // 50 base - 20 for no policies - 5 for unprotected pods
-        t.Fatalf("Expected score to be 25, got %d", score2)
-    }
-
-    // Test case 3: No policies, no deny all, no unprotected pods
-    score3 := CalculateScore(false, false, 0)
-    if score3 != 30 { // 50 base - 20 for no policies
-        t.Fatalf("Expected score to be 30, got %d", score3)
-    }
-
-    // Test case 4: Policies exist, deny all exists, no unprotected pods (repeat of case 1 for consistency)
-    score4 := CalculateScore(true, true, 0)
-    if score4 != 70 {
-        t.Fatalf("Expected score to be 70, got %d", score4)
-    }
-}
-
 func TestGetPodInfo(t *testing.T) {
     var clientset kubernetes.Interface = fake.NewSimpleClientset()
 
@@ -186,4 +162,28 @@ spec:
 	}
 
 	assert.Equal(t, expectedNetworkPolicy, actualNetworkPolicy, "they should be equal")
-}
\ No newline at end of file
+}
+
+func TestProcessNamespacePoliciesListsOnce(t *testing.T) {
+	web := testPod("shop", "web-0", "10.0.0.1", map[string]string{"app": "web"})
+	db := testPod("shop", "db-0", "10.0.0.2", map[string]string{"app": "db"})
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		&web, &db,
+		&netv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}, Spec: netv1.NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}},
+		&netv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "batch", Namespace: "shop"}, Spec: netv1.NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "batch"}}}},
+	)
+
+	var output bytes.Buffer
+	scanResult := &ScanResult{}
+	assert.NoError(t, processNamespacePolicies(clientset, "shop", nil, bufio.NewWriter(&output), false, false, scanResult, ""))
+	assert.Equal(t, []string{"shop db-0 10.0.0.2"}, scanResult.UnprotectedPods)
+	assert.Equal(t, []string{"shop/batch"}, scanResult.PoliciesSelectingNothing)
+
+	// The selectors of every policy are matched against a single list of the pods
+	calls := map[string]int{}
+	for _, action := range clientset.Actions() {
+		calls[action.GetVerb()+" "+action.GetResource().Resource]++
+	}
+	assert.Equal(t, map[string]int{"list networkpolicies": 1, "list pods": 1, "get namespaces": 1}, calls)
+}
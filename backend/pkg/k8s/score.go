@@ -0,0 +1,216 @@
+package k8s
+
+import (
+	"fmt"
+	"math"
+)
+
+// Parts of the Netfetch score. Each part is worth the share of 100 points given by its weight, and a scan loses
+// the fraction of those points that matches the fraction of pods, or namespaces, failing the part:
+//
+//   - ingressIsolation: pods that no policy isolates for ingress
+//   - egressIsolation: pods that no policy isolates for egress
+//   - defaultDeny: namespaces without a default deny policy
+//   - broadRules: pods allowed traffic by an overly broad rule, open to every peer, the internet or every namespace
+//
+// Findings that are waived or suppressed do not count. A namespace without pods only loses the defaultDeny points.
+const (
+	ScoreIngressIsolation = "ingressIsolation"
+	ScoreEgressIsolation  = "egressIsolation"
+	ScoreDefaultDeny      = "defaultDeny"
+	ScoreBroadRules       = "broadRules"
+)
+
+// ScoreWeights are the relative weights of the parts of the score. Only their ratio matters, a part with a weight
+// of 0 is left out of the score.
+type ScoreWeights struct {
+	IngressIsolation int `json:"ingressIsolation" yaml:"ingressIsolation"`
+	EgressIsolation  int `json:"egressIsolation" yaml:"egressIsolation"`
+	DefaultDeny      int `json:"defaultDeny" yaml:"defaultDeny"`
+	BroadRules       int `json:"broadRules" yaml:"broadRules"`
+}
+
+// DefaultScoreWeights are the weights used unless the configuration sets others.
+var DefaultScoreWeights = ScoreWeights{IngressIsolation: 40, EgressIsolation: 30, DefaultDeny: 20, BroadRules: 10}
+
+// ScoreDeduction is the number of points a part of the score cost, and why.
+type ScoreDeduction struct {
+	Part   string  `json:"part" yaml:"part"`
+	Points float64 `json:"points" yaml:"points"`
+	Reason string  `json:"reason" yaml:"reason"`
+}
+
+// NamespaceScore is the score of a single namespace.
+type NamespaceScore struct {
+	Namespace  string           `json:"namespace" yaml:"namespace"`
+	Score      int              `json:"score" yaml:"score"`
+	Pods       int              `json:"pods" yaml:"pods"`
+	Deductions []ScoreDeduction `json:"deductions" yaml:"deductions"`
+}
+
+// ScoreBreakdown explains the score of a scan. The deductions of the scan are computed over all scanned pods and
+// namespaces, so a namespace with many pods weighs more than one with a few.
+type ScoreBreakdown struct {
+	Weights    ScoreWeights     `json:"weights" yaml:"weights"`
+	Pods       int              `json:"pods" yaml:"pods"`
+	Deductions []ScoreDeduction `json:"deductions" yaml:"deductions"`
+	Namespaces []NamespaceScore `json:"namespaces" yaml:"namespaces"`
+}
+
+// scoreTally counts the pods and namespaces failing each part of the score. The namespace is set when the tally
+// covers a single namespace.
+type scoreTally struct {
+	namespace          string
+	pods               int
+	ingressOpen        int
+	egressOpen         int
+	broadRules         int
+	namespaces         int
+	withoutDefaultDeny int
+}
+
+// CalculateScore scores the scan result with the weights of the active configuration, per namespace and overall.
+func CalculateScore(result *ScanResult) (int, ScoreBreakdown) {
+	return activeConfig.Score.Weights.Score(result)
+}
+
+// Score scores the scan result with the weights, per namespace and overall.
+func (w ScoreWeights) Score(result *ScanResult) (int, ScoreBreakdown) {
+	breakdown := ScoreBreakdown{Weights: w, Deductions: []ScoreDeduction{}, Namespaces: []NamespaceScore{}}
+	total := scoreTally{}
+	tallies := map[string]*scoreTally{}
+	var namespaces []string
+	addNamespace := func(namespace string) *scoreTally {
+		if tallies[namespace] == nil {
+			tallies[namespace] = &scoreTally{namespace: namespace, namespaces: 1}
+			namespaces = append(namespaces, namespace)
+		}
+		return tallies[namespace]
+	}
+
+	for _, namespace := range result.NamespacesScanned {
+		if namespace != "cluster-wide" {
+			addNamespace(namespace)
+		}
+	}
+	for _, evaluation := range result.PodEvaluations {
+		ingressOpen, egressOpen, broadRules := scorePod(evaluation)
+		for _, tally := range []*scoreTally{addNamespace(evaluation.Namespace), &total} {
+			tally.pods++
+			tally.ingressOpen += boolCount(ingressOpen)
+			tally.egressOpen += boolCount(egressOpen)
+			tally.broadRules += boolCount(broadRules)
+		}
+	}
+
+	// A namespace without default deny only counts when its finding is neither waived nor suppressed
+	accepted := &ScanResult{waivers: result.waivers}
+	for _, namespace := range namespaces {
+		tally := tallies[namespace]
+		total.namespaces++
+		if !contains(result.HasDenyAll, namespace) && !acceptFinding(accepted, RuleNamespaceWithoutDenyAll, namespace, namespace, "") {
+			tally.withoutDefaultDeny = 1
+			total.withoutDefaultDeny++
+		}
+
+		score, deductions := w.deduct(*tally)
+		breakdown.Namespaces = append(breakdown.Namespaces, NamespaceScore{Namespace: namespace, Score: score, Pods: tally.pods, Deductions: deductions})
+	}
+
+	if len(namespaces) == 1 {
+		total.namespace = namespaces[0]
+	}
+	score, deductions := w.deduct(total)
+	breakdown.Pods = total.pods
+	breakdown.Deductions = deductions
+	return score, breakdown
+}
+
+// deduct computes the deductions of the tally and the score left after them.
+func (w ScoreWeights) deduct(tally scoreTally) (int, []ScoreDeduction) {
+	deductions := []ScoreDeduction{}
+	weight := w.IngressIsolation + w.EgressIsolation + w.DefaultDeny + w.BroadRules
+	if weight <= 0 {
+		return 100, deductions
+	}
+
+	lost := 0.0
+	deduct := func(part string, partWeight int, failing int, outOf int, reason string) {
+		if failing == 0 || outOf == 0 || partWeight == 0 {
+			return
+		}
+		points := 100 * float64(partWeight) / float64(weight) * float64(failing) / float64(outOf)
+		lost += points
+		deductions = append(deductions, ScoreDeduction{Part: part, Points: math.Round(points*10) / 10, Reason: reason})
+	}
+
+	deduct(ScoreIngressIsolation, w.IngressIsolation, tally.ingressOpen, tally.pods,
+		fmt.Sprintf("%d of %d pods are not isolated for ingress", tally.ingressOpen, tally.pods))
+	deduct(ScoreEgressIsolation, w.EgressIsolation, tally.egressOpen, tally.pods,
+		fmt.Sprintf("%d of %d pods are not isolated for egress", tally.egressOpen, tally.pods))
+	defaultDenyReason := fmt.Sprintf("%d of %d namespaces have no default deny policy", tally.withoutDefaultDeny, tally.namespaces)
+	if tally.namespace != "" {
+		defaultDenyReason = fmt.Sprintf("namespace %s has no default deny policy", tally.namespace)
+	}
+	deduct(ScoreDefaultDeny, w.DefaultDeny, tally.withoutDefaultDeny, tally.namespaces, defaultDenyReason)
+	deduct(ScoreBroadRules, w.BroadRules, tally.broadRules, tally.pods,
+		fmt.Sprintf("%d of %d pods are allowed traffic by overly broad rules", tally.broadRules, tally.pods))
+
+	score := int(math.Round(100 - lost))
+	if score < 0 {
+		score = 0
+	}
+	return score, deductions
+}
+
+// scorePod reports which parts of the score the remaining findings of the pod fail.
+func scorePod(evaluation PodPolicyEvaluation) (ingressOpen bool, egressOpen bool, broadRules bool) {
+	for _, finding := range evaluation.Findings {
+		switch finding {
+		case FindingUnprotected:
+			ingressOpen, egressOpen = true, true
+		case FindingEgressOnlyIsolated:
+			ingressOpen = true
+		case FindingIngressOnlyIsolated:
+			egressOpen = true
+		case FindingIngressOpenToAll, FindingEgressOpenToAll, FindingIngressOpenToWorld, FindingEgressOpenToWorld,
+			FindingIngressFromAllNamespaces, FindingEgressToAllNamespaces:
+			broadRules = true
+		}
+	}
+	return ingressOpen, egressOpen, broadRules
+}
+
+// boolCount returns 1 for true and 0 for false.
+func boolCount(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// scoreResult scores the scan result and prints the score and its deductions when printScore is set.
+func scoreResult(scanResult *ScanResult, printScore bool) {
+	score, breakdown := CalculateScore(scanResult)
+	scanResult.Score, scanResult.ScoreBreakdown = score, &breakdown
+	if !printScore {
+		return
+	}
+
+	fmt.Printf("\nYour Netfetch security score is: %d/100\n", scanResult.Score)
+	printDeductions(scanResult.ScoreBreakdown.Deductions, "  ")
+	if len(scanResult.ScoreBreakdown.Namespaces) > 1 {
+		fmt.Println("\nScore per namespace:")
+		for _, namespace := range scanResult.ScoreBreakdown.Namespaces {
+			fmt.Printf("  %s: %d/100\n", namespace.Namespace, namespace.Score)
+			printDeductions(namespace.Deductions, "    ")
+		}
+	}
+}
+
+// printDeductions prints a line per deduction.
+func printDeductions(deductions []ScoreDeduction, indent string) {
+	for _, deduction := range deductions {
+		fmt.Printf("%s-%.1f %s: %s\n", indent, deduction.Points, deduction.Part, deduction.Reason)
+	}
+}
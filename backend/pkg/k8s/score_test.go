@@ -0,0 +1,100 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scoreTestResult() *ScanResult {
+	return &ScanResult{
+		PolicyType:        PolicyTypeKubernetes,
+		NamespacesScanned: []string{"shop", "web"},
+		HasDenyAll:        []string{"web"},
+		PodEvaluations: []PodPolicyEvaluation{
+			{Namespace: "shop", Name: "web-0", Findings: []string{FindingUnprotected}},
+			{Namespace: "shop", Name: "web-1", Findings: []string{FindingUnprotected}},
+			{Namespace: "shop", Name: "db-0", Findings: []string{FindingIngressOnlyIsolated, FindingIngressFromAllNamespaces}},
+			{Namespace: "web", Name: "frontend-0", Findings: []string{}},
+		},
+	}
+}
+
+func TestCalculateScore(t *testing.T) {
+	score, breakdown := CalculateScore(scoreTestResult())
+
+	assert.Equal(t, 45, score)
+	assert.Equal(t, DefaultScoreWeights, breakdown.Weights)
+	assert.Equal(t, 4, breakdown.Pods)
+	assert.Equal(t, []ScoreDeduction{
+		{Part: ScoreIngressIsolation, Points: 20, Reason: "2 of 4 pods are not isolated for ingress"},
+		{Part: ScoreEgressIsolation, Points: 22.5, Reason: "3 of 4 pods are not isolated for egress"},
+		{Part: ScoreDefaultDeny, Points: 10, Reason: "1 of 2 namespaces have no default deny policy"},
+		{Part: ScoreBroadRules, Points: 2.5, Reason: "1 of 4 pods are allowed traffic by overly broad rules"},
+	}, breakdown.Deductions)
+
+	assert.Equal(t, []NamespaceScore{
+		{Namespace: "shop", Score: 20, Pods: 3, Deductions: []ScoreDeduction{
+			{Part: ScoreIngressIsolation, Points: 26.7, Reason: "2 of 3 pods are not isolated for ingress"},
+			{Part: ScoreEgressIsolation, Points: 30, Reason: "3 of 3 pods are not isolated for egress"},
+			{Part: ScoreDefaultDeny, Points: 20, Reason: "namespace shop has no default deny policy"},
+			{Part: ScoreBroadRules, Points: 3.3, Reason: "1 of 3 pods are allowed traffic by overly broad rules"},
+		}},
+		{Namespace: "web", Score: 100, Pods: 1, Deductions: []ScoreDeduction{}},
+	}, breakdown.Namespaces)
+}
+
+func TestScoreWeights(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  ScoreWeights
+		expected int
+	}{
+		{name: "Default deny only", weights: ScoreWeights{DefaultDeny: 1}, expected: 50},
+		{name: "Ingress isolation only", weights: ScoreWeights{IngressIsolation: 5}, expected: 50},
+		{name: "Egress and broad rules", weights: ScoreWeights{EgressIsolation: 1, BroadRules: 1}, expected: 50},
+		{name: "No weights", weights: ScoreWeights{}, expected: 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score, _ := test.weights.Score(scoreTestResult())
+			assert.Equal(t, test.expected, score)
+		})
+	}
+
+	config, err := ParseConfig([]byte("score:\n  weights:\n    broadRules: 0\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, ScoreWeights{IngressIsolation: 40, EgressIsolation: 30, DefaultDeny: 20}, config.Score.Weights)
+
+	_, err = ParseConfig([]byte("score:\n  weights:\n    defaultDeny: -1\n"))
+	assert.Error(t, err)
+
+	_, err = ParseConfig([]byte("score:\n  weights:\n    ingressIsolation: 0\n    egressIsolation: 0\n    defaultDeny: 0\n    broadRules: 0\n"))
+	assert.EqualError(t, err, "score: at least one weight must be greater than 0")
+}
+
+func TestScoreIgnoresAcceptedFindings(t *testing.T) {
+	config, err := ParseConfig([]byte(`
+suppressions:
+  - rule: NETFETCH002
+    namespace: shop
+    expires: 2099-01-01
+    justification: Shared namespace
+`))
+	assert.NoError(t, err)
+	UseConfig(config)
+	defer UseConfig(nil)
+
+	// The scanners remove waived findings from the evaluations
+	result := scoreTestResult()
+	result.PodEvaluations[0].Findings = []string{}
+	result.PodEvaluations[1].Findings = []string{}
+
+	score, breakdown := CalculateScore(result)
+	assert.Equal(t, 90, score)
+	assert.Equal(t, []ScoreDeduction{
+		{Part: ScoreEgressIsolation, Points: 10, Reason: "1 of 3 pods are not isolated for egress"},
+		{Part: ScoreBroadRules, Points: 3.3, Reason: "1 of 3 pods are allowed traffic by overly broad rules"},
+	}, breakdown.Namespaces[0].Deductions)
+}
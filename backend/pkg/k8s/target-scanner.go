@@ -3,8 +3,8 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"regexp"
 
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
@@ -14,7 +14,7 @@ import (
 )
 
 // FindNativeNetworkPolicyByName searches for a specific native network policy by name across all non-system namespaces.
-func FindNativeNetworkPolicyByName(dynamicClient dynamic.Interface, clientset *kubernetes.Clientset, policyName string) (*unstructured.Unstructured, string, error) {
+func FindNativeNetworkPolicyByName(dynamicClient dynamic.Interface, clientset kubernetes.Interface, policyName string) (*unstructured.Unstructured, string, error) {
 	gvr := schema.GroupVersionResource{
 		Group:    "networking.k8s.io",
 		Version:  "v1",
@@ -72,6 +72,16 @@ func FindCiliumClusterWideNetworkPolicyByName(dynamicClient dynamic.Interface, p
     return policy, nil
 }
 
+// FindAdminNetworkPolicyByName searches for an AdminNetworkPolicy by name, and for a BaselineAdminNetworkPolicy if none is found.
+func FindAdminNetworkPolicyByName(dynamicClient dynamic.Interface, policyName string) (*unstructured.Unstructured, error) {
+	for _, gvr := range []schema.GroupVersionResource{adminNetworkPolicyResource, baselineAdminNetworkPolicyResource} {
+		policy, err := dynamicClient.Resource(gvr).Get(context.TODO(), policyName, v1.GetOptions{})
+		if err == nil {
+			return policy, nil
+		}
+	}
+	return nil, fmt.Errorf("admin network policy %s not found", policyName)
+}
 
 // GetAllNonSystemNamespaces returns a list of all non-system namespaces using a dynamic client.
 func GetAllNonSystemNamespaces(dynamicClient dynamic.Interface) ([]string, error) {
@@ -88,7 +98,7 @@ func GetAllNonSystemNamespaces(dynamicClient dynamic.Interface) ([]string, error
 
 	var namespaces []string
 	for _, ns := range namespacesList.Items {
-		if !IsSystemNamespace(ns.GetName()) {
+		if activeConfig.IncludesNamespace(ns.GetName(), ns.GetLabels()) {
 			namespaces = append(namespaces, ns.GetName())
 		}
 	}
@@ -135,32 +145,20 @@ func ListPodsTargetedByNetworkPolicy(dynamicClient dynamic.Interface, policy *un
 
 // ListPodsTargetedByCiliumNetworkPolicy lists all pods targeted by the given Cilium network policy in the specified namespace.
 func ListPodsTargetedByCiliumNetworkPolicy(dynamicClient dynamic.Interface, policy *unstructured.Unstructured, namespace string) ([][]string, error) {
-    // Retrieve the PodSelector (matchLabels)
-    podSelector, found, err := unstructured.NestedMap(policy.Object, "spec", "endpointSelector", "matchLabels")
+    // Retrieve the endpointSelector (matchLabels and matchExpressions)
+    endpointSelector, _, err := unstructured.NestedMap(policy.Object, "spec", "endpointSelector")
     if err != nil {
         return nil, fmt.Errorf("failed to retrieve pod selector from Cilium network policy %s: %v", policy.GetName(), err)
     }
 
-    // Check if the selector is empty
-    selector := make(labels.Set)
-    if found && len(podSelector) > 0 {
-        for key, value := range podSelector {
-            if strValue, ok := value.(string); ok {
-                selector[key] = strValue
-            } else {
-                return nil, fmt.Errorf("invalid type for selector value %v in policy %s", value, policy.GetName())
-            }
-        }
-    }
-
-    // Fetch pods based on the selector
-    pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), v1.ListOptions{LabelSelector: selector.AsSelectorPreValidated().String()})
+    // Match the pods of the namespace against the selector, an empty selector targets every pod
+    pods, err := podsSelectedByEndpointSelector(clientset, namespace, endpointSelector)
     if err != nil {
-        return nil, fmt.Errorf("error listing pods in namespace %s: %v", namespace, err)
+        return nil, fmt.Errorf("error listing pods targeted by policy %s: %v", policy.GetName(), err)
     }
 
     var targetedPods [][]string
-    for _, pod := range pods.Items {
+    for _, pod := range pods {
         targetedPods = append(targetedPods, []string{namespace, pod.Name, pod.Status.PodIP})
     }
 
@@ -168,46 +166,80 @@ func ListPodsTargetedByCiliumNetworkPolicy(dynamicClient dynamic.Interface, poli
 }
 
 // ListPodsTargetedByCiliumClusterWideNetworkPolicy lists all pods targeted by the given Cilium cluster wide network policy.
-func ListPodsTargetedByCiliumClusterWideNetworkPolicy(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, policy *unstructured.Unstructured) ([][]string, error) {
-    // Retrieve the PodSelector (matchLabels)
-    podSelector, found, err := unstructured.NestedMap(policy.Object, "spec", "endpointSelector", "matchLabels")
+func ListPodsTargetedByCiliumClusterWideNetworkPolicy(clientset kubernetes.Interface, dynamicClient dynamic.Interface, policy *unstructured.Unstructured) ([][]string, error) {
+    // Retrieve the endpointSelector (matchLabels and matchExpressions)
+    endpointSelector, _, err := unstructured.NestedMap(policy.Object, "spec", "endpointSelector")
     if err != nil {
         return nil, fmt.Errorf("failed to retrieve pod selector from Cilium cluster wide network policy %s: %v", policy.GetName(), err)
     }
 
-    // Regex for valid Kubernetes label keys
-    validLabelKey := regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_.]*[A-Za-z0-9]$`)
-
-    // Check if the selector is empty
-    selector := labels.Set{}
-    if found && len(podSelector) > 0 {
-        for key, value := range podSelector {
-            // Skip reserved labels
-            if !validLabelKey.MatchString(key) {
-                fmt.Printf("Skipping reserved label key %s in policy %s\n", key, policy.GetName())
-                continue
-            }
-            if strValue, ok := value.(string); ok {
-                selector[key] = strValue
-            } else {
-                return nil, fmt.Errorf("invalid type for selector value %v in policy %s", value, policy.GetName())
-            }
-        }
-    }
-
-    // Fetch pods based on the selector across all namespaces
-    pods, err := clientset.CoreV1().Pods("").List(context.TODO(), v1.ListOptions{
-        LabelSelector: selector.AsSelector().String(),
-    })
+    // Match pods across all namespaces, including selectors on the namespace and reserved labels
+    pods, err := podsSelectedByEndpointSelector(clientset, "", endpointSelector)
     if err != nil {
         return nil, fmt.Errorf("error listing pods for cluster wide policy: %v", err)
     }
 
     var targetedPods [][]string
-    for _, pod := range pods.Items {
+    for _, pod := range pods {
         podDetails := []string{pod.Namespace, pod.Name, pod.Status.PodIP}
         targetedPods = append(targetedPods, podDetails)
     }
 
     return targetedPods, nil
-}
\ No newline at end of file
+}
+
+// podsSelectedByEndpointSelector returns the pods in a namespace, or in all namespaces if namespace is empty,
+// whose Cilium endpoint labels match the endpoint selector.
+func podsSelectedByEndpointSelector(clientset kubernetes.Interface, namespace string, endpointSelector map[string]interface{}) ([]corev1.Pod, error) {
+    selector, err := ciliumSelector(endpointSelector)
+    if err != nil {
+        return nil, err
+    }
+
+    pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), v1.ListOptions{})
+    if err != nil {
+        return nil, err
+    }
+    namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), v1.ListOptions{})
+    if err != nil {
+        return nil, err
+    }
+    namespaceLabels := make(map[string]map[string]string, len(namespaces.Items))
+    for _, ns := range namespaces.Items {
+        namespaceLabels[ns.Name] = ns.Labels
+    }
+
+    var selected []corev1.Pod
+    for _, pod := range pods.Items {
+        if selector.Matches(labels.Set(ciliumEndpointLabels(pod, namespaceLabels[pod.Namespace]))) {
+            selected = append(selected, pod)
+        }
+    }
+    return selected, nil
+}
+
+// ListPodsTargetedByAdminNetworkPolicy lists all running pods selected by the subject of an AdminNetworkPolicy or BaselineAdminNetworkPolicy.
+func ListPodsTargetedByAdminNetworkPolicy(clientset kubernetes.Interface, policy *unstructured.Unstructured) ([][]string, error) {
+	model, err := AdminPolicyModel(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods for admin network policy: %v", err)
+	}
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %v", err)
+	}
+	namespaceLabels := NamespaceLabels(namespaces.Items)
+
+	var targetedPods [][]string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && !activeConfig.ExcludesPod(pod) && model.Selects(pod, namespaceLabels[pod.Namespace]) {
+			targetedPods = append(targetedPods, []string{pod.Namespace, pod.Name, pod.Status.PodIP})
+		}
+	}
+	return targetedPods, nil
+}
@@ -0,0 +1,59 @@
+package k8s
+
+import "fmt"
+
+// Thresholds are the limits enforced on scan results in CI mode.
+// A FailUnderScore of 0 and a negative MaxUnprotected disable the respective check.
+type Thresholds struct {
+	FailUnderScore int
+	MaxUnprotected int
+}
+
+// CheckThresholds returns a message for every threshold breached by the scan results in the document.
+func CheckThresholds(document *ScanResultDocument, thresholds Thresholds) []string {
+	var breaches []string
+
+	if thresholds.FailUnderScore > 0 {
+		for _, entry := range document.Results {
+			if !isScoredResult(entry) {
+				continue
+			}
+			if entry.Score < thresholds.FailUnderScore {
+				breaches = append(breaches, fmt.Sprintf("%s score %d is below the required minimum of %d", entry.PolicyType, entry.Score, thresholds.FailUnderScore))
+			}
+		}
+	}
+
+	if thresholds.MaxUnprotected >= 0 {
+		unprotected := CountUnprotectedPods(document)
+		if unprotected > thresholds.MaxUnprotected {
+			breaches = append(breaches, fmt.Sprintf("found %d unprotected pods, the maximum allowed is %d", unprotected, thresholds.MaxUnprotected))
+		}
+	}
+
+	return breaches
+}
+
+// CountUnprotectedPods returns the number of distinct pods reported as unprotected by the scored results.
+func CountUnprotectedPods(document *ScanResultDocument) int {
+	unique := make(map[string]struct{})
+	for _, entry := range document.Results {
+		if !isScoredResult(entry) {
+			continue
+		}
+		for _, pod := range entry.UnprotectedPods {
+			unique[pod.Namespace+"/"+pod.Name] = struct{}{}
+		}
+	}
+	return len(unique)
+}
+
+// isScoredResult reports whether an entry holds a final verdict. Results recorded for a targeted
+// policy carry no score, and the cluster wide Cilium scan is a pre-scan whose pods are re-evaluated
+// by the namespaced Cilium scan unless it already protects every pod.
+func isScoredResult(entry ScanResultEntry) bool {
+	if len(entry.NamespacesScanned) == 0 {
+		return false
+	}
+	return entry.PolicyType != PolicyTypeCiliumClusterwide || entry.AllPodsProtected
+}
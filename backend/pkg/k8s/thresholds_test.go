@@ -0,0 +1,30 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	document := NewScanResultDocument(
+		&ScanResult{
+			PolicyType:        PolicyTypeCiliumClusterwide,
+			NamespacesScanned: []string{"cluster-wide"},
+			UnprotectedPods:   []string{"shop/web-0", "shop/db-0"},
+			Score:             50,
+		},
+		&ScanResult{
+			PolicyType:        PolicyTypeCilium,
+			NamespacesScanned: []string{"shop"},
+			UnprotectedPods:   []string{"shop web-0 10.0.0.12"},
+			Score:             49,
+		},
+	)
+
+	// The cluster wide pre-scan is ignored, so only the namespaced Cilium result counts
+	assert.Equal(t, 1, CountUnprotectedPods(document))
+	assert.Empty(t, CheckThresholds(document, Thresholds{FailUnderScore: 0, MaxUnprotected: -1}))
+	assert.Empty(t, CheckThresholds(document, Thresholds{FailUnderScore: 49, MaxUnprotected: 1}))
+	assert.Len(t, CheckThresholds(document, Thresholds{FailUnderScore: 80, MaxUnprotected: 0}), 2)
+}
@@ -7,6 +7,7 @@ import (
 	"gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 )
@@ -31,6 +32,12 @@ func gatherVisualizationData(clientset kubernetes.Interface, namespace string) (
 		return nil, err
 	}
 
+	// List the pods once and match every policy against them in memory
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
 	vizData := &VisualizationData{
 		Policies: make([]PolicyVisualization, 0), // Initialize as empty slice
 	}
@@ -44,17 +51,11 @@ func gatherVisualizationData(clientset kubernetes.Interface, namespace string) (
 			continue
 		}
 
-		pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-			LabelSelector: selector.String(),
-		})
-		if err != nil {
-			log.Printf("Error listing pods for policy %s: %v\n", policy.Name, err)
-			continue
-		}
-
-		podNames := make([]string, 0, len(pods.Items))
+		podNames := make([]string, 0)
 		for _, pod := range pods.Items {
-			podNames = append(podNames, pod.Name)
+			if pod.Namespace == policy.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				podNames = append(podNames, pod.Name)
+			}
 		}
 
 		vizData.Policies = append(vizData.Policies, PolicyVisualization{
@@ -75,17 +76,19 @@ func GatherNamespacesWithPolicies(clientset kubernetes.Interface) ([]string, err
 		return nil, err
 	}
 
-	var namespacesWithPolicies []string
+	// List the policies of every namespace at once
+	policies, err := clientset.NetworkingV1().NetworkPolicies("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	hasPolicies := make(map[string]bool)
+	for _, policy := range policies.Items {
+		hasPolicies[policy.Namespace] = true
+	}
 
-	// Check each namespace for network policies
+	var namespacesWithPolicies []string
 	for _, ns := range namespaces.Items {
-		policies, err := clientset.NetworkingV1().NetworkPolicies(ns.Name).List(context.TODO(), metav1.ListOptions{})
-		if err != nil {
-			log.Printf("Error listing policies in namespace %s: %v\n", ns.Name, err)
-			continue
-		}
-
-		if len(policies.Items) > 0 {
+		if hasPolicies[ns.Name] {
 			namespacesWithPolicies = append(namespacesWithPolicies, ns.Name)
 		}
 	}
@@ -0,0 +1,164 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WaiverAnnotation marks a pod, or every pod of a namespace, as intentionally unprotected. Its value explains why,
+// for example "public ingress controller; ticket SEC-123; expires 2027-01-01".
+const WaiverAnnotation = "netfetch.io/waiver"
+
+// Waiver is an accepted finding declared with the WaiverAnnotation on a pod or namespace.
+type Waiver struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Text      string
+	Expires   string
+
+	expires time.Time
+	invalid bool
+}
+
+// WaivedFinding is a finding covered by a waiver. Findings whose waiver has expired are still reported and count
+// against the score.
+type WaivedFinding struct {
+	Rule      string          `json:"rule" yaml:"rule"`
+	Namespace string          `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name      string          `json:"name" yaml:"name"`
+	WaivedBy  ObjectReference `json:"waivedBy" yaml:"waivedBy"`
+	Waiver    string          `json:"waiver" yaml:"waiver"`
+	Expires   string          `json:"expires,omitempty" yaml:"expires,omitempty"`
+	Expired   bool            `json:"expired" yaml:"expired"`
+}
+
+// ParseWaiver reads the value of a WaiverAnnotation. Its parts are separated by semicolons, and a part like
+// "expires 2027-01-01" sets the last day the waiver applies. A waiver without an expiry date never expires, and
+// one with an unreadable date is treated as expired.
+func ParseWaiver(kind, namespace, name, text string) Waiver {
+	waiver := Waiver{Kind: kind, Namespace: namespace, Name: name, Text: strings.TrimSpace(text)}
+	for _, part := range strings.Split(waiver.Text, ";") {
+		fields := strings.Fields(part)
+		if len(fields) < 2 || !strings.EqualFold(strings.TrimSuffix(fields[0], ":"), "expires") {
+			continue
+		}
+		waiver.Expires = strings.Join(fields[1:], " ")
+		expires, err := time.Parse(suppressionDateLayout, waiver.Expires)
+		if err != nil {
+			waiver.invalid = true
+			continue
+		}
+		waiver.expires = expires
+	}
+	return waiver
+}
+
+// Expired reports whether the waiver no longer applies. A waiver applies through the whole day it expires on.
+func (w Waiver) Expired(now time.Time) bool {
+	if w.invalid {
+		return true
+	}
+	return !w.expires.IsZero() && !now.UTC().Before(w.expires.AddDate(0, 0, 1))
+}
+
+// finding records the finding of the rule for the object as covered by the waiver.
+func (w Waiver) finding(rule, namespace, name string, now time.Time) WaivedFinding {
+	return WaivedFinding{
+		Rule:      rule,
+		Namespace: namespace,
+		Name:      name,
+		WaivedBy:  ObjectReference{Kind: w.Kind, Namespace: w.Namespace, Name: w.Name},
+		Waiver:    w.Text,
+		Expires:   w.Expires,
+		Expired:   w.Expired(now),
+	}
+}
+
+// waiverIndex holds the waivers of the pods and namespaces of a cluster.
+type waiverIndex struct {
+	namespaces map[string]Waiver
+	pods       map[string]Waiver
+}
+
+// loadWaivers reads the WaiverAnnotation of every namespace and pod.
+func loadWaivers(clientset kubernetes.Interface) (*waiverIndex, error) {
+	index := &waiverIndex{namespaces: map[string]Waiver{}, pods: map[string]Waiver{}}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+	for _, namespace := range namespaces.Items {
+		if text, found := namespace.Annotations[WaiverAnnotation]; found {
+			index.namespaces[namespace.Name] = ParseWaiver("Namespace", "", namespace.Name, text)
+		}
+	}
+
+	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if text, found := pod.Annotations[WaiverAnnotation]; found {
+			index.pods[pod.Namespace+"/"+pod.Name] = ParseWaiver("Pod", pod.Namespace, pod.Name, text)
+		}
+	}
+	return index, nil
+}
+
+// lookup returns the waiver of the pod, or of its namespace when the pod has none. An empty pod looks up the
+// waiver of the namespace only.
+func (w *waiverIndex) lookup(namespace, pod string) (Waiver, bool) {
+	if w == nil {
+		return Waiver{}, false
+	}
+	if pod != "" {
+		if waiver, found := w.pods[namespace+"/"+pod]; found {
+			return waiver, true
+		}
+	}
+	waiver, found := w.namespaces[namespace]
+	return waiver, found
+}
+
+// useWaivers loads the waivers of the cluster into the scan result, so the scanners can honor them. A scan
+// continues without waivers when they cannot be read.
+func useWaivers(clientset kubernetes.Interface, scanResult *ScanResult) error {
+	waivers, err := loadWaivers(clientset)
+	if err != nil {
+		return err
+	}
+	scanResult.waivers = waivers
+	return nil
+}
+
+// recordWaived adds the finding to the waived findings of the scan result unless it is already recorded.
+func recordWaived(scanResult *ScanResult, finding WaivedFinding) {
+	for _, recorded := range scanResult.Waived {
+		if recorded.Rule == finding.Rule && recorded.Namespace == finding.Namespace && recorded.Name == finding.Name {
+			return
+		}
+	}
+	scanResult.Waived = append(scanResult.Waived, finding)
+}
+
+// ExpiredWaivers returns the waived findings of the document whose waiver has expired, once per annotated object.
+func ExpiredWaivers(document *ScanResultDocument) []WaivedFinding {
+	var expired []WaivedFinding
+	seen := map[ObjectReference]bool{}
+	for _, entry := range document.Results {
+		for _, waived := range entry.Waived {
+			if waived.Expired && !seen[waived.WaivedBy] {
+				seen[waived.WaivedBy] = true
+				expired = append(expired, waived)
+			}
+		}
+	}
+	return expired
+}
@@ -0,0 +1,123 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseWaiver(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		text            string
+		expectedExpires string
+		expectedExpired bool
+	}{
+		{name: "Without expiry date", text: "public ingress controller; ticket SEC-123", expectedExpired: false},
+		{name: "Expires in the future", text: "public ingress controller; ticket SEC-123; expires 2027-01-01", expectedExpires: "2027-01-01", expectedExpired: false},
+		{name: "Expires with a colon", text: "legacy; Expires: 2027-01-01", expectedExpires: "2027-01-01", expectedExpired: false},
+		{name: "Expired", text: "legacy; expires 2025-12-31", expectedExpires: "2025-12-31", expectedExpired: true},
+		{name: "Expires today", text: "legacy; expires 2026-01-01", expectedExpires: "2026-01-01", expectedExpired: false},
+		{name: "Unreadable expiry date", text: "legacy; expires next year", expectedExpires: "next year", expectedExpired: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waiver := ParseWaiver("Pod", "shop", "web-0", test.text)
+			assert.Equal(t, test.text, waiver.Text)
+			assert.Equal(t, test.expectedExpires, waiver.Expires)
+			assert.Equal(t, test.expectedExpired, waiver.Expired(now))
+		})
+	}
+}
+
+func TestWaivedFindings(t *testing.T) {
+	namespace := func(name, waiver string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if waiver != "" {
+			ns.Annotations = map[string]string{WaiverAnnotation: waiver}
+		}
+		return ns
+	}
+	ingress := testPod("shop", "ingress-0", "10.0.0.1", map[string]string{"app": "ingress"})
+	ingress.Annotations = map[string]string{WaiverAnnotation: "public ingress controller; ticket SEC-123"}
+	legacy := testPod("shop", "legacy-0", "10.0.0.2", map[string]string{"app": "legacy"})
+	legacy.Annotations = map[string]string{WaiverAnnotation: "legacy; expires 2020-01-01"}
+	web := testPod("shop", "web-0", "10.0.0.3", map[string]string{"app": "web"})
+	tools := testPod("tools", "debug-0", "10.0.0.4", map[string]string{"app": "debug"})
+
+	clientset := fake.NewSimpleClientset(namespace("shop", ""), namespace("tools", "debugging tools; expires 2099-01-01"), &ingress, &legacy, &web, &tools)
+	result := &ScanResult{PolicyType: PolicyTypeKubernetes, NamespacesScanned: []string{"shop", "tools"}}
+	assert.NoError(t, useWaivers(clientset, result))
+
+	result.UnprotectedPods = acceptUnprotectedPods([]string{"shop ingress-0 10.0.0.1", "shop legacy-0 10.0.0.2", "shop web-0 10.0.0.3", "tools debug-0 10.0.0.4"}, result)
+	assert.Equal(t, []string{"shop legacy-0 10.0.0.2", "shop web-0 10.0.0.3"}, result.UnprotectedPods)
+
+	document := NewScanResultDocument(result)
+	entry := document.Results[0]
+	assert.Equal(t, []string{"shop"}, entry.NamespacesWithoutDenyAll)
+	assert.Equal(t, []WaivedFinding{
+		{Rule: RuleUnprotectedPod, Namespace: "shop", Name: "ingress-0", WaivedBy: ObjectReference{Kind: "Pod", Namespace: "shop", Name: "ingress-0"}, Waiver: "public ingress controller; ticket SEC-123"},
+		{Rule: RuleUnprotectedPod, Namespace: "shop", Name: "legacy-0", WaivedBy: ObjectReference{Kind: "Pod", Namespace: "shop", Name: "legacy-0"}, Waiver: "legacy; expires 2020-01-01", Expires: "2020-01-01", Expired: true},
+		{Rule: RuleUnprotectedPod, Namespace: "tools", Name: "debug-0", WaivedBy: ObjectReference{Kind: "Namespace", Name: "tools"}, Waiver: "debugging tools; expires 2099-01-01", Expires: "2099-01-01"},
+		{Rule: RuleNamespaceWithoutDenyAll, Namespace: "tools", Name: "tools", WaivedBy: ObjectReference{Kind: "Namespace", Name: "tools"}, Waiver: "debugging tools; expires 2099-01-01", Expires: "2099-01-01"},
+	}, entry.Waived)
+
+	expired := ExpiredWaivers(document)
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, "legacy-0", expired[0].Name)
+	}
+
+	var csvOutput bytes.Buffer
+	assert.NoError(t, WriteScanResultDocument(&csvOutput, document, OutputFormatCSV))
+	assert.Equal(t, "policy_type,namespace,pod,ip,score,status\n"+
+		"kubernetes,shop,legacy-0,10.0.0.2,0,waiver-expired\n"+
+		"kubernetes,shop,web-0,10.0.0.3,0,unprotected\n"+
+		"kubernetes,shop,ingress-0,,0,waived\n"+
+		"kubernetes,tools,debug-0,,0,waived\n", csvOutput.String())
+
+	var sarifOutput bytes.Buffer
+	assert.NoError(t, WriteSARIF(&sarifOutput, document))
+	var log sarifLog
+	assert.NoError(t, json.Unmarshal(sarifOutput.Bytes(), &log))
+	suppressed := map[string]string{}
+	for _, result := range log.Runs[0].Results {
+		if len(result.Suppressions) > 0 {
+			suppressed[result.RuleID+" "+result.Locations[0].LogicalLocations[0].FullyQualifiedName] = result.Suppressions[0].Justification
+		}
+		if result.Locations[0].LogicalLocations[0].FullyQualifiedName == "Pod/shop/legacy-0" {
+			assert.Equal(t, "2020-01-01", result.Properties["waiverExpired"])
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"NETFETCH001 Pod/shop/ingress-0": "public ingress controller; ticket SEC-123",
+		"NETFETCH001 Pod/tools/debug-0":  "debugging tools; expires 2099-01-01",
+		"NETFETCH002 Namespace/tools":    "debugging tools; expires 2099-01-01",
+	}, suppressed)
+}
+
+func TestWriteSARIFUnknownWaivedRule(t *testing.T) {
+	document := &ScanResultDocument{Results: []ScanResultEntry{{
+		PolicyType: PolicyTypeKubernetes,
+		Waived: []WaivedFinding{
+			{Rule: "NETFETCH999", Namespace: "shop", Name: "web-0", WaivedBy: ObjectReference{Kind: "Pod", Namespace: "shop", Name: "web-0"}, Waiver: "unknown rule"},
+			{Rule: RuleUnprotectedPod, Namespace: "shop", Name: "db-0", WaivedBy: ObjectReference{Kind: "Pod", Namespace: "shop", Name: "db-0"}, Waiver: "known rule"},
+		},
+	}}}
+
+	var sarifOutput bytes.Buffer
+	assert.NoError(t, WriteSARIF(&sarifOutput, document))
+	var log sarifLog
+	assert.NoError(t, json.Unmarshal(sarifOutput.Bytes(), &log))
+	if assert.Len(t, log.Runs[0].Results, 1) {
+		assert.Equal(t, RuleUnprotectedPod, log.Runs[0].Results[0].RuleID)
+	}
+}
@@ -0,0 +1,201 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// WebhookEnforcementLabel opts a namespace into admission checks. Its value is one of the enforcement modes.
+	WebhookEnforcementLabel = "netfetch.io/enforce"
+	// EnforcementDeny rejects workloads that no policy selects
+	EnforcementDeny = "deny"
+	// EnforcementWarn admits workloads that no policy selects with a warning
+	EnforcementWarn = "warn"
+)
+
+// AdmissionWebhook checks that new pods and deployments are selected by a NetworkPolicy or Cilium policy before
+// they are admitted to namespaces that opt in with the WebhookEnforcementLabel.
+type AdmissionWebhook struct {
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+}
+
+// NewAdmissionWebhook creates an admission webhook evaluating the policies read through the clients.
+func NewAdmissionWebhook(clientset kubernetes.Interface, dynamicClient dynamic.Interface) *AdmissionWebhook {
+	return &AdmissionWebhook{clientset: clientset, dynamicClient: dynamicClient}
+}
+
+// HandleAdmissionRequest answers AdmissionReview requests of the API server.
+func HandleAdmissionRequest(webhook *AdmissionWebhook) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			http.Error(w, "Invalid AdmissionReview", http.StatusBadRequest)
+			return
+		}
+
+		review.Response = webhook.Review(review.Request)
+		review.Response.UID = review.Request.UID
+		review.Request = nil
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			log.Printf("Error encoding admission response: %v\n", err)
+		}
+	}
+}
+
+// Review admits the pod or deployment of the request unless its namespace enforces policy coverage and no policy
+// selects it. Workloads with a valid waiver annotation, on themselves or their namespace, are admitted. Requests that
+// cannot be evaluated are admitted with a warning.
+func (a *AdmissionWebhook) Review(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{Allowed: true}
+
+	namespace, err := a.clientset.CoreV1().Namespaces().Get(context.TODO(), request.Namespace, metav1.GetOptions{})
+	if err != nil {
+		response.Warnings = []string{fmt.Sprintf("netfetch could not read namespace %s: %v", request.Namespace, err)}
+		return response
+	}
+	mode := namespace.Labels[WebhookEnforcementLabel]
+	if mode != EnforcementDeny && mode != EnforcementWarn {
+		return response
+	}
+
+	pod, description, err := admittedPod(request)
+	if err != nil {
+		response.Warnings = []string{fmt.Sprintf("netfetch could not evaluate %s: %v", request.Kind.Kind, err)}
+		return response
+	}
+	if pod == nil || activeConfig.ExcludesPod(*pod) {
+		return response
+	}
+
+	selected, err := a.podSelected(*pod, *namespace)
+	if err != nil {
+		response.Warnings = []string{fmt.Sprintf("netfetch could not evaluate %s: %v", description, err)}
+		return response
+	}
+	if selected {
+		return response
+	}
+
+	message := fmt.Sprintf("%s in namespace %s is not selected by any NetworkPolicy or Cilium policy", description, request.Namespace)
+	if waiver, found := admissionWaiver(*pod, *namespace); found {
+		if !waiver.Expired(time.Now()) {
+			return response
+		}
+		message += fmt.Sprintf(" and its %s annotation is no longer valid (expires %s)", WaiverAnnotation, waiver.Expires)
+	}
+	if mode == EnforcementWarn {
+		response.Warnings = []string{message}
+		return response
+	}
+	response.Allowed = false
+	response.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonForbidden,
+		Code:    http.StatusForbidden,
+		Message: message + fmt.Sprintf(" (enforced by the %s=%s label of the namespace)", WebhookEnforcementLabel, mode),
+	}
+	return response
+}
+
+// admittedPod returns the pod of a Pod request, or the pod template of a Deployment request, with a description of
+// the workload. Other kinds return no pod.
+func admittedPod(request *admissionv1.AdmissionRequest) (*corev1.Pod, string, error) {
+	switch request.Kind.Kind {
+	case "Pod":
+		var pod corev1.Pod
+		if err := json.Unmarshal(request.Object.Raw, &pod); err != nil {
+			return nil, "", err
+		}
+		pod.Namespace = request.Namespace
+		name := pod.Name
+		if name == "" {
+			name = pod.GenerateName
+		}
+		return &pod, fmt.Sprintf("Pod %s", name), nil
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := json.Unmarshal(request.Object.Raw, &deployment); err != nil {
+			return nil, "", err
+		}
+		pod := &corev1.Pod{ObjectMeta: deployment.Spec.Template.ObjectMeta, Spec: deployment.Spec.Template.Spec}
+		pod.Namespace = request.Namespace
+		return pod, fmt.Sprintf("Deployment %s", deployment.Name), nil
+	}
+	return nil, "", nil
+}
+
+// admissionWaiver returns the waiver annotation of the admitted pod, or of its namespace when the pod has none.
+func admissionWaiver(pod corev1.Pod, namespace corev1.Namespace) (Waiver, bool) {
+	if text, found := pod.Annotations[WaiverAnnotation]; found {
+		return ParseWaiver("Pod", namespace.Name, pod.Name, text), true
+	}
+	if text, found := namespace.Annotations[WaiverAnnotation]; found {
+		return ParseWaiver("Namespace", "", namespace.Name, text), true
+	}
+	return Waiver{}, false
+}
+
+// podSelected reports whether a NetworkPolicy or CiliumNetworkPolicy of the namespace, or a
+// CiliumClusterwideNetworkPolicy, selects the pod.
+func (a *AdmissionWebhook) podSelected(pod corev1.Pod, namespace corev1.Namespace) (bool, error) {
+	networkPolicies, err := a.clientset.NetworkingV1().NetworkPolicies(namespace.Name).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("error listing network policies: %w", err)
+	}
+	policies, errs := NativePolicyModels(networkPolicies.Items)
+	for _, err := range errs {
+		log.Printf("Error evaluating policy: %v\n", err)
+	}
+
+	ciliumLists := map[string]dynamic.ResourceInterface{
+		"CiliumNetworkPolicies":            a.dynamicClient.Resource(ciliumNetworkPolicyResource).Namespace(namespace.Name),
+		"CiliumClusterwideNetworkPolicies": a.dynamicClient.Resource(ciliumClusterwideNetworkPolicyResource),
+	}
+	for kind, client := range ciliumLists {
+		list, err := client.List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			// Cilium is not installed in this cluster
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return false, fmt.Errorf("error listing %s: %w", kind, err)
+		}
+		for i := range list.Items {
+			models, err := CiliumPolicyModels(&list.Items[i])
+			if err != nil {
+				log.Printf("Error evaluating policy: %v\n", err)
+				continue
+			}
+			policies = append(policies, models...)
+		}
+	}
+
+	namespaceLabels := NamespaceLabels([]corev1.Namespace{namespace})[namespace.Name]
+	for _, policy := range policies {
+		if policy.Selects(pod, namespaceLabels) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,157 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAdmissionWebhook(t *testing.T) {
+	namespace := func(name, mode string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if mode != "" {
+			ns.Labels = map[string]string{WebhookEnforcementLabel: mode}
+		}
+		return ns
+	}
+	dbPolicy := func(namespace string) *netv1.NetworkPolicy {
+		return &netv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: namespace},
+			Spec: netv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+				PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeIngress},
+			},
+		}
+	}
+	webPolicy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"ingress":          []interface{}{map[string]interface{}{}},
+		},
+	}}
+
+	clientset := fake.NewSimpleClientset(namespace("shop", EnforcementDeny), namespace("staging", EnforcementWarn), namespace("dev", ""), dbPolicy("shop"))
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), offlineListKinds, webPolicy)
+	webhook := NewAdmissionWebhook(clientset, dynamicClient)
+
+	pod := func(name string, labels map[string]string) runtime.Object {
+		return &corev1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}, ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	waivedPod := func(name, waiver string) runtime.Object {
+		object := pod(name, map[string]string{"app": "ingress"}).(*corev1.Pod)
+		object.Annotations = map[string]string{WaiverAnnotation: waiver}
+		return object
+	}
+	deployment := func(name string, labels map[string]string) runtime.Object {
+		return &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}}},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		namespace        string
+		object           runtime.Object
+		expectedAllowed  bool
+		expectedWarnings []string
+		expectedMessage  string
+	}{
+		{
+			name:            "pod selected by a network policy",
+			namespace:       "shop",
+			object:          pod("db-0", map[string]string{"app": "db"}),
+			expectedAllowed: true,
+		},
+		{
+			name:            "deployment selected by a cilium policy",
+			namespace:       "shop",
+			object:          deployment("web", map[string]string{"app": "web"}),
+			expectedAllowed: true,
+		},
+		{
+			name:            "unprotected pod is rejected",
+			namespace:       "shop",
+			object:          pod("cache-0", map[string]string{"app": "cache"}),
+			expectedAllowed: false,
+			expectedMessage: "Pod cache-0 in namespace shop is not selected by any NetworkPolicy or Cilium policy (enforced by the netfetch.io/enforce=deny label of the namespace)",
+		},
+		{
+			name:             "unprotected deployment is admitted with a warning",
+			namespace:        "staging",
+			object:           deployment("cache", map[string]string{"app": "cache"}),
+			expectedAllowed:  true,
+			expectedWarnings: []string{"Deployment cache in namespace staging is not selected by any NetworkPolicy or Cilium policy"},
+		},
+		{
+			name:            "unprotected pod with a waiver is admitted",
+			namespace:       "shop",
+			object:          waivedPod("ingress-0", "public ingress controller; ticket SEC-123; expires 2099-01-01"),
+			expectedAllowed: true,
+		},
+		{
+			name:            "unprotected pod with an expired waiver is rejected",
+			namespace:       "shop",
+			object:          waivedPod("ingress-0", "public ingress controller; expires 2020-01-01"),
+			expectedAllowed: false,
+			expectedMessage: "Pod ingress-0 in namespace shop is not selected by any NetworkPolicy or Cilium policy and its netfetch.io/waiver annotation is no longer valid (expires 2020-01-01) (enforced by the netfetch.io/enforce=deny label of the namespace)",
+		},
+		{
+			name:            "namespace without the label is not checked",
+			namespace:       "dev",
+			object:          pod("cache-0", map[string]string{"app": "cache"}),
+			expectedAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.object)
+			assert.NoError(t, err)
+			kind := tt.object.GetObjectKind().GroupVersionKind()
+			review := admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       types.UID("request-uid"),
+					Kind:      metav1.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind},
+					Namespace: tt.namespace,
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			}
+			body, err := json.Marshal(review)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			HandleAdmissionRequest(webhook)(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+
+			var response admissionv1.AdmissionReview
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, types.UID("request-uid"), response.Response.UID)
+			assert.Equal(t, tt.expectedAllowed, response.Response.Allowed)
+			assert.Equal(t, tt.expectedWarnings, response.Response.Warnings)
+			if tt.expectedMessage != "" {
+				assert.Equal(t, tt.expectedMessage, response.Response.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package statik
+
+// The dashboard is embedded from the production build of frontend/dash. Regenerate statik.go after changing the
+// frontend by running go generate ./statik from the backend directory; releases run it before building.
+//go:generate sh -c "cd ../../frontend/dash && npm ci && npm run build"
+//go:generate go run github.com/rakyll/statik -src=../../frontend/dash/dist -dest=.. -f
@@ -50,6 +50,22 @@ app.kubernetes.io/name: {{ include "netfetch.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
 {{- end }}
 
+{{/*
+Selector labels of the operator, distinct from the dashboard so its Service does not select the operator
+*/}}
+{{- define "netfetch.operatorSelectorLabels" -}}
+app.kubernetes.io/name: {{ include "netfetch.name" . }}-operator
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end }}
+
+{{/*
+Selector labels of the admission webhook
+*/}}
+{{- define "netfetch.webhookSelectorLabels" -}}
+app.kubernetes.io/name: {{ include "netfetch.name" . }}-webhook
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end }}
+
 {{/*
 Create the name of the service account to use
 */}}